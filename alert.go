@@ -0,0 +1,98 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import "time"
+
+// Gate applies hysteresis and cool-down to a raw alert condition.
+// A raised alert stays raised until the condition clears for alertHold
+// and a cleared alert can't be raised again before alertCooldown elapses.
+type Gate struct {
+	// raised means the alert is raised
+	raised bool
+	// clearSince records time when the condition of raised alert cleared
+	clearSince time.Time
+	// clearedAt records time when the alert was last cleared
+	clearedAt time.Time
+}
+
+// Update updates the gate with raw alert condition at time ts and returns true if the alert is raised
+func (g *Gate) Update(ts time.Time, raw bool) bool {
+	if raw {
+		g.clearSince = time.Time{}
+		if !g.raised && (g.clearedAt.IsZero() || ts.Sub(g.clearedAt) >= alertCooldown) {
+			g.raised = true
+		}
+		return g.raised
+	}
+
+	if g.raised {
+		if g.clearSince.IsZero() {
+			g.clearSince = ts
+		}
+		if ts.Sub(g.clearSince) >= alertHold {
+			g.raised, g.clearedAt = false, ts
+		}
+	}
+
+	return g.raised
+}
+
+// alertGates stores gates of all the alerts of an operator
+type alertGates struct {
+	watching   Gate
+	angry      Gate
+	distance   Gate
+	drowsy     Gate
+	ppe        Gate
+	phone      Gate
+	absent     Gate
+	unattended Gate
+	crowd      Gate
+	risk       Gate
+	emotion    Gate
+	rules      []Gate
+}
+
+// apply passes raw alerts at time ts through the gates and raises the resulting alerts in result
+func (g *alertGates) apply(ts time.Time, raw, result *Result) {
+	result.AlertWatching = g.watching.Update(ts, raw.AlertWatching)
+	result.AlertAngry = g.angry.Update(ts, raw.AlertAngry)
+	result.AlertDistance = g.distance.Update(ts, raw.AlertDistance)
+	result.AlertDrowsy = g.drowsy.Update(ts, raw.AlertDrowsy)
+	result.AlertPPE = g.ppe.Update(ts, raw.AlertPPE)
+	result.AlertPhone = g.phone.Update(ts, raw.AlertPhone)
+	result.AlertAbsent = g.absent.Update(ts, raw.AlertAbsent)
+	result.AlertUnattended = g.unattended.Update(ts, raw.AlertUnattended)
+	result.AlertCrowd = g.crowd.Update(ts, raw.AlertCrowd)
+	result.AlertRisk = g.risk.Update(ts, raw.AlertRisk)
+	result.AlertEmotion = g.emotion.Update(ts, raw.AlertEmotion)
+	if len(g.rules) != len(alertRules) {
+		g.rules = make([]Gate, len(alertRules))
+	}
+	rules := result.ruleAlerts()
+	for i, raised := range raw.ruleAlerts() {
+		rules[i] = g.rules[i].Update(ts, raised)
+	}
+}
@@ -0,0 +1,264 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/intel-iot-devkit/machine-operator-monitor-go/payload"
+	"gocv.io/x/gocv"
+)
+
+const (
+	// apiSnapshotTimeout is time to wait for the display loop to encode frame snapshot
+	apiSnapshotTimeout = time.Second
+	// apiShutdownTimeout is time to wait for in-flight requests when the server stops
+	apiShutdownTimeout = 5 * time.Second
+)
+
+// APIServer serves the latest operator status, active alerts, inference performance,
+// configuration, frame snapshots and the dashboard with MJPEG video stream over HTTP
+type APIServer struct {
+	// mu protects the latest state below
+	mu sync.Mutex
+	// status is the latest operator status; nil until the first result arrives
+	status *payload.Status
+	// perf is the latest inference performance; nil until reported
+	perf *payload.Perf
+	// alerts are active alerts mapped by their IDs
+	alerts map[string]payload.Alert
+	// live are channels of WebSocket clients of the live feed
+	live map[chan []byte]struct{}
+	// snapshots passes snapshot requests to the display loop
+	snapshots chan chan []byte
+	// streams are channels of MJPEG stream clients
+	streams map[chan []byte]struct{}
+	// streamInterval is minimum interval between frames of the MJPEG stream
+	streamInterval time.Duration
+	// streamed is time when the last frame was streamed; it is only accessed by the display loop
+	streamed time.Time
+	// server serves the requests
+	server *http.Server
+}
+
+// NewAPIServer creates new API server listening on addr which streams video at most at streamFPS and returns it
+func NewAPIServer(addr string, streamFPS int) *APIServer {
+	s := &APIServer{
+		alerts:         make(map[string]payload.Alert),
+		live:           make(map[chan []byte]struct{}),
+		snapshots:      make(chan chan []byte),
+		streams:        make(map[chan []byte]struct{}),
+		streamInterval: time.Second / time.Duration(streamFPS),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/alerts", s.handleAlerts)
+	mux.HandleFunc("/perf", s.handlePerf)
+	mux.HandleFunc("/config", s.handleConfig)
+	mux.HandleFunc("/snapshot", s.handleSnapshot)
+	mux.HandleFunc("/live", s.handleLive)
+	mux.HandleFunc("/stream", s.handleStream)
+	mux.HandleFunc("/", s.handleDashboard)
+	s.server = &http.Server{Addr: addr, Handler: mux}
+
+	return s
+}
+
+// writeJSON writes v to w as JSON; nil v is reported as not found
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// handleStatus responds with the latest operator status
+func (s *APIServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	status := s.status
+	s.mu.Unlock()
+
+	if status == nil {
+		http.Error(w, "no status yet", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, r, status)
+}
+
+// handleAlerts responds with the active alerts ordered by the time they were raised
+func (s *APIServer) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	alerts := make([]payload.Alert, 0, len(s.alerts))
+	for _, a := range s.alerts {
+		alerts = append(alerts, a)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Raised.Before(alerts[j].Raised)
+	})
+	writeJSON(w, r, alerts)
+}
+
+// handlePerf responds with the latest inference performance
+func (s *APIServer) handlePerf(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	perf := s.perf
+	s.mu.Unlock()
+
+	if perf == nil {
+		http.Error(w, "no performance info yet", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, r, perf)
+}
+
+// handleConfig responds with the command line parameters mapped to their values
+func (s *APIServer) handleConfig(w http.ResponseWriter, r *http.Request) {
+	config := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		config[f.Name] = f.Value.String()
+	})
+	writeJSON(w, r, config)
+}
+
+// handleSnapshot responds with JPEG snapshot of the latest displayed frame
+func (s *APIServer) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	reply := make(chan []byte, 1)
+	select {
+	case s.snapshots <- reply:
+	case <-time.After(apiSnapshotTimeout):
+		http.Error(w, "snapshot timed out", http.StatusServiceUnavailable)
+		return
+	}
+
+	img := <-reply
+	if img == nil {
+		http.Error(w, "failed to encode snapshot", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Write(img)
+}
+
+// Snapshot serves pending snapshot request with JPEG encoded img; it returns immediately if there is none
+func (s *APIServer) Snapshot(img gocv.Mat) {
+	select {
+	case reply := <-s.snapshots:
+		buf, err := gocv.IMEncode(gocv.JPEGFileExt, img)
+		if err != nil {
+			fmt.Printf("Error encoding snapshot: %v\n", err)
+			reply <- nil
+			return
+		}
+		defer buf.Close()
+		// the encoded bytes are owned by the native buffer, so the handler gets a copy
+		reply <- append([]byte(nil), buf.GetBytes()...)
+	default:
+	}
+}
+
+// update records the latest operator status and inference performance of result
+func (s *APIServer) update(result *Result) {
+	status := result.ToPayload()
+	var perf *payload.Perf
+	if result.hasPerf() {
+		p := result.ToPerfPayload()
+		perf = &p
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status = &status
+	if perf != nil {
+		s.perf = perf
+	}
+	s.broadcast(&status)
+}
+
+// updateAlert records alert state transition; cleared alerts are no longer active
+func (s *APIServer) updateAlert(a Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a.State == alertCleared {
+		delete(s.alerts, a.ID)
+		return
+	}
+	s.alerts[a.ID] = a.ToPayload()
+}
+
+// apiRunner serves API requests with operator status received from statusChan and alert state transitions
+// received from alertsChan. It returns error if the server fails to listen.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func apiRunner(doneChan <-chan struct{}, statusChan <-chan *Result, alertsChan <-chan Alert, s *APIServer) error {
+	serveChan := make(chan error, 1)
+	go func() {
+		serveChan <- s.server.ListenAndServe()
+	}()
+
+	for {
+		select {
+		case result, ok := <-statusChan:
+			if !ok {
+				// frameRunner stopped; keep serving the latest state until we are told to stop
+				statusChan = nil
+				continue
+			}
+			s.update(result)
+		case a := <-alertsChan:
+			s.updateAlert(a)
+		case err := <-serveChan:
+			return fmt.Errorf("API server: %v", err)
+		case <-doneChan:
+			fmt.Printf("Stopping apiRunner: received stop signal\n")
+			ctx, cancel := context.WithTimeout(context.Background(), apiShutdownTimeout)
+			defer cancel()
+			return s.server.Shutdown(ctx)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// awsIoTPort is port of AWS IoT Core MQTT endpoints authenticating clients by X.509 certificates
+	awsIoTPort = "8883"
+	// awsIoTQoS is Quality Of Service of the published messages; AWS IoT Core doesn't support QoS 2
+	awsIoTQoS = 1
+)
+
+// AWSIoT publishes messages to AWS IoT Core and reports operator status in the thing shadow
+type AWSIoT struct {
+	// client is MQTT client connected to AWS IoT Core
+	client MQTT.Client
+	// thing is thing name
+	thing string
+	// prefix replaces the MQTT topic prefix in the published topics; empty if the topics are kept
+	prefix string
+	// shadow means operator status is reported in the thing shadow
+	shadow bool
+}
+
+// NewAWSIoT connects to AWS IoT Core and returns new AWS IoT publisher; shadow enables thing shadow updates.
+// It reads the following environment variables to configure the connection:
+// AWS_IOT_ENDPOINT: account specific AWS IoT Core endpoint, e.g. abc123-ats.iot.eu-west-1.amazonaws.com; required parameter
+// AWS_IOT_THING: thing name used as MQTT client ID; defaults to machine ID
+// AWS_IOT_CERT: thing X.509 certificate; required parameter
+// AWS_IOT_CERT_KEY: thing certificate private key; required parameter
+// AWS_IOT_CA_ROOT: Amazon root CA certificate; not required, the system CA certificates are used by default
+// AWS_IOT_TOPIC_PREFIX: prefix which replaces the MQTT topic prefix; not required
+// It returns error if any of the required parameters is missing or if it fails to connect.
+func NewAWSIoT(shadow bool) (*AWSIoT, error) {
+	endpoint := os.Getenv("AWS_IOT_ENDPOINT")
+	thing := os.Getenv("AWS_IOT_THING")
+	tlsCert := os.Getenv("AWS_IOT_CERT")
+	tlsKey := os.Getenv("AWS_IOT_CERT_KEY")
+	tlsCA := os.Getenv("AWS_IOT_CA_ROOT")
+	prefix := strings.TrimSuffix(os.Getenv("AWS_IOT_TOPIC_PREFIX"), "/")
+
+	if endpoint == "" {
+		return nil, fmt.Errorf("AWS IoT endpoint is empty")
+	}
+	if thing == "" {
+		thing = machineID
+	}
+	if thing == "" {
+		return nil, fmt.Errorf("AWS IoT thing name is empty")
+	}
+	if tlsCert == "" || tlsKey == "" {
+		return nil, fmt.Errorf("AWS IoT requires thing certificate and key")
+	}
+	if !strings.Contains(endpoint, ":") {
+		endpoint += ":" + awsIoTPort
+	}
+
+	tlsConfig, err := MQTTNewTLSConfig(tlsCA, tlsCert, tlsKey, false)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid TLS configuration: %s", err)
+	}
+
+	opts := MQTT.NewClientOptions()
+	opts.AddBroker("ssl://" + endpoint)
+	opts.SetClientID(thing)
+	opts.SetKeepAlive(20 * time.Second)
+	opts.CleanSession = true
+	opts.SetTLSConfig(tlsConfig)
+	opts.SetAutoReconnect(true)
+	opts.SetMaxReconnectInterval(reconnectMaxInterval)
+	opts.SetConnectionLostHandler(func(client MQTT.Client, err error) {
+		fmt.Printf("AWS IoT connection lost: %v; reconnecting\n", err)
+	})
+
+	client := MQTT.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, token.Error()
+	}
+
+	return &AWSIoT{
+		client: client,
+		thing:  thing,
+		prefix: prefix,
+		shadow: shadow,
+	}, nil
+}
+
+// topic maps MQTT topic to AWS IoT topic
+func (a *AWSIoT) topic(topic string) string {
+	if a.prefix == "" {
+		return topic
+	}
+
+	return a.prefix + strings.TrimPrefix(topic, strings.TrimSuffix(topicPrefix, "/"))
+}
+
+// publish publishes message to topic and waits for the publishing to finish
+func (a *AWSIoT) publish(topic, message string) error {
+	token := a.client.Publish(topic, awsIoTQoS, false, message)
+	if ok := token.WaitTimeout(TIMEOUT); ok && token.Error() != nil {
+		return token.Error()
+	}
+
+	return nil
+}
+
+// Send publishes message to AWS IoT topic corresponding to MQTT topic.
+// Operator status is also reported in the thing shadow if shadow updates are enabled.
+func (a *AWSIoT) Send(topic, message string) error {
+	if err := a.publish(a.topic(topic), message); err != nil {
+		return err
+	}
+
+	if a.shadow && topic == resultsTopic {
+		update := `{"state":{"reported":` + message + `}}`
+		return a.publish("$aws/things/"+a.thing+"/shadow/update", update)
+	}
+
+	return nil
+}
+
+// Close disconnects from AWS IoT Core
+func (a *AWSIoT) Close() error {
+	a.client.Disconnect(100)
+	return nil
+}
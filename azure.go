@@ -0,0 +1,398 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// azureAPIVersion is IoT Hub MQTT API version
+	azureAPIVersion = "2021-04-12"
+	// azureDPSAPIVersion is Device Provisioning Service MQTT API version
+	azureDPSAPIVersion = "2019-03-31"
+	// azureDPSHost is global Device Provisioning Service endpoint
+	azureDPSHost = "global.azure-devices-provisioning.net"
+	// azurePort is port of IoT Hub and Device Provisioning Service MQTT endpoints
+	azurePort = "8883"
+	// azureTokenTTL is validity of SAS tokens; a new token is generated whenever the client reconnects
+	azureTokenTTL = 24 * time.Hour
+	// azureDPSTimeout is time to wait for the device to be provisioned
+	azureDPSTimeout = time.Minute
+	// azureQoS is Quality Of Service of the published messages; IoT Hub doesn't support QoS 2
+	azureQoS = 1
+)
+
+// azureSASToken returns shared access signature token for resource signed with base64 encoded key which expires at expiry.
+// Policy name is added to the token unless it is empty.
+func azureSASToken(resource, key, policy string, expiry time.Time) (string, error) {
+	k, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return "", fmt.Errorf("Invalid shared access key: %v", err)
+	}
+
+	sr := url.QueryEscape(resource)
+	se := strconv.FormatInt(expiry.Unix(), 10)
+	mac := hmac.New(sha256.New, k)
+	mac.Write([]byte(sr + "\n" + se))
+	sig := url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+	token := fmt.Sprintf("SharedAccessSignature sr=%s&sig=%s&se=%s", sr, sig, se)
+	if policy != "" {
+		token += "&skn=" + policy
+	}
+
+	return token, nil
+}
+
+// parseConnectionString parses IoT Hub device connection string in HostName=...;DeviceId=...;SharedAccessKey=... format
+// and returns the hub host name, the device ID and the key
+func parseConnectionString(s string) (hub, device, key string, err error) {
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return "", "", "", fmt.Errorf("Invalid connection string part: %s", part)
+		}
+		switch kv[0] {
+		case "HostName":
+			hub = kv[1]
+		case "DeviceId":
+			device = kv[1]
+		case "SharedAccessKey":
+			key = kv[1]
+		}
+	}
+	if hub == "" || device == "" || key == "" {
+		return "", "", "", fmt.Errorf("Connection string requires HostName, DeviceId and SharedAccessKey")
+	}
+
+	return hub, device, key, nil
+}
+
+// dpsResponse is Device Provisioning Service registration response
+type dpsResponse struct {
+	OperationID       string `json:"operationId"`
+	Status            string `json:"status"`
+	RegistrationState struct {
+		AssignedHub  string `json:"assignedHub"`
+		DeviceID     string `json:"deviceId"`
+		ErrorMessage string `json:"errorMessage"`
+	} `json:"registrationState"`
+}
+
+// azureProvision registers device with registration ID regID in Device Provisioning Service with ID scope scope
+// using symmetric key and returns IoT Hub and device ID the device was assigned to
+func azureProvision(scope, regID, key string, tlsConfig *tls.Config) (hub, device string, err error) {
+	resource := scope + "/registrations/" + regID
+	password, err := azureSASToken(resource, key, "registration", time.Now().Add(azureTokenTTL))
+	if err != nil {
+		return "", "", err
+	}
+
+	opts := MQTT.NewClientOptions()
+	opts.AddBroker("ssl://" + azureDPSHost + ":" + azurePort)
+	opts.SetClientID(regID)
+	opts.SetUsername(resource + "/api-version=" + azureDPSAPIVersion)
+	opts.SetPassword(password)
+	opts.SetTLSConfig(tlsConfig)
+	client := MQTT.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return "", "", token.Error()
+	}
+	defer client.Disconnect(100)
+
+	responses := make(chan MQTT.Message, 1)
+	token := client.Subscribe("$dps/registrations/res/#", azureQoS, func(c MQTT.Client, msg MQTT.Message) {
+		responses <- msg
+	})
+	if token.Wait() && token.Error() != nil {
+		return "", "", token.Error()
+	}
+
+	body, _ := json.Marshal(map[string]string{"registrationId": regID})
+	topic := "$dps/registrations/PUT/iotdps-register/?$rid=1"
+	deadline := time.After(azureDPSTimeout)
+	for rid := 2; ; rid++ {
+		if token := client.Publish(topic, azureQoS, false, body); token.Wait() && token.Error() != nil {
+			return "", "", token.Error()
+		}
+
+		var msg MQTT.Message
+		select {
+		case msg = <-responses:
+		case <-deadline:
+			return "", "", fmt.Errorf("Device provisioning timed out")
+		}
+
+		// response topic is $dps/registrations/res/<status>/?$rid=<rid>[&retry-after=<seconds>]
+		parts := strings.SplitN(msg.Topic(), "/", 5)
+		if len(parts) < 5 {
+			return "", "", fmt.Errorf("Invalid provisioning response topic: %s", msg.Topic())
+		}
+		var resp dpsResponse
+		if err := json.Unmarshal(msg.Payload(), &resp); err != nil {
+			return "", "", fmt.Errorf("Invalid provisioning response: %v", err)
+		}
+
+		switch parts[3] {
+		case "200":
+			if resp.Status != "assigned" {
+				return "", "", fmt.Errorf("Device provisioning %s: %s", resp.Status, resp.RegistrationState.ErrorMessage)
+			}
+			return resp.RegistrationState.AssignedHub, resp.RegistrationState.DeviceID, nil
+		case "202":
+			// the registration is in progress; poll its status after the requested delay
+			retry := 3 * time.Second
+			if q, err := url.ParseQuery(strings.TrimPrefix(parts[4], "?")); err == nil {
+				if s, err := strconv.Atoi(q.Get("retry-after")); err == nil {
+					retry = time.Duration(s) * time.Second
+				}
+			}
+			time.Sleep(retry)
+			topic = fmt.Sprintf("$dps/registrations/GET/iotdps-get-operationstatus/?$rid=%d&operationId=%s", rid, resp.OperationID)
+			body = nil
+		default:
+			return "", "", fmt.Errorf("Device provisioning failed with status %s: %s", parts[3], msg.Payload())
+		}
+	}
+}
+
+// azureDesired are device twin desired properties the monitor is configured by
+type azureDesired struct {
+	// OperatorThresholds overrides operator thresholds in the same format as -operator-thresholds config
+	OperatorThresholds json.RawMessage `json:"operator_thresholds"`
+	// Paused pauses or resumes monitoring
+	Paused *bool `json:"paused"`
+	// Version is version of the desired properties
+	Version int `json:"$version"`
+}
+
+// AzureIoT is IoT Hub device client which publishes messages as device-to-cloud messages
+// and applies device twin desired properties
+type AzureIoT struct {
+	// client is MQTT client connected to IoT Hub
+	client MQTT.Client
+	// device is device ID
+	device string
+	// rid is ID of the latest device twin request
+	rid uint64
+	// cmdChan receives commands requested by desired properties
+	cmdChan chan<- *command
+}
+
+// NewAzureIoT connects to IoT Hub and returns new IoT Hub device client.
+// Commands requested by device twin desired properties are sent down the cmdChan.
+// It reads the following environment variables to configure the connection:
+// AZURE_IOT_CONNECTION_STRING: device connection string; required unless the device is provisioned by DPS
+// AZURE_DPS_ID_SCOPE: Device Provisioning Service ID scope; the device is provisioned by DPS if set
+// AZURE_DPS_REGISTRATION_ID: DPS registration ID; defaults to machine ID
+// AZURE_DPS_KEY: DPS enrollment symmetric key, or the derived device key for group enrollments
+// AZURE_IOT_CA_ROOT: root CA certificate; not required, the system CA certificates are used by default
+// It returns error if the connection is not configured, if the provisioning fails or if it fails to connect.
+func NewAzureIoT(cmdChan chan<- *command) (*AzureIoT, error) {
+	connStr := os.Getenv("AZURE_IOT_CONNECTION_STRING")
+	scope := os.Getenv("AZURE_DPS_ID_SCOPE")
+	regID := os.Getenv("AZURE_DPS_REGISTRATION_ID")
+	dpsKey := os.Getenv("AZURE_DPS_KEY")
+	tlsCA := os.Getenv("AZURE_IOT_CA_ROOT")
+
+	tlsConfig, err := MQTTNewTLSConfig(tlsCA, "", "", false)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid TLS configuration: %s", err)
+	}
+
+	var hub, device, key string
+	switch {
+	case scope != "":
+		if regID == "" {
+			regID = machineID
+		}
+		if regID == "" || dpsKey == "" {
+			return nil, fmt.Errorf("DPS provisioning requires registration ID and key")
+		}
+		if hub, device, err = azureProvision(scope, regID, dpsKey, tlsConfig); err != nil {
+			return nil, err
+		}
+		key = dpsKey
+		fmt.Printf("Device %s provisioned to %s\n", device, hub)
+	case connStr != "":
+		if hub, device, key, err = parseConnectionString(connStr); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("IoT Hub connection string or DPS ID scope is required")
+	}
+
+	// the key is checked up front as the credentials provider can't report errors
+	if _, err := azureSASToken(hub, key, "", time.Now()); err != nil {
+		return nil, err
+	}
+
+	a := &AzureIoT{device: device, cmdChan: cmdChan}
+
+	opts := MQTT.NewClientOptions()
+	opts.AddBroker("ssl://" + hub + ":" + azurePort)
+	opts.SetClientID(device)
+	opts.SetKeepAlive(20 * time.Second)
+	opts.CleanSession = true
+	opts.SetTLSConfig(tlsConfig)
+	// SAS tokens expire, so a fresh one is generated for every connection
+	opts.SetCredentialsProvider(func() (string, string) {
+		token, _ := azureSASToken(hub+"/devices/"+device, key, "", time.Now().Add(azureTokenTTL))
+		return hub + "/" + device + "/?api-version=" + azureAPIVersion, token
+	})
+	opts.SetAutoReconnect(true)
+	opts.SetMaxReconnectInterval(reconnectMaxInterval)
+	opts.SetOnConnectHandler(a.onConnect)
+	opts.SetConnectionLostHandler(func(client MQTT.Client, err error) {
+		fmt.Printf("IoT Hub connection lost: %v; reconnecting\n", err)
+	})
+
+	a.client = MQTT.NewClient(opts)
+	if token := a.client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, token.Error()
+	}
+
+	return a, nil
+}
+
+// onConnect subscribes to device twin responses and desired property updates and requests the device twin
+// once the client (re)connects; the desired properties may have changed while the client was offline
+func (a *AzureIoT) onConnect(client MQTT.Client) {
+	for topic, handler := range map[string]MQTT.MessageHandler{
+		"$iothub/twin/res/#":                      a.handleTwin,
+		"$iothub/twin/PATCH/properties/desired/#": a.handleDesired,
+	} {
+		if token := client.Subscribe(topic, azureQoS, handler); token.WaitTimeout(TIMEOUT) && token.Error() != nil {
+			fmt.Printf("Error subscribing to %s: %v\n", topic, token.Error())
+		}
+	}
+
+	rid := atomic.AddUint64(&a.rid, 1)
+	client.Publish(fmt.Sprintf("$iothub/twin/GET/?$rid=%d", rid), azureQoS, false, "")
+}
+
+// handleTwin applies desired properties of the requested device twin; responses to reported property updates are ignored
+func (a *AzureIoT) handleTwin(c MQTT.Client, msg MQTT.Message) {
+	// response topic is $iothub/twin/res/<status>/?$rid=<rid>
+	if !strings.HasPrefix(msg.Topic(), "$iothub/twin/res/200/") {
+		if !strings.HasPrefix(msg.Topic(), "$iothub/twin/res/204/") {
+			fmt.Printf("Device twin request failed: %s\n", msg.Topic())
+		}
+		return
+	}
+
+	var twin struct {
+		Desired azureDesired `json:"desired"`
+	}
+	if err := json.Unmarshal(msg.Payload(), &twin); err != nil {
+		fmt.Printf("Ignoring invalid device twin: %v\n", err)
+		return
+	}
+	a.apply(twin.Desired)
+}
+
+// handleDesired applies desired property updates
+func (a *AzureIoT) handleDesired(c MQTT.Client, msg MQTT.Message) {
+	var desired azureDesired
+	if err := json.Unmarshal(msg.Payload(), &desired); err != nil {
+		fmt.Printf("Ignoring invalid desired properties: %v\n", err)
+		return
+	}
+	a.apply(desired)
+}
+
+// apply applies desired properties and reports the applied version and the configuration error if any
+func (a *AzureIoT) apply(desired azureDesired) {
+	reported := map[string]interface{}{
+		"machine_id":      machineID,
+		"camera_id":       cameraID,
+		"desired_version": desired.Version,
+		"config_error":    nil,
+	}
+
+	if len(desired.OperatorThresholds) > 0 {
+		if string(desired.OperatorThresholds) == "null" {
+			// removed overrides restore the default thresholds
+			setThresholds(nil)
+		} else if t, err := parseThresholds(desired.OperatorThresholds); err != nil {
+			reported["config_error"] = fmt.Sprintf("operator_thresholds: %v", err)
+		} else {
+			setThresholds(t)
+		}
+	}
+
+	if desired.Paused != nil {
+		cmd := &command{name: cmdResume}
+		if *desired.Paused {
+			cmd.name = cmdPause
+		}
+		select {
+		case a.cmdChan <- cmd:
+		default:
+			reported["config_error"] = "paused: another command is in progress"
+		}
+	}
+
+	body, err := json.Marshal(reported)
+	if err != nil {
+		return
+	}
+	rid := atomic.AddUint64(&a.rid, 1)
+	topic := fmt.Sprintf("$iothub/twin/PATCH/properties/reported/?$rid=%d", rid)
+	if token := a.client.Publish(topic, azureQoS, false, body); token.WaitTimeout(TIMEOUT) && token.Error() != nil {
+		fmt.Printf("Error reporting device twin properties: %v\n", token.Error())
+	}
+}
+
+// Send publishes message as device-to-cloud message with the MQTT topic in its topic application property,
+// so IoT Hub message routes can tell the messages apart
+func (a *AzureIoT) Send(topic, message string) error {
+	t := fmt.Sprintf("devices/%s/messages/events/$.ct=application%%2Fjson&$.ce=utf-8&topic=%s", a.device, url.QueryEscape(topic))
+	token := a.client.Publish(t, azureQoS, false, message)
+	if ok := token.WaitTimeout(TIMEOUT); ok && token.Error() != nil {
+		return token.Error()
+	}
+
+	return nil
+}
+
+// Close disconnects from IoT Hub
+func (a *AzureIoT) Close() error {
+	a.client.Disconnect(100)
+	return nil
+}
@@ -0,0 +1,156 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// bannerText displays alerts as text only
+	bannerText = "text"
+	// bannerBorder flashes border around the frame in the alert color
+	bannerBorder = "border"
+	// bannerFull flashes semi-transparent banner with the alert text across the frame
+	bannerFull = "banner"
+)
+
+// alertBanners maps alert severities to the way their alerts are displayed; severities not in the map are text only
+var alertBanners = map[string]string{}
+
+// parseAlertBanners parses comma separated alert display modes in severity=mode format, e.g.
+// warning=border,critical=banner and returns them by severities
+func parseAlertBanners(s string) (map[string]string, error) {
+	banners := make(map[string]string)
+	for _, b := range parseLabels(s) {
+		kv := strings.SplitN(b, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid alert banner: %s", b)
+		}
+
+		severity := strings.ToLower(strings.TrimSpace(kv[0]))
+		if severityRank(severity) < 0 {
+			return nil, fmt.Errorf("Invalid severity: %s", kv[0])
+		}
+
+		switch mode := strings.TrimSpace(kv[1]); mode {
+		case bannerText, bannerBorder, bannerFull:
+			banners[severity] = mode
+		default:
+			return nil, fmt.Errorf("Invalid alert banner mode: %s", mode)
+		}
+	}
+
+	return banners, nil
+}
+
+// alertText returns text of alert of type typ as displayed in the program window
+func alertText(typ string) string {
+	for _, r := range alertRules {
+		if r.Name == typ {
+			return r.Message
+		}
+	}
+
+	return msg("alert." + typ)
+}
+
+// bannerAlert returns type and severity of the most severe raised alert of result which was not acknowledged;
+// ok is false if there is no such alert
+func bannerAlert(result *Result) (typ, severity string, ok bool) {
+	for t, raised := range alertTypes(result) {
+		if !raised || result.Acknowledged[t] {
+			continue
+		}
+		s := result.Severities[t]
+		// ties are broken by alert type so the banner doesn't jump between alerts
+		if !ok || severityRank(s) > severityRank(severity) || (s == severity && t < typ) {
+			typ, severity, ok = t, s, true
+		}
+	}
+
+	return typ, severity, ok
+}
+
+// drawAlertBanner draws border or banner of the most severe unacknowledged alert of result into img as configured
+// for its severity. The border and banner flash in flash period, i.e. they are drawn in every other period of time ts;
+// zero flash period draws them steadily.
+func drawAlertBanner(img *gocv.Mat, result *Result, ts time.Time, flash time.Duration) {
+	typ, severity, ok := bannerAlert(result)
+	if !ok {
+		return
+	}
+	mode := alertBanners[severity]
+	if mode == "" || mode == bannerText {
+		return
+	}
+	if flash > 0 && (ts.UnixNano()/int64(flash))%2 != 0 {
+		return
+	}
+
+	c, ok := severityColors[severity]
+	if !ok {
+		c = overlayAlertColor
+	}
+	w, h := img.Cols(), img.Rows()
+
+	switch mode {
+	case bannerBorder:
+		thickness := h / 40
+		if thickness < 8 {
+			thickness = 8
+		}
+		gocv.Rectangle(img, image.Rect(0, 0, w, h), c, thickness)
+	case bannerFull:
+		band := image.Rect(0, h*2/5, w, h*3/5)
+		// the banner is blended into the frame so the scene stays visible behind it
+		blend := img.Clone()
+		gocv.Rectangle(&blend, band, c, -1)
+		gocv.AddWeighted(blend, 0.5, *img, 0.5, 0, img)
+		blend.Close()
+
+		drawBannerText(img, alertText(typ), band)
+	}
+}
+
+// drawBannerText draws text into img centered in rectangle band, scaled down to fit it if need be
+func drawBannerText(img *gocv.Mat, text string, band image.Rectangle) {
+	scale := 2.0
+	for scale > 0.5 {
+		size := gocv.GetTextSize(text, gocv.FontHersheySimplex, overlayFontScale*scale, overlayThickness)
+		if size.X <= band.Dx()-20 && size.Y <= band.Dy()-10 {
+			break
+		}
+		scale -= 0.25
+	}
+
+	size := gocv.GetTextSize(text, gocv.FontHersheySimplex, overlayFontScale*scale, overlayThickness)
+	pt := image.Point{band.Min.X + (band.Dx()-size.X)/2, band.Min.Y + (band.Dy()+size.Y)/2}
+	putText(img, text, pt, scale, overlayTextColor)
+}
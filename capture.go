@@ -0,0 +1,139 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// captureAPIs maps capture API names to OpenCV video capture API preferences
+var captureAPIs = map[string]gocv.VideoCaptureAPI{
+	"any":       gocv.VideoCaptureAny,
+	"ffmpeg":    gocv.VideoCaptureFFmpeg,
+	"gstreamer": gocv.VideoCaptureGstreamer,
+	"v4l2":      gocv.VideoCaptureV4L2,
+	"mfx":       gocv.VideoCaptureIntelMFX,
+	"msmf":      gocv.VideoCaptureMSMF,
+}
+
+// hwAccels maps hardware decode names to OpenCV video acceleration types
+var hwAccels = map[string]int{
+	// none disables hardware decoding
+	"none": 0,
+	// any picks any available hardware decoder, including NVDEC on CUDA enabled FFmpeg builds
+	"any": 1,
+	// d3d11 is DirectX 11 decoding on Windows
+	"d3d11": 2,
+	// vaapi is Video Acceleration API decoding on Linux, which includes QuickSync
+	"vaapi": 3,
+	// mfx is Intel Media SDK (QuickSync) decoding
+	"mfx": 4,
+}
+
+// parseCaptureAPI returns OpenCV video capture API for its name.
+// It returns error if the capture API is not supported.
+func parseCaptureAPI(api string) (gocv.VideoCaptureAPI, error) {
+	a, ok := captureAPIs[api]
+	if !ok {
+		return 0, fmt.Errorf("Unsupported video capture API: %s", api)
+	}
+
+	return a, nil
+}
+
+// parseHWAccel returns OpenCV video acceleration type for its name.
+// It returns error if the hardware decoder is not supported.
+func parseHWAccel(accel string) (int, error) {
+	a, ok := hwAccels[accel]
+	if !ok {
+		return 0, fmt.Errorf("Unsupported hardware decoder: %s", accel)
+	}
+
+	return a, nil
+}
+
+// captureParams returns video capture open parameters which request accel hardware decoding
+func captureParams(accel int) []gocv.VideoCaptureProperties {
+	return []gocv.VideoCaptureProperties{
+		gocv.VideoCaptureHWAcceleration, gocv.VideoCaptureProperties(accel),
+	}
+}
+
+// frameTimestamp returns the time of the last frame read from vc relative to start.
+// It uses the frame timestamp if the video container provides it and falls back to frame position and fps.
+func frameTimestamp(vc *gocv.VideoCapture, start time.Time, fps float64) time.Time {
+	if msec := vc.Get(gocv.VideoCapturePosMsec); msec > 0 {
+		return start.Add(time.Duration(msec * float64(time.Millisecond)))
+	}
+
+	if pos := vc.Get(gocv.VideoCapturePosFrames); pos > 0 && fps > 0 {
+		return start.Add(time.Duration(pos / fps * float64(time.Second)))
+	}
+
+	return start
+}
+
+// screenPipeline returns GStreamer pipeline which captures X11 screen region or window at fps frames per second.
+// region is either "all" or "x,y,width,height"; window is X11 window name and takes precedence over region.
+// It returns error if the region is not valid.
+func screenPipeline(region, window string, fps int) (string, error) {
+	var src string
+	switch {
+	case window != "":
+		src = fmt.Sprintf("ximagesrc xname=%q use-damage=0", window)
+	case region == "all":
+		src = "ximagesrc use-damage=0"
+	default:
+		var x, y, w, h int
+		if n, err := fmt.Sscanf(region, "%d,%d,%d,%d", &x, &y, &w, &h); err != nil || n != 4 || w <= 0 || h <= 0 {
+			return "", fmt.Errorf("Invalid screen region: %s", region)
+		}
+		// ximagesrc end coordinates are inclusive
+		src = fmt.Sprintf("ximagesrc startx=%d starty=%d endx=%d endy=%d use-damage=0", x, y, x+w-1, y+h-1)
+	}
+
+	return fmt.Sprintf("%s ! video/x-raw,framerate=%d/1 ! videoconvert ! video/x-raw,format=BGR ! appsink drop=1", src, fps), nil
+}
+
+// NewScreenCapture creates new video capture which captures screen region or window and returns it.
+// It adjusts delay parameter so the display refresh matches the capture frame rate.
+// It fails with error if the screen can't be captured.
+func NewScreenCapture(region, window string, fps int, delay *float64) (*gocv.VideoCapture, error) {
+	pipeline, err := screenPipeline(region, window, fps)
+	if err != nil {
+		return nil, err
+	}
+
+	vc, err := gocv.VideoCaptureFileWithAPI(pipeline, gocv.VideoCaptureGstreamer)
+	if err != nil {
+		return nil, err
+	}
+
+	*delay = 1000 / float64(fps)
+
+	return vc, nil
+}
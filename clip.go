@@ -0,0 +1,201 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// bufferedFrame is frame kept in pre-roll buffer
+type bufferedFrame struct {
+	// ts is time when the frame was captured
+	ts time.Time
+	// jpeg is JPEG encoded frame; raw frames would take hundreds of megabytes for a few seconds of HD video
+	jpeg []byte
+}
+
+// ClipRecorder records video clips of raised alerts covering the time before and after the alerts were raised.
+// It keeps the frames of the pre-roll in memory; alerts raised while a clip is recorded extend the clip.
+// It is safe for concurrent use.
+type ClipRecorder struct {
+	// dir is directory where the clips are stored
+	dir string
+	// codec is FOURCC video codec code
+	codec string
+	// fps is recorded video frame rate
+	fps float64
+	// pre is time before the alert the clip covers
+	pre time.Duration
+	// post is time after the alert the clip covers
+	post time.Duration
+	// mu guards the fields below
+	mu sync.Mutex
+	// buffer stores frames of the pre-roll from the oldest one
+	buffer []bufferedFrame
+	// pending are alerts raised since the latest frame
+	pending []Alert
+	// vw writes the currently recorded clip; nil if no clip is recorded
+	vw *gocv.VideoWriter
+	// until is time when the currently recorded clip ends
+	until time.Time
+}
+
+// NewClipRecorder creates new clip recorder which stores clips in dir and returns it.
+// It fails with error if dir can't be created or if the codec is not a valid FOURCC code.
+func NewClipRecorder(dir, codec string, fps float64, pre, post time.Duration) (*ClipRecorder, error) {
+	if len(codec) != 4 {
+		return nil, fmt.Errorf("Invalid video codec: %s", codec)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &ClipRecorder{
+		dir:   dir,
+		codec: codec,
+		fps:   fps,
+		pre:   pre,
+		post:  post,
+	}, nil
+}
+
+// Trigger requests clip of alert a; the clip starts with the next frame
+func (c *ClipRecorder) Trigger(a Alert) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pending = append(c.pending, a)
+}
+
+// Write adds frame img captured at ts to the clip being recorded or to the pre-roll buffer.
+// It returns error if the clip file can't be created or if the frame can't be written.
+func (c *ClipRecorder) Write(img gocv.Mat, ts time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.pending) > 0 {
+		if c.vw == nil {
+			if err := c.start(c.pending[0], img.Cols(), img.Rows()); err != nil {
+				c.pending = nil
+				return err
+			}
+		}
+		c.until = ts.Add(c.post)
+		c.pending = nil
+	}
+
+	if c.vw != nil {
+		if err := c.vw.Write(img); err != nil {
+			return err
+		}
+		if ts.After(c.until) {
+			err := c.vw.Close()
+			c.vw = nil
+			return err
+		}
+		return nil
+	}
+
+	if c.pre <= 0 {
+		return nil
+	}
+	buf, err := gocv.IMEncode(gocv.JPEGFileExt, img)
+	if err != nil {
+		return err
+	}
+	defer buf.Close()
+	c.buffer = append(c.buffer, bufferedFrame{ts: ts, jpeg: append([]byte(nil), buf.GetBytes()...)})
+	// drop the frames which fell out of the pre-roll
+	var i int
+	for i < len(c.buffer) && ts.Sub(c.buffer[i].ts) > c.pre {
+		i++
+	}
+	c.buffer = c.buffer[i:]
+
+	return nil
+}
+
+// start starts clip of alert a with frames of width and height and writes the pre-roll into it
+func (c *ClipRecorder) start(a Alert, width, height int) error {
+	path := filepath.Join(c.dir, fmt.Sprintf("%s-%s.avi", a.Type, a.ID))
+	vw, err := gocv.VideoWriterFile(path, c.codec, c.fps, width, height, true)
+	if err != nil {
+		return err
+	}
+	c.vw = vw
+
+	for _, f := range c.buffer {
+		img, err := gocv.IMDecode(f.jpeg, gocv.IMReadColor)
+		if err != nil {
+			continue
+		}
+		// frames captured before the input source changed may have different size
+		if img.Cols() == width && img.Rows() == height {
+			c.vw.Write(img)
+		}
+		img.Close()
+	}
+	c.buffer = nil
+
+	fmt.Printf("Recording alert clip %s\n", path)
+
+	return nil
+}
+
+// Close finishes the clip being recorded
+func (c *ClipRecorder) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.vw == nil {
+		return nil
+	}
+	err := c.vw.Close()
+	c.vw = nil
+
+	return err
+}
+
+// clipRunner requests clips of the alerts raised in alert state transitions received from alertsChan
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func clipRunner(doneChan <-chan struct{}, alertsChan <-chan Alert, c *ClipRecorder) error {
+	for {
+		select {
+		case a := <-alertsChan:
+			if a.State == alertRaised && !a.Heartbeat {
+				c.Trigger(a)
+			}
+		case <-doneChan:
+			fmt.Printf("Stopping clipRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
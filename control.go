@@ -0,0 +1,185 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// statusTimeout is time to wait for status snapshot
+	statusTimeout = time.Second
+)
+
+const (
+	// cmdPause pauses monitoring and clears the alerts
+	cmdPause = "pause"
+	// cmdResume resumes paused monitoring
+	cmdResume = "resume"
+	// cmdReset resets operator timers and alerts
+	cmdReset = "reset"
+	// cmdAck acknowledges alerts
+	cmdAck = "ack"
+	// cmdStatus requests status snapshot
+	cmdStatus = "status"
+	// cmdSnooze snoozes alerts of a type
+	cmdSnooze = "snooze"
+	// cmdSuspend suspends monitoring outside of shifts; it is sent by the shift schedule only
+	cmdSuspend = "suspend"
+	// cmdUnsuspend resumes monitoring suspended outside of shifts; it is sent by the shift schedule only
+	cmdUnsuspend = "unsuspend"
+)
+
+// command is monitor command
+type command struct {
+	// name is command name
+	name string
+	// arg is command argument, e.g. ID or type of alert to acknowledge
+	arg string
+	// duration is snooze duration
+	duration time.Duration
+	// reply receives status snapshot requested by status command
+	reply chan string
+}
+
+// parseCommand parses command from s in "name [argument]" format.
+// Snooze command takes alert type and optional duration: "snooze type [duration]".
+func parseCommand(s string) (*command, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 3 || (len(fields) == 3 && !strings.EqualFold(fields[0], cmdSnooze)) {
+		return nil, fmt.Errorf("invalid command: %q", s)
+	}
+
+	cmd := &command{name: strings.ToLower(fields[0])}
+	if len(fields) == 2 {
+		cmd.arg = fields[1]
+	}
+
+	switch cmd.name {
+	case cmdPause, cmdResume, cmdReset, cmdStatus:
+		if cmd.arg != "" {
+			return nil, fmt.Errorf("command %s takes no argument", cmd.name)
+		}
+	case cmdAck:
+	case cmdSnooze:
+		if _, ok := alertTypes(new(Result))[cmd.arg]; !ok && cmd.arg != "all" {
+			return nil, fmt.Errorf("invalid alert type: %q", cmd.arg)
+		}
+		cmd.duration = snoozeDuration
+		if len(fields) == 3 {
+			d, err := time.ParseDuration(fields[2])
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("invalid snooze duration: %s", fields[2])
+			}
+			cmd.duration = d
+		}
+	default:
+		return nil, fmt.Errorf("unknown command: %s", cmd.name)
+	}
+
+	return cmd, nil
+}
+
+// source is video input source
+type source struct {
+	// input is path to image or video file; empty if camera device is used
+	input string
+	// deviceID is camera device ID
+	deviceID int
+}
+
+// String implements fmt.Stringer interface for source
+func (s *source) String() string {
+	if s.input != "" {
+		return s.input
+	}
+
+	return fmt.Sprintf("camera %d", s.deviceID)
+}
+
+// parseSource parses video input source from s.
+// s is either camera device ID or path to image or video file.
+func parseSource(s string) *source {
+	s = strings.TrimSpace(s)
+	if id, err := strconv.Atoi(s); err == nil {
+		return &source{deviceID: id}
+	}
+
+	return &source{input: s, deviceID: -1}
+}
+
+// newSourceHandler returns MQTT message handler which parses video input sources
+// from received messages and sends them down the sourceChan
+func newSourceHandler(sourceChan chan<- *source) MQTT.MessageHandler {
+	return func(c MQTT.Client, msg MQTT.Message) {
+		src := parseSource(string(msg.Payload()))
+		if src.input == "" && src.deviceID < 0 {
+			fmt.Printf("Ignoring invalid video input: %s\n", msg.Payload())
+			return
+		}
+
+		select {
+		case sourceChan <- src:
+		default:
+			fmt.Printf("Ignoring video input %s: another input switch is in progress\n", src)
+		}
+	}
+}
+
+// newCommandHandler returns MQTT message handler which parses commands from received messages
+// and sends them down the cmdChan. Status snapshots are published to statusTopic.
+func newCommandHandler(cmdChan chan<- *command) MQTT.MessageHandler {
+	return func(c MQTT.Client, msg MQTT.Message) {
+		cmd, err := parseCommand(string(msg.Payload()))
+		if err != nil {
+			fmt.Printf("Ignoring command: %v\n", err)
+			return
+		}
+		if cmd.name == cmdStatus {
+			cmd.reply = make(chan string, 1)
+		}
+
+		select {
+		case cmdChan <- cmd:
+		default:
+			fmt.Printf("Ignoring command %s: another command is in progress\n", cmd.name)
+			return
+		}
+
+		if cmd.reply == nil {
+			return
+		}
+		select {
+		case snapshot := <-cmd.reply:
+			c.Publish(statusTopic, 0, false, snapshot)
+		case <-time.After(statusTimeout):
+			fmt.Printf("Status snapshot timed out\n")
+		}
+	}
+}
@@ -0,0 +1,63 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+)
+
+// zone is operator zone faces are counted in; empty zone spans the whole frame
+var zone image.Rectangle
+
+// parseZone parses operator zone from s in "x,y,width,height" format; empty s means the whole frame
+func parseZone(s string) (image.Rectangle, error) {
+	if s == "" {
+		return image.Rectangle{}, nil
+	}
+
+	var x, y, w, h int
+	if n, err := fmt.Sscanf(s, "%d,%d,%d,%d", &x, &y, &w, &h); err != nil || n != 4 || x < 0 || y < 0 || w <= 0 || h <= 0 {
+		return image.Rectangle{}, fmt.Errorf("Invalid operator zone: %s", s)
+	}
+
+	return image.Rect(x, y, x+w, y+h), nil
+}
+
+// countInZone returns number of faces whose center lies in the operator zone
+func countInZone(faces []*Face) int {
+	if zone.Empty() {
+		return len(faces)
+	}
+
+	var n int
+	for _, f := range faces {
+		center := f.Rect.Min.Add(f.Rect.Max).Div(2)
+		if center.In(zone) {
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,226 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// csvTimeFormat is format of CSV timestamps which spreadsheets recognize as date and time
+const csvTimeFormat = "2006-01-02 15:04:05.000"
+
+// CSVWriter writes operator status records as CSV rows with a column for every alert type
+type CSVWriter struct {
+	// w writes the rows
+	w *csv.Writer
+	// types are sorted alert types, one column each
+	types []string
+}
+
+// NewCSVWriter writes CSV header with columns for alert types to w and returns new CSV writer
+func NewCSVWriter(w io.Writer, types []string) (*CSVWriter, error) {
+	sorted := append([]string(nil), types...)
+	sort.Strings(sorted)
+
+	header := []string{"time", "operator", "watching", "angry", "sentiment", "distance", "paused", "suspended"}
+	for _, typ := range sorted {
+		header = append(header, "alert_"+typ)
+	}
+	header = append(header, "face_net", "sent_net", "pose_net")
+
+	c := &CSVWriter{w: csv.NewWriter(w), types: sorted}
+	if err := c.w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// csvBool formats b as 1 or 0 so spreadsheets can sum and average the columns
+func csvBool(b bool) string {
+	if b {
+		return "1"
+	}
+
+	return "0"
+}
+
+// csvFloat formats f; zero is written as empty cell as it means the value is unknown
+func csvFloat(f float64) string {
+	if f == 0 {
+		return ""
+	}
+
+	return strconv.FormatFloat(f, 'f', 3, 64)
+}
+
+// Write writes rec as CSV row and flushes it
+func (c *CSVWriter) Write(rec statusRecord) error {
+	row := []string{
+		rec.Time.Local().Format(csvTimeFormat),
+		rec.Operator,
+		csvBool(rec.Watching),
+		csvBool(rec.Angry),
+		rec.Sentiment,
+		csvFloat(rec.Distance),
+		csvBool(rec.Paused),
+		csvBool(rec.Suspended),
+	}
+	for _, typ := range c.types {
+		row = append(row, csvBool(rec.Alerts[typ]))
+	}
+	if rec.Perf != nil {
+		row = append(row, csvFloat(rec.Perf.FaceNet), csvFloat(rec.Perf.SentNet), csvFloat(rec.Perf.PoseNet))
+	} else {
+		row = append(row, "", "", "")
+	}
+
+	if err := c.w.Write(row); err != nil {
+		return err
+	}
+	c.w.Flush()
+
+	return c.w.Error()
+}
+
+// csvRunner writes operator status received from statusChan as CSV row to w every rate seconds
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func csvRunner(doneChan <-chan struct{}, statusChan <-chan *Result, w *CSVWriter, rate int) error {
+	ticker := time.NewTicker(time.Duration(rate) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			select {
+			case result, ok := <-statusChan:
+				if !ok {
+					// frameRunner stopped; wait until we are told to stop
+					statusChan = nil
+					continue
+				}
+				rec := statusRecord{Status: result.ToPayload()}
+				if result.hasPerf() {
+					perf := result.ToPerfPayload()
+					rec.Perf = &perf
+				}
+				if err := w.Write(rec); err != nil {
+					return err
+				}
+			case <-doneChan:
+				fmt.Printf("Stopping csvRunner: received stop signal\n")
+				return nil
+			}
+		case _, ok := <-statusChan:
+			// we discard status in between ticker times
+			if !ok {
+				statusChan = nil
+			}
+		case <-doneChan:
+			fmt.Printf("Stopping csvRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
+
+// exportCommand implements export subcommand which dumps operator status stored in event store to CSV
+func exportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	path := fs.String("store", "events.db", "Path to SQLite event store")
+	since := fs.String("since", "24h", "Export status since the given time in RFC3339 format or the given duration ago")
+	out := fs.String("o", "", "Path to CSV file. Default: standard output")
+	fs.Parse(args)
+
+	from, err := parseSince(*since)
+	if err != nil {
+		return fmt.Errorf("Invalid time: %s", *since)
+	}
+
+	s, err := openEventStore(*path)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	events, err := s.Query(from, eventStatus, "")
+	if err != nil {
+		return err
+	}
+
+	// the columns cover every alert type which occurs in the exported status
+	recs := make([]statusRecord, len(events))
+	seen := make(map[string]bool)
+	var types []string
+	for i, e := range events {
+		if err := json.Unmarshal([]byte(e.Data), &recs[i]); err != nil {
+			return fmt.Errorf("Invalid status record: %v", err)
+		}
+		for typ := range recs[i].Alerts {
+			if !seen[typ] {
+				seen[typ] = true
+				types = append(types, typ)
+			}
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	c, err := NewCSVWriter(w, types)
+	if err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		if err := c.Write(rec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// alertTypeNames returns names of all the alert types including the custom ones
+func alertTypeNames() []string {
+	var types []string
+	for typ := range alertTypes(new(Result)) {
+		types = append(types, typ)
+	}
+
+	return types
+}
@@ -0,0 +1,250 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// streamBoundary separates JPEG frames of the MJPEG stream
+	streamBoundary = "frame"
+)
+
+// handleDashboard responds with the dashboard page showing the annotated video stream with live operator status,
+// active alerts and inference performance
+func (s *APIServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	// the dashboard is registered as the catch-all pattern
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	title := name
+	if machineID != "" {
+		title = machineID + " - " + name
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, dashboardHTML, title, title)
+}
+
+// handleStream responds with MJPEG stream of the annotated frames until the client disconnects
+func (s *APIServer) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	c := make(chan []byte, 1)
+	s.mu.Lock()
+	s.streams[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.streams, c)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+streamBoundary)
+	w.Header().Set("Cache-Control", "no-cache")
+	for {
+		select {
+		case frame := <-c:
+			if _, err := fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n",
+				streamBoundary, len(frame)); err != nil {
+				return
+			}
+			if _, err := w.Write(frame); err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "\r\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Stream sends JPEG encoded img to the MJPEG stream clients at most at the stream frame rate.
+// Frames are only encoded while there are clients; slow clients miss the frames they are not ready for.
+// It must be called from the display loop only.
+func (s *APIServer) Stream(img gocv.Mat) {
+	s.mu.Lock()
+	clients := len(s.streams)
+	s.mu.Unlock()
+
+	now := time.Now()
+	if clients == 0 || now.Sub(s.streamed) < s.streamInterval {
+		return
+	}
+	s.streamed = now
+
+	buf, err := gocv.IMEncode(gocv.JPEGFileExt, img)
+	if err != nil {
+		fmt.Printf("Error encoding stream frame: %v\n", err)
+		return
+	}
+	defer buf.Close()
+	jpeg := append([]byte(nil), buf.GetBytes()...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.streams {
+		select {
+		case c <- jpeg:
+		default:
+		}
+	}
+}
+
+// dashboardHTML is the dashboard page; it is formatted with the page title and heading
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>
+body { margin: 0; font-family: sans-serif; background: #1e1e1e; color: #eee; }
+header { padding: 8px 16px; background: #333; display: flex; justify-content: space-between; align-items: center; }
+h1 { font-size: 1.2em; margin: 0; }
+main { display: flex; flex-wrap: wrap; gap: 16px; padding: 16px; }
+#video { flex: 3 1 640px; }
+#video img { width: 100%%; background: #000; }
+aside { flex: 1 1 280px; }
+section { background: #2a2a2a; border-radius: 4px; padding: 8px 12px; margin-bottom: 16px; }
+h2 { font-size: 1em; margin: 0 0 8px; color: #aaa; }
+table { width: 100%%; border-collapse: collapse; }
+td { padding: 2px 0; }
+td:last-child { text-align: right; font-weight: bold; }
+.yes { color: #4caf50; } .no { color: #ff9800; }
+#connection.online { color: #4caf50; } #connection.offline { color: #f44336; }
+.alert { padding: 6px 8px; margin-bottom: 4px; border-left: 6px solid; background: #333; }
+.info { border-color: #ffeb3b; } .warning { border-color: #ff9800; } .critical { border-color: #f44336; }
+.acknowledged { opacity: 0.5; }
+</style>
+</head>
+<body>
+<header><h1>%s</h1><span id="connection" class="offline">offline</span></header>
+<main>
+<div id="video"><img src="/stream" alt="video stream"></div>
+<aside>
+<section><h2>Operator</h2><table id="status"></table></section>
+<section><h2>Active alerts</h2><div id="alerts">none</div></section>
+<section><h2>Performance</h2><table id="perf"></table></section>
+</aside>
+</main>
+<script>
+function rows(table, values) {
+  var html = '';
+  values.forEach(function (v) {
+    var cls = v[1] === true ? 'yes' : v[1] === false ? 'no' : '';
+    var text = v[1] === true ? 'yes' : v[1] === false ? 'no' : v[1];
+    html += '<tr><td>' + v[0] + '</td><td class="' + cls + '">' + text + '</td></tr>';
+  });
+  document.getElementById(table).innerHTML = html;
+}
+
+function escape(s) {
+  var div = document.createElement('div');
+  div.textContent = s;
+  return div.innerHTML;
+}
+
+function showStatus(s) {
+  var values = [
+    ['Operator', escape(s.operator || 'unknown')],
+    ['Watching', s.watching],
+    ['Angry', s.angry],
+    ['Sentiment', escape(s.sentiment || 'unknown')]
+  ];
+  if (s.distance) values.push(['Distance', s.distance.toFixed(2) + ' m']);
+  if (s.risk !== undefined) values.push(['Risk', s.risk.toFixed(2)]);
+  if (s.paused) values.push(['Monitoring', 'paused']);
+  if (s.suspended) values.push(['Monitoring', 'outside of shift']);
+  rows('status', values);
+}
+
+function connect() {
+  var ws = new WebSocket((location.protocol === 'https:' ? 'wss://' : 'ws://') + location.host + '/live');
+  var connection = document.getElementById('connection');
+  ws.onopen = function () { connection.className = 'online'; connection.textContent = 'online'; };
+  ws.onmessage = function (e) { showStatus(JSON.parse(e.data)); };
+  ws.onclose = function () {
+    connection.className = 'offline';
+    connection.textContent = 'offline';
+    setTimeout(connect, 2000);
+  };
+}
+
+function poll(path, show) {
+  fetch(path).then(function (r) { return r.ok ? r.json() : null; }).then(function (v) {
+    if (v) show(v);
+  }).catch(function () {});
+}
+
+function showAlerts(alerts) {
+  var html = '';
+  alerts.forEach(function (a) {
+    html += '<div class="alert ' + escape(a.severity) + ' ' + escape(a.state) + '"><b>' + escape(a.type) + '</b> ' +
+      escape(a.severity) + ', ' + escape(a.state) + ' since ' + new Date(a.raised).toLocaleTimeString() + '</div>';
+  });
+  document.getElementById('alerts').innerHTML = html || 'none';
+}
+
+function showPerf(p) {
+  rows('perf', [
+    ['Processed', p.fps.toFixed(1) + ' fps'],
+    ['Input', p.input_fps.toFixed(1) + ' fps'],
+    ['Face detection', p.face_net.toFixed(1) + ' ms'],
+    ['Sentiment', p.sent_net.toFixed(1) + ' ms'],
+    ['Head pose', p.pose_net.toFixed(1) + ' ms'],
+    ['Dropped frames', p.dropped + ' of ' + p.frames]
+  ]);
+}
+
+connect();
+setInterval(function () { poll('/alerts', showAlerts); }, 2000);
+setInterval(function () { poll('/perf', showPerf); }, 5000);
+poll('/alerts', showAlerts);
+poll('/perf', showPerf);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,140 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"image"
+	"time"
+)
+
+const (
+	// degradeNone means all the models run on every frame
+	degradeNone = iota
+	// degradeOptional means the optional models are skipped
+	degradeOptional
+	// degradeSentiment means the sentiment detection is skipped as well
+	degradeSentiment
+	// degradeResolution means the face and person detectors run on lower input resolution as well
+	degradeResolution
+	// degradeSampling means every other frame is skipped as well
+	degradeSampling
+)
+
+const (
+	// degradeFrames is number of consecutive frames exceeding the latency budget after which the inference is degraded
+	degradeFrames = 5
+	// recoverFrames is number of consecutive frames well within the latency budget after which the inference recovers
+	recoverFrames = 30
+	// degradeScale scales input size of the face and person detectors at degradeResolution level
+	degradeScale = 0.5
+)
+
+// Degrader degrades the inference when its latency exceeds the budget and recovers it when the load drops
+type Degrader struct {
+	// budget is per-frame latency budget; zero disables the degradation
+	budget time.Duration
+	// level is current degradation level
+	level int
+	// over is number of consecutive frames exceeding the budget
+	over int
+	// under is number of consecutive frames within half of the budget
+	under int
+}
+
+// NewDegrader creates new degrader with per-frame latency budget and returns it
+func NewDegrader(budget time.Duration) *Degrader {
+	return &Degrader{
+		budget: budget,
+	}
+}
+
+// Update records inference latency of a frame and returns the current degradation level.
+// The level is raised when the budget is exceeded in several consecutive frames and lowered
+// when the latency stays within half of the budget for longer, so the level doesn't oscillate.
+func (g *Degrader) Update(latency time.Duration) int {
+	if g.budget <= 0 {
+		return degradeNone
+	}
+
+	switch {
+	case latency > g.budget:
+		g.over, g.under = g.over+1, 0
+	case latency < g.budget/2:
+		g.over, g.under = 0, g.under+1
+	default:
+		g.over, g.under = 0, 0
+	}
+
+	if g.over >= degradeFrames && g.level < degradeSampling {
+		g.level, g.over = g.level+1, 0
+		return g.level
+	}
+
+	if g.under >= recoverFrames && g.level > degradeNone {
+		g.level, g.under = g.level-1, 0
+	}
+
+	return g.level
+}
+
+// Level returns the current degradation level
+func (g *Degrader) Level() int {
+	return g.level
+}
+
+// degradeNets returns nets to run at degradation level. Skipped networks are nil.
+func degradeNets(nets *Nets, level int) *Nets {
+	if level < degradeOptional {
+		return nets
+	}
+
+	n := &Nets{
+		Face: nets.Face,
+		Sent: nets.Sent,
+		Pose: nets.Pose,
+	}
+
+	if level >= degradeSentiment {
+		n.Sent = nil
+	}
+
+	if level >= degradeResolution {
+		n.InputScale = degradeScale
+	}
+
+	return n
+}
+
+// detectorSize returns input size of detector net scaled by scale. Full size is returned if scale is not
+// between 0 and 1 or the net only takes inputs of fixed size.
+func detectorSize(net Model, size image.Point, scale float64) image.Point {
+	if scale <= 0 || scale >= 1 {
+		return size
+	}
+	if f, ok := net.(fixedSizer); ok && f.FixedSize() {
+		return size
+	}
+
+	return image.Pt(int(float64(size.X)*scale), int(float64(size.Y)*scale))
+}
@@ -0,0 +1,348 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// autoDevice selects the best inference device available
+	autoDevice = "AUTO"
+	// heteroMode loads the models on the first of the listed devices which can run them
+	heteroMode = "HETERO"
+	// multiMode spreads the inference requests across all the listed devices
+	multiMode = "MULTI"
+	// autoTarget is the target ID which stands for autoDevice until the networks are loaded
+	autoTarget = -2
+)
+
+// backendNames maps inference backend names to their IDs
+var backendNames = map[string]int{
+	"DEFAULT":  0,
+	"HALIDE":   1,
+	"OPENVINO": 2,
+	"OPENCV":   3,
+}
+
+// deviceNames maps inference device names to their target IDs
+var deviceNames = map[string]int{
+	"CPU":      0,
+	"GPU":      1,
+	"GPU_FP16": 2,
+	"MYRIAD":   3,
+}
+
+// autoDevices lists inference devices in the order of preference when the device is selected automatically
+var autoDevices = []string{"GPU", "MYRIAD", "CPU"}
+
+// targetOrder lists inference target names in the order of their IDs
+var targetOrder = []string{"CPU", "GPU", "GPU_FP16", "MYRIAD"}
+
+// idFlag is command line flag which accepts either a name from names or a numeric ID and stores the ID in value
+type idFlag struct {
+	// value stores the ID
+	value *int
+	// names maps names to IDs
+	names map[string]int
+	// auto means the flag accepts AUTO device name
+	auto bool
+}
+
+// String implements flag.Value interface for idFlag
+func (f *idFlag) String() string {
+	if f.value == nil {
+		return ""
+	}
+
+	if f.auto && *f.value == autoTarget {
+		return autoDevice
+	}
+
+	for name, id := range f.names {
+		if id == *f.value {
+			return name
+		}
+	}
+
+	return strconv.Itoa(*f.value)
+}
+
+// Set implements flag.Value interface for idFlag
+func (f *idFlag) Set(s string) error {
+	name := strings.ToUpper(s)
+	// AUTO is resolved when the networks are loaded since only the runtime knows which targets can run them
+	if f.auto && name == autoDevice {
+		*f.value = autoTarget
+		return nil
+	}
+
+	if id, ok := f.names[name]; ok {
+		*f.value = id
+		return nil
+	}
+
+	// numeric IDs are still accepted for backward compatibility
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("unknown name %s", s)
+	}
+	*f.value = id
+
+	return nil
+}
+
+// targetFlag is command line flag which accepts a single device like idFlag,
+// or HETERO or MULTI device list such as HETERO:MYRIAD,CPU
+type targetFlag struct {
+	idFlag
+	// mode stores HETERO or MULTI mode; empty for a single device
+	mode *string
+	// devices stores targets of the listed devices
+	devices *[]int
+}
+
+// String implements flag.Value interface for targetFlag
+func (f *targetFlag) String() string {
+	if f.mode == nil || *f.mode == "" {
+		return f.idFlag.String()
+	}
+
+	names := make([]string, len(*f.devices))
+	for i, t := range *f.devices {
+		v := t
+		names[i] = (&idFlag{value: &v, names: f.names}).String()
+	}
+
+	return *f.mode + ":" + strings.Join(names, ",")
+}
+
+// Set implements flag.Value interface for targetFlag
+func (f *targetFlag) Set(s string) error {
+	parts := strings.SplitN(s, ":", 2)
+	mode := strings.ToUpper(parts[0])
+	if len(parts) != 2 || (mode != heteroMode && mode != multiMode) {
+		*f.mode, *f.devices = "", nil
+		return f.idFlag.Set(s)
+	}
+
+	var devices []int
+	for _, name := range parseLabels(parts[1]) {
+		var t int
+		d := &idFlag{value: &t, names: f.names}
+		if err := d.Set(name); err != nil {
+			return err
+		}
+		devices = append(devices, t)
+	}
+
+	if len(devices) == 0 {
+		return fmt.Errorf("no devices listed")
+	}
+
+	*f.mode, *f.devices, *f.value = mode, devices, devices[0]
+
+	return nil
+}
+
+// netTargets returns inference targets the i-th set of networks tries in order until the networks load.
+// HETERO mode falls back to the next listed device while MULTI mode assigns the devices round robin.
+// AUTO target tries the devices in the order of preference.
+func netTargets(i, target int) []int {
+	if target == autoTarget {
+		return autoTargets()
+	}
+
+	switch targetMode {
+	case heteroMode:
+		return targetDevices
+	case multiMode:
+		return []int{targetDevices[i%len(targetDevices)]}
+	}
+
+	return []int{target}
+}
+
+// autoTargets returns targets of autoDevices in the order of preference.
+// Every model is loaded on the first of them which passes a test forward pass, see newModelOnTargets.
+func autoTargets() []int {
+	targets := make([]int, len(autoDevices))
+	for i, d := range autoDevices {
+		targets[i] = deviceNames[d]
+	}
+
+	return targets
+}
+
+// detectDevices returns names of inference devices which appear to be present in the system.
+// It is a heuristic: CPU is always listed, GPU is guessed from its DRI render node and MYRIAD from its USB vendor ID,
+// so it neither proves that the inference runtime supports the devices nor finds devices exposed in other ways.
+func detectDevices() []string {
+	devices := []string{"CPU"}
+
+	if gpuCount() > 0 {
+		devices = append(devices, "GPU")
+	}
+
+	vendors, _ := filepath.Glob("/sys/bus/usb/devices/*/idVendor")
+	for _, v := range vendors {
+		if id, err := ioutil.ReadFile(v); err == nil && strings.TrimSpace(string(id)) == "03e7" {
+			devices = append(devices, "MYRIAD")
+			break
+		}
+	}
+
+	return devices
+}
+
+// gpuCount returns number of GPUs in the system detected by their DRI render nodes
+func gpuCount() int {
+	nodes, _ := filepath.Glob("/dev/dri/renderD*")
+	return len(nodes)
+}
+
+// isGPUTarget returns true if inference target t runs on GPU
+func isGPUTarget(t int) bool {
+	return t == deviceNames["GPU"] || t == deviceNames["GPU_FP16"]
+}
+
+// listedTargets returns all the inference targets target stands for: the devices AUTO tries,
+// the listed HETERO or MULTI devices or the target itself
+func listedTargets(target int) []int {
+	if target == autoTarget {
+		return autoTargets()
+	}
+	if targetMode != "" {
+		return targetDevices
+	}
+
+	return []int{target}
+}
+
+// checkGPU returns error if GPU with index can't be selected for models running on backend and any of targets.
+// Only OpenCV backend runs on the OpenCL device selected by index; the other backends always use the default GPU.
+func checkGPU(index, backend int, targets []int) error {
+	if index <= 0 || backend == backendNames["OPENCV"] {
+		return nil
+	}
+
+	for _, t := range targets {
+		if isGPUTarget(t) {
+			b := backend
+			return fmt.Errorf("GPU %d can't be selected for %s backend which runs on the default GPU only; use OPENCV backend",
+				index, (&idFlag{value: &b, names: backendNames}).String())
+		}
+	}
+
+	return nil
+}
+
+// selectGPU selects GPU with index to run OpenCL inference of backend on.
+// OpenCV picks the OpenCL device when it first initializes OpenCL, so it must be called before any model is loaded.
+// Device selected by OPENCV_OPENCL_DEVICE environment variable takes precedence.
+func selectGPU(index, backend int) error {
+	if err := checkGPU(index, backend, []int{deviceNames["GPU"]}); err != nil {
+		return err
+	}
+	if backend != backendNames["OPENCV"] {
+		return nil
+	}
+
+	if count := gpuCount(); index >= count {
+		return fmt.Errorf("GPU %d not found, %d GPUs available", index, count)
+	}
+
+	if os.Getenv("OPENCV_OPENCL_DEVICE") != "" {
+		return nil
+	}
+
+	return os.Setenv("OPENCV_OPENCL_DEVICE", fmt.Sprintf(":GPU:%d", index))
+}
+
+// testTarget returns error if face detection model can't be loaded on backend and target
+// or its test forward pass fails
+func testTarget(model, config string, backend, target int) error {
+	m, err := NewModel(model, config, backend, target, -1)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return probe(netFace, m).run()
+}
+
+// devicesCommand implements devices subcommand which prints inference devices detected in the system and,
+// if face detection model is given, tests which inference targets the runtime can run the model on
+func devicesCommand(args []string) error {
+	fs := flag.NewFlagSet("devices", flag.ExitOnError)
+	model := fs.String("face-model", "", "Path to .bin file of face detection model to test the inference targets with")
+	config := fs.String("face-config", "", "Path to .xml file of face model configuration")
+	var backend int
+	fs.Var(&idFlag{value: &backend, names: backendNames}, "backend", "Inference backend to test. DEFAULT, HALIDE, OPENVINO or OPENCV")
+	fs.Parse(args)
+
+	devices := detectDevices()
+	sort.Strings(devices)
+
+	fmt.Printf("Inference devices detected by their device nodes (heuristic):\n")
+	for _, d := range devices {
+		fmt.Printf("  %s\n", d)
+	}
+	if count := gpuCount(); count > 1 {
+		fmt.Printf("%d GPUs detected, select one by -gpu 0 to %d\n", count, count-1)
+	}
+
+	if *model == "" {
+		fmt.Printf("Pass -face-model and -face-config to test which inference targets the runtime supports\n")
+		return nil
+	}
+
+	supported := make(map[int]bool)
+	fmt.Printf("Inference targets of backend %s:\n", (&idFlag{value: &backend, names: backendNames}).String())
+	for _, name := range targetOrder {
+		t := deviceNames[name]
+		if err := testTarget(*model, *config, backend, t); err != nil {
+			fmt.Printf("  %-10s (target %d) not supported: %v\n", name, t, err)
+			continue
+		}
+		supported[t] = true
+		fmt.Printf("  %-10s (target %d) supported\n", name, t)
+	}
+
+	for _, t := range autoTargets() {
+		if supported[t] {
+			fmt.Printf("%s selects target %d\n", autoDevice, t)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("No inference target can run the model")
+}
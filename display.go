@@ -0,0 +1,106 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// windowPropertyTopmost is OpenCV WND_PROP_TOPMOST window property, which is not exported by gocv
+	windowPropertyTopmost gocv.WindowPropertyFlag = 5
+)
+
+var (
+	// displaySize is size of the display window; zero size keeps the default window size
+	displaySize image.Point
+	// displayPos is position of the display window on the screen; nil leaves it to the window manager
+	displayPos *image.Point
+)
+
+// parseWindowSize parses display window size from s in "widthxheight" format; empty s means the default size
+func parseWindowSize(s string) (image.Point, error) {
+	if s == "" {
+		return image.Point{}, nil
+	}
+
+	var w, h int
+	if n, err := fmt.Sscanf(s, "%dx%d", &w, &h); err != nil || n != 2 || w <= 0 || h <= 0 {
+		return image.Point{}, fmt.Errorf("Invalid window size: %s", s)
+	}
+
+	return image.Pt(w, h), nil
+}
+
+// parseWindowPosition parses display window position from s in "x,y" format; empty s means no position
+func parseWindowPosition(s string) (*image.Point, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var x, y int
+	if n, err := fmt.Sscanf(s, "%d,%d", &x, &y); err != nil || n != 2 {
+		return nil, fmt.Errorf("Invalid window position: %s", s)
+	}
+
+	p := image.Pt(x, y)
+	return &p, nil
+}
+
+// openWindow opens display window titled title with the size, position, fullscreen and always-on-top
+// behavior requested on the command line and returns it
+func openWindow(title string) *gocv.Window {
+	window := gocv.NewWindow(title)
+	if displaySize == (image.Point{}) && displayPos == nil {
+		window.SetWindowProperty(gocv.WindowPropertyFullscreen, gocv.WindowAutosize)
+	}
+	if fullscreen || displaySize != (image.Point{}) || displayPos != nil {
+		setFullscreen(window, fullscreen)
+	}
+	if windowTopmost {
+		window.SetWindowProperty(windowPropertyTopmost, 1)
+	}
+
+	return window
+}
+
+// setFullscreen switches display window to fullscreen if on is true or to the window size and position
+// requested on the command line otherwise
+func setFullscreen(window *gocv.Window, on bool) {
+	if on {
+		window.SetWindowProperty(gocv.WindowPropertyFullscreen, gocv.WindowFullscreen)
+		return
+	}
+
+	window.SetWindowProperty(gocv.WindowPropertyFullscreen, gocv.WindowNormal)
+	if displaySize != (image.Point{}) {
+		window.ResizeWindow(displaySize.X, displaySize.Y)
+	}
+	if displayPos != nil {
+		window.MoveWindow(displayPos.X, displayPos.Y)
+	}
+}
@@ -0,0 +1,185 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"image"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// Eyes stores eye state of a face
+type Eyes struct {
+	// LeftOpen means the left eye is open
+	LeftOpen bool
+	// RightOpen means the right eye is open
+	RightOpen bool
+}
+
+// Closed returns true if both eyes are closed
+func (e *Eyes) Closed() bool {
+	return !e.LeftOpen && !e.RightOpen
+}
+
+// detectEyes detects whether the eyes of faces in img are open and returns their state.
+// It requires facial landmarks of the faces to be detected.
+// Eyes of the faces whose eyes are not completely inside img are nil.
+// The net is expected to be compatible with open-closed-eye-0001 model.
+func detectEyes(net Model, img *gocv.Mat, faces []*Face) []*Eyes {
+	eyes := make([]*Eyes, len(faces))
+	bounds := image.Rect(0, 0, img.Cols(), img.Rows())
+
+	// crops stores left and right eye of every face next to each other
+	var crops []gocv.Mat
+	var idx []int
+	for i := range faces {
+		lm := faces[i].Landmarks
+		if lm == nil {
+			continue
+		}
+
+		l, r := eyeRect(lm.LeftEye, lm), eyeRect(lm.RightEye, lm)
+		if !l.In(bounds) || !r.In(bounds) {
+			continue
+		}
+
+		for _, rect := range []image.Rectangle{l, r} {
+			region := img.Region(rect)
+			crop := gocv.NewMat()
+			region.CopyTo(&crop)
+			region.Close()
+			crops = append(crops, crop)
+		}
+		idx = append(idx, i)
+	}
+
+	if len(idx) == 0 {
+		return eyes
+	}
+
+	// close Mats
+	defer func() {
+		for i := range crops {
+			crops[i].Close()
+		}
+	}()
+
+	blob := gocv.NewMat()
+	defer blob.Close()
+	gocv.BlobFromImages(crops, &blob, 1.0, image.Pt(32, 32),
+		gocv.NewScalar(0, 0, 0, 0), false, false, gocv.MatTypeCV32F)
+
+	// run a forward pass through eye state network
+	net.SetInput(blob, "")
+	out := net.Forward("")
+	defer out.Close()
+
+	// flatten the result from [2N, 2, 1, 1] to [2N, 2]
+	res := out.Reshape(1, len(crops))
+	defer res.Close()
+
+	// open returns true if j-th eye crop is more likely open than closed
+	open := func(j int) bool {
+		return res.GetFloatAt(j, 1) > res.GetFloatAt(j, 0)
+	}
+
+	for j, i := range idx {
+		eyes[i] = &Eyes{
+			LeftOpen:  open(2 * j),
+			RightOpen: open(2*j + 1),
+		}
+	}
+
+	return eyes
+}
+
+// eyeSample records whether operator eyes were closed at a given time
+type eyeSample struct {
+	ts     time.Time
+	closed bool
+}
+
+// PERCLOS measures percentage of time operator eyes are closed over a rolling time window.
+// It measures percentage of time of any other operator condition, such as inattention, the same way.
+type PERCLOS struct {
+	// window is duration of the rolling time window
+	window time.Duration
+	// samples are eye state samples within the window
+	samples []eyeSample
+	// since is time of the first sample since the measurement started
+	since time.Time
+}
+
+// NewPERCLOS creates new PERCLOS measured over window and returns it
+func NewPERCLOS(window time.Duration) *PERCLOS {
+	return &PERCLOS{
+		window: window,
+	}
+}
+
+// Add records eye state at time ts and drops the samples which fell out of the window
+func (p *PERCLOS) Add(ts time.Time, closed bool) {
+	if p.since.IsZero() {
+		p.since = ts
+	}
+	p.samples = append(p.samples, eyeSample{ts: ts, closed: closed})
+
+	var i int
+	for i < len(p.samples) && ts.Sub(p.samples[i].ts) > p.window {
+		i++
+	}
+	p.samples = p.samples[i:]
+}
+
+// Ratio returns ratio of the samples within the window when the eyes were closed
+func (p *PERCLOS) Ratio() float64 {
+	if len(p.samples) == 0 {
+		return 0
+	}
+
+	var closed int
+	for _, s := range p.samples {
+		if s.closed {
+			closed++
+		}
+	}
+
+	return float64(closed) / float64(len(p.samples))
+}
+
+// Full returns true if the measurement has been running for the whole window so the ratio is meaningful
+func (p *PERCLOS) Full() bool {
+	if len(p.samples) == 0 {
+		return false
+	}
+
+	return p.samples[len(p.samples)-1].ts.Sub(p.since) >= p.window
+}
+
+// Reset drops all the samples and starts the measurement over
+func (p *PERCLOS) Reset() {
+	p.samples = nil
+	p.since = time.Time{}
+}
@@ -0,0 +1,362 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/intel-iot-devkit/machine-operator-monitor-go/payload"
+)
+
+// smtpTimeout is time within which an email must be sent to the SMTP server
+const smtpTimeout = 30 * time.Second
+
+// Mailer sends emails through SMTP server
+type Mailer struct {
+	// server is SMTP server address in host:port format
+	server string
+	// host is SMTP server host name
+	host string
+	// auth authenticates to the server; nil if no authentication is required
+	auth smtp.Auth
+	// from is sender address
+	from string
+	// to are recipient addresses
+	to []string
+}
+
+// NewMailer creates new mailer and returns it
+// It reads the following environment variables to configure the mailer:
+// SMTP_SERVER: SMTP server address in host:port format; required parameter
+// SMTP_FROM: sender address; required parameter
+// SMTP_TO: comma separated recipient addresses; required parameter
+// SMTP_USERNAME: SMTP username; not required
+// SMTP_PASSWORD: SMTP password for SMTP_USERNAME; not required
+// It returns error if any of the required parameters is missing.
+func NewMailer() (*Mailer, error) {
+	server := os.Getenv("SMTP_SERVER")
+	from := os.Getenv("SMTP_FROM")
+	to := parseLabels(os.Getenv("SMTP_TO"))
+	username := os.Getenv("SMTP_USERNAME")
+	password := os.Getenv("SMTP_PASSWORD")
+
+	if server == "" {
+		return nil, fmt.Errorf("SMTP server is empty")
+	}
+
+	if from == "" || len(to) == 0 {
+		return nil, fmt.Errorf("SMTP sender or recipients are empty")
+	}
+
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid SMTP server: %v", err)
+	}
+
+	m := &Mailer{
+		server: server,
+		host:   host,
+		from:   from,
+		to:     to,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return m, nil
+}
+
+// Send sends email with subject and body to all the recipients
+func (m *Mailer) Send(subject, body string) error {
+	return m.SendAttachment(subject, body, "", nil)
+}
+
+// SendAttachment sends email with subject, body and JPEG image attached as file name to all the recipients.
+// The image is not attached if it is empty.
+func (m *Mailer) SendAttachment(subject, body, name string, image []byte) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\n",
+		m.from, strings.Join(m.to, ", "), mime.QEncoding.Encode("utf-8", subject), time.Now().Format(time.RFC1123Z))
+
+	if len(image) == 0 {
+		fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n", body)
+		return m.sendMail(msg.Bytes())
+	}
+
+	w := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", w.Boundary())
+
+	part, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(part, "%s\r\n", body)
+
+	part, err = w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"image/jpeg"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", name)},
+	})
+	if err != nil {
+		return err
+	}
+	// wrap base64 encoded image to lines of 76 characters as MIME requires
+	encoded := base64.StdEncoding.EncodeToString(image)
+	for len(encoded) > 76 {
+		fmt.Fprintf(part, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(part, "%s\r\n", encoded)
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return m.sendMail(msg.Bytes())
+}
+
+// sendMail sends msg to all the recipients like smtp.SendMail does, but fails rather than hangs
+// if the SMTP server doesn't complete the exchange within smtpTimeout
+func (m *Mailer) sendMail(msg []byte) error {
+	conn, err := net.DialTimeout("tcp", m.server, smtpTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("SMTP server doesn't support authentication")
+		}
+		if err := c.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	for _, to := range m.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// emailDefaultTemplate is name of the template file used for the alert types without their own template
+const emailDefaultTemplate = "default"
+
+// emailBuiltinTemplate is template of the alert emails used unless a template file overrides it
+var emailBuiltinTemplate = template.Must(template.New(emailDefaultTemplate).Parse(
+	`{{define "subject"}}{{.Name}}: {{.Severity}} {{.Type}} alert {{.State}}{{end}}` +
+		`{{define "body"}}{{.Message}}{{if .Suppressed}}
+
+{{.Suppressed}} earlier notifications of this alert type were suppressed.{{end}}
+
+{{.JSON}}{{end}}`))
+
+// emailData is data the alert email templates are executed with
+type emailData struct {
+	payload.Alert
+	// Name is program name
+	Name string
+	// Message is localized alert message
+	Message string
+	// Suppressed is number of notifications of the alert type suppressed by rate limiting since the last email
+	Suppressed int
+	// JSON is the alert payload as published to MQTT
+	JSON string
+}
+
+// loadEmailTemplates loads alert email templates from directory dir and returns them by alert types.
+// Every <type>.tmpl file in dir must define "subject" and "body" templates of the emails of the alert type;
+// default.tmpl replaces the built-in template of the remaining alert types.
+func loadEmailTemplates(dir string) (map[string]*template.Template, error) {
+	templates := map[string]*template.Template{}
+	if dir == "" {
+		return templates, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		typ := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+		t, err := template.ParseFiles(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range []string{"subject", "body"} {
+			if t.Lookup(name) == nil {
+				return nil, fmt.Errorf("%s does not define %s template", file, name)
+			}
+		}
+		templates[typ] = t
+	}
+
+	return templates, nil
+}
+
+// renderEmail returns subject and body of email notifying about alert a using templates by alert types
+func renderEmail(templates map[string]*template.Template, a Alert, suppressed int) (string, string, error) {
+	t, ok := templates[a.Type]
+	if !ok {
+		t, ok = templates[emailDefaultTemplate]
+	}
+	if !ok {
+		t = emailBuiltinTemplate
+	}
+
+	// the snapshot is attached to the email rather than inlined
+	a.image = ""
+	data := emailData{
+		Alert:      a.ToPayload(),
+		Name:       name,
+		Message:    msg("alert." + a.Type),
+		Suppressed: suppressed,
+		JSON:       a.ToMQTTMessage(),
+	}
+
+	var subject, body bytes.Buffer
+	if err := t.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return "", "", err
+	}
+	if err := t.ExecuteTemplate(&body, "body", data); err != nil {
+		return "", "", err
+	}
+
+	// subject must fit in a single header line
+	return strings.Join(strings.Fields(subject.String()), " "), body.String(), nil
+}
+
+// emailLimiter limits rate of alert emails of every alert type
+type emailLimiter struct {
+	// interval is minimum interval between emails of the same alert type; zero disables the limit
+	interval time.Duration
+	// sent is time when the last email of alert type was sent
+	sent map[string]time.Time
+	// suppressed is number of suppressed notifications of alert type since the last email
+	suppressed map[string]int
+}
+
+// newEmailLimiter creates new email rate limiter sending at most one email of every alert type per interval
+func newEmailLimiter(interval time.Duration) *emailLimiter {
+	return &emailLimiter{
+		interval:   interval,
+		sent:       map[string]time.Time{},
+		suppressed: map[string]int{},
+	}
+}
+
+// allow returns true and number of notifications suppressed since the last email if email of alert type typ
+// can be sent at time now; it returns false and counts the notification as suppressed otherwise
+func (l *emailLimiter) allow(typ string, now time.Time) (bool, int) {
+	if last, ok := l.sent[typ]; ok && now.Sub(last) < l.interval {
+		l.suppressed[typ]++
+		return false, 0
+	}
+
+	suppressed := l.suppressed[typ]
+	l.sent[typ] = now
+	delete(l.suppressed, typ)
+
+	return true, suppressed
+}
+
+// emailRunner emails alert state transitions received from alertsChan and routed to email as they happen
+// using templates by alert types; at most one email of every alert type is sent per interval unless it is zero.
+// Snapshots of raised alerts are attached to the emails if attach is true.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func emailRunner(doneChan <-chan struct{}, alertsChan <-chan Alert, m *Mailer, templates map[string]*template.Template,
+	interval time.Duration, attach bool) error {
+	limiter := newEmailLimiter(interval)
+
+	for {
+		select {
+		case a := <-alertsChan:
+			if !a.routedTo(sinkEmail) || a.Heartbeat {
+				continue
+			}
+			ok, suppressed := limiter.allow(a.Type, time.Now())
+			if !ok {
+				continue
+			}
+			subject, body, err := renderEmail(templates, a, suppressed)
+			if err != nil {
+				fmt.Printf("Error rendering alert %s email: %v\n", a.ID, err)
+				continue
+			}
+			var image []byte
+			if attach {
+				image = readSnapshot(a)
+			}
+			if err := m.SendAttachment(subject, body, a.ID+".jpg", image); err != nil {
+				fmt.Printf("Error emailing alert %s: %v\n", a.ID, err)
+			}
+		case <-doneChan:
+			fmt.Printf("Stopping emailRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
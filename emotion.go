@@ -0,0 +1,152 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emotionPolicy raises alert when operator shows sentiment for too long or too often
+type emotionPolicy struct {
+	// sentiment is sentiment the policy watches
+	sentiment Sentiment
+	// after is time the sentiment must last for to raise the alert; zero if the policy counts episodes
+	after time.Duration
+	// count is number of sentiment episodes within window which raises the alert
+	count int
+	// window is time window the sentiment episodes are counted in
+	window time.Duration
+}
+
+// emotionPolicies are policies of sentiments which raise the emotion alert
+var emotionPolicies []emotionPolicy
+
+// parseEmotionPolicies parses comma separated emotion policies and returns them.
+// Policy sentiment=duration raises alert when the sentiment lasts longer than the duration, e.g. sad=30s;
+// policy sentiment=count/window raises alert when the sentiment starts count times within the window, e.g. surprised=3/1m.
+func parseEmotionPolicies(s string) ([]emotionPolicy, error) {
+	var policies []emotionPolicy
+	for _, entry := range parseLabels(s) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid emotion policy: %s", entry)
+		}
+
+		sent, err := parseSentiment(parts[0])
+		if err != nil || sent == UNKNOWN {
+			return nil, fmt.Errorf("Invalid emotion policy sentiment: %s", parts[0])
+		}
+		p := emotionPolicy{sentiment: sent}
+
+		if i := strings.Index(parts[1], "/"); i >= 0 {
+			p.count, err = strconv.Atoi(parts[1][:i])
+			if err != nil || p.count < 1 {
+				return nil, fmt.Errorf("Invalid emotion policy count: %s", entry)
+			}
+			p.window, err = time.ParseDuration(parts[1][i+1:])
+			if err != nil || p.window <= 0 {
+				return nil, fmt.Errorf("Invalid emotion policy window: %s", entry)
+			}
+		} else {
+			p.after, err = time.ParseDuration(parts[1])
+			if err != nil || p.after <= 0 {
+				return nil, fmt.Errorf("Invalid emotion policy duration: %s", entry)
+			}
+		}
+
+		policies = append(policies, p)
+	}
+
+	return policies, nil
+}
+
+// EmotionMonitor watches sentiments of an operator according to emotion policies
+type EmotionMonitor struct {
+	// votes smooth the sentiment of every policy over several frames
+	votes []*MajorityVote
+	// shown stores whether the sentiment of every policy is shown
+	shown []bool
+	// started stores times when the sentiment of every policy started to show
+	started []time.Time
+	// episodes stores start times of the sentiment episodes of every policy within its window
+	episodes [][]time.Time
+}
+
+// NewEmotionMonitor creates new emotion monitor of emotionPolicies smoothing sentiments over window frames and returns it
+func NewEmotionMonitor(window int) *EmotionMonitor {
+	m := &EmotionMonitor{
+		votes:    make([]*MajorityVote, len(emotionPolicies)),
+		shown:    make([]bool, len(emotionPolicies)),
+		started:  make([]time.Time, len(emotionPolicies)),
+		episodes: make([][]time.Time, len(emotionPolicies)),
+	}
+	for i := range m.votes {
+		m.votes[i] = NewMajorityVote(window)
+	}
+
+	return m
+}
+
+// Update records sentiments of faces detected at time ts and returns true if any of the policies raises alert
+func (m *EmotionMonitor) Update(ts time.Time, faces []*Face) bool {
+	var raised bool
+	for i, p := range emotionPolicies {
+		var detected bool
+		for _, f := range faces {
+			detected = detected || f.Sentiment == p.sentiment
+		}
+		shown := m.votes[i].Add(detected)
+
+		// record the start of a new sentiment episode
+		if shown && !m.shown[i] {
+			m.started[i] = ts
+			m.episodes[i] = append(m.episodes[i], ts)
+		}
+		m.shown[i] = shown
+
+		if p.count > 0 {
+			// drop the episodes which fell out of the window
+			for len(m.episodes[i]) > 0 && ts.Sub(m.episodes[i][0]) > p.window {
+				m.episodes[i] = m.episodes[i][1:]
+			}
+			raised = raised || len(m.episodes[i]) >= p.count
+		} else {
+			m.episodes[i] = nil
+			raised = raised || (shown && ts.Sub(m.started[i]) > p.after)
+		}
+	}
+
+	return raised
+}
+
+// Reset drops all the recorded sentiments
+func (m *EmotionMonitor) Reset() {
+	for i := range m.votes {
+		m.votes[i].Reset()
+		m.shown[i], m.started[i], m.episodes[i] = false, time.Time{}, nil
+	}
+}
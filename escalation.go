@@ -0,0 +1,199 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// sinkDisplay is local display alert sink
+	sinkDisplay = "display"
+	// sinkMQTT is MQTT alert sink
+	sinkMQTT = "mqtt"
+	// sinkEmail is email alert sink
+	sinkEmail = "email"
+	// sinkWebhook is HTTP webhook alert sink
+	sinkWebhook = "webhook"
+	// sinkKafka is Apache Kafka alert sink
+	sinkKafka = "kafka"
+	// sinkAMQP is AMQP broker alert sink
+	sinkAMQP = "amqp"
+	// sinkAWSIoT is AWS IoT Core alert sink
+	sinkAWSIoT = "aws-iot"
+	// sinkAzureIoT is Azure IoT Hub alert sink
+	sinkAzureIoT = "azure-iot"
+	// sinkPubSub is Google Cloud Pub/Sub alert sink
+	sinkPubSub = "pubsub"
+	// sinkSyslog is syslog alert sink
+	sinkSyslog = "syslog"
+	// sinkJournald is systemd journal alert sink
+	sinkJournald = "journald"
+	// sinkSlack is Slack alert sink
+	sinkSlack = "slack"
+	// sinkSMS is SMS alert sink
+	sinkSMS = "sms"
+	// sinkIncident is incident management alert sink
+	sinkIncident = "incident"
+	// sinkPush is push notification alert sink
+	sinkPush = "push"
+)
+
+// escalationStep escalates alert which stays unacknowledged for at least after
+type escalationStep struct {
+	// after is time since the alert was raised
+	after time.Duration
+	// severity is minimum severity of the escalated alert
+	severity string
+	// sinks are sinks the escalated alert is published to
+	sinks []string
+}
+
+// escalationPolicies stores escalation steps of alert types sorted by time since the alert was raised
+var escalationPolicies = map[string][]escalationStep{}
+
+// parseEscalations parses comma separated escalation steps in type=after:severity:sink[+sink...] format, e.g.
+// watching=30s:warning:mqtt,watching=60s:critical:email and returns them by alert types.
+// Every step adds its sinks to the sinks of the previous steps.
+func parseEscalations(s string) (map[string][]escalationStep, error) {
+	policies := make(map[string][]escalationStep)
+	for _, e := range parseLabels(s) {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid escalation step: %s", e)
+		}
+
+		typ := strings.TrimSpace(kv[0])
+		if _, ok := alertTypes(new(Result))[typ]; !ok {
+			return nil, fmt.Errorf("Invalid alert type: %s", typ)
+		}
+
+		parts := strings.Split(kv[1], ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("Invalid escalation step: %s", e)
+		}
+
+		after, err := time.ParseDuration(strings.TrimSpace(parts[0]))
+		if err != nil || after <= 0 {
+			return nil, fmt.Errorf("Invalid escalation time: %s", parts[0])
+		}
+
+		step := escalationStep{after: after, severity: strings.ToLower(strings.TrimSpace(parts[1]))}
+		if severityRank(step.severity) < 0 {
+			return nil, fmt.Errorf("Invalid severity: %s", parts[1])
+		}
+
+		for _, sink := range strings.Split(parts[2], "+") {
+			switch sink = strings.TrimSpace(sink); sink {
+			case sinkDisplay, sinkMQTT, sinkEmail, sinkWebhook, sinkKafka, sinkAMQP, sinkAWSIoT, sinkAzureIoT, sinkPubSub, sinkSyslog, sinkJournald, sinkSlack, sinkSMS, sinkIncident, sinkPush:
+				step.sinks = append(step.sinks, sink)
+			default:
+				return nil, fmt.Errorf("Invalid alert sink: %s", sink)
+			}
+		}
+
+		policies[typ] = append(policies[typ], step)
+	}
+
+	for typ := range policies {
+		p := policies[typ]
+		sort.Slice(p, func(i, j int) bool { return p[i].after < p[j].after })
+	}
+
+	return policies, nil
+}
+
+// escalationSinks returns sinks used by any escalation step
+func escalationSinks() map[string]bool {
+	sinks := make(map[string]bool)
+	for _, steps := range escalationPolicies {
+		for _, step := range steps {
+			for _, sink := range step.sinks {
+				sinks[sink] = true
+			}
+		}
+	}
+
+	return sinks
+}
+
+// initialSinks returns sinks new alert of type typ is published to.
+// Alerts with escalation policy are only displayed until they escalate; nil means the default sinks.
+func initialSinks(typ string) []string {
+	if len(escalationPolicies[typ]) == 0 {
+		return nil
+	}
+
+	return []string{sinkDisplay}
+}
+
+// escalate applies the escalation steps alert a reached at time ts and returns true if the alert escalated
+func escalate(a *Alert, ts time.Time) bool {
+	steps := escalationPolicies[a.Type]
+
+	var escalated bool
+	for a.Escalation < len(steps) && ts.Sub(a.Raised) >= steps[a.Escalation].after {
+		for _, sink := range steps[a.Escalation].sinks {
+			if !a.routedTo(sink) {
+				a.Sinks = append(a.Sinks, sink)
+			}
+		}
+		a.Escalation++
+		escalated = true
+	}
+
+	return escalated
+}
+
+// escalatedSeverity returns severity s raised to the severity of escalation level of alert of type typ
+func escalatedSeverity(typ string, level int, s string) string {
+	if level == 0 {
+		return s
+	}
+
+	if e := escalationPolicies[typ][level-1].severity; severityRank(e) > severityRank(s) {
+		return e
+	}
+
+	return s
+}
+
+// routedTo returns true if alert a is published to sink.
+// Alerts without sinks are published to all the sinks but email unless all alerts are emailed.
+func (a Alert) routedTo(sink string) bool {
+	if a.Sinks == nil {
+		return sink != sinkEmail || emailAll
+	}
+
+	for _, s := range a.Sinks {
+		if s == sink {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,169 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// panelTopLeft places the face panel in the top left corner of the frame
+	panelTopLeft = "top-left"
+	// panelTopRight places the face panel in the top right corner of the frame
+	panelTopRight = "top-right"
+	// panelBottomLeft places the face panel in the bottom left corner of the frame
+	panelBottomLeft = "bottom-left"
+	// panelBottomRight places the face panel in the bottom right corner of the frame
+	panelBottomRight = "bottom-right"
+	// panelMargin is distance of the face panel from the frame edges in pixels
+	panelMargin = 10
+)
+
+// facePanel is enlarged crop of the primary operator face shown in a corner of the frame with its readouts
+type facePanel struct {
+	// crop is the face crop taken before anything was drawn into the frame
+	crop gocv.Mat
+	// face is the cropped face
+	face *Face
+}
+
+// primaryFace returns face of the operator at the machine, i.e. the largest face of status s; nil if there is none
+func primaryFace(s *Status) *Face {
+	if s == nil {
+		return nil
+	}
+
+	var primary *Face
+	var area int
+	for _, f := range s.Faces {
+		if a := f.Rect.Dx() * f.Rect.Dy(); a > area {
+			primary, area = f, a
+		}
+	}
+
+	return primary
+}
+
+// newFacePanel crops the primary operator face of status s with some margin around it from img and returns the
+// face panel; nil if there is no face to crop
+func newFacePanel(img gocv.Mat, s *Status) *facePanel {
+	f := primaryFace(s)
+	if f == nil {
+		return nil
+	}
+
+	// square crop centered in the face with margin for the hair and chin
+	c := f.Rect.Min.Add(f.Rect.Max).Div(2)
+	side := f.Rect.Dx()
+	if f.Rect.Dy() > side {
+		side = f.Rect.Dy()
+	}
+	side = side * 7 / 5
+	rect := image.Rect(c.X-side/2, c.Y-side/2, c.X+side/2, c.Y+side/2).Intersect(image.Rect(0, 0, img.Cols(), img.Rows()))
+	if rect.Empty() {
+		return nil
+	}
+
+	region := img.Region(rect)
+	defer region.Close()
+
+	return &facePanel{crop: region.Clone(), face: f}
+}
+
+// Draw draws the face panel into corner of img, the crop scaled to size pixels wide
+// with the sentiment and head pose readouts of the face below it
+func (p *facePanel) Draw(img *gocv.Mat, corner string, size int) {
+	// the panel must leave most of the frame visible
+	if size > img.Cols()/3 {
+		size = img.Cols() / 3
+	}
+	height := size * p.crop.Rows() / p.crop.Cols()
+	if height > img.Rows()/2 {
+		height = img.Rows() / 2
+	}
+	if size <= 0 || height <= 0 {
+		return
+	}
+
+	lines := p.readouts()
+	lineHeight := int(30*overlayFontScale) + 2*overlayThickness
+	w, h := size, height+len(lines)*lineHeight+6
+
+	var pt image.Point
+	switch corner {
+	case panelTopLeft:
+		pt = image.Point{panelMargin, panelMargin}
+	case panelTopRight:
+		pt = image.Point{img.Cols() - w - panelMargin, panelMargin}
+	case panelBottomLeft:
+		pt = image.Point{panelMargin, img.Rows() - h - panelMargin}
+	default:
+		pt = image.Point{img.Cols() - w - panelMargin, img.Rows() - h - panelMargin}
+	}
+	panel := image.Rectangle{pt, pt.Add(image.Point{w, h})}.Intersect(image.Rect(0, 0, img.Cols(), img.Rows()))
+	if panel.Dx() != w || panel.Dy() != h {
+		return
+	}
+
+	scaled := gocv.NewMat()
+	defer scaled.Close()
+	gocv.Resize(p.crop, &scaled, image.Point{w, height}, 0, 0, gocv.InterpolationLinear)
+
+	gocv.Rectangle(img, panel, color.RGBA{0, 0, 0, 0}, -1)
+	dst := img.Region(image.Rectangle{pt, pt.Add(image.Point{w, height})})
+	scaled.CopyTo(&dst)
+	dst.Close()
+	gocv.Rectangle(img, panel, faceColor(p.face), 2)
+
+	for i, line := range lines {
+		putText(img, line, image.Point{pt.X + 6, pt.Y + height + (i+1)*lineHeight}, 0.8, faceColor(p.face))
+	}
+}
+
+// readouts returns the sentiment and head pose readouts of the panel face
+func (p *facePanel) readouts() []string {
+	var lines []string
+	if p.face.Sentiment != UNKNOWN {
+		label := msg("sentiment." + strings.ToLower(p.face.Sentiment.String()))
+		if c := sentimentConfidence(p.face); c > 0 {
+			label = fmt.Sprintf("%s %.0f%%", label, c*100)
+		}
+		lines = append(lines, label)
+	}
+	if p.face.PoseQuality > poseConfidence {
+		lines = append(lines, fmt.Sprintf("yaw %.0f pitch %.0f roll %.0f", p.face.Yaw, p.face.Pitch, p.face.Roll))
+	}
+
+	return lines
+}
+
+// Close closes the face crop
+func (p *facePanel) Close() {
+	p.crop.Close()
+}
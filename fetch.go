@@ -0,0 +1,123 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// modelZooURL is base URL of Open Model Zoo model downloads
+	modelZooURL = "https://storage.openvinotoolkit.org/repositories/open_model_zoo/2022.1/models_bin/3"
+)
+
+// defaultModels are Open Model Zoo models required to run the monitor
+var defaultModels = []string{
+	"face-detection-adas-0001",
+	"emotions-recognition-retail-0003",
+	"head-pose-estimation-adas-0001",
+}
+
+// fetchModels implements fetch-models subcommand which downloads Open Model Zoo models listed in args
+// and caches them in a local directory. Models which are already cached are not downloaded again.
+func fetchModels(args []string) error {
+	fs := flag.NewFlagSet("fetch-models", flag.ExitOnError)
+	dir := fs.String("dir", "models", "Path to directory where the models are cached")
+	precision := fs.String("precision", "FP32", "Precision of the models")
+	url := fs.String("url", modelZooURL, "Base URL of Open Model Zoo model downloads")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s fetch-models [options] [model names]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	models := fs.Args()
+	if len(models) == 0 {
+		models = defaultModels
+	}
+
+	if !isPrecision(*precision) {
+		return fmt.Errorf("Invalid precision: %s", *precision)
+	}
+	*precision = strings.ToUpper(*precision)
+
+	for _, m := range models {
+		for _, ext := range []string{".xml", ".bin"} {
+			path := filepath.Join(*dir, m, *precision, m+ext)
+			if _, err := os.Stat(path); err == nil {
+				fmt.Printf("Using cached %s\n", path)
+				continue
+			}
+
+			fmt.Printf("Downloading %s\n", path)
+			if err := download(fmt.Sprintf("%s/%s/%s/%s%s", *url, m, *precision, m, ext), path); err != nil {
+				return fmt.Errorf("Error downloading %s: %v", m, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// download downloads file from url and stores it at path.
+// The file is downloaded to a temporary file first so that interrupted downloads don't end up in the cache.
+func download(url, path string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), ".download")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,135 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// Gaze is normalized gaze direction vector in camera coordinates.
+// X points to the right, Y points up and Z points from the camera towards the operator.
+type Gaze struct {
+	X float64
+	Y float64
+	Z float64
+}
+
+// Angle returns angle in degrees between the gaze and direction given by yaw and pitch angles in degrees.
+// Zero yaw and pitch direction points straight at the camera.
+func (g *Gaze) Angle(yaw, pitch float64) float64 {
+	y, p := yaw*math.Pi/180, pitch*math.Pi/180
+	// direction from the operator towards the target
+	tx, ty, tz := math.Sin(y)*math.Cos(p), math.Sin(p), -math.Cos(y)*math.Cos(p)
+
+	dot := g.X*tx + g.Y*ty + g.Z*tz
+	norm := math.Sqrt(g.X*g.X + g.Y*g.Y + g.Z*g.Z)
+	if norm == 0 {
+		return 180
+	}
+
+	return math.Acos(math.Max(-1, math.Min(1, dot/norm))) * 180 / math.Pi
+}
+
+// detectGaze estimates gaze of faces in img and returns it.
+// It requires facial landmarks and head pose angles of the faces to be detected.
+// Gaze of the faces whose eyes are not completely inside img is nil.
+// The net is expected to be compatible with gaze-estimation-adas-0002 model.
+func detectGaze(net Model, img *gocv.Mat, faces []*Face) []*Gaze {
+	gaze := make([]*Gaze, len(faces))
+	bounds := image.Rect(0, 0, img.Cols(), img.Rows())
+
+	var left, right []gocv.Mat
+	var idx []int
+	for i := range faces {
+		lm := faces[i].Landmarks
+		if lm == nil {
+			continue
+		}
+
+		l, r := eyeRect(lm.LeftEye, lm), eyeRect(lm.RightEye, lm)
+		if !l.In(bounds) || !r.In(bounds) {
+			continue
+		}
+
+		lr, rr := img.Region(l), img.Region(r)
+		lc, rc := gocv.NewMat(), gocv.NewMat()
+		lr.CopyTo(&lc)
+		rr.CopyTo(&rc)
+		lr.Close()
+		rr.Close()
+
+		left, right = append(left, lc), append(right, rc)
+		idx = append(idx, i)
+	}
+
+	if len(idx) == 0 {
+		return gaze
+	}
+
+	// close Mats
+	defer func() {
+		for i := range idx {
+			left[i].Close()
+			right[i].Close()
+		}
+	}()
+
+	leftBlob, rightBlob := gocv.NewMat(), gocv.NewMat()
+	defer leftBlob.Close()
+	defer rightBlob.Close()
+	gocv.BlobFromImages(left, &leftBlob, 1.0, image.Pt(60, 60),
+		gocv.NewScalar(0, 0, 0, 0), false, false, gocv.MatTypeCV32F)
+	gocv.BlobFromImages(right, &rightBlob, 1.0, image.Pt(60, 60),
+		gocv.NewScalar(0, 0, 0, 0), false, false, gocv.MatTypeCV32F)
+
+	// head pose angles are passed in as [N, 3] matrix
+	angles := gocv.NewMatWithSize(len(idx), 3, gocv.MatTypeCV32F)
+	defer angles.Close()
+	for j, i := range idx {
+		angles.SetFloatAt(j, 0, float32(faces[i].Yaw))
+		angles.SetFloatAt(j, 1, float32(faces[i].Pitch))
+		angles.SetFloatAt(j, 2, float32(faces[i].Roll))
+	}
+
+	// run a forward pass through gaze network
+	net.SetInput(leftBlob, "left_eye_image")
+	net.SetInput(rightBlob, "right_eye_image")
+	net.SetInput(angles, "head_pose_angles")
+	res := net.Forward("")
+	defer res.Close()
+
+	for j, i := range idx {
+		// the network Z axis points from the eyes towards the camera
+		gaze[i] = &Gaze{
+			X: float64(res.GetFloatAt(j, 0)),
+			Y: float64(res.GetFloatAt(j, 1)),
+			Z: -float64(res.GetFloatAt(j, 2)),
+		}
+	}
+
+	return gaze
+}
@@ -0,0 +1,224 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// gpioRoot is sysfs GPIO interface directory
+var gpioRoot = "/sys/class/gpio"
+
+// GPIO drives output pin through Linux sysfs GPIO interface
+type GPIO struct {
+	// pin is GPIO pin number
+	pin int
+	// value is pin value file
+	value *os.File
+}
+
+// NewGPIO exports GPIO pin, configures it as output and returns it deasserted.
+// Active low pins are asserted by driving them low.
+func NewGPIO(pin int, activeLow bool) (*GPIO, error) {
+	dir := filepath.Join(gpioRoot, fmt.Sprintf("gpio%d", pin))
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := ioutil.WriteFile(filepath.Join(gpioRoot, "export"), []byte(strconv.Itoa(pin)), 0200); err != nil {
+			return nil, err
+		}
+	}
+
+	// udev may take a while to grant access to the exported pin
+	activeLowVal := "0"
+	if activeLow {
+		activeLowVal = "1"
+	}
+	var err error
+	for i := 0; i < 10; i++ {
+		if err = ioutil.WriteFile(filepath.Join(dir, "active_low"), []byte(activeLowVal), 0644); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "direction"), []byte("out"), 0644); err != nil {
+		return nil, err
+	}
+
+	value, err := os.OpenFile(filepath.Join(dir, "value"), os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &GPIO{pin: pin, value: value}
+	if err := g.Set(false); err != nil {
+		value.Close()
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Set asserts the pin if on is true and deasserts it otherwise
+func (g *GPIO) Set(on bool) error {
+	v := "0"
+	if on {
+		v = "1"
+	}
+
+	_, err := g.value.WriteAt([]byte(v), 0)
+	return err
+}
+
+// Close deasserts the pin and unexports it
+func (g *GPIO) Close() error {
+	if err := g.Set(false); err != nil {
+		g.value.Close()
+		return err
+	}
+	g.value.Close()
+
+	return ioutil.WriteFile(filepath.Join(gpioRoot, "unexport"), []byte(strconv.Itoa(g.pin)), 0200)
+}
+
+// activeAlerts tracks alerts which are raised or acknowledged from their state transitions
+type activeAlerts map[string]Alert
+
+// update records alert state transition
+func (a activeAlerts) update(alert Alert) {
+	switch alert.State {
+	case alertRaised, alertAcknowledged:
+		a[alert.ID] = alert
+	default:
+		delete(a, alert.ID)
+	}
+}
+
+// any returns true if any active alert is of one of types; empty types match all alerts
+func (a activeAlerts) any(types []string) bool {
+	for _, alert := range a {
+		if len(types) == 0 {
+			return true
+		}
+		for _, typ := range types {
+			if alert.Type == typ {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// atLeast returns true if any active alert has at least severity s
+func (a activeAlerts) atLeast(s string) bool {
+	for _, alert := range a {
+		if severityRank(alert.Severity) >= severityRank(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AlertLatch coalesces alert state transitions for the interlock sinks, which act on the set of active alerts.
+// It keeps only the latest transition of every alert, so that the sinks never miss an alert being raised or cleared
+// however far they fall behind.
+type AlertLatch struct {
+	mu sync.Mutex
+	// pending stores the latest transition of every alert since the latch was last applied
+	pending map[string]Alert
+	// C receives a signal when there are pending transitions
+	C chan struct{}
+}
+
+// NewAlertLatch creates new alert latch and returns it
+func NewAlertLatch() *AlertLatch {
+	return &AlertLatch{
+		pending: make(map[string]Alert),
+		C:       make(chan struct{}, 1),
+	}
+}
+
+// Put records alert state transitions and signals C without blocking
+func (l *AlertLatch) Put(alerts []Alert) {
+	if len(alerts) == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	for _, a := range alerts {
+		l.pending[a.ID] = a
+	}
+	l.mu.Unlock()
+
+	select {
+	case l.C <- struct{}{}:
+	default:
+	}
+}
+
+// Apply updates active with the pending transitions and clears them
+func (l *AlertLatch) Apply(active activeAlerts) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, a := range l.pending {
+		active.update(a)
+	}
+	l.pending = make(map[string]Alert)
+}
+
+// gpioRunner asserts the GPIO pin while any of alerts of types received from latch is active.
+// The pin is deasserted and released when the runner stops.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func gpioRunner(doneChan <-chan struct{}, latch *AlertLatch, g *GPIO, types []string) error {
+	defer g.Close()
+
+	active := make(activeAlerts)
+	var asserted bool
+	for {
+		select {
+		case <-latch.C:
+			latch.Apply(active)
+			if on := active.any(types); on != asserted {
+				if err := g.Set(on); err != nil {
+					return fmt.Errorf("Error setting GPIO pin %d: %v", g.pin, err)
+				}
+				asserted = on
+			}
+		case <-doneChan:
+			fmt.Printf("Stopping gpioRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
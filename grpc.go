@@ -0,0 +1,221 @@
+//go:build grpc
+// +build grpc
+
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"net"
+	"sync"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/intel-iot-devkit/machine-operator-monitor-go/monitorpb"
+	"github.com/intel-iot-devkit/machine-operator-monitor-go/payload"
+	"google.golang.org/grpc"
+)
+
+// grpcStreamBuffer is number of messages buffered for every client; slow clients miss the messages which don't fit
+const grpcStreamBuffer = 16
+
+// grpcServer implements monitorpb.MonitorServer
+type grpcServer struct {
+	// mu protects the subscriptions
+	mu sync.Mutex
+	// status are channels of clients streaming operator status
+	status map[chan *monitorpb.Status]struct{}
+	// perf are channels of clients streaming inference performance
+	perf map[chan *monitorpb.Perf]struct{}
+	// listener accepts client connections
+	listener net.Listener
+	// server serves the clients
+	server *grpc.Server
+}
+
+// newGRPCServer creates new gRPC server listening on addr and returns it
+func newGRPCServer(addr string) (GRPCServer, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &grpcServer{
+		status:   make(map[chan *monitorpb.Status]struct{}),
+		perf:     make(map[chan *monitorpb.Perf]struct{}),
+		listener: l,
+		server:   grpc.NewServer(),
+	}
+	monitorpb.RegisterMonitorServer(s.server, s)
+
+	return s, nil
+}
+
+// Serve accepts client connections until the server is stopped
+func (s *grpcServer) Serve() error {
+	return s.server.Serve(s.listener)
+}
+
+// Stop closes the listener and ends all the streams
+func (s *grpcServer) Stop() {
+	s.server.Stop()
+}
+
+// toHeader turns payload header into protobuf header
+func toHeader(h payload.Header) *monitorpb.Header {
+	ts, err := ptypes.TimestampProto(h.Time)
+	if err != nil {
+		ts = ptypes.TimestampNow()
+	}
+
+	return &monitorpb.Header{
+		Schema:    int32(h.Schema),
+		Time:      ts,
+		MachineId: h.MachineID,
+		CameraId:  h.CameraID,
+	}
+}
+
+// toStatus turns operator status of result into protobuf status
+func toStatus(result *Result) *monitorpb.Status {
+	p := result.ToPayload()
+	status := &monitorpb.Status{
+		Header:       toHeader(p.Header),
+		Operator:     p.Operator,
+		Watching:     p.Watching,
+		Angry:        p.Angry,
+		Distance:     p.Distance,
+		Paused:       p.Paused,
+		Suspended:    p.Suspended,
+		Alerts:       p.Alerts,
+		Severities:   p.Severities,
+		Acknowledged: p.Acknowledged,
+		Sentiment:    p.Sentiment,
+	}
+	if p.Phone != nil {
+		status.Phone = &wrappers.BoolValue{Value: *p.Phone}
+	}
+	if p.Degradation != nil {
+		status.Degradation = &wrappers.Int32Value{Value: int32(*p.Degradation)}
+	}
+	if p.Risk != nil {
+		status.Risk = &wrappers.DoubleValue{Value: *p.Risk}
+	}
+
+	return status
+}
+
+// toPerf turns inference performance of result into protobuf performance
+func toPerf(result *Result) *monitorpb.Perf {
+	p := result.ToPerfPayload()
+
+	return &monitorpb.Perf{
+		Header:   toHeader(p.Header),
+		FaceNet:  p.FaceNet,
+		SentNet:  p.SentNet,
+		PoseNet:  p.PoseNet,
+		Nets:     p.Nets,
+		Fps:      p.FPS,
+		InputFps: p.InputFPS,
+		Frames:   p.Frames,
+		Dropped:  p.Dropped,
+	}
+}
+
+// Broadcast sends operator status and inference performance of result to all the streaming clients
+func (s *grpcServer) Broadcast(result *Result) {
+	// results are updated in place, so they are converted before they are handed over to the streams
+	status := toStatus(result)
+	var perf *monitorpb.Perf
+	if result.hasPerf() {
+		perf = toPerf(result)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.status {
+		select {
+		case c <- status:
+		default:
+		}
+	}
+	if perf == nil {
+		return
+	}
+	for c := range s.perf {
+		select {
+		case c <- perf:
+		default:
+		}
+	}
+}
+
+// StreamStatus streams operator status of every processed frame until the client cancels the stream
+func (s *grpcServer) StreamStatus(req *monitorpb.StreamRequest, stream monitorpb.Monitor_StreamStatusServer) error {
+	c := make(chan *monitorpb.Status, grpcStreamBuffer)
+	s.mu.Lock()
+	s.status[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.status, c)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case status := <-c:
+			if err := stream.Send(status); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// StreamPerf streams inference performance of every processed frame until the client cancels the stream
+func (s *grpcServer) StreamPerf(req *monitorpb.StreamRequest, stream monitorpb.Monitor_StreamPerfServer) error {
+	c := make(chan *monitorpb.Perf, grpcStreamBuffer)
+	s.mu.Lock()
+	s.perf[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.perf, c)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case perf := <-c:
+			if err := stream.Send(perf); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
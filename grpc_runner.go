@@ -0,0 +1,64 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import "fmt"
+
+// GRPCServer streams operator status and inference performance to gRPC clients
+type GRPCServer interface {
+	// Serve accepts client connections until the server is stopped
+	Serve() error
+	// Broadcast sends operator status and inference performance of result to all the streaming clients
+	Broadcast(result *Result)
+	// Stop closes the listener and ends all the streams
+	Stop()
+}
+
+// grpcRunner broadcasts operator status received from statusChan to the clients streaming from s.
+// It returns error if the server fails to serve the clients.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func grpcRunner(doneChan <-chan struct{}, statusChan <-chan *Result, s GRPCServer) error {
+	serveChan := make(chan error, 1)
+	go func() {
+		serveChan <- s.Serve()
+	}()
+
+	for {
+		select {
+		case result, ok := <-statusChan:
+			if !ok {
+				// frameRunner stopped; keep the streams open until we are told to stop
+				statusChan = nil
+				continue
+			}
+			s.Broadcast(result)
+		case err := <-serveChan:
+			return fmt.Errorf("gRPC server: %v", err)
+		case <-doneChan:
+			fmt.Printf("Stopping grpcRunner: received stop signal\n")
+			s.Stop()
+			return nil
+		}
+	}
+}
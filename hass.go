@@ -0,0 +1,160 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+)
+
+// hassInvalidID matches characters Home Assistant does not allow in discovery node and object IDs
+var hassInvalidID = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// hassEntity is Home Assistant entity announced by MQTT discovery
+type hassEntity struct {
+	// component is Home Assistant component of the entity, e.g. sensor or binary_sensor
+	component string
+	// object is ID of the entity within the monitor
+	object string
+	// config is discovery config of the entity without the topics and the device
+	config map[string]interface{}
+}
+
+// hassNodeID returns discovery node ID of the monitor; it is machine ID unless it is not set
+func hassNodeID() string {
+	id := machineID
+	if id == "" {
+		id = os.Getenv("MQTT_CLIENT_ID")
+	}
+
+	return hassInvalidID.ReplaceAllString(id, "_")
+}
+
+// hassEntities returns Home Assistant entities of the operator status and of all the alert types
+func hassEntities() []hassEntity {
+	entities := []hassEntity{
+		{"binary_sensor", "watching", map[string]interface{}{
+			"name":           "Operator watching",
+			"icon":           "mdi:eye",
+			"value_template": "{{ 'ON' if value_json.watching else 'OFF' }}",
+		}},
+		{"binary_sensor", "angry", map[string]interface{}{
+			"name":           "Operator angry",
+			"icon":           "mdi:emoticon-angry",
+			"value_template": "{{ 'ON' if value_json.angry else 'OFF' }}",
+		}},
+		{"sensor", "sentiment", map[string]interface{}{
+			"name":           "Operator sentiment",
+			"icon":           "mdi:emoticon-neutral",
+			"value_template": "{{ value_json.sentiment | default('unknown') }}",
+		}},
+		{"sensor", "operator", map[string]interface{}{
+			"name":           "Operator",
+			"icon":           "mdi:account-hard-hat",
+			"value_template": "{{ value_json.operator | default('unknown') }}",
+		}},
+		{"binary_sensor", "paused", map[string]interface{}{
+			"name":           "Monitoring paused",
+			"icon":           "mdi:pause-circle",
+			"value_template": "{{ 'ON' if value_json.get('paused') or value_json.get('suspended') else 'OFF' }}",
+		}},
+	}
+
+	var types []string
+	for typ := range alertFlags(&Result{}) {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		entities = append(entities, hassEntity{"binary_sensor", "alert_" + hassInvalidID.ReplaceAllString(typ, "_"), map[string]interface{}{
+			"name":           fmt.Sprintf("%s alert", typ),
+			"device_class":   "problem",
+			"value_template": fmt.Sprintf("{{ 'ON' if value_json.alerts[%q] else 'OFF' }}", typ),
+		}})
+	}
+
+	return entities
+}
+
+// hassDiscovery returns retained discovery messages announcing the entities by their config topics under prefix
+func hassDiscovery(prefix string) map[string]string {
+	node := hassNodeID()
+	device := map[string]interface{}{
+		"identifiers":  []string{node},
+		"name":         node,
+		"manufacturer": "Intel",
+		"model":        name,
+	}
+
+	messages := make(map[string]string)
+	for _, e := range hassEntities() {
+		config := map[string]interface{}{
+			"unique_id":             node + "_" + e.object,
+			"object_id":             node + "_" + e.object,
+			"state_topic":           resultsTopic,
+			"availability_topic":    connectionTopic,
+			"availability_template": "{{ value_json.status }}",
+			"payload_available":     "online",
+			"payload_not_available": "offline",
+			"device":                device,
+		}
+		for k, v := range e.config {
+			config[k] = v
+		}
+
+		msg, err := json.Marshal(config)
+		if err != nil {
+			continue
+		}
+		messages[fmt.Sprintf("%s/%s/%s/%s/config", prefix, e.component, node, e.object)] = string(msg)
+	}
+
+	return messages
+}
+
+// publishHassDiscovery publishes retained Home Assistant discovery messages under prefix using client c
+func publishHassDiscovery(c *MQTTClient, prefix string) {
+	for topic, msg := range hassDiscovery(prefix) {
+		token := c.client.Publish(topic, QOS, true, msg)
+		if ok := token.WaitTimeout(TIMEOUT); ok && token.Error() != nil {
+			fmt.Printf("Error publishing message to %s: %v\n", topic, token.Error())
+		}
+	}
+}
+
+// newHassStatusHandler returns MQTT message handler which republishes the discovery messages under prefix
+// when Home Assistant announces it is online, e.g. after it restarts
+func newHassStatusHandler(c *MQTTClient, prefix string) MQTT.MessageHandler {
+	return func(client MQTT.Client, msg MQTT.Message) {
+		// the handler must not block the client waiting for the publish to complete
+		if string(msg.Payload()) == "online" {
+			go publishHassDiscovery(c, prefix)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// historyEvent is alert state transition stored in alert history
+type historyEvent struct {
+	// Time is time of the transition
+	Time time.Time `json:"time"`
+	Alert
+	// Snapshot is path to the frame snapshot taken when the alert was raised
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// History stores alert state transitions in a JSON lines file
+type History struct {
+	// f is the history file
+	f *os.File
+	// snapshots is path to directory with frame snapshots; empty if snapshots are disabled
+	snapshots string
+}
+
+// NewHistory opens alert history file at path for appending and returns it.
+// Frame snapshots of raised alerts are saved in snapshots directory unless it is empty.
+func NewHistory(path, snapshots string) (*History, error) {
+	if snapshots != "" {
+		if err := os.MkdirAll(snapshots, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &History{
+		f:         f,
+		snapshots: snapshots,
+	}, nil
+}
+
+// eventTime returns time of the latest state transition of alert a
+func eventTime(a Alert) time.Time {
+	switch {
+	case a.State == alertSnoozed && a.Snoozed != nil:
+		return *a.Snoozed
+	case a.State == alertCleared && a.Cleared != nil:
+		return *a.Cleared
+	case a.State == alertAcknowledged && a.Acknowledged != nil:
+		return *a.Acknowledged
+	}
+
+	return a.Raised
+}
+
+// Record stores state transitions of alerts; heartbeats are skipped.
+// Snapshot of img is saved for the raised alerts if img is not nil.
+func (h *History) Record(alerts []Alert, img *gocv.Mat) error {
+	for _, a := range alerts {
+		if a.Heartbeat {
+			continue
+		}
+		e := historyEvent{
+			Time:  eventTime(a),
+			Alert: a,
+		}
+
+		if a.State == alertRaised && img != nil && h.snapshots != "" {
+			path := filepath.Join(h.snapshots, a.ID+".jpg")
+			if gocv.IMWrite(path, *img) {
+				e.Snapshot = path
+			}
+		}
+
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		if _, err := h.f.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes the history file
+func (h *History) Close() error {
+	return h.f.Close()
+}
+
+// parseSince parses s as either a duration back from now or a RFC3339 time and returns the time
+func parseSince(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+// alertsCommand implements alerts subcommand which queries alert history
+func alertsCommand(args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("Usage: %s alerts list [options]", os.Args[0])
+	}
+
+	fs := flag.NewFlagSet("alerts list", flag.ExitOnError)
+	path := fs.String("history", "alerts.jsonl", "Path to alert history file")
+	since := fs.String("since", "24h", "List alerts since the given time in RFC3339 format or the given duration ago")
+	typ := fs.String("type", "", "List alerts of the given type only")
+	fs.Parse(args[1:])
+
+	from, err := parseSince(*since)
+	if err != nil {
+		return fmt.Errorf("Invalid time: %s", *since)
+	}
+
+	f, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e historyEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("Invalid alert history record: %v", err)
+		}
+
+		if e.Time.Before(from) || (*typ != "" && e.Type != *typ) {
+			continue
+		}
+
+		fmt.Printf("%s  %-16s %-9s %-13s %s\n", e.Time.Format(time.RFC3339), e.ID, e.Type, e.State, e.Snapshot)
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,73 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/intel-iot-devkit/machine-operator-monitor-go/payload"
+)
+
+// httpSinkRunner collects operator status received from resultsChan into batches of at most size statuses
+// and posts every batch as JSON array to w every rate seconds. When the batch is full, the oldest status is dropped.
+// Batches which fail to post after all the retries are dropped too.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func httpSinkRunner(doneChan <-chan struct{}, resultsChan <-chan *Result, w *Webhook, rate, size int) error {
+	ticker := time.NewTicker(time.Duration(rate) * time.Second)
+	defer ticker.Stop()
+
+	batch := make([]payload.Status, 0, size)
+	for {
+		select {
+		case result, ok := <-resultsChan:
+			if !ok {
+				// frameRunner stopped; keep posting until we are told to stop
+				resultsChan = nil
+				continue
+			}
+			if len(batch) == size {
+				batch = append(batch[:0], batch[1:]...)
+			}
+			// results are updated in place, so the status is copied as it arrives
+			batch = append(batch, result.ToPayload())
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+			body, err := json.Marshal(batch)
+			if err != nil {
+				return err
+			}
+			if err := w.Post("batch", string(body)); err != nil {
+				fmt.Printf("Error posting %d statuses to HTTP sink: %v\n", len(batch), err)
+			}
+			batch = batch[:0]
+		case <-doneChan:
+			fmt.Printf("Stopping httpSinkRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
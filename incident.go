@@ -0,0 +1,323 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Incident management services
+const (
+	// incidentPagerDuty is PagerDuty Events API v2
+	incidentPagerDuty = "pagerduty"
+	// incidentOpsgenie is Opsgenie Alert API
+	incidentOpsgenie = "opsgenie"
+)
+
+const (
+	// pagerDutyAPI is URL of PagerDuty Events API v2 endpoint
+	pagerDutyAPI = "https://events.pagerduty.com/v2/enqueue"
+	// opsgenieAPI is default URL of Opsgenie Alert API
+	opsgenieAPI = "https://api.opsgenie.com/v2/alerts"
+)
+
+// pagerDutySeverities maps alert severities to PagerDuty event severities which drive incident urgency
+var pagerDutySeverities = map[string]string{
+	severityInfo:     "info",
+	severityWarning:  "warning",
+	severityCritical: "critical",
+}
+
+// opsgeniePriorities maps alert severities to Opsgenie alert priorities
+var opsgeniePriorities = map[string]string{
+	severityInfo:     "P5",
+	severityWarning:  "P3",
+	severityCritical: "P1",
+}
+
+// IncidentManager opens and resolves incidents in incident management service
+type IncidentManager interface {
+	// Trigger opens incident of alert a or updates it if it is open
+	Trigger(a Alert) error
+	// Acknowledge acknowledges incident of alert a
+	Acknowledge(a Alert) error
+	// Resolve resolves incident of alert a
+	Resolve(a Alert) error
+}
+
+// incidentSource returns source of the incidents, the monitored machine or the program name
+func incidentSource() string {
+	if machineID != "" {
+		return machineID
+	}
+
+	return name
+}
+
+// incidentSummary returns summary of incident of alert a
+func incidentSummary(a Alert) string {
+	return fmt.Sprintf("%s: %s", incidentSource(), msg("alert."+a.Type))
+}
+
+// incidentDetails returns details of incident of alert a
+func incidentDetails(a Alert) map[string]string {
+	details := map[string]string{
+		"alert_id":   a.ID,
+		"alert_type": a.Type,
+		"severity":   a.Severity,
+		"raised":     a.Raised.Format(time.RFC3339),
+	}
+	if machineID != "" {
+		details["machine_id"] = machineID
+	}
+	if cameraID != "" {
+		details["camera_id"] = cameraID
+	}
+	if a.Escalation > 0 {
+		details["escalation"] = fmt.Sprint(a.Escalation)
+	}
+
+	return details
+}
+
+// postJSON posts v as JSON to url with headers using client and returns error unless it is accepted
+func postJSON(client *http.Client, url string, headers http.Header, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range headers {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// PagerDuty manages incidents through PagerDuty Events API v2; alert IDs deduplicate the events
+type PagerDuty struct {
+	// key is integration routing key of the PagerDuty service
+	key string
+	// client sends the requests
+	client *http.Client
+}
+
+// NewPagerDuty creates new PagerDuty incident manager and returns it
+// It reads the following environment variables to configure the manager:
+// PAGERDUTY_ROUTING_KEY: integration key of Events API v2 integration of the service; required parameter
+// It returns error if the routing key is missing.
+func NewPagerDuty() (*PagerDuty, error) {
+	key := os.Getenv("PAGERDUTY_ROUTING_KEY")
+	if key == "" {
+		return nil, fmt.Errorf("PagerDuty routing key is empty")
+	}
+
+	return &PagerDuty{
+		key:    key,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// event sends event of action about alert a
+func (p *PagerDuty) event(action string, a Alert) error {
+	event := map[string]interface{}{
+		"routing_key":  p.key,
+		"event_action": action,
+		"dedup_key":    a.ID,
+	}
+	if action == "trigger" {
+		event["payload"] = map[string]interface{}{
+			"summary":        incidentSummary(a),
+			"source":         incidentSource(),
+			"severity":       pagerDutySeverities[a.Severity],
+			"timestamp":      a.Raised.Format(time.RFC3339),
+			"component":      cameraID,
+			"class":          a.Type,
+			"custom_details": incidentDetails(a),
+		}
+	}
+
+	return postJSON(p.client, pagerDutyAPI, nil, event)
+}
+
+// Trigger opens incident of alert a or updates its severity if it is open
+func (p *PagerDuty) Trigger(a Alert) error {
+	return p.event("trigger", a)
+}
+
+// Acknowledge acknowledges incident of alert a
+func (p *PagerDuty) Acknowledge(a Alert) error {
+	return p.event("acknowledge", a)
+}
+
+// Resolve resolves incident of alert a
+func (p *PagerDuty) Resolve(a Alert) error {
+	return p.event("resolve", a)
+}
+
+// Opsgenie manages incidents through Opsgenie Alert API; alert IDs are aliases of the Opsgenie alerts
+type Opsgenie struct {
+	// url is URL of Alert API
+	url string
+	// headers authenticate the requests
+	headers http.Header
+	// client sends the requests
+	client *http.Client
+}
+
+// NewOpsgenie creates new Opsgenie incident manager and returns it
+// It reads the following environment variables to configure the manager:
+// OPSGENIE_API_KEY: API key of API integration; required parameter
+// OPSGENIE_API_URL: Alert API URL, e.g. https://api.eu.opsgenie.com/v2/alerts for EU accounts; not required
+// It returns error if the API key is missing.
+func NewOpsgenie() (*Opsgenie, error) {
+	key := os.Getenv("OPSGENIE_API_KEY")
+	api := os.Getenv("OPSGENIE_API_URL")
+
+	if key == "" {
+		return nil, fmt.Errorf("Opsgenie API key is empty")
+	}
+
+	if api == "" {
+		api = opsgenieAPI
+	}
+
+	return &Opsgenie{
+		url:     strings.TrimSuffix(api, "/"),
+		headers: http.Header{"Authorization": {"GenieKey " + key}},
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Trigger creates Opsgenie alert of alert a; Opsgenie deduplicates the open alerts by their aliases
+func (o *Opsgenie) Trigger(a Alert) error {
+	return postJSON(o.client, o.url, o.headers, map[string]interface{}{
+		"message":  incidentSummary(a),
+		"alias":    a.ID,
+		"source":   incidentSource(),
+		"entity":   incidentSource(),
+		"priority": opsgeniePriorities[a.Severity],
+		"tags":     []string{name, a.Type},
+		"details":  incidentDetails(a),
+	})
+}
+
+// action performs action on Opsgenie alert of alert a
+func (o *Opsgenie) action(action string, a Alert) error {
+	u := fmt.Sprintf("%s/%s/%s?identifierType=alias", o.url, url.PathEscape(a.ID), action)
+
+	return postJSON(o.client, u, o.headers, map[string]string{
+		"source": incidentSource(),
+		"note":   fmt.Sprintf("Alert %s", a.State),
+	})
+}
+
+// Acknowledge acknowledges Opsgenie alert of alert a
+func (o *Opsgenie) Acknowledge(a Alert) error {
+	return o.action("acknowledge", a)
+}
+
+// Resolve closes Opsgenie alert of alert a
+func (o *Opsgenie) Resolve(a Alert) error {
+	return o.action("close", a)
+}
+
+// newIncidentManager creates incident manager of service and returns it
+func newIncidentManager(service string) (IncidentManager, error) {
+	switch service {
+	case incidentPagerDuty:
+		return NewPagerDuty()
+	case incidentOpsgenie:
+		return NewOpsgenie()
+	}
+
+	return nil, fmt.Errorf("Unsupported incident management service: %s", service)
+}
+
+// incidentRunner opens incidents of alerts received from alertsChan and routed to incident management as they
+// are raised and acknowledges and resolves them as the alerts are acknowledged, cleared or snoozed.
+// Alerts published to the default sinks only open incidents if they have at least severity.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func incidentRunner(doneChan <-chan struct{}, alertsChan <-chan Alert, m IncidentManager, severity string) error {
+	// open are IDs of alerts with open incidents
+	open := make(map[string]bool)
+
+	for {
+		select {
+		case a := <-alertsChan:
+			if a.Heartbeat {
+				continue
+			}
+			var err error
+			switch {
+			case a.State == alertRaised:
+				if !a.routedTo(sinkIncident) {
+					continue
+				}
+				if a.Sinks == nil && severityRank(a.Severity) < severityRank(severity) {
+					continue
+				}
+				err = m.Trigger(a)
+				open[a.ID] = true
+			case !open[a.ID]:
+				continue
+			case a.State == alertAcknowledged:
+				err = m.Acknowledge(a)
+			default:
+				err = m.Resolve(a)
+				delete(open, a.ID)
+			}
+			if err != nil {
+				fmt.Printf("Error updating incident of alert %s: %v\n", a.ID, err)
+			}
+		case <-doneChan:
+			fmt.Printf("Stopping incidentRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,377 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// Nets stores inference networks used to detect operator status
+type Nets struct {
+	// Face is face detection network
+	Face Model
+	// InputScale scales input size of the face and person detection networks down; zero means full size
+	InputScale float64
+	// Sent is sentiment detection network
+	Sent Model
+	// Pose is pose detection network
+	Pose Model
+	// Landmarks is facial landmarks detection network; nil if disabled
+	Landmarks Model
+	// Gaze is gaze estimation network; nil if disabled
+	Gaze Model
+	// Eyes is eye state detection network; nil if disabled
+	Eyes Model
+	// PPE is protective equipment detection network; nil if disabled
+	PPE Model
+	// ReID is face reidentification network; nil if disabled
+	ReID Model
+	// Mask is face mask detection network; nil if disabled
+	Mask Model
+	// Phone is phone detection network; nil if disabled
+	Phone Model
+	// Person is person detection network; nil if disabled
+	Person Model
+	// Gallery stores face embeddings of known operators; nil if disabled
+	Gallery *Gallery
+	// Cache stores head pose and sentiment of faces for reuse; nil if disabled
+	Cache *InferenceCache
+}
+
+// newModelOnTargets creates network name from model file and its configuration on the first of targets
+// which can run it and returns it. If there are several targets, the network is verified by a test forward pass
+// on every target before the next target is tried, so every network falls back on its own.
+// It returns error if the network can't run on any of the targets.
+func newModelOnTargets(name, model, config string, backend int, targets []int) (Model, error) {
+	var err error
+	for i, t := range targets {
+		var m Model
+		m, err = NewModel(model, config, backend, t, gpuIndex)
+		if err == nil && len(targets) > 1 {
+			if err = probe(name, m).run(); err != nil {
+				m.Close()
+			}
+		}
+		if err == nil {
+			if i > 0 {
+				fmt.Printf("Falling back to inference target %d for %s model\n", t, name)
+			}
+			return m, nil
+		}
+		if len(targets) > 1 {
+			fmt.Printf("Error loading %s model on inference target %d: %v\n", name, t, err)
+		}
+	}
+
+	return nil, fmt.Errorf("Error creating %s model: %v", name, err)
+}
+
+// modelTargets returns inference targets of a model; models with their own target t use only that one,
+// or try the devices in the order of preference if it is AUTO
+func modelTargets(t int, targets []int) []int {
+	if t == autoTarget {
+		return autoTargets()
+	}
+	if t < 0 {
+		return targets
+	}
+
+	return []int{t}
+}
+
+// NewNets reads face, sentiment and pose detection models, sets their inference backend and target and returns them.
+// The face, sentiment and pose detection models use their own backend and target if set; the optional models use
+// backend. Every model is loaded on the first of targets which can run it.
+// It returns error if any of the models fails to be created.
+func NewNets(backend int, targets []int) (*Nets, error) {
+	nets := new(Nets)
+	for _, m := range []struct {
+		net                 *Model
+		name, model, config string
+		backend             int
+		targets             []int
+	}{
+		{&nets.Face, netFace, faceModel, faceConfig, faceBackend, modelTargets(faceTarget, targets)},
+		{&nets.Sent, netSent, sentModel, sentConfig, sentBackend, modelTargets(sentTarget, targets)},
+		{&nets.Pose, netPose, poseModel, poseConfig, poseBackend, modelTargets(poseTarget, targets)},
+		{&nets.Landmarks, netLandmarks, landmarksModel, landmarksConfig, backend, targets},
+		{&nets.Gaze, netGaze, gazeModel, gazeConfig, backend, targets},
+		{&nets.Eyes, netEyes, eyeModel, eyeConfig, backend, targets},
+		{&nets.PPE, netPPE, ppeModel, ppeConfig, backend, targets},
+		{&nets.ReID, netReID, reidModel, reidConfig, backend, targets},
+		{&nets.Mask, netMask, maskModel, maskConfig, backend, targets},
+		{&nets.Phone, netPhone, phoneModel, phoneConfig, backend, targets},
+		{&nets.Person, netPerson, personModel, personConfig, backend, targets},
+	} {
+		// optional models are disabled unless their model file is set
+		if m.model == "" {
+			continue
+		}
+		net, err := newModelOnTargets(m.name, m.model, m.config, m.backend, m.targets)
+		if err != nil {
+			nets.Close()
+			return nil, err
+		}
+		*m.net = net
+	}
+
+	return nets, nil
+}
+
+// Err returns error of the first failed forward pass of the networks since Err was last called
+func (n *Nets) Err() error {
+	var err error
+	for _, net := range []Model{n.Face, n.Sent, n.Pose, n.Landmarks, n.Gaze, n.Eyes, n.PPE, n.ReID, n.Mask, n.Phone, n.Person} {
+		if f, ok := net.(failer); ok {
+			if e := f.Err(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+
+	return err
+}
+
+// Close closes all the networks
+func (n *Nets) Close() {
+	for _, net := range []Model{n.Face, n.Sent, n.Pose, n.Landmarks, n.Gaze, n.Eyes, n.PPE, n.ReID, n.Mask, n.Phone, n.Person} {
+		if net != nil {
+			net.Close()
+		}
+	}
+}
+
+// LoadNets creates count sets of networks, one for every inference request in flight, and returns them.
+// If operator identification is requested, it also computes the gallery which all the sets of networks share.
+// It returns error if any of the networks or the gallery fails to be created.
+func LoadNets(count, backend, target int) ([]*Nets, error) {
+	nets := make([]*Nets, 0, count)
+	// closeAll cleans up the networks created so far
+	closeAll := func() {
+		for i := range nets {
+			nets[i].Close()
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		n, err := NewNets(backend, netTargets(i, target))
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+
+	// compute face embeddings of known operators
+	if gallery != "" {
+		g, err := NewGallery(gallery, nets[0], reidThreshold)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("Error creating operator gallery: %v", err)
+		}
+		for i := range nets {
+			nets[i].Gallery = g
+		}
+	}
+
+	// faces which haven't moved reuse head pose and sentiment inferred by any of the sets of networks
+	if reuseMaxAge > 0 {
+		c := NewInferenceCache(reuseIoU, reuseMaxAge)
+		for i := range nets {
+			nets[i].Cache = c
+		}
+	}
+
+	return nets, nil
+}
+
+// warmUpNets runs passes test forward passes through all nets so that the inference engine
+// finishes its lazy initialization before the first frames are processed.
+// It returns error if any of the passes fails.
+func warmUpNets(nets []*Nets, passes int) error {
+	for i := 0; i < passes; i++ {
+		if err := validateNets(nets); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// detection is operator status detected in a single frame
+type detection struct {
+	// seq is sequence number of the frame
+	seq uint64
+	// ts is time when the frame was captured
+	ts time.Time
+	// captured is wall clock time when the frame was captured
+	captured time.Time
+	// reset signals the frame is the first frame of a new input source
+	reset bool
+	// status is detected operator status
+	status *Status
+	// started is time when the inference of the frame started
+	started time.Time
+	// latency is time the inference of the frame took
+	latency time.Duration
+	// img is the frame image kept for alert snapshots; nil if snapshots are disabled
+	img *gocv.Mat
+	// perf is inference engine performance
+	perf *Perf
+	// stats are frame image statistics; nil if camera tampering is not detected
+	stats *frameStats
+	// err is error of failed inference; the detection is not valid if it is set
+	err error
+}
+
+// copyFrame makes a deep copy of f so it can be processed while the original frame is reused
+func copyFrame(f *frame) *frame {
+	img := gocv.NewMat()
+	f.img.CopyTo(&img)
+
+	c := &frame{
+		img:        &img,
+		ts:         f.ts,
+		captured:   f.captured,
+		reset:      f.reset,
+		depthScale: f.depthScale,
+	}
+
+	if f.depth != nil {
+		depth := gocv.NewMat()
+		f.depth.CopyTo(&depth)
+		c.depth = &depth
+	}
+
+	return c
+}
+
+// close closes frame image matrices
+func (f *frame) close() {
+	if f.img != nil {
+		f.img.Close()
+	}
+	if f.depth != nil {
+		f.depth.Close()
+	}
+}
+
+// detect runs inference on frame f using nets and returns detected operator status
+func detect(nets *Nets, f *frame) *detection {
+	start := time.Now()
+	nets = degradeNets(nets, f.level)
+
+	// detect faces and return them; the confidences are looked up by bounding boxes as the faces get filtered
+	faces, confidences := detectFaces(nets.Face, f.img, nets.InputScale)
+	confidence := make(map[image.Rectangle]float64, len(faces))
+	for i := range faces {
+		confidence[faces[i]] = confidences[i]
+	}
+
+	// drop faces on posters and screens and measure operator distance
+	var distance float64
+	if f.depth != nil {
+		faces, distance = filterFacesByDepth(f.depth, f.depthScale, faces, image.Pt(f.img.Cols(), f.img.Rows()))
+	}
+
+	// detect operator status
+	status := detectStatus(nets, f.img, faces)
+	status.Distance = distance
+	for _, face := range status.Faces {
+		face.Confidence = confidence[face.Rect]
+	}
+	status.ZoneFaces = countInZone(status.Faces)
+
+	// tell operator turned away from the camera from absent operator if no face was found
+	if !status.checked && nets.Person != nil {
+		status.Present = len(detectPersons(nets.Person, f.img, nets.InputScale)) > 0
+		status.personChecked = true
+	}
+
+	d := &detection{
+		seq:      f.seq,
+		ts:       f.ts,
+		captured: f.captured,
+		reset:    f.reset,
+		status:   status,
+		started:  start,
+		latency:  time.Since(start),
+	}
+
+	if tamper {
+		d.stats = measureFrame(*f.img)
+	}
+
+	if status.checked {
+		d.perf = getPerformanceInfo(nets, status.checked)
+	}
+
+	d.err = nets.Err()
+
+	return d
+}
+
+// inferRunner runs inference on frames read from jobsChan and sends detections down the detsChan.
+// Each inferRunner owns its own set of networks so that multiple inference requests can be in flight.
+// It returns when jobsChan is closed.
+func inferRunner(nets *Nets, jobsChan <-chan *frame, detsChan chan<- *detection) {
+	for f := range jobsChan {
+		d := detect(nets, f)
+		// the frame image is handed over to the detection for alert snapshots
+		if historySnapshots != "" || snapshotDir != "" {
+			d.img, f.img = f.img, nil
+		}
+		f.close()
+		detsChan <- d
+	}
+}
+
+// startInferRunners starts inferRunner goroutine for each of the nets and returns the channel
+// which distributes frames to them. Once the channel is closed and all the goroutines return, the nets are closed.
+func startInferRunners(nets []*Nets, detsChan chan<- *detection) chan<- *frame {
+	jobsChan := make(chan *frame, len(nets))
+
+	var wg sync.WaitGroup
+	for i := range nets {
+		wg.Add(1)
+		go func(n *Nets) {
+			defer wg.Done()
+			inferRunner(n, jobsChan, detsChan)
+		}(nets[i])
+	}
+
+	go func() {
+		wg.Wait()
+		for i := range nets {
+			nets[i].Close()
+		}
+	}()
+
+	return jobsChan
+}
@@ -0,0 +1,256 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// influxKeyEscaper escapes measurement names, tag keys, tag values and field keys in line protocol
+	influxKeyEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	// influxStringEscaper escapes string field values in line protocol
+	influxStringEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// Influx writes measurements to InfluxDB 2 bucket
+type Influx struct {
+	// url is write API URL with the organization and bucket in its query
+	url string
+	// token is API token; empty if the requests are not authenticated
+	token string
+	// tags are tags of every written point
+	tags map[string]string
+	// client sends the requests
+	client *http.Client
+}
+
+// NewInflux creates new InfluxDB writer and returns it.
+// It reads the following environment variables to configure the writer:
+// INFLUX_URL: InfluxDB server URL, e.g. http://localhost:8086; required parameter
+// INFLUX_ORG: organization the bucket belongs to; required parameter
+// INFLUX_BUCKET: bucket the measurements are written to; required parameter
+// INFLUX_TOKEN: API token with write permission to the bucket; not required
+// The points are tagged with the machine, camera and site IDs which are set.
+func NewInflux() (*Influx, error) {
+	server := strings.TrimSuffix(os.Getenv("INFLUX_URL"), "/")
+	org := os.Getenv("INFLUX_ORG")
+	bucket := os.Getenv("INFLUX_BUCKET")
+
+	if server == "" {
+		return nil, fmt.Errorf("InfluxDB URL is empty")
+	}
+	if org == "" || bucket == "" {
+		return nil, fmt.Errorf("InfluxDB organization and bucket are required")
+	}
+
+	tags := make(map[string]string)
+	for k, v := range map[string]string{"machine": machineID, "camera": cameraID, "site": siteID} {
+		if v != "" {
+			tags[k] = v
+		}
+	}
+
+	q := url.Values{}
+	q.Set("org", org)
+	q.Set("bucket", bucket)
+	q.Set("precision", "ns")
+
+	return &Influx{
+		url:    server + "/api/v2/write?" + q.Encode(),
+		token:  os.Getenv("INFLUX_TOKEN"),
+		tags:   tags,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+// writePoint appends point of measurement with fields to buf in line protocol.
+// Field values are either bool, int, float64 or string; points without fields are skipped.
+func (db *Influx) writePoint(buf *bytes.Buffer, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) {
+	if len(fields) == 0 {
+		return
+	}
+
+	buf.WriteString(influxKeyEscaper.Replace(measurement))
+	// tags are sorted as InfluxDB recommends
+	keys := make([]string, 0, len(db.tags)+len(tags))
+	all := make(map[string]string)
+	for _, t := range []map[string]string{db.tags, tags} {
+		for k, v := range t {
+			if _, ok := all[k]; !ok {
+				keys = append(keys, k)
+			}
+			all[k] = v
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if all[k] == "" {
+			continue
+		}
+		fmt.Fprintf(buf, ",%s=%s", influxKeyEscaper.Replace(k), influxKeyEscaper.Replace(all[k]))
+	}
+
+	keys = keys[:0]
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		sep := ","
+		if i == 0 {
+			sep = " "
+		}
+		buf.WriteString(sep + influxKeyEscaper.Replace(k) + "=")
+		switch v := fields[k].(type) {
+		case bool:
+			buf.WriteString(strconv.FormatBool(v))
+		case int:
+			buf.WriteString(strconv.Itoa(v) + "i")
+		case float64:
+			buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		case string:
+			buf.WriteString(`"` + influxStringEscaper.Replace(v) + `"`)
+		}
+	}
+
+	fmt.Fprintf(buf, " %d\n", ts.UnixNano())
+}
+
+// Write writes operator status and inference performance of result to the bucket as operator_status
+// and inference_perf measurements
+func (db *Influx) Write(result *Result) error {
+	p := result.ToPayload()
+	tags := map[string]string{"operator": p.Operator}
+
+	status := map[string]interface{}{
+		"watching":  p.Watching,
+		"angry":     p.Angry,
+		"paused":    p.Paused,
+		"suspended": p.Suspended,
+	}
+	if p.Distance > 0 {
+		status["distance"] = p.Distance
+	}
+	if p.Phone != nil {
+		status["phone"] = *p.Phone
+	}
+	if p.Degradation != nil {
+		status["degradation"] = *p.Degradation
+	}
+	if p.Risk != nil {
+		status["risk"] = *p.Risk
+	}
+	raised := 0
+	for typ, alert := range p.Alerts {
+		status["alert_"+typ] = alert
+		if alert {
+			raised++
+		}
+	}
+	status["alerts"] = raised
+
+	var buf bytes.Buffer
+	db.writePoint(&buf, "operator_status", tags, status, p.Time)
+	if result.hasPerf() {
+		perf := result.ToPerfPayload()
+		fields := map[string]interface{}{
+			"face_net":  perf.FaceNet,
+			"sent_net":  perf.SentNet,
+			"pose_net":  perf.PoseNet,
+			"fps":       perf.FPS,
+			"input_fps": perf.InputFPS,
+			"frames":    int(perf.Frames),
+			"dropped":   int(perf.Dropped),
+		}
+		for name, ms := range perf.Nets {
+			fields[name+"_net"] = ms
+		}
+		db.writePoint(&buf, "inference_perf", nil, fields, perf.Time)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, db.url, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if db.token != "" {
+		req.Header.Set("Authorization", "Token "+db.token)
+	}
+
+	resp, err := db.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// influxRunner writes operator status received from statusChan to InfluxDB every rate seconds
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func influxRunner(doneChan <-chan struct{}, statusChan <-chan *Result, db *Influx, rate int) error {
+	ticker := time.NewTicker(time.Duration(rate) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			select {
+			case result, ok := <-statusChan:
+				if !ok {
+					// frameRunner stopped; wait until we are told to stop
+					statusChan = nil
+					continue
+				}
+				if err := db.Write(result); err != nil {
+					fmt.Printf("Error writing status to InfluxDB: %v\n", err)
+				}
+			case <-doneChan:
+				fmt.Printf("Stopping influxRunner: received stop signal\n")
+				return nil
+			}
+		case _, ok := <-statusChan:
+			// we discard status in between ticker times
+			if !ok {
+				statusChan = nil
+			}
+		case <-doneChan:
+			fmt.Printf("Stopping influxRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
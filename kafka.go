@@ -0,0 +1,142 @@
+//go:build kafka
+// +build kafka
+
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Shopify/sarama"
+)
+
+// kafkaTopicHeader is Kafka record header carrying the MQTT topic the message corresponds to
+const kafkaTopicHeader = "topic"
+
+// kafkaProducer publishes messages to Kafka topic keyed by machine ID
+type kafkaProducer struct {
+	// producer sends the messages synchronously
+	producer sarama.SyncProducer
+	// topic is Kafka topic
+	topic string
+	// key is message key which keeps messages of the machine in the same partition
+	key string
+}
+
+// newKafkaProducer creates new Kafka producer and returns it
+// It reads the following environment variables to configure the producer:
+// KAFKA_BROKERS: comma separated broker addresses in host:port format; required parameter
+// KAFKA_TOPIC: Kafka topic; defaults to machine-safety
+// KAFKA_USERNAME: SASL/PLAIN username; not required
+// KAFKA_PASSWORD: SASL/PLAIN password for KAFKA_USERNAME; not required
+// KAFKA_TLS: enables TLS if true; not required
+// KAFKA_CERT: SSL client certificate; not required
+// KAFKA_CERT_KEY: SSL client certificate private key; not required
+// KAFKA_CA_ROOT: SSL CA root certificate; not required, the system CA certificates are used by default
+// It returns error if no broker is configured or if the producer fails to connect to the brokers.
+func newKafkaProducer() (SinkCloser, error) {
+	brokers := parseLabels(os.Getenv("KAFKA_BROKERS"))
+	topic := os.Getenv("KAFKA_TOPIC")
+	username := os.Getenv("KAFKA_USERNAME")
+	password := os.Getenv("KAFKA_PASSWORD")
+	tlsEnabled := os.Getenv("KAFKA_TLS")
+	tlsCert := os.Getenv("KAFKA_CERT")
+	tlsKey := os.Getenv("KAFKA_CERT_KEY")
+	tlsCA := os.Getenv("KAFKA_CA_ROOT")
+
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("Kafka brokers are empty")
+	}
+	if topic == "" {
+		topic = "machine-safety"
+	}
+
+	config := sarama.NewConfig()
+	// record headers require Kafka 0.11
+	config.Version = sarama.V0_11_0_0
+	config.ClientID = name
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 5
+	config.Producer.Return.Successes = true
+
+	if username != "" {
+		config.Net.SASL.Enable = true
+		config.Net.SASL.User = username
+		config.Net.SASL.Password = password
+	}
+
+	var secure bool
+	if tlsEnabled != "" {
+		v, err := strconv.ParseBool(tlsEnabled)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid KAFKA_TLS: %s", tlsEnabled)
+		}
+		secure = v
+	}
+	if secure || tlsCert != "" || tlsKey != "" || tlsCA != "" {
+		tlsConfig, err := MQTTNewTLSConfig(tlsCA, tlsCert, tlsKey, false)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid TLS configuration: %s", err)
+		}
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = tlsConfig
+	}
+
+	producer, err := sarama.NewSyncProducer(brokers, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafkaProducer{
+		producer: producer,
+		topic:    topic,
+		key:      machineID,
+	}, nil
+}
+
+// Send publishes message corresponding to MQTT topic to the Kafka topic; the MQTT topic is sent in record header
+func (k *kafkaProducer) Send(topic, message string) error {
+	msg := &sarama.ProducerMessage{
+		Topic: k.topic,
+		Value: sarama.StringEncoder(message),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(kafkaTopicHeader), Value: []byte(topic)},
+		},
+	}
+	if k.key != "" {
+		msg.Key = sarama.StringEncoder(k.key)
+	}
+
+	_, _, err := k.producer.SendMessage(msg)
+
+	return err
+}
+
+// Close closes the producer
+func (k *kafkaProducer) Close() error {
+	return k.producer.Close()
+}
@@ -0,0 +1,112 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// Landmarks stores facial landmarks in frame coordinates
+type Landmarks struct {
+	// LeftEye is center of the left eye
+	LeftEye image.Point
+	// RightEye is center of the right eye
+	RightEye image.Point
+	// Nose is tip of the nose
+	Nose image.Point
+	// LeftMouth is left corner of the mouth
+	LeftMouth image.Point
+	// RightMouth is right corner of the mouth
+	RightMouth image.Point
+}
+
+// detectLandmarks detects facial landmarks in face crops and returns them in frame coordinates.
+// rects are bounding boxes of the crops in the frame. The net is expected to be compatible
+// with landmarks-regression-retail-0009 model which returns 5 normalized landmark coordinates.
+func detectLandmarks(net Model, crops []gocv.Mat, rects []image.Rectangle) []*Landmarks {
+	blob := gocv.NewMat()
+	defer blob.Close()
+	gocv.BlobFromImages(crops, &blob, 1.0, image.Pt(48, 48),
+		gocv.NewScalar(0, 0, 0, 0), false, false, gocv.MatTypeCV32F)
+
+	// run a forward pass through landmarks network
+	net.SetInput(blob, "")
+	out := net.Forward("")
+	defer out.Close()
+
+	// flatten the result from [N, 10, 1, 1] to [N, 10]
+	res := out.Reshape(1, len(crops))
+	defer res.Close()
+
+	landmarks := make([]*Landmarks, len(crops))
+	for i := range crops {
+		// point returns j-th landmark in frame coordinates
+		point := func(j int) image.Point {
+			x := res.GetFloatAt(i, 2*j) * float32(rects[i].Dx())
+			y := res.GetFloatAt(i, 2*j+1) * float32(rects[i].Dy())
+			return image.Pt(rects[i].Min.X+int(x), rects[i].Min.Y+int(y))
+		}
+
+		landmarks[i] = &Landmarks{
+			LeftEye:    point(0),
+			RightEye:   point(1),
+			Nose:       point(2),
+			LeftMouth:  point(3),
+			RightMouth: point(4),
+		}
+	}
+
+	return landmarks
+}
+
+// eyeRect returns square region centered on eye sized relative to the distance between the eyes
+func eyeRect(eye image.Point, lm *Landmarks) image.Rectangle {
+	d := math.Hypot(float64(lm.RightEye.X-lm.LeftEye.X), float64(lm.RightEye.Y-lm.LeftEye.Y))
+	half := int(d * 0.3)
+	if half < 1 {
+		half = 1
+	}
+
+	return image.Rect(eye.X-half, eye.Y-half, eye.X+half, eye.Y+half)
+}
+
+// alignFace rotates face crop bounded by rect so that the eyes lie on a horizontal line and returns it
+func alignFace(crop gocv.Mat, rect image.Rectangle, lm *Landmarks) gocv.Mat {
+	dx := float64(lm.RightEye.X - lm.LeftEye.X)
+	dy := float64(lm.RightEye.Y - lm.LeftEye.Y)
+	angle := math.Atan2(dy, dx) * 180 / math.Pi
+
+	// rotate around the point between the eyes
+	center := image.Pt((lm.LeftEye.X+lm.RightEye.X)/2-rect.Min.X, (lm.LeftEye.Y+lm.RightEye.Y)/2-rect.Min.Y)
+	m := gocv.GetRotationMatrix2D(center, angle, 1.0)
+	defer m.Close()
+
+	aligned := gocv.NewMat()
+	gocv.WarpAffine(crop, &aligned, m, image.Pt(crop.Cols(), crop.Rows()))
+
+	return aligned
+}
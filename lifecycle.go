@@ -0,0 +1,341 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	"github.com/intel-iot-devkit/machine-operator-monitor-go/payload"
+)
+
+const (
+	// alertRaised is state of raised alert
+	alertRaised = "raised"
+	// alertAcknowledged is state of raised alert acknowledged by a supervisor
+	alertAcknowledged = "acknowledged"
+	// alertCleared is state of alert whose condition cleared
+	alertCleared = "cleared"
+	// alertSnoozed is state of alert type snoozed by a supervisor
+	alertSnoozed = "snoozed"
+)
+
+// alertQueue is number of alerts queued for every alert sink; alerts which don't fit are dropped
+const alertQueue = 64
+
+// Alert is an alert with its lifecycle state
+type Alert struct {
+	// ID uniquely identifies the alert
+	ID string `json:"id"`
+	// Type is alert type
+	Type string `json:"type"`
+	// State is alert state
+	State string `json:"state"`
+	// Severity is alert severity
+	Severity string `json:"severity"`
+	// Escalation is number of escalation steps the alert went through
+	Escalation int `json:"escalation,omitempty"`
+	// Sinks are sinks the alert is published to; nil means the default sinks
+	Sinks []string `json:"sinks,omitempty"`
+	// Raised is time when the alert was raised
+	Raised time.Time `json:"raised"`
+	// Acknowledged is time when the alert was acknowledged; nil if it was not acknowledged
+	Acknowledged *time.Time `json:"acknowledged,omitempty"`
+	// Cleared is time when the alert was cleared; nil if it was not cleared
+	Cleared *time.Time `json:"cleared,omitempty"`
+	// Snoozed is time when the alert type was snoozed; nil if it was not snoozed
+	Snoozed *time.Time `json:"snoozed,omitempty"`
+	// SnoozedUntil is time until which the alert type is snoozed; nil if it was not snoozed
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+	// Heartbeat means the alert state did not change; the alert is republished as it persists
+	Heartbeat bool `json:"heartbeat,omitempty"`
+	// beat is time when the alert was last published
+	beat time.Time
+	// snapshot is path to annotated snapshot of the frame the alert was raised in; empty if not attached
+	snapshot string
+	// image is base64 encoded JPEG of the snapshot; empty if not attached
+	image string
+}
+
+// ToPayload turns alert into alert payload describing its latest state transition
+func (a Alert) ToPayload() payload.Alert {
+	return payload.Alert{
+		Header:       payloadHeader(eventTime(a)),
+		ID:           a.ID,
+		Type:         a.Type,
+		State:        a.State,
+		Severity:     a.Severity,
+		Escalation:   a.Escalation,
+		Sinks:        a.Sinks,
+		Raised:       a.Raised,
+		Acknowledged: a.Acknowledged,
+		Cleared:      a.Cleared,
+		Snoozed:      a.Snoozed,
+		SnoozedUntil: a.SnoozedUntil,
+		Heartbeat:    a.Heartbeat,
+		Snapshot:     a.snapshot,
+		Image:        a.image,
+	}
+}
+
+// ToMQTTMessage turns alert into MQTT message
+func (a Alert) ToMQTTMessage() string {
+	msg, err := json.Marshal(a.ToPayload())
+	if err != nil {
+		return "{}"
+	}
+
+	return string(msg)
+}
+
+// alertFlags returns alert types mapped to result fields which raise them
+func alertFlags(r *Result) map[string]*bool {
+	flags := map[string]*bool{
+		"watching":   &r.AlertWatching,
+		"angry":      &r.AlertAngry,
+		"distance":   &r.AlertDistance,
+		"drowsy":     &r.AlertDrowsy,
+		"ppe":        &r.AlertPPE,
+		"phone":      &r.AlertPhone,
+		"absent":     &r.AlertAbsent,
+		"unattended": &r.AlertUnattended,
+		"crowd":      &r.AlertCrowd,
+		"risk":       &r.AlertRisk,
+		"tamper":     &r.AlertTamper,
+		"emotion":    &r.AlertEmotion,
+	}
+
+	rules := r.ruleAlerts()
+	for i, rule := range alertRules {
+		flags[rule.Name] = &rules[i]
+	}
+
+	return flags
+}
+
+// alertTypes returns alert types mapped to whether they are raised in result
+func alertTypes(r *Result) map[string]bool {
+	types := make(map[string]bool)
+	for typ, raised := range alertFlags(r) {
+		types[typ] = *raised
+	}
+
+	return types
+}
+
+// AlertManager tracks lifecycle of the alerts raised in results
+type AlertManager struct {
+	// prefix makes alert IDs unique across program runs
+	prefix string
+	// seq is sequence number of the next alert
+	seq uint64
+	// active stores raised and acknowledged alerts by their types
+	active map[string]*Alert
+	// snoozed stores times until which alert types are snoozed
+	snoozed map[string]time.Time
+}
+
+// NewAlertManager creates new alert manager and returns it
+func NewAlertManager() *AlertManager {
+	return &AlertManager{
+		prefix:  fmt.Sprintf("%x", time.Now().Unix()),
+		active:  make(map[string]*Alert),
+		snoozed: make(map[string]time.Time),
+	}
+}
+
+// Update raises and clears alerts at time ts according to result and returns the alerts which changed state.
+// Continuous alert condition raises the alert only once; the alert is republished as a heartbeat
+// every alertHeartbeat while it persists unless the heartbeat is disabled.
+// Alerts of snoozed types are not raised and they are cleared in result.
+func (m *AlertManager) Update(ts time.Time, result *Result) []Alert {
+	for typ, until := range m.snoozed {
+		if !ts.Before(until) {
+			delete(m.snoozed, typ)
+			fmt.Printf("Snooze of %s alerts expired\n", typ)
+		}
+	}
+	for typ, raised := range alertFlags(result) {
+		if _, ok := m.snoozed[typ]; ok {
+			*raised = false
+		}
+	}
+
+	var changed []Alert
+	for typ, raised := range alertTypes(result) {
+		a, ok := m.active[typ]
+		switch {
+		case raised && !ok:
+			m.seq++
+			a = &Alert{
+				ID:       fmt.Sprintf("%s-%d", m.prefix, m.seq),
+				Type:     typ,
+				State:    alertRaised,
+				Severity: severity(typ, 0),
+				Sinks:    initialSinks(typ),
+				Raised:   ts,
+				beat:     ts,
+			}
+			m.active[typ] = a
+			changed = append(changed, *a)
+		case !raised && ok:
+			a.State, a.Cleared = alertCleared, &ts
+			delete(m.active, typ)
+			changed = append(changed, *a)
+		case raised && ok:
+			// alert escalates as it stays raised and further while it stays unacknowledged
+			escalated := a.State == alertRaised && escalate(a, ts)
+			if s := escalatedSeverity(typ, a.Escalation, severity(typ, ts.Sub(a.Raised))); s != a.Severity || escalated {
+				a.Severity, a.beat = s, ts
+				changed = append(changed, *a)
+			} else if alertHeartbeat > 0 && ts.Sub(a.beat) >= alertHeartbeat {
+				a.beat = ts
+				beat := *a
+				beat.Heartbeat = true
+				changed = append(changed, beat)
+			}
+		}
+	}
+
+	result.Acknowledged = m.acknowledged()
+	result.Severities = m.severities()
+
+	return changed
+}
+
+// Acknowledge acknowledges active alerts at time ts and returns the alerts which changed state.
+// The alerts are selected by their ID or type; empty id or "all" acknowledges all the active alerts.
+func (m *AlertManager) Acknowledge(ts time.Time, id string) []Alert {
+	id = strings.TrimSpace(id)
+
+	var changed []Alert
+	for typ, a := range m.active {
+		if a.State != alertRaised || (id != "" && id != "all" && id != a.ID && id != typ) {
+			continue
+		}
+		a.State, a.Acknowledged, a.beat = alertAcknowledged, &ts, ts
+		changed = append(changed, *a)
+	}
+
+	return changed
+}
+
+// Snooze snoozes alerts of type typ at time ts for duration d and returns the snooze events.
+// Empty typ or "all" snoozes the types of all the active alerts; zero duration cancels the snooze.
+// Active alerts of the snoozed types are dropped without being cleared.
+func (m *AlertManager) Snooze(ts time.Time, typ string, d time.Duration) []Alert {
+	var types []string
+	switch typ = strings.TrimSpace(typ); typ {
+	case "", "all":
+		for t := range m.active {
+			types = append(types, t)
+		}
+	default:
+		types = []string{typ}
+	}
+
+	var events []Alert
+	for _, t := range types {
+		if d <= 0 {
+			if _, ok := m.snoozed[t]; ok {
+				delete(m.snoozed, t)
+				fmt.Printf("Snooze of %s alerts cancelled\n", t)
+			}
+			continue
+		}
+
+		until := ts.Add(d)
+		m.snoozed[t] = until
+		fmt.Printf("Snoozing %s alerts until %s\n", t, until.Format(time.RFC3339))
+
+		e := Alert{Type: t, Raised: ts}
+		if a, ok := m.active[t]; ok {
+			e = *a
+			delete(m.active, t)
+		}
+		e.State, e.Snoozed, e.SnoozedUntil = alertSnoozed, &ts, &until
+		events = append(events, e)
+	}
+
+	return events
+}
+
+// acknowledged returns types of active alerts which were acknowledged
+func (m *AlertManager) acknowledged() map[string]bool {
+	acked := make(map[string]bool)
+	for typ, a := range m.active {
+		if a.State == alertAcknowledged {
+			acked[typ] = true
+		}
+	}
+
+	return acked
+}
+
+// severities returns severities of active alerts by their types
+func (m *AlertManager) severities() map[string]string {
+	severities := make(map[string]string)
+	for typ, a := range m.active {
+		severities[typ] = a.Severity
+	}
+
+	return severities
+}
+
+// newAckHandler returns MQTT message handler which acknowledges alerts whose ID or type is in message payload
+func newAckHandler(cmdChan chan<- *command) MQTT.MessageHandler {
+	return func(c MQTT.Client, msg MQTT.Message) {
+		select {
+		case cmdChan <- &command{name: cmdAck, arg: string(msg.Payload())}:
+		default:
+			fmt.Printf("Ignoring alert acknowledgment %s: another acknowledgment is in progress\n", msg.Payload())
+		}
+	}
+}
+
+// alertRunner publishes alert state transitions received from alertsChan and routed to sink to c as they happen
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func alertRunner(doneChan <-chan struct{}, alertsChan <-chan Alert, c Sink, sink string) error {
+	for {
+		select {
+		case a := <-alertsChan:
+			if !a.routedTo(sink) {
+				continue
+			}
+			t := alertsTopic
+			if a.Heartbeat {
+				t = heartbeatTopic
+			}
+			if err := c.Send(t, a.ToMQTTMessage()); err != nil {
+				fmt.Printf("Error publishing message to %s: %v\n", t, err)
+			}
+		case <-doneChan:
+			fmt.Printf("Stopping alertRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
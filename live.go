@@ -0,0 +1,110 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/intel-iot-devkit/machine-operator-monitor-go/payload"
+)
+
+const (
+	// liveBuffer is number of messages buffered for every live feed client; slow clients miss the messages which don't fit
+	liveBuffer = 16
+	// liveWriteTimeout is time to wait for live feed client to accept message before it is disconnected
+	liveWriteTimeout = 5 * time.Second
+)
+
+// liveUpgrader upgrades live feed requests to WebSocket connections.
+// Dashboards are usually served from other hosts, so requests from any origin are accepted.
+var liveUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// handleLive upgrades the request to WebSocket connection and sends operator status of every processed frame
+// down the connection as JSON text message until the client disconnects
+func (s *APIServer) handleLive(w http.ResponseWriter, r *http.Request) {
+	conn, err := liveUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already responded with the error
+		return
+	}
+	defer conn.Close()
+
+	c := make(chan []byte, liveBuffer)
+	s.mu.Lock()
+	s.live[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.live, c)
+		s.mu.Unlock()
+	}()
+
+	// the client sends nothing, so reading only detects it disconnected
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case msg := <-c:
+			conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// broadcast sends status to all the live feed clients; it must be called with s.mu held
+func (s *APIServer) broadcast(status *payload.Status) {
+	if len(s.live) == 0 {
+		return
+	}
+
+	msg, err := json.Marshal(status)
+	if err != nil {
+		fmt.Printf("Error encoding live status: %v\n", err)
+		return
+	}
+	for c := range s.live {
+		select {
+		case c <- msg:
+		default:
+		}
+	}
+}
@@ -0,0 +1,111 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Syslog priorities of the logged alerts; log/syslog defines them only on the platforms which support it
+const (
+	logCrit    = 2
+	logWarning = 4
+	logNotice  = 5
+	logInfo    = 6
+)
+
+// AlertLogger writes alert state transitions to system log
+type AlertLogger interface {
+	// Log writes message with priority and structured fields to the log
+	Log(priority int, message string, fields map[string]string) error
+	// Close closes connection to the log
+	Close() error
+}
+
+// alertPriority returns syslog priority of alert a: raised alerts are logged with priority of their severity,
+// while the other state transitions are notices
+func alertPriority(a Alert) int {
+	if a.State != alertRaised {
+		return logNotice
+	}
+
+	switch a.Severity {
+	case severityCritical:
+		return logCrit
+	case severityWarning:
+		return logWarning
+	default:
+		return logInfo
+	}
+}
+
+// alertLogFields returns structured fields alert a is logged with; the field names follow journald conventions
+func alertLogFields(a Alert) map[string]string {
+	fields := map[string]string{
+		"ALERT_ID":       a.ID,
+		"ALERT_TYPE":     a.Type,
+		"ALERT_STATE":    a.State,
+		"ALERT_SEVERITY": a.Severity,
+	}
+	if machineID != "" {
+		fields["MACHINE_ID"] = machineID
+	}
+	if cameraID != "" {
+		fields["CAMERA_ID"] = cameraID
+	}
+
+	return fields
+}
+
+// alertLogMessage returns log message describing state transition of alert a
+func alertLogMessage(a Alert) string {
+	var b strings.Builder
+	if machineID != "" {
+		fmt.Fprintf(&b, "machine %s: ", machineID)
+	}
+	fmt.Fprintf(&b, "%s %s alert %s: %s", a.Severity, a.Type, a.State, msg("alert."+a.Type))
+
+	return b.String()
+}
+
+// logRunner writes alert state transitions received from alertsChan and routed to sink to l as they happen;
+// heartbeats of persisting alerts are not logged
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func logRunner(doneChan <-chan struct{}, alertsChan <-chan Alert, l AlertLogger, sink string) error {
+	for {
+		select {
+		case a := <-alertsChan:
+			if !a.routedTo(sink) || a.Heartbeat {
+				continue
+			}
+			if err := l.Log(alertPriority(a), alertLogMessage(a), alertLogFields(a)); err != nil {
+				fmt.Printf("Error logging alert %s to %s: %v\n", a.ID, sink, err)
+			}
+		case <-doneChan:
+			fmt.Printf("Stopping logRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,151 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/syslog"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// journalSocket is socket journald receives log entries on in its native protocol
+const journalSocket = "/run/systemd/journal/socket"
+
+// syslogLogger writes alerts to syslog
+type syslogLogger struct {
+	// w writes to the local syslog daemon or to the remote syslog server
+	w *syslog.Writer
+}
+
+// newSyslogLogger connects to syslog at addr and returns the logger.
+// Address local connects to the local syslog daemon, while udp://host:port and tcp://host:port connect
+// to remote syslog server. It returns error if the address is invalid or if it fails to connect.
+func newSyslogLogger(addr string) (AlertLogger, error) {
+	var network, raddr string
+	if addr != "local" {
+		u, err := url.Parse(addr)
+		if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Host == "" {
+			return nil, fmt.Errorf("Invalid syslog address: %s", addr)
+		}
+		network, raddr = u.Scheme, u.Host
+	}
+
+	w, err := syslog.Dial(network, raddr, syslog.LOG_DAEMON|syslog.LOG_INFO, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogLogger{w: w}, nil
+}
+
+// Log writes message with priority to syslog; the fields are appended to the message as key=value pairs
+func (l *syslogLogger) Log(priority int, message string, fields map[string]string) error {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		message += " " + strings.ToLower(k) + "=" + strconv.Quote(fields[k])
+	}
+
+	switch priority {
+	case logCrit:
+		return l.w.Crit(message)
+	case logWarning:
+		return l.w.Warning(message)
+	case logNotice:
+		return l.w.Notice(message)
+	default:
+		return l.w.Info(message)
+	}
+}
+
+// Close closes connection to syslog
+func (l *syslogLogger) Close() error {
+	return l.w.Close()
+}
+
+// journalLogger writes alerts to systemd journal in journald native protocol
+type journalLogger struct {
+	// conn is connection to journald socket
+	conn *net.UnixConn
+}
+
+// newJournalLogger connects to the local journald and returns the logger
+// It returns error if journald is not running.
+func newJournalLogger() (AlertLogger, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &journalLogger{conn: conn}, nil
+}
+
+// Log writes message with priority and fields as journal entry fields
+func (l *journalLogger) Log(priority int, message string, fields map[string]string) error {
+	var b bytes.Buffer
+	writeJournalField(&b, "PRIORITY", strconv.Itoa(priority))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", name)
+	writeJournalField(&b, "MESSAGE", message)
+	for k, v := range fields {
+		writeJournalField(&b, k, v)
+	}
+
+	_, err := l.conn.Write(b.Bytes())
+
+	return err
+}
+
+// writeJournalField writes journal entry field; values with newlines are written with their length
+// as journald native protocol requires
+func writeJournalField(b *bytes.Buffer, key, value string) {
+	b.WriteString(key)
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// Close closes connection to journald
+func (l *journalLogger) Close() error {
+	return l.conn.Close()
+}
@@ -24,33 +24,94 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"image"
 	"image/color"
+	"math"
 	"os"
 	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"syscall"
+	"text/template"
 	"time"
 
+	"github.com/intel-iot-devkit/machine-operator-monitor-go/payload"
 	"gocv.io/x/gocv"
 )
 
 const (
 	// name is a program name
 	name = "machine-operator-monitor"
-	// topic is MQTT topic
+	// topic is default MQTT topic prefix
 	topic = "machine/safety"
-	// alertWatching contains text to display when operator is not watching the machine
-	alertWatching = "Operator not watching: PAUSE THE MACHINE!"
-	// alertAngry contains text to display when operator is operating machine angrily
-	alertAngry = "Operator angry: PAUSE THE MACHINE!"
+	// alertWatching is message key of text to display when operator is not watching the machine
+	alertWatching = "alert.watching"
+	// alertAngry is message key of text to display when operator is operating machine angrily
+	alertAngry = "alert.angry"
+	// alertDistance is message key of text to display when operator is too close to the machine
+	alertDistance = "alert.distance"
+	// alertDrowsy is message key of text to display when operator is drowsy
+	alertDrowsy = "alert.drowsy"
+	// alertPPE is message key of text to display when operator does not wear required protective equipment
+	alertPPE = "alert.ppe"
+	// alertAbsent is message key of text to display when operator is absent
+	alertAbsent = "alert.absent"
+	// alertPhone is message key of text to display when operator is distracted by phone
+	alertPhone = "alert.phone"
+	// alertUnattended is message key of text to display when nobody is at the machine
+	alertUnattended = "alert.unattended"
+	// alertCrowd is message key of text to display when too many operators are at the machine
+	alertCrowd = "alert.crowd"
+	// alertRisk is message key of text to display when operator risk score exceeds threshold
+	alertRisk = "alert.risk"
+	// alertTamper is message key of text to display when camera is obstructed or tampered with
+	alertTamper = "alert.tamper"
+	// alertEmotion is message key of text to display when operator sentiment triggers emotion policy
+	alertEmotion = "alert.emotion"
+	// labelWatching is message key of watching status label
+	labelWatching = "label.watching"
+	// labelAngry is message key of angry status label
+	labelAngry = "label.angry"
+	// labelOperator is message key of operator label
+	labelOperator = "label.operator"
+	// labelDistance is message key of distance label
+	labelDistance = "label.distance"
+	// labelDegradation is message key of degradation level label
+	labelDegradation = "label.degradation"
+	// labelRisk is message key of operator risk score label
+	labelRisk = "label.risk"
+	// labelPaused is message key of paused monitoring label
+	labelPaused = "label.paused"
+	// labelSuspended is message key of monitoring suspended outside of shifts label
+	labelSuspended = "label.suspended"
+	// labelFaceTime is message key of face inference time label
+	labelFaceTime = "label.face-time"
+	// labelSentTime is message key of sentiment inference time label
+	labelSentTime = "label.sent-time"
+	// labelPoseTime is message key of pose inference time label
+	labelPoseTime = "label.pose-time"
+	// labelCaptureFPS is message key of capture frame rate label
+	labelCaptureFPS = "label.capture-fps"
+	// labelProcessedFPS is message key of processed frame rate label
+	labelProcessedFPS = "label.processed-fps"
+	// labelLatency is message key of capture-to-display latency label
+	labelLatency = "label.latency"
 )
 
 var (
 	// deviceID is camera device ID
 	deviceID int
+	// machineID identifies the monitored machine in the published payloads
+	machineID string
+	// cameraID identifies the camera in the published payloads
+	cameraID string
+	// siteID identifies the plant site the machine is at in InfluxDB tags
+	siteID string
 	// input is path to image or video file
 	input string
 	// faceModel is path to .bin file of face detection model
@@ -65,47 +126,661 @@ var (
 	sentConfig string
 	// sentConfidence is confidence threshold for sentiment detection model
 	sentConfidence float64
+	// locale selects message catalog of alert texts and on-screen labels
+	locale string
+	// messagesDir is path to directory with message catalogs
+	messagesDir string
+	// sentLabelsFile is path to file which maps sentiment model output classes to sentiments
+	sentLabelsFile string
+	// sentLabels maps sentiment model output classes to sentiments
+	sentLabels []Sentiment
+	// sentWindow is number of frames over which the sentiment is smoothed
+	sentWindow int
+	// emotionAlerts are comma separated emotion policies which raise the emotion alert
+	emotionAlerts string
 	// poseModel is path to .bin file of pose detection model
 	poseModel string
 	// poseConfig is path to .xml file of pose detection model configuration
 	poseConfig string
 	// poseConfidence is confidence threshold for pose detection model
 	poseConfidence float64
+	// faceMinSize is minimum face width and height in pixels
+	faceMinSize int
+	// faceMaxAspect is maximum ratio of longer and shorter side of face bounding box
+	faceMaxAspect float64
+	// faceMinSharpness is minimum face sharpness measured as variance of Laplacian
+	faceMinSharpness float64
+	// poseYaw is yaw angle in degrees of head turned towards the machine
+	poseYaw float64
+	// posePitch is pitch angle in degrees of head turned towards the machine
+	posePitch float64
+	// poseYawLimit is maximum yaw angle in degrees of head turned away from the machine
+	poseYawLimit float64
+	// posePitchLimit is maximum pitch angle in degrees of head turned away from the machine
+	posePitchLimit float64
+	// poseRollLimit is maximum head roll angle in degrees
+	poseRollLimit float64
+	// poseMinSize is face size in pixels below which head pose quality degrades
+	poseMinSize int
+	// facePrecision is precision of face detection model
+	facePrecision string
+	// sentPrecision is precision of sentiment detection model
+	sentPrecision string
+	// posePrecision is precision of pose detection model
+	posePrecision string
+	// poseLayersList is comma separated list of pose detection model yaw, pitch and roll output layer names
+	poseLayersList string
+	// poseLayers are names of pose detection model yaw, pitch and roll output layers
+	poseLayers []string
 	// angryTimeout is maximum time operator is allowed to be angry operating machine for
 	angryTimeout time.Duration
 	// watchTimeout is maximum time operator is allowed not to be watching machine for
 	watchTimeout time.Duration
+	// attentionWindow is rolling time window the attention ratio is measured over; zero uses watchTimeout instead
+	attentionWindow time.Duration
+	// attentionThreshold is minimum ratio of time operator must be watching machine for within attentionWindow
+	attentionThreshold float64
+	// gpioPin is GPIO pin asserted while an alert is active; negative disables it
+	gpioPin int
+	// gpioActiveLow means the GPIO pin is asserted by driving it low
+	gpioActiveLow bool
+	// gpioAlerts are comma separated types of alerts which assert the GPIO pin
+	gpioAlerts string
+	// modbusAddr is address of Modbus TCP server which pauses the machine
+	modbusAddr string
+	// modbusUnit is Modbus unit identifier
+	modbusUnit int
+	// modbusCoil is address of Modbus coil set while the machine should be paused
+	modbusCoil int
+	// modbusRegister is address of Modbus holding register written while the machine should be paused
+	modbusRegister int
+	// modbusValue is value written into Modbus register while the machine should be paused
+	modbusValue int
+	// modbusSeverity is minimum severity of alerts which pause the machine over Modbus
+	modbusSeverity string
+	// opcuaEndpoint is OPC UA server endpoint
+	opcuaEndpoint string
+	// opcuaNodes maps alert and status values to IDs of OPC UA nodes they are written into
+	opcuaNodes string
+	// opcuaRunState is ID of OPC UA node which holds machine run state
+	opcuaRunState string
+	// historyFile is path to alert history file
+	historyFile string
+	// historySnapshots is path to directory with frame snapshots of raised alerts
+	historySnapshots string
+	// snapshotDir is path to directory with annotated frame snapshots of raised alerts; empty if disabled
+	snapshotDir string
+	// snapshotAttach is how the annotated snapshots are attached to the published alerts: path, base64 or none if empty
+	snapshotAttach string
+	// emailAll means all alert state transitions are emailed, not only the alerts escalated to email
+	emailAll bool
+	// emailTemplateDir is path to directory with alert email templates; empty if the built-in template is used
+	emailTemplateDir string
+	// emailTemplates are alert email templates by alert types
+	emailTemplates map[string]*template.Template
+	// emailInterval is minimum interval between emails of the same alert type; zero disables the limit
+	emailInterval time.Duration
+	// emailSnapshots means annotated snapshots of raised alerts are attached to the alert emails
+	emailSnapshots bool
+	// alertSeverities are comma separated alert severity rules
+	alertSeverities string
+	// alertBanner are comma separated alert display modes by severities
+	alertBanner string
+	// alertFlash is period in which alert borders and banners flash
+	alertFlash time.Duration
+	// alertEscalations are comma separated alert escalation steps
+	alertEscalations string
+	// grace is period after startup and input source change during which no alerts are raised
+	grace time.Duration
+	// alertHeartbeat is interval in which persisting alerts are republished; zero disables the heartbeat
+	alertHeartbeat time.Duration
+	// snoozeDuration is default duration alerts are snoozed for
+	snoozeDuration time.Duration
+	// alertHold is time alert stays raised after its condition clears
+	alertHold time.Duration
+	// alertCooldown is time after alert clears before it can be raised again
+	alertCooldown time.Duration
+	// angryResetFrames is number of consecutive frames operator must not be angry for the anger timer to reset
+	angryResetFrames int
+	// watchResetFrames is number of consecutive frames operator must be watching for the watching timer to reset
+	watchResetFrames int
 	// backend is inference backend
 	backend int
 	// target is inference target
 	target int
+	// targetMode is HETERO or MULTI mode of target devices; empty if single target device is used
+	targetMode string
+	// targetDevices are targets of HETERO or MULTI target devices
+	targetDevices []int
+	// gpuIndex is index of GPU running OpenCL inference
+	gpuIndex int
+	// faceBackend is face detection model inference backend
+	faceBackend = -1
+	// faceTarget is face detection model inference target
+	faceTarget = -1
+	// sentBackend is sentiment detection model inference backend
+	sentBackend = -1
+	// sentTarget is sentiment detection model inference target
+	sentTarget = -1
+	// poseBackend is pose detection model inference backend
+	poseBackend = -1
+	// poseTarget is pose detection model inference target
+	poseTarget = -1
 	// publish is a flag which instructs the program to publish data analytics
 	publish bool
+	// kafka is a flag which instructs the program to publish data analytics and alerts to Apache Kafka
+	kafka bool
+	// awsIoT is a flag which instructs the program to publish data analytics and alerts to AWS IoT Core
+	awsIoT bool
+	// awsIoTShadow is a flag which instructs the program to report operator status in AWS IoT thing shadow
+	awsIoTShadow bool
+	// azureIoT is a flag which instructs the program to publish data analytics and alerts to Azure IoT Hub
+	azureIoT bool
+	// pubsubPublish is a flag which instructs the program to publish data analytics and alerts to Google Cloud Pub/Sub
+	pubsubPublish bool
+	// hass is a flag which instructs the program to announce its data analytics to Home Assistant by MQTT discovery
+	hass bool
+	// hassPrefix is Home Assistant MQTT discovery prefix
+	hassPrefix string
+	// sparkplugGroup is Sparkplug B group ID the monitor reports to as edge node; empty if Sparkplug B is disabled
+	sparkplugGroup string
+	// amqpPublish is a flag which instructs the program to publish data analytics and alerts to AMQP broker
+	amqpPublish bool
 	// rate is number of seconds between analytics are collected and sent to a remote server
 	rate int
+	// qos is Quality Of Service of MQTT topics without their own options
+	qos int
+	// topicOpts are comma separated publishing options of MQTT topics
+	topicOpts string
+	// topicPrefix is prefix of all MQTT topics
+	topicPrefix string
+	// resultsSubtopic is MQTT sub-topic for operator status; empty uses topicPrefix itself
+	resultsSubtopic string
+	// alertsSubtopic is MQTT sub-topic for alert state transitions
+	alertsSubtopic string
+	// heartbeatSubtopic is MQTT sub-topic for heartbeats of persisting alerts
+	heartbeatSubtopic string
+	// perfSubtopic is MQTT sub-topic for inference engine performance
+	perfSubtopic string
+	// mqttBuffer is maximum number of messages buffered while MQTT broker is unreachable; zero disables buffering
+	mqttBuffer int
+	// syslogAddr is address of syslog the alerts are logged to: local or udp://host:port or tcp://host:port
+	syslogAddr string
+	// journald is a flag which instructs the program to log alerts to systemd journal
+	journald bool
+	// detections is a flag which instructs the program to publish detailed detection payload of every processed frame
+	detections bool
+	// mqttBufferFile is path to file the buffered messages are persisted in; empty keeps them in memory only
+	mqttBufferFile string
+	// slack is a flag which instructs the program to post alerts to Slack
+	slack bool
+	// slackSnapshots means annotated snapshots of raised alerts are posted to Slack along with the alerts
+	slackSnapshots bool
+	// smsTo are comma separated phone numbers alerts are texted to with optional shifts
+	smsTo string
+	// smsRecipients are phone numbers alerts are texted to parsed from smsTo
+	smsRecipients []smsRecipient
+	// smsSeverity is minimum severity of the alerts texted unless they are escalated to SMS
+	smsSeverity string
+	// smsDailyCap is maximum number of texts sent per day; zero means unlimited
+	smsDailyCap int
+	// incidentService is incident management service alerts open incidents in; empty if disabled
+	incidentService string
+	// incidentSeverity is minimum severity of the alerts which open incidents unless they are escalated to incident
+	incidentSeverity string
+	// pushService is service alert notifications are pushed to phones through; empty if disabled
+	pushService string
+	// webhookURLs are comma separated URLs of HTTP endpoints alerts are posted to
+	webhookURLs string
+	// webhookHeaders are comma separated custom HTTP headers sent to the webhook endpoints
+	webhookHeaders string
+	// webhookRetries is number of times a failed webhook request is retried
+	webhookRetries int
+	// webhookStatus is a flag which instructs the program to post operator status to the webhook endpoints
+	webhookStatus bool
+	// httpSink is URL of HTTP endpoint batches of operator status are posted to
+	httpSink string
+	// httpSinkBatch is maximum number of operator statuses in a batch posted to the HTTP sink
+	httpSinkBatch int
+	// apiAddr is address the REST API server listens on; empty if the server is disabled
+	apiAddr string
+	// streamFPS is maximum frame rate of the MJPEG video stream served by the API server
+	streamFPS int
+	// grpcAddr is address the gRPC streaming server listens on; empty if the server is disabled
+	grpcAddr string
+	// otel is a flag which instructs the program to export pipeline spans and metrics via OTLP
+	otel bool
+	// storePath is path to SQLite event store; empty if the events are not stored
+	storePath string
+	// storeSummary is interval in which operator status summaries are stored
+	storeSummary time.Duration
+	// csvPath is path to CSV file the operator status of the live session is written to; empty if disabled
+	csvPath string
+	// influx is a flag which instructs the program to write operator status and inference performance to InfluxDB
+	influx bool
 	// delay is video playback delay
 	delay float64
+	// headless is a flag which instructs the program to run without display window
+	headless bool
+	// windowSize is size of the display window in widthxheight format
+	windowSize string
+	// windowPos is position of the display window on the screen in x,y format
+	windowPos string
+	// fullscreen is a flag which instructs the program to show the display window in fullscreen
+	fullscreen bool
+	// windowTopmost is a flag which instructs the program to keep the display window above other windows
+	windowTopmost bool
+	// facePanelCorner is corner of the frame the primary operator face panel is shown in; empty if disabled
+	facePanelCorner string
+	// facePanelSize is width of the primary operator face panel in pixels
+	facePanelSize int
+	// fpsCounter is a flag which instructs the program to show frame rates and latency in the display overlay
+	fpsCounter bool
+	// fpsLog is interval in which frame rates and latency are logged
+	fpsLog time.Duration
+	// overlayPose is a flag which instructs the program to draw head pose and gaze of the faces in the display overlay
+	overlayPose bool
+	// overlayColor is color of the overlay status text in r,g,b format
+	overlayColor string
+	// overlayOutline is color of the overlay text outline in r,g,b format; none disables the outline
+	overlayOutline string
+	// overlayAlert is color of the overlay alert text of alerts without severity color in r,g,b format
+	overlayAlert string
+	// overlayFontScale is scale of the overlay text font
+	overlayFontScale float64
+	// overlayThickness is thickness of the overlay text strokes in pixels
+	overlayThickness int
+	// overlayPos is position of the first overlay text line in x,y format
+	overlayPos string
+	// overlayLineHeight is distance between the overlay text lines in pixels; 0 derives it from the font scale
+	overlayLineHeight int
+	// depthDeviceID is RealSense camera depth stream device ID
+	depthDeviceID int
+	// depthScale is number of meters per depth unit of the RealSense depth capture
+	depthScale float64
+	// depthMaxDistance is maximum distance of the detected face from the camera in meters
+	depthMaxDistance float64
+	// depthMinRelief is minimum depth variation of the detected face in meters
+	depthMinRelief float64
+	// minDistance is minimum distance in meters operator is allowed to be from the machine
+	minDistance float64
+	// record is path to directory where raw input video is recorded
+	record string
+	// recordCodec is FOURCC code of the recorded video codec
+	recordCodec string
+	// recordSegment is maximum duration of a single recorded video file
+	recordSegment time.Duration
+	// recordKeep is maximum number of recorded video files kept on disk
+	recordKeep int
+	// clips is path to directory where video clips of raised alerts are recorded; empty if disabled
+	clips string
+	// clipPre is time before the alert the alert clips cover
+	clipPre time.Duration
+	// clipPost is time after the alert the alert clips cover
+	clipPost time.Duration
+	// captureAPI is preferred video capture API
+	captureAPI string
+	// hwDecode is hardware video decoder
+	hwDecode string
+	// replay derives time from input video frame timestamps instead of wall clock
+	replay bool
+	// control is a flag which instructs the program to accept remote control commands
+	control bool
+	// screen is captured screen region
+	screen string
+	// screenWindow is name of captured window
+	screenWindow string
+	// screenFPS is screen capture frame rate
+	screenFPS int
+	// asyncRequests is number of inference requests in flight per network
+	asyncRequests int
+	// warmup is number of test forward passes run through the models before monitoring starts
+	warmup int
+	// reuseMaxAge is maximum age of head pose and sentiment reused for faces which haven't moved
+	reuseMaxAge time.Duration
+	// reuseIoU is minimum overlap of face bounding boxes for the face to be considered not moving
+	reuseIoU float64
+	// latencyBudget is per-frame inference latency budget
+	latencyBudget time.Duration
+	// watchModels is interval between model file modification checks
+	watchModels time.Duration
+	// track is a flag which instructs the program to track faces and monitor every operator individually
+	track bool
+	// trackIoU is minimum overlap of bounding boxes of the same face in consecutive frames
+	trackIoU float64
+	// trackMaxAge is maximum time a face is tracked without being detected
+	trackMaxAge time.Duration
+	// landmarksModel is path to .bin file of facial landmarks detection model
+	landmarksModel string
+	// landmarksConfig is path to .xml file of facial landmarks detection model configuration
+	landmarksConfig string
+	// alignFaces aligns faces using facial landmarks before sentiment detection
+	alignFaces bool
+	// gazeModel is path to .bin file of gaze estimation model
+	gazeModel string
+	// gazeConfig is path to .xml file of gaze estimation model configuration
+	gazeConfig string
+	// gazeYaw is yaw angle in degrees of the direction from the operator towards the machine
+	gazeYaw float64
+	// gazePitch is pitch angle in degrees of the direction from the operator towards the machine
+	gazePitch float64
+	// gazeCone is maximum angle in degrees between operator gaze and the direction towards the machine
+	gazeCone float64
+	// eyeModel is path to .bin file of eye state detection model
+	eyeModel string
+	// eyeConfig is path to .xml file of eye state detection model configuration
+	eyeConfig string
+	// perclosWindow is time window over which the eye closure ratio is measured
+	perclosWindow time.Duration
+	// perclosThreshold is maximum ratio of time operator is allowed to have their eyes closed
+	perclosThreshold float64
+	// ppeModel is path to .bin file of protective equipment detection model
+	ppeModel string
+	// ppeConfig is path to .xml file of protective equipment detection model configuration
+	ppeConfig string
+	// ppeConfidence is confidence threshold for protective equipment detection model
+	ppeConfidence float64
+	// ppeLabelsList is comma separated list of protective equipment detection model class labels
+	ppeLabelsList string
+	// ppeLabels are labels of protective equipment detection model classes
+	ppeLabels []string
+	// ppeRequiredList is comma separated list of protective equipment operator is required to wear
+	ppeRequiredList string
+	// ppeRequired are labels of protective equipment operator is required to wear
+	ppeRequired []string
+	// ppeTimeout is maximum time operator is allowed not to wear protective equipment for
+	ppeTimeout time.Duration
+	// reidModel is path to .bin file of face reidentification model
+	reidModel string
+	// reidConfig is path to .xml file of face reidentification model configuration
+	reidConfig string
+	// gallery is path to directory with images of known operators
+	gallery string
+	// reidThreshold is minimum face similarity required to identify operator
+	reidThreshold float64
+	// thresholdsSource is path to file or URL of endpoint with alert thresholds of individual operators
+	thresholdsSource string
+	// thresholdsInterval is interval between reloads of operator thresholds; zero loads them only once
+	thresholdsInterval time.Duration
+	// phoneModel is path to .bin file of phone detection model
+	phoneModel string
+	// phoneConfig is path to .xml file of phone detection model configuration
+	phoneConfig string
+	// phoneConfidence is confidence threshold for phone detection
+	phoneConfidence float64
+	// phoneClass is class ID of phones in phone detection model output
+	phoneClass int
+	// phoneTimeout is maximum time operator is allowed to use phone for
+	phoneTimeout time.Duration
+	// personModel is path to .bin file of person detection model
+	personModel string
+	// personConfig is path to .xml file of person detection model configuration
+	personConfig string
+	// personConfidence is confidence threshold for person detection
+	personConfidence float64
+	// absentTimeout is maximum time operator is allowed to be absent for
+	absentTimeout time.Duration
+	// schedule is shift schedule
+	schedule string
+	// tamper enables camera tampering detection
+	tamper bool
+	// tamperTimeout is maximum time the frames are allowed to look tampered for
+	tamperTimeout time.Duration
+	// tamperBrightness is mean frame brightness below which the camera looks obstructed
+	tamperBrightness float64
+	// tamperSharpness is frame sharpness below which the camera looks obstructed
+	tamperSharpness float64
+	// risk enables operator risk scoring
+	risk bool
+	// riskThreshold is risk score which raises alert; zero disables the alert
+	riskThreshold float64
+	// riskWindow is duration of the rolling time window the risk score is measured over
+	riskWindow time.Duration
+	// riskWeightsList are comma separated weights of the risk score components
+	riskWeightsList string
+	// riskStationTime is time on station which maximizes time on station risk
+	riskStationTime time.Duration
+	// rulesPath is path to JSON file with custom alert rules
+	rulesPath string
+	// unattendedTimeout is maximum time the machine is allowed to be unattended for
+	unattendedTimeout time.Duration
+	// maxOperators is maximum number of faces allowed in the operator zone; zero disables the check
+	maxOperators int
+	// crowdTimeout is maximum time more than maxOperators faces are allowed in the operator zone for
+	crowdTimeout time.Duration
+	// operatorZone is operator zone in x,y,width,height format
+	operatorZone string
+	// maskModel is path to .bin file of face mask detection model
+	maskModel string
+	// maskConfig is path to .xml file of face mask detection model configuration
+	maskConfig string
+	// maskConfidence is confidence threshold for face mask detection
+	maskConfidence float64
+	// maskPolicy is how sentiment of masked faces is handled
+	maskPolicy string
 )
 
 func init() {
 	flag.IntVar(&deviceID, "device", -1, "Camera device ID")
 	flag.StringVar(&input, "input", "", "Path to image or video file")
+	flag.StringVar(&machineID, "machine-id", os.Getenv("MACHINE_ID"), "ID of the monitored machine included in the published payloads")
+	flag.StringVar(&cameraID, "camera-id", "", "ID of the camera included in the published payloads. Default: -input or -device")
+	flag.StringVar(&siteID, "site-id", os.Getenv("SITE_ID"), "ID of the plant site the machine is at included in InfluxDB tags")
 	flag.StringVar(&faceModel, "face-model", "", "Path to .bin file of face detection model")
 	flag.StringVar(&faceConfig, "face-config", "", "Path to .xml file of face model configuration")
 	flag.Float64Var(&faceConfidence, "face-confidence", 0.5, "Confidence threshold for face detection")
 	flag.StringVar(&sentModel, "sent-model", "", "Path to .bin file of sentiment detection model")
 	flag.StringVar(&sentConfig, "sent-config", "", "Path to .xml file of sentiment model configuration")
 	flag.Float64Var(&sentConfidence, "sent-confidence", 0.5, "Confidence threshold for sentiment detection")
+	flag.StringVar(&locale, "locale", os.Getenv("MONITOR_LOCALE"), "Locale of alert texts and on-screen labels, e.g. de or de_DE. Default: built-in English")
+	flag.StringVar(&messagesDir, "messages", "messages", "Path to directory with <locale>.txt message catalogs")
+	flag.StringVar(&sentLabelsFile, "sent-labels", "", "Path to file which maps sentiment model output classes to sentiments, one per line")
+	flag.IntVar(&sentWindow, "sent-window", 1, "Number of frames over which the sentiment is smoothed by majority vote")
+	flag.StringVar(&emotionAlerts, "emotion-alerts", "", "Comma separated sentiments which raise emotion alert when they last too long, e.g. sad=30s, or recur too often, e.g. surprised=3/1m")
 	flag.StringVar(&poseModel, "pose-model", "", "Path to .bin file of pose detection model")
 	flag.StringVar(&poseConfig, "pose-config", "", "Path to .xml file of pose detection model configuration")
 	flag.Float64Var(&poseConfidence, "pose-confidence", 0.5, "Confidence threshold for pose detection")
+	flag.IntVar(&faceMinSize, "face-min-size", 0, "Minimum face width and height in pixels; smaller faces are ignored")
+	flag.Float64Var(&faceMaxAspect, "face-max-aspect", 0, "Maximum ratio of longer and shorter side of face bounding box; more distorted faces are ignored. 0: disabled")
+	flag.Float64Var(&faceMinSharpness, "face-min-sharpness", 0, "Minimum face sharpness measured as variance of Laplacian; blurred faces are ignored. 0: disabled")
+	flag.Float64Var(&poseYaw, "pose-yaw", 0, "Yaw angle in degrees of head turned towards the machine. 0: towards the camera")
+	flag.Float64Var(&posePitch, "pose-pitch", 0, "Pitch angle in degrees of head turned towards the machine. 0: towards the camera")
+	flag.Float64Var(&poseYawLimit, "pose-yaw-limit", 22.5, "Maximum yaw angle in degrees of operator head turned away from the machine")
+	flag.Float64Var(&posePitchLimit, "pose-pitch-limit", 22.5, "Maximum pitch angle in degrees of operator head turned away from the machine")
+	flag.Float64Var(&poseRollLimit, "pose-roll-limit", 180, "Maximum roll angle in degrees of operator head")
+	flag.IntVar(&poseMinSize, "pose-min-size", 60, "Face size in pixels below which pose detection confidence degrades")
+	flag.StringVar(&facePrecision, "face-precision", "", "Precision of face detection model: FP32, FP16, INT8. Empty: as in model path")
+	flag.StringVar(&sentPrecision, "sent-precision", "", "Precision of sentiment detection model: FP32, FP16, INT8. Empty: as in model path")
+	flag.StringVar(&posePrecision, "pose-precision", "", "Precision of pose detection model: FP32, FP16, INT8. Empty: as in model path")
+	flag.StringVar(&poseLayersList, "pose-layers", "angle_y_fc,angle_p_fc,angle_r_fc", "Comma separated names of pose detection model yaw, pitch and roll output layers")
 	flag.DurationVar(&angryTimeout, "angry-timeout", 5*time.Second, "Maximum time operator is allowed to be angry for")
 	flag.DurationVar(&watchTimeout, "watch-timeout", 5*time.Second, "Maximum time operator is allowed to not be watching the machine for")
-	flag.IntVar(&backend, "backend", 0, "Inference backend. 0: Auto, 1: Halide language, 2: Intel DL Inference Engine")
-	flag.IntVar(&target, "target", 0, "Target device. 0: CPU, 1: OpenCL, 2: OpenCL half precision, 3: VPU")
+	flag.DurationVar(&attentionWindow, "attention-window", 0, "Rolling time window the attention ratio is measured over, e.g. 60s. 0: use -watch-timeout instead")
+	flag.Float64Var(&attentionThreshold, "attention-threshold", 0.7, "Minimum ratio of time operator must be watching the machine for within -attention-window")
+	flag.IntVar(&gpioPin, "gpio-pin", -1, "GPIO pin asserted while an alert is active, e.g. to gate machine enable circuit. -1: disabled")
+	flag.BoolVar(&gpioActiveLow, "gpio-active-low", false, "Assert the GPIO pin by driving it low")
+	flag.StringVar(&gpioAlerts, "gpio-alerts", "", "Comma separated types of alerts which assert the GPIO pin. Default: all")
+	flag.StringVar(&modbusAddr, "modbus", "", "Address of Modbus TCP server, e.g. PLC, which pauses the machine, in host:port format")
+	flag.IntVar(&modbusUnit, "modbus-unit", 1, "Modbus unit identifier")
+	flag.IntVar(&modbusCoil, "modbus-coil", -1, "Address of Modbus coil set while the machine should be paused. -1: disabled")
+	flag.IntVar(&modbusRegister, "modbus-register", -1, "Address of Modbus holding register written while the machine should be paused. -1: disabled")
+	flag.IntVar(&modbusValue, "modbus-value", 1, "Value written into Modbus register while the machine should be paused; 0 is written when it can run")
+	flag.StringVar(&modbusSeverity, "modbus-severity", severityCritical, "Minimum severity of alerts which pause the machine over Modbus")
+	flag.StringVar(&opcuaEndpoint, "opcua", "", "OPC UA server endpoint, e.g. opc.tcp://localhost:4840")
+	flag.StringVar(&opcuaNodes, "opcua-nodes", "", "Comma separated value=node ID pairs of alert and status values written into OPC UA nodes, e.g. alert=ns=2;s=Monitor.Alert")
+	flag.StringVar(&opcuaRunState, "opcua-run-state", "", "ID of boolean OPC UA node which holds machine run state; alerts are suppressed while the machine is stopped")
+	flag.StringVar(&historyFile, "history", "", "Path to file recording alert history")
+	flag.StringVar(&historySnapshots, "history-snapshots", "", "Path to directory storing frame snapshots of raised alerts")
+	flag.StringVar(&snapshotDir, "snapshots", "", "Path to directory storing annotated frame snapshots of raised alerts")
+	flag.StringVar(&snapshotAttach, "snapshot-attach", "", "Attach annotated snapshots to the published alerts: path or base64. Default: not attached")
+	flag.BoolVar(&emailAll, "email", false, "Email all alert state transitions, not only the alerts escalated to email; configured by SMTP_* environment variables")
+	flag.StringVar(&emailTemplateDir, "email-templates", "", "Path to directory with <type>.tmpl alert email templates")
+	flag.DurationVar(&emailInterval, "email-interval", 0, "Minimum interval between emails of the same alert type. 0: not limited")
+	flag.BoolVar(&emailSnapshots, "email-snapshot", false, "Attach annotated snapshots of raised alerts to the alert emails")
+	flag.StringVar(&alertBanner, "alert-banner", "warning=border,critical=banner", "Comma separated alert display modes in severity=mode format. Mode: text, border or banner")
+	flag.DurationVar(&alertFlash, "alert-flash", 500*time.Millisecond, "Period in which alert borders and banners flash. 0: steady")
+	flag.StringVar(&alertSeverities, "alert-severity", "", "Comma separated alert severity rules in type=severity[:after] format, e.g. watching=critical:30s. Severity: info, warning or critical")
+	flag.StringVar(&alertEscalations, "alert-escalation", "", "Comma separated escalation steps of unacknowledged alerts in type=after:severity:sink[+sink...] format, e.g. watching=30s:critical:mqtt. Sinks: display, mqtt, email, webhook, kafka, amqp, aws-iot, azure-iot, pubsub, syslog, journald, slack, sms, incident, push")
+	flag.DurationVar(&grace, "grace", 5*time.Second, "Period after startup and input source change during which no alerts are raised")
+	flag.DurationVar(&alertHeartbeat, "alert-heartbeat", 0, "Interval in which persisting alerts are republished as heartbeats. 0: disabled")
+	flag.DurationVar(&snoozeDuration, "snooze", 15*time.Minute, "Default duration alerts are snoozed for")
+	flag.DurationVar(&alertHold, "alert-hold", 0, "Time alert stays raised after its condition clears")
+	flag.DurationVar(&alertCooldown, "alert-cooldown", 0, "Time after alert clears before the same alert can be raised again")
+	flag.IntVar(&angryResetFrames, "angry-reset-frames", 1, "Number of consecutive frames operator must not be angry for the anger timer to reset")
+	flag.IntVar(&watchResetFrames, "watch-reset-frames", 1, "Number of consecutive frames operator must be watching for the watching timer to reset")
+	flag.Var(&idFlag{value: &backend, names: backendNames}, "backend", "Inference backend. DEFAULT, HALIDE, OPENVINO or OPENCV")
+	flag.Var(&targetFlag{idFlag: idFlag{value: &target, names: deviceNames, auto: true}, mode: &targetMode, devices: &targetDevices},
+		"target", "Target device. CPU, GPU, GPU_FP16 (GPU in 16-bit mode), MYRIAD, AUTO (first of GPU, MYRIAD and CPU which runs the models) or HETERO and MULTI device list, e.g. HETERO:MYRIAD,CPU")
+	flag.IntVar(&gpuIndex, "gpu", -1, "Index of GPU running inference on GPU targets of OPENCV backend. -1: OpenCL default device")
+	flag.Var(&idFlag{value: &faceBackend, names: backendNames}, "face-backend", "Face detection inference backend. -1: same as -backend")
+	flag.Var(&idFlag{value: &faceTarget, names: deviceNames, auto: true}, "face-target", "Face detection target device. -1: same as -target")
+	flag.Var(&idFlag{value: &sentBackend, names: backendNames}, "sent-backend", "Sentiment detection inference backend. -1: same as -backend")
+	flag.Var(&idFlag{value: &sentTarget, names: deviceNames, auto: true}, "sent-target", "Sentiment detection target device. -1: same as -target")
+	flag.Var(&idFlag{value: &poseBackend, names: backendNames}, "pose-backend", "Pose detection inference backend. -1: same as -backend")
+	flag.Var(&idFlag{value: &poseTarget, names: deviceNames, auto: true}, "pose-target", "Pose detection target device. -1: same as -target")
 	flag.BoolVar(&publish, "publish", false, "Publish data analytics to a remote server")
+	flag.BoolVar(&kafka, "kafka", false, "Publish data analytics and alerts to Apache Kafka; configured by KAFKA_* environment variables")
+	flag.BoolVar(&awsIoT, "aws-iot", false, "Publish data analytics and alerts to AWS IoT Core; configured by AWS_IOT_* environment variables")
+	flag.BoolVar(&awsIoTShadow, "aws-iot-shadow", false, "Report operator status in AWS IoT thing shadow")
+	flag.BoolVar(&hass, "hass", false, "Announce operator status and alerts as Home Assistant entities by MQTT discovery; requires -publish")
+	flag.StringVar(&hassPrefix, "hass-prefix", "homeassistant", "Home Assistant MQTT discovery prefix")
+	flag.StringVar(&sparkplugGroup, "sparkplug", "", "Sparkplug B group ID to report data analytics to as edge node named by -machine-id; uses the MQTT_* broker configuration")
+	flag.BoolVar(&pubsubPublish, "pubsub", false, "Publish data analytics and alerts to Google Cloud Pub/Sub; configured by PUBSUB_* environment variables")
+	flag.BoolVar(&azureIoT, "azure-iot", false, "Publish data analytics and alerts to Azure IoT Hub and apply device twin config; configured by AZURE_* environment variables")
+	flag.BoolVar(&amqpPublish, "amqp", false, "Publish data analytics and alerts to AMQP 0-9-1 broker, e.g. RabbitMQ; configured by AMQP_* environment variables")
 	flag.IntVar(&rate, "rate", 1, "Number of seconds between analytics are sent to a remote server")
+	flag.IntVar(&qos, "mqtt-qos", QOS, "MQTT Quality Of Service of the published messages: 0, 1 or 2")
+	flag.StringVar(&topicPrefix, "mqtt-prefix", topic, "Prefix of all MQTT topics, e.g. site/line3/machine7/safety")
+	flag.StringVar(&resultsSubtopic, "mqtt-status-topic", "", "MQTT sub-topic for operator status. Default: the prefix itself")
+	flag.StringVar(&alertsSubtopic, "mqtt-alerts-topic", "alerts", "MQTT sub-topic for alert state transitions")
+	flag.StringVar(&heartbeatSubtopic, "mqtt-heartbeat-topic", "alerts", "MQTT sub-topic for heartbeats of persisting alerts")
+	flag.StringVar(&perfSubtopic, "mqtt-perf-topic", "perf", "MQTT sub-topic for inference engine performance")
+	flag.StringVar(&topicOpts, "mqtt-topics", "", "Comma separated publishing options of MQTT topics in topic=qos[:retain] format, e.g. machine/safety=1:retain")
+	flag.StringVar(&syslogAddr, "syslog", "", "Log alerts to syslog: local for the local syslog daemon or udp://host:port or tcp://host:port for remote syslog server")
+	flag.BoolVar(&journald, "journald", false, "Log alerts to systemd journal")
+	flag.BoolVar(&detections, "detections", false, "Publish detailed detection payload of every processed frame to detections topic; requires -publish")
+	flag.IntVar(&mqttBuffer, "mqtt-buffer", 1000, "Maximum number of messages buffered while MQTT broker is unreachable. 0: disabled")
+	flag.StringVar(&mqttBufferFile, "mqtt-buffer-file", "", "Path to file the messages buffered while MQTT broker is unreachable are persisted in. Default: memory only")
+	flag.BoolVar(&slack, "slack", false, "Post alerts to Slack channel; configured by SLACK_* environment variables")
+	flag.BoolVar(&slackSnapshots, "slack-snapshot", false, "Post annotated snapshots of raised alerts to Slack; requires SLACK_BOT_TOKEN")
+	flag.StringVar(&smsTo, "sms", "", "Comma separated phone numbers raised alerts are texted to in number[@days hh:mm-hh:mm] format; configured by TWILIO_* environment variables")
+	flag.StringVar(&smsSeverity, "sms-severity", severityCritical, "Minimum severity of the alerts texted unless they are escalated to sms")
+	flag.IntVar(&smsDailyCap, "sms-daily-cap", 20, "Maximum number of texts sent per day. 0: unlimited")
+	flag.StringVar(&incidentService, "incidents", "", "Incident management service raised alerts open incidents in: pagerduty or opsgenie; configured by PAGERDUTY_* or OPSGENIE_* environment variables")
+	flag.StringVar(&incidentSeverity, "incident-severity", severityCritical, "Minimum severity of the alerts which open incidents unless they are escalated to incident")
+	flag.StringVar(&pushService, "push", "", "Service alert notifications are pushed to phones through: ntfy or gotify; configured by NTFY_* or GOTIFY_* environment variables")
+	flag.StringVar(&webhookURLs, "webhook", "", "Comma separated URLs of HTTP endpoints alerts are posted to")
+	flag.StringVar(&webhookHeaders, "webhook-headers", "", "Comma separated custom HTTP headers in Name:value format sent to the webhook endpoints")
+	flag.IntVar(&webhookRetries, "webhook-retries", 3, "Number of times a failed webhook request is retried")
+	flag.BoolVar(&webhookStatus, "webhook-status", false, "Post operator status to the webhook endpoints every -rate seconds")
+	flag.StringVar(&httpSink, "http-sink", "", "URL of HTTP endpoint batches of operator status are posted to every -rate seconds")
+	flag.IntVar(&httpSinkBatch, "http-sink-batch", 100, "Maximum number of operator statuses in a batch posted to the HTTP sink")
+	flag.StringVar(&apiAddr, "api", "", "Address the REST API server listens on, e.g. :8080. Disabled if empty")
+	flag.IntVar(&streamFPS, "stream-fps", 10, "Maximum frame rate of the MJPEG video stream served by the REST API server")
+	flag.BoolVar(&influx, "influx", false, "Write operator status and inference performance to InfluxDB every -rate seconds; configured by INFLUX_* environment variables")
+	flag.BoolVar(&otel, "otel", false, "Export frame processing pipeline spans and metrics via OTLP; configured by OTEL_* environment variables")
+	flag.StringVar(&storePath, "store", "", "Path to SQLite database status changes, alerts and summaries are stored in. Disabled if empty")
+	flag.DurationVar(&storeSummary, "store-summary", 5*time.Minute, "Interval in which operator status summaries are stored in the event store. 0: disabled")
+	flag.StringVar(&csvPath, "csv", "", "Path to CSV file the operator status is written to every -rate seconds. Disabled if empty")
+	flag.StringVar(&grpcAddr, "grpc", "", "Address the gRPC streaming server listens on, e.g. :9090. Disabled if empty")
 	flag.Float64Var(&delay, "delay", 5.0, "Video playback delay")
+	flag.BoolVar(&overlayPose, "overlay-pose", false, "Draw head pose axes, gaze and pose angles of the detected faces in the display overlay")
+	flag.StringVar(&facePanelCorner, "face-panel", "", "Corner of the display the enlarged primary operator face is shown in: top-left, top-right, bottom-left or bottom-right. Disabled if empty")
+	flag.IntVar(&facePanelSize, "face-panel-size", 200, "Width of the primary operator face panel in pixels")
+	flag.BoolVar(&fpsCounter, "fps-counter", false, "Show capture and processed frame rates and capture-to-display latency in the display overlay")
+	flag.DurationVar(&fpsLog, "fps-log", 0, "Interval in which capture and processed frame rates and capture-to-display latency are logged. 0: disabled")
+	flag.StringVar(&overlayColor, "overlay-color", "255,255,255", "Color of the overlay text, r,g,b")
+	flag.StringVar(&overlayOutline, "overlay-outline", "0,0,0", "Color of the outline around the overlay text, r,g,b. none: no outline")
+	flag.StringVar(&overlayAlert, "overlay-alert-color", "255,0,0", "Color of the overlay alert text of alerts without severity, r,g,b")
+	flag.Float64Var(&overlayFontScale, "overlay-font-scale", 0.5, "Scale of the overlay text font")
+	flag.IntVar(&overlayThickness, "overlay-thickness", 1, "Thickness of the overlay text strokes in pixels")
+	flag.StringVar(&overlayPos, "overlay-origin", "10,20", "Position of the first overlay text line baseline, x,y in pixels")
+	flag.IntVar(&overlayLineHeight, "overlay-line-height", 0, "Distance between the overlay text lines in pixels. 0: derived from the font scale")
+	flag.BoolVar(&headless, "headless", false, "Run without display window; enabled automatically when no display is available")
+	flag.StringVar(&windowSize, "window-size", "", "Size of the display window, widthxheight in pixels, e.g. 1280x720. Default: fit to the video")
+	flag.StringVar(&windowPos, "window-pos", "", "Position of the display window top left corner on the screen, x,y in pixels. Default: placed by the window manager")
+	flag.BoolVar(&fullscreen, "fullscreen", false, "Show the display window in fullscreen; toggled by the F key")
+	flag.BoolVar(&windowTopmost, "window-topmost", false, "Keep the display window above other windows")
+	flag.IntVar(&depthDeviceID, "depth-device", -1, "RealSense camera depth stream device ID")
+	flag.Float64Var(&depthScale, "depth-scale", 0.001, "Number of meters per RealSense depth unit")
+	flag.Float64Var(&depthMaxDistance, "depth-max-distance", 2.0, "Maximum distance of operator face from the camera in meters. 0: no limit")
+	flag.Float64Var(&depthMinRelief, "depth-min-relief", 0.01, "Minimum depth variation of operator face in meters; flatter faces are ignored")
+	flag.Float64Var(&minDistance, "min-distance", 0, "Minimum distance in meters operator is allowed to be from the machine. 0: disabled")
+	flag.StringVar(&record, "record", "", "Path to directory where raw input video is recorded")
+	flag.StringVar(&recordCodec, "record-codec", "MJPG", "FOURCC code of the recorded video codec")
+	flag.DurationVar(&recordSegment, "record-segment", 10*time.Minute, "Maximum duration of a single recorded video file")
+	flag.IntVar(&recordKeep, "record-keep", 0, "Maximum number of recorded video files kept on disk. 0: keep all")
+	flag.StringVar(&clips, "clips", "", "Path to directory where video clips of raised alerts are recorded")
+	flag.DurationVar(&clipPre, "clip-pre", 10*time.Second, "Time before the alert the alert clips cover")
+	flag.DurationVar(&clipPost, "clip-post", 10*time.Second, "Time after the alert the alert clips cover")
+	flag.StringVar(&captureAPI, "capture-api", "any", "Preferred video capture API: any, ffmpeg, gstreamer, v4l2, mfx, msmf")
+	flag.StringVar(&hwDecode, "hw-decode", "none", "Hardware video decoder: none, any, vaapi, mfx, d3d11")
+	flag.BoolVar(&replay, "replay", false, "Derive time from input video frame timestamps instead of wall clock")
+	flag.BoolVar(&control, "control", false, "Accept remote control commands over MQTT")
+	flag.StringVar(&screen, "screen", "", "Capture screen region instead of camera. all: whole screen, x,y,width,height: region")
+	flag.StringVar(&screenWindow, "screen-window", "", "Capture window with given name instead of camera")
+	flag.IntVar(&screenFPS, "screen-fps", 10, "Screen capture frame rate")
+	flag.IntVar(&asyncRequests, "async", 1, "Number of inference requests in flight per network")
+	flag.BoolVar(&track, "track", false, "Track faces across frames and monitor every operator individually")
+	flag.Float64Var(&trackIoU, "track-iou", 0.3, "Minimum intersection over union of bounding boxes of the same face in consecutive frames")
+	flag.DurationVar(&trackMaxAge, "track-max-age", time.Second, "Maximum time a face is tracked without being detected")
+	flag.IntVar(&warmup, "warmup", 3, "Number of test forward passes run through the models before monitoring starts")
+	flag.DurationVar(&reuseMaxAge, "reuse-max-age", 0, "Maximum age of head pose and sentiment reused for faces which haven't moved. 0: disabled")
+	flag.Float64Var(&reuseIoU, "reuse-iou", 0.9, "Minimum intersection over union of face bounding boxes for the face to be considered not moving")
+	flag.DurationVar(&latencyBudget, "latency-budget", 0, "Per-frame inference latency budget; the inference is degraded when it is exceeded. 0: disabled")
+	flag.DurationVar(&watchModels, "watch-models", 0, "Interval between model file modification checks; modified models are reloaded. 0: disabled")
+	flag.StringVar(&landmarksModel, "landmarks-model", "", "Path to .bin file of facial landmarks detection model")
+	flag.StringVar(&landmarksConfig, "landmarks-config", "", "Path to .xml file of facial landmarks model configuration")
+	flag.BoolVar(&alignFaces, "align-faces", false, "Align faces using facial landmarks before sentiment detection")
+	flag.StringVar(&gazeModel, "gaze-model", "", "Path to .bin file of gaze estimation model")
+	flag.StringVar(&gazeConfig, "gaze-config", "", "Path to .xml file of gaze estimation model configuration")
+	flag.Float64Var(&gazeYaw, "gaze-yaw", 0, "Yaw angle in degrees of the direction towards the machine. 0: towards the camera")
+	flag.Float64Var(&gazePitch, "gaze-pitch", 0, "Pitch angle in degrees of the direction towards the machine. 0: towards the camera")
+	flag.Float64Var(&gazeCone, "gaze-cone", 22.5, "Maximum angle in degrees between operator gaze and the direction towards the machine")
+	flag.StringVar(&eyeModel, "eye-model", "", "Path to .bin file of eye state detection model")
+	flag.StringVar(&eyeConfig, "eye-config", "", "Path to .xml file of eye state detection model configuration")
+	flag.DurationVar(&perclosWindow, "perclos-window", time.Minute, "Time window over which the eye closure ratio is measured")
+	flag.Float64Var(&perclosThreshold, "perclos-threshold", 0.15, "Maximum ratio of time operator is allowed to have their eyes closed")
+	flag.StringVar(&ppeModel, "ppe-model", "", "Path to .bin file of protective equipment detection model")
+	flag.StringVar(&ppeConfig, "ppe-config", "", "Path to .xml file of protective equipment detection model configuration")
+	flag.Float64Var(&ppeConfidence, "ppe-confidence", 0.5, "Confidence threshold for protective equipment detection")
+	flag.StringVar(&ppeLabelsList, "ppe-labels", "hardhat,glasses", "Comma separated labels of protective equipment model classes in class ID order")
+	flag.StringVar(&ppeRequiredList, "ppe-required", "", "Comma separated labels of protective equipment operator is required to wear. Empty: all labels")
+	flag.DurationVar(&ppeTimeout, "ppe-timeout", 5*time.Second, "Maximum time operator is allowed not to wear protective equipment for")
+	flag.StringVar(&reidModel, "reid-model", "", "Path to .bin file of face reidentification model")
+	flag.StringVar(&reidConfig, "reid-config", "", "Path to .xml file of face reidentification model configuration")
+	flag.StringVar(&gallery, "gallery", "", "Path to directory with images of known operators")
+	flag.StringVar(&thresholdsSource, "operator-thresholds", "", "Path to JSON file or URL of HTTP endpoint with alert thresholds of individual operators")
+	flag.DurationVar(&thresholdsInterval, "operator-thresholds-interval", 0, "Interval between reloads of operator thresholds. 0: load them only once")
+	flag.Float64Var(&reidThreshold, "reid-threshold", 0.6, "Minimum face similarity required to identify operator")
+	flag.StringVar(&phoneModel, "phone-model", "", "Path to .bin file of phone detection model")
+	flag.StringVar(&phoneConfig, "phone-config", "", "Path to .xml file of phone detection model configuration")
+	flag.Float64Var(&phoneConfidence, "phone-confidence", 0.5, "Confidence threshold for phone detection")
+	flag.IntVar(&phoneClass, "phone-class", 77, "Class ID of phones in phone detection model output")
+	flag.DurationVar(&phoneTimeout, "phone-timeout", 3*time.Second, "Maximum time operator is allowed to use phone for")
+	flag.StringVar(&personModel, "person-model", "", "Path to .bin file of person detection model")
+	flag.StringVar(&personConfig, "person-config", "", "Path to .xml file of person detection model configuration")
+	flag.Float64Var(&personConfidence, "person-confidence", 0.5, "Confidence threshold for person detection")
+	flag.DurationVar(&absentTimeout, "absent-timeout", 5*time.Second, "Maximum time operator is allowed to be absent for")
+	flag.BoolVar(&risk, "risk", false, "Score operator fatigue risk and publish it with the operator status")
+	flag.Float64Var(&riskThreshold, "risk-threshold", 0, "Risk score between 0 and 1 which raises alert. 0: disabled")
+	flag.DurationVar(&riskWindow, "risk-window", 10*time.Minute, "Duration of rolling time window the risk score is measured over")
+	flag.StringVar(&riskWeightsList, "risk-weights", "0.4,0.2,0.3,0.1", "Comma separated weights of inattention, anger episodes, drowsiness and time on station in the risk score")
+	flag.DurationVar(&riskStationTime, "risk-station-time", 4*time.Hour, "Time on station which maximizes time on station risk")
+	flag.StringVar(&rulesPath, "rules", "", "Path to JSON file with custom alert rules")
+	flag.BoolVar(&tamper, "tamper", false, "Detect camera obstruction and tampering: dark, heavily blurred or frozen frames")
+	flag.DurationVar(&tamperTimeout, "tamper-timeout", 10*time.Second, "Maximum time the frames are allowed to look tampered for")
+	flag.Float64Var(&tamperBrightness, "tamper-brightness", 20, "Mean frame brightness between 0 and 255 below which the camera looks obstructed")
+	flag.Float64Var(&tamperSharpness, "tamper-sharpness", 5, "Frame sharpness below which the camera looks obstructed")
+	flag.StringVar(&schedule, "schedule", "", "Comma separated shifts outside of which monitoring is suspended, e.g. mon-fri 06:00-22:00,sat 08:00-12:00. Default: always active")
+	flag.IntVar(&maxOperators, "max-operators", 0, "Maximum number of faces allowed in the operator zone. 0: disabled")
+	flag.DurationVar(&crowdTimeout, "crowd-timeout", 5*time.Second, "Maximum time more than -max-operators faces are allowed in the operator zone for")
+	flag.StringVar(&operatorZone, "operator-zone", "", "Operator zone faces are counted in, x,y,width,height in pixels. Default: whole frame")
+	flag.DurationVar(&unattendedTimeout, "unattended-timeout", 0, "Maximum time the machine is allowed to be unattended, i.e. without any face or person detected, for. 0: disabled")
+	flag.StringVar(&maskModel, "mask-model", "", "Path to .bin file of face mask detection model")
+	flag.StringVar(&maskConfig, "mask-config", "", "Path to .xml file of face mask detection model configuration")
+	flag.Float64Var(&maskConfidence, "mask-confidence", 0.5, "Confidence threshold for face mask detection")
+	flag.StringVar(&maskPolicy, "mask-policy", maskUnknown, "Sentiment of masked faces. unknown: mark as unknown, skip: keep previous sentiment")
 }
 
 // Sentiment is operator sentiment
@@ -172,11 +847,84 @@ type Perf struct {
 	SentNet float64
 	// PoseNet stores pose detector performance info
 	PoseNet float64
+	// Nets stores performance info of the optional networks which ran by their names
+	Nets map[string]float64
 }
 
 // String implements fmt.Stringer interface for Perf
 func (p *Perf) String() string {
-	return fmt.Sprintf("Face inference time: %.2f ms, Sentiment inference time: %.2f ms, Pose inference time: %.2f ms", p.FaceNet, p.SentNet, p.PoseNet)
+	return fmt.Sprintf("%s: %.2f ms, %s: %.2f ms, %s: %.2f ms",
+		msg(labelFaceTime), p.FaceNet, msg(labelSentTime), p.SentNet, msg(labelPoseTime), p.PoseNet)
+}
+
+// Face stores detection results of a single face
+type Face struct {
+	// Rect is face bounding box
+	Rect image.Rectangle
+	// Landmarks are facial landmarks; nil if landmarks detection is disabled
+	Landmarks *Landmarks
+	// Yaw is head yaw angle in degrees
+	Yaw float64
+	// Pitch is head pitch angle in degrees
+	Pitch float64
+	// Roll is head roll angle in degrees
+	Roll float64
+	// Gaze is gaze direction; nil if gaze estimation is disabled
+	Gaze *Gaze
+	// Eyes is eye state; nil if eye state detection is disabled
+	Eyes *Eyes
+	// PPE are labels of worn personal protective equipment
+	PPE []string
+	// OperatorID is ID of identified operator; empty if the operator is unknown
+	OperatorID string
+	// PoseQuality is confidence of the head pose estimate
+	PoseQuality float64
+	// Confidence is face detection confidence
+	Confidence float64
+	// SentimentProbs are probabilities of the sentiment model output classes; nil if sentiment was not inferred
+	SentimentProbs []float32
+	// Sentiment is the most likely sentiment of the person
+	Sentiment Sentiment
+	// Masked means the person wears face mask
+	Masked bool
+	// UsingPhone means the person uses phone
+	UsingPhone bool
+	// TrackID is ID of the face track; zero if tracking is disabled
+	TrackID int
+	// IsWatching means the person is watching the machine
+	IsWatching bool
+	// IsAngry means the person is angry
+	IsAngry bool
+}
+
+// status returns status of the operator with the face.
+// Distance and number of faces in the operator zone are taken over from the status s of the whole frame.
+func (f *Face) status(s *Status) *Status {
+	fs := &Status{
+		IsWatching: f.IsWatching,
+		IsAngry:    f.IsAngry,
+		Distance:   s.Distance,
+		ZoneFaces:  s.ZoneFaces,
+		OperatorID: f.OperatorID,
+		UsingPhone: f.UsingPhone,
+		Faces:      []*Face{f},
+		checked:    true,
+	}
+
+	// head pose of low quality face is not reliable
+	fs.poseChecked = f.PoseQuality > poseConfidence
+	// sentiment of masked face is not detected if it is skipped
+	fs.sentChecked = !f.Masked || maskPolicy != maskSkip
+
+	if f.Eyes != nil {
+		fs.EyesClosed, fs.eyesChecked = f.Eyes.Closed(), true
+	}
+
+	if ppeModel != "" {
+		fs.MissingPPE = missingPPE(ppeRequired, f.PPE)
+	}
+
+	return fs
 }
 
 // Status stores machine operator status
@@ -185,10 +933,49 @@ type Status struct {
 	IsWatching bool
 	// IsAngry means operator is angry
 	IsAngry bool
+	// Distance is operator distance from the machine in meters; zero if unknown
+	Distance float64
+	// ZoneFaces is number of faces in the operator zone
+	ZoneFaces int
+	// OperatorID is ID of the identified operator at the machine; empty if the operator is unknown
+	OperatorID string
+	// Faces are faces which the status was detected from
+	Faces []*Face
+	// EyesClosed means operator eyes are closed
+	EyesClosed bool
+	// eyesChecked means eye state detection was successful
+	eyesChecked bool
+	// sentChecked means sentiment was detected from at least one face
+	sentChecked bool
+	// poseChecked means head pose was reliably detected for at least one face
+	poseChecked bool
+	// MissingPPE are labels of required personal protective equipment operator does not wear
+	MissingPPE []string
+	// UsingPhone means operator uses phone
+	UsingPhone bool
+	// Present means a person was detected when no face was found
+	Present bool
+	// personChecked means person detection was run because no face was found
+	personChecked bool
 	// checked means status was checked in a sense that status detection was successful
 	checked bool
 }
 
+// NewOperator creates new operator with empty status and returns it
+func NewOperator() *Operator {
+	return &Operator{
+		now:         new(Status),
+		prev:        new(Status),
+		perclos:     NewPERCLOS(perclosWindow),
+		inattention: NewPERCLOS(attentionWindow),
+		angry:       NewMajorityVote(sentWindow),
+		emotion:     NewEmotionMonitor(sentWindow),
+		limits:      defaultThresholds(),
+		risk:        NewRiskScore(riskWindow),
+		raw:         new(Result),
+	}
+}
+
 // Operator is machine operator
 type Operator struct {
 	// now is Operator current status
@@ -199,6 +986,38 @@ type Operator struct {
 	timeStoppedWatching time.Time
 	// timeAngry records time when operator became angry
 	timeStartAngry time.Time
+	// watchingFrames is number of consecutive frames operator has been watching machine
+	watchingFrames int
+	// calmFrames is number of consecutive frames operator has not been angry
+	calmFrames int
+	// perclos measures how long operator eyes are closed
+	perclos *PERCLOS
+	// inattention measures how long operator is not watching machine
+	inattention *PERCLOS
+	// timeStartMissingPPE records time when operator started missing protective equipment
+	timeStartMissingPPE time.Time
+	// timeStartPhone records time when operator started using phone
+	timeStartPhone time.Time
+	// timeStartAbsent records time when operator became absent
+	timeStartAbsent time.Time
+	// timeStartUnattended records time when nobody was found at the machine
+	timeStartUnattended time.Time
+	// timeStartCrowd records time when too many faces appeared in the operator zone
+	timeStartCrowd time.Time
+	// timeStartRules records times when custom alert rules started to hold
+	timeStartRules []time.Time
+	// risk scores operator fatigue risk
+	risk *RiskScore
+	// angry smooths sentiment detected in consecutive frames
+	angry *MajorityVote
+	// emotion watches operator sentiments according to emotion policies
+	emotion *EmotionMonitor
+	// limits are alert timeouts and thresholds of the operator
+	limits Thresholds
+	// raw stores alerts before they pass through the gates
+	raw *Result
+	// gates apply hysteresis and cool-down to the alerts
+	gates alertGates
 }
 
 // Result is monitoring computation result returned to main goroutine
@@ -209,56 +1028,356 @@ type Result struct {
 	AlertWatching bool
 	// AlertAngry is used to raise an alert based on operator (not) being angry whilst operating machine
 	AlertAngry bool
+	// AlertDistance is used to raise an alert based on operator being too close to the machine
+	AlertDistance bool
+	// AlertDrowsy is used to raise an alert based on operator eyes being closed for too long
+	AlertDrowsy bool
+	// AlertPPE is used to raise an alert based on operator not wearing required protective equipment
+	AlertPPE bool
+	// AlertAbsent is used to raise an alert based on operator being absent
+	AlertAbsent bool
+	// AlertUnattended is used to raise an alert based on nobody being at the machine
+	AlertUnattended bool
+	// AlertCrowd is used to raise an alert based on too many operators being at the machine
+	AlertCrowd bool
+	// AlertRisk is used to raise an alert based on operator risk score
+	AlertRisk bool
+	// AlertTamper is used to raise an alert based on camera being obstructed or tampered with
+	AlertTamper bool
+	// AlertEmotion is used to raise an alert based on operator sentiment lasting too long or recurring too often
+	AlertEmotion bool
+	// AlertRules are used to raise alerts based on custom alert rules, one for each rule
+	AlertRules []bool
+	// Risk is operator risk score; the highest one if there are several operators
+	Risk float64
+	// Acknowledged stores types of raised alerts which were acknowledged
+	Acknowledged map[string]bool
+	// Severities stores severities of raised alerts by their types
+	Severities map[string]string
+	// Degradation is current inference degradation level
+	Degradation int
+	// Paused means monitoring was paused by command
+	Paused bool
+	// Suspended means monitoring was suspended outside of shifts
+	Suspended bool
+	// AlertPhone is used to raise an alert based on operator being distracted by phone
+	AlertPhone bool
 	// Perf is inference engine performance
 	Perf *Perf
+	// Throughput is frame throughput of the monitoring pipeline
+	Throughput Throughput
+	// Time is time of the frame the result was computed from
+	Time time.Time
+	// Captured is wall clock time when the frame the result was computed from was captured
+	Captured time.Time
+}
+
+// clearAlerts clears all the alerts
+func (r *Result) clearAlerts() {
+	r.AlertWatching, r.AlertAngry, r.AlertDistance = false, false, false
+	r.AlertDrowsy, r.AlertPPE, r.AlertPhone, r.AlertAbsent = false, false, false, false
+	r.AlertUnattended, r.AlertCrowd, r.AlertRisk, r.AlertTamper = false, false, false, false
+	r.AlertEmotion = false
+	for i := range r.AlertRules {
+		r.AlertRules[i] = false
+	}
+	r.Risk = 0
+}
+
+// snapshot returns copy of the result which is not affected by later updates of r
+func (r *Result) snapshot() *Result {
+	s := *r
+	s.AlertRules = append([]bool(nil), r.ruleAlerts()...)
+
+	return &s
+}
+
+// ruleAlerts returns alerts raised by custom alert rules, one for each rule
+func (r *Result) ruleAlerts() []bool {
+	if len(r.AlertRules) != len(alertRules) {
+		r.AlertRules = make([]bool, len(alertRules))
+	}
+
+	return r.AlertRules
+}
+
+// mergeAlerts raises all the alerts raised in o
+func (r *Result) mergeAlerts(o *Result) {
+	r.AlertWatching = r.AlertWatching || o.AlertWatching
+	r.AlertAngry = r.AlertAngry || o.AlertAngry
+	r.AlertDistance = r.AlertDistance || o.AlertDistance
+	r.AlertDrowsy = r.AlertDrowsy || o.AlertDrowsy
+	r.AlertPPE = r.AlertPPE || o.AlertPPE
+	r.AlertPhone = r.AlertPhone || o.AlertPhone
+	r.AlertAbsent = r.AlertAbsent || o.AlertAbsent
+	r.AlertUnattended = r.AlertUnattended || o.AlertUnattended
+	r.AlertCrowd = r.AlertCrowd || o.AlertCrowd
+	r.AlertRisk = r.AlertRisk || o.AlertRisk
+	r.AlertTamper = r.AlertTamper || o.AlertTamper
+	r.AlertEmotion = r.AlertEmotion || o.AlertEmotion
+	rules := r.ruleAlerts()
+	for i, raised := range o.AlertRules {
+		rules[i] = rules[i] || raised
+	}
+	r.Risk = math.Max(r.Risk, o.Risk)
 }
 
 // String implements fmt.Stringer interface for Result
 func (r *Result) String() string {
-	return fmt.Sprintf("Watching %v, Angry: %v", r.status.IsWatching, r.status.IsAngry)
+	str := fmt.Sprintf("%s %v, %s: %v", msg(labelWatching), r.status.IsWatching, msg(labelAngry), r.status.IsAngry)
+	if r.status.OperatorID != "" {
+		str = fmt.Sprintf("%s: %s, %s", msg(labelOperator), r.status.OperatorID, str)
+	}
+	if r.status.Distance > 0 {
+		str = fmt.Sprintf("%s, %s: %.2f m", str, msg(labelDistance), r.status.Distance)
+	}
+	if r.Degradation > degradeNone {
+		str = fmt.Sprintf("%s, %s: %d", str, msg(labelDegradation), r.Degradation)
+	}
+	if risk {
+		str = fmt.Sprintf("%s, %s: %.2f", str, msg(labelRisk), r.Risk)
+	}
+	if r.Paused {
+		str = fmt.Sprintf("%s, %s", str, msg(labelPaused))
+	}
+	if r.Suspended {
+		str = fmt.Sprintf("%s, %s", str, msg(labelSuspended))
+	}
+
+	return str
+}
+
+// payloadHeader returns header of payloads describing time ts
+func payloadHeader(ts time.Time) payload.Header {
+	return payload.Header{
+		Schema:    payload.SchemaVersion,
+		Time:      ts,
+		MachineID: machineID,
+		CameraID:  cameraID,
+	}
+}
+
+// ToPayload turns result into status payload
+func (r *Result) ToPayload() payload.Status {
+	p := payload.Status{
+		Header:     payloadHeader(r.Time),
+		Operator:   r.status.OperatorID,
+		Watching:   r.status.IsWatching,
+		Angry:      r.status.IsAngry,
+		Distance:   r.status.Distance,
+		Paused:     r.Paused,
+		Suspended:  r.Suspended,
+		Alerts:     alertTypes(r),
+		Severities: r.Severities,
+	}
+	if len(r.status.Faces) > 0 && r.status.Faces[0].Sentiment != UNKNOWN {
+		p.Sentiment = r.status.Faces[0].Sentiment.String()
+	}
+	if phoneModel != "" {
+		phone := r.status.UsingPhone
+		p.Phone = &phone
+	}
+	if latencyBudget > 0 {
+		degradation := r.Degradation
+		p.Degradation = &degradation
+	}
+	if risk {
+		score := r.Risk
+		p.Risk = &score
+	}
+	for typ, acked := range r.Acknowledged {
+		if acked {
+			p.Acknowledged = append(p.Acknowledged, typ)
+		}
+	}
+	sort.Strings(p.Acknowledged)
+
+	return p
+}
+
+// ToPerfPayload turns inference engine performance and frame throughput of result into performance payload.
+// Inference times are zero until a frame with a face is processed.
+func (r *Result) ToPerfPayload() payload.Perf {
+	p := payload.Perf{
+		Header:   payloadHeader(r.Time),
+		FPS:      r.Throughput.FPS,
+		InputFPS: r.Throughput.InputFPS,
+		Frames:   r.Throughput.Frames,
+		Dropped:  r.Throughput.Dropped,
+	}
+	if r.Perf != nil {
+		p.FaceNet, p.SentNet, p.PoseNet = r.Perf.FaceNet, r.Perf.SentNet, r.Perf.PoseNet
+		p.Nets = r.Perf.Nets
+	}
+
+	return p
+}
+
+// ToDetectionPayload turns faces detected in the frame of result into detection payload
+func (r *Result) ToDetectionPayload() payload.Detection {
+	p := payload.Detection{
+		Header: payloadHeader(r.Time),
+		Faces:  make([]payload.Face, 0, len(r.status.Faces)),
+	}
+
+	for _, f := range r.status.Faces {
+		face := payload.Face{
+			Box: payload.Box{
+				X:      f.Rect.Min.X,
+				Y:      f.Rect.Min.Y,
+				Width:  f.Rect.Dx(),
+				Height: f.Rect.Dy(),
+			},
+			Confidence:     f.Confidence,
+			Yaw:            f.Yaw,
+			Pitch:          f.Pitch,
+			Roll:           f.Roll,
+			PoseConfidence: f.PoseQuality,
+			Operator:       f.OperatorID,
+			Track:          f.TrackID,
+			Watching:       f.IsWatching,
+			Angry:          f.IsAngry,
+			Masked:         f.Masked,
+			Phone:          f.UsingPhone,
+		}
+		if f.Sentiment != UNKNOWN {
+			face.Sentiment = f.Sentiment.String()
+		}
+		if f.SentimentProbs != nil {
+			face.Sentiments = make(map[string]float64, len(f.SentimentProbs))
+			for i, prob := range f.SentimentProbs {
+				// classes without label are not sentiments the monitor knows
+				if s := sentiment(i); s != UNKNOWN {
+					face.Sentiments[s.String()] = float64(prob)
+				}
+			}
+		}
+		p.Faces = append(p.Faces, face)
+	}
+
+	return p
+}
+
+// ToDetectionMessage turns faces detected in the frame of result into MQTT message
+func (r *Result) ToDetectionMessage() string {
+	msg, err := json.Marshal(r.ToDetectionPayload())
+	if err != nil {
+		return "{}"
+	}
+
+	return string(msg)
+}
+
+// ToPerfMessage turns inference engine performance of result into MQTT message
+func (r *Result) ToPerfMessage() string {
+	msg, err := json.Marshal(r.ToPerfPayload())
+	if err != nil {
+		return "{}"
+	}
+
+	return string(msg)
 }
 
 // ToMQTTMessage turns result into MQTT message which can be published to MQTT broker
 func (r *Result) ToMQTTMessage() string {
-	return fmt.Sprintf("{\"Watching\":%v, \"Angry\": %v}", r.status.IsWatching, r.status.IsAngry)
+	msg, err := json.Marshal(r.ToPayload())
+	if err != nil {
+		return "{}"
+	}
+
+	return string(msg)
 }
 
-// getPerformanceInfo queries the Inference Engine performance info and returns it as string
-func getPerformanceInfo(faceNet, sentNet, poseNet *gocv.Net, statusChecked bool) *Perf {
+// getPerformanceInfo queries the Inference Engine performance info of nets and returns it
+// The optional networks run on the detected faces, so their performance is only reported if status was checked.
+func getPerformanceInfo(nets *Nets, statusChecked bool) *Perf {
 	freq := gocv.GetTickFrequency() / 1000
 
-	facePerf := faceNet.GetPerfProfile() / freq
+	perf := &Perf{
+		FaceNet: nets.Face.GetPerfProfile() / freq,
+	}
 
-	var posePerf, sentPerf float64
 	if statusChecked {
-		posePerf = poseNet.GetPerfProfile() / freq
-		sentPerf = sentNet.GetPerfProfile() / freq
-	}
+		perf.PoseNet = nets.Pose.GetPerfProfile() / freq
+		// sentiment detection is skipped when the inference is degraded
+		if nets.Sent != nil {
+			perf.SentNet = nets.Sent.GetPerfProfile() / freq
+		}
 
-	return &Perf{
-		FaceNet: facePerf,
-		SentNet: sentPerf,
-		PoseNet: posePerf,
+		for name, net := range map[string]Model{
+			"landmarks": nets.Landmarks,
+			"gaze":      nets.Gaze,
+			"eyes":      nets.Eyes,
+			"ppe":       nets.PPE,
+			"reid":      nets.ReID,
+			"mask":      nets.Mask,
+			"phone":     nets.Phone,
+		} {
+			if net == nil {
+				continue
+			}
+			if perf.Nets == nil {
+				perf.Nets = make(map[string]float64)
+			}
+			perf.Nets[name] = net.GetPerfProfile() / freq
+		}
 	}
+
+	return perf
 }
 
-// messageRunner reads data published to pubChan with rate frequency and sends them to remote analytics server
+// messageRunner accumulates data published to pubChan in windows of rate seconds and sends their summaries
+// to remote analytics server via c at the end of every window.
+// A window without data is sent as soon as the next data arrives, e.g. once the monitoring resumes.
 // doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
-func messageRunner(doneChan <-chan struct{}, pubChan <-chan *Result, c *MQTTClient, topic string, rate int) error {
+func messageRunner(doneChan <-chan struct{}, pubChan <-chan *Result, c Sink, topic string, rate int) error {
 	ticker := time.NewTicker(time.Duration(rate) * time.Second)
+	defer ticker.Stop()
+
+	w := NewWindow()
+	// due means the window is over but it can't be sent until it has data
+	var due bool
+	send := func() {
+		result := w.last
+		err := c.Send(topic, w.ToMQTTMessage())
+		// TODO: decide whether to return with error and stop program;
+		// For now we just signal there was an error and carry on
+		if err != nil {
+			fmt.Printf("Error publishing message to %s: %v", topic, err)
+		}
+		// protective equipment alerts are published to their own topic
+		if ppeModel != "" {
+			if err := c.Send(ppeTopic, result.ToPPEMessage()); err != nil {
+				fmt.Printf("Error publishing message to %s: %v", ppeTopic, err)
+			}
+		}
+		// inference performance is published to its own topic
+		if result.hasPerf() {
+			if err := c.Send(perfTopic, result.ToPerfMessage()); err != nil {
+				fmt.Printf("Error publishing message to %s: %v", perfTopic, err)
+			}
+		}
+		w.Reset()
+		due = false
+	}
 
 	for {
 		select {
 		case <-ticker.C:
-			result := <-pubChan
-			_, err := c.Publish(topic, result.ToMQTTMessage())
-			// TODO: decide whether to return with error and stop program;
-			// For now we just signal there was an error and carry on
-			if err != nil {
-				fmt.Printf("Error publishing message to %s: %v", topic, err)
+			if w.Len() == 0 {
+				due = true
+				continue
+			}
+			send()
+		case result, ok := <-pubChan:
+			if !ok {
+				pubChan = nil
+				continue
+			}
+			w.Add(result)
+			if due {
+				send()
 			}
-		case <-pubChan:
-			// we discard messages in between ticker times
 		case <-doneChan:
 			fmt.Printf("Stopping messageRunner: received stop signal\n")
 			return nil
@@ -266,76 +1385,293 @@ func messageRunner(doneChan <-chan struct{}, pubChan <-chan *Result, c *MQTTClie
 	}
 }
 
+// detectionsRunner publishes detection payload of every result received from pubChan to detectionsTopic via c.
+// The payloads are dropped rather than buffered while the broker is unreachable as they quickly go stale.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func detectionsRunner(doneChan <-chan struct{}, pubChan <-chan *Result, c *MQTTClient) error {
+	for {
+		select {
+		case result, ok := <-pubChan:
+			if !ok {
+				pubChan = nil
+				continue
+			}
+			msg := result.ToDetectionMessage()
+			if !c.isConnected() {
+				continue
+			}
+			if _, err := c.publish(detectionsTopic, msg); err != nil {
+				fmt.Printf("Error publishing message to %s: %v\n", detectionsTopic, err)
+			}
+		case <-doneChan:
+			fmt.Printf("Stopping detectionsRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
+
+// inferFaces propagates face crops forward through pose network and sentCrops through sentiment network
+// unless the sentiment detection is skipped. It returns head pose and sentiment inferred from every face.
+func inferFaces(nets *Nets, crops, sentCrops []gocv.Mat, layers []string) []*faceInference {
+	// propagate the detected faces forward through pose network
+	poseBlob := gocv.NewMat()
+	defer poseBlob.Close()
+	gocv.BlobFromImages(crops, &poseBlob, 1.0, image.Pt(60, 60),
+		gocv.NewScalar(0, 0, 0, 0), false, false, gocv.MatTypeCV32F)
+
+	// run a forward pass through pose network
+	nets.Pose.SetInput(poseBlob, "")
+	poseRes := nets.Pose.ForwardLayers(layers)
+	defer func() {
+		for i := range poseRes {
+			poseRes[i].Close()
+		}
+	}()
+
+	inferences := make([]*faceInference, len(crops))
+	for i := range crops {
+		inferences[i] = &faceInference{
+			yaw:   float64(poseRes[0].GetFloatAt(i, 0)),
+			pitch: float64(poseRes[1].GetFloatAt(i, 0)),
+			roll:  float64(poseRes[2].GetFloatAt(i, 0)),
+		}
+	}
+
+	// classify sentiment of the detected faces unless it is skipped
+	if nets.Sent != nil {
+		classes, confidences, probs := classifySentiments(nets.Sent, sentCrops)
+		for i := range inferences {
+			inferences[i].class, inferences[i].confidence = classes[i], confidences[i]
+			inferences[i].probs = probs[i]
+			inferences[i].sentChecked = true
+		}
+	}
+
+	return inferences
+}
+
 // detectStatus detects sentiment and position of the operator working with the machine and returns it
-func detectStatus(poseNet, sentNet *gocv.Net, img *gocv.Mat, faces []image.Rectangle) *Status {
+// All faces are batched together so each of the networks runs a single forward pass per frame.
+func detectStatus(nets *Nets, img *gocv.Mat, faces []image.Rectangle) *Status {
 	s := new(Status)
 	// names of neural network layers containg the outputs of face position
-	layers := []string{"angle_y_fc", "angle_p_fc", "angle_r_fc"}
-	// face will store face data
-	var face gocv.Mat
-	// do the sentiment and pose detection here
+	layers := poseLayers
+	// crops will store face data
+	var crops []gocv.Mat
+	var rects []image.Rectangle
 	for i := range faces {
 		// make sure the face rect is completely inside the main frame
 		if !faces[i].In(image.Rect(0, 0, img.Cols(), img.Rows())) {
 			continue
 		}
 
-		face = img.Region(faces[i])
+		face := img.Region(faces[i])
+		crop := gocv.NewMat()
+		face.CopyTo(&crop)
+		face.Close()
+
+		// skip tiny, distorted and blurred faces whose status is unreliable
+		if !faceQuality(crop, faces[i]) {
+			crop.Close()
+			continue
+		}
+
+		crops = append(crops, crop)
+		rects = append(rects, faces[i])
+		s.Faces = append(s.Faces, &Face{Rect: faces[i]})
+	}
+
+	if len(crops) == 0 {
+		return s
+	}
+
+	// close Mats
+	defer func() {
+		for i := range crops {
+			crops[i].Close()
+		}
+	}()
 
-		// propagate the detected face forward through pose network
-		poseImg := gocv.NewMat()
-		face.CopyTo(&poseImg)
-		poseBlob := gocv.BlobFromImage(poseImg, 1.0, image.Pt(60, 60),
-			gocv.NewScalar(0, 0, 0, 0), false, false)
+	// sentCrops are face crops fed to sentiment network
+	sentCrops := crops
 
-		// run a forward pass through sentiment network
-		poseNet.SetInput(poseBlob, "")
-		poseRes := poseNet.ForwardLayers(layers)
+	// detect facial landmarks and align the faces before sentiment detection if requested
+	if nets.Landmarks != nil {
+		landmarks := detectLandmarks(nets.Landmarks, crops, rects)
+		for i := range landmarks {
+			s.Faces[i].Landmarks = landmarks[i]
+		}
 
-		// the operator is watching if their head is tilted within a 45 degree angle relative to the shelf
-		if (poseRes[0].GetFloatAt(0, 0) > -22.5 && poseRes[0].GetFloatAt(0, 0) < 22.5) &&
-			(poseRes[1].GetFloatAt(0, 0) > -22.5 && poseRes[1].GetFloatAt(0, 0) < 22.5) {
-			s.IsWatching = true
+		if alignFaces {
+			sentCrops = make([]gocv.Mat, len(crops))
+			for i := range crops {
+				sentCrops[i] = alignFace(crops[i], rects[i], landmarks[i])
+			}
+			defer func() {
+				for i := range sentCrops {
+					sentCrops[i].Close()
+				}
+			}()
 		}
+	}
 
-		// propagate the detected face forward through sentiment network
-		sentImg := gocv.NewMat()
-		face.CopyTo(&sentImg)
-		sentBlob := gocv.BlobFromImage(sentImg, 1.0, image.Pt(64, 64),
-			gocv.NewScalar(0, 0, 0, 0), false, false)
+	// inferences store head pose and sentiment of the faces; faces which haven't moved reuse the cached ones
+	inferences := make([]*faceInference, len(crops))
+	now := time.Now()
+	var idx []int
+	for i := range crops {
+		if nets.Cache != nil {
+			inferences[i] = nets.Cache.Lookup(rects[i], now, nets.Sent != nil)
+		}
+		if inferences[i] == nil {
+			idx = append(idx, i)
+		}
+	}
 
-		// run a forward pass through sentiment network
-		sentNet.SetInput(sentBlob, "")
-		sentRes := sentNet.Forward("")
+	if len(idx) > 0 {
+		inferCrops, inferSentCrops := make([]gocv.Mat, len(idx)), make([]gocv.Mat, len(idx))
+		for j, i := range idx {
+			inferCrops[j], inferSentCrops[j] = crops[i], sentCrops[i]
+		}
 
-		// flatten the result from [1, 5, 1, 1] to [1, 5]
-		sentRes = sentRes.Reshape(1, 5)
-		// find the most likely mood in returned list of sentiments
-		_, confidence, _, maxLoc := gocv.MinMaxLoc(sentRes)
-		if float64(confidence) > sentConfidence {
-			if maxLoc.Y == 4 {
-				s.IsAngry = true
+		fresh := inferFaces(nets, inferCrops, inferSentCrops, layers)
+		for j, i := range idx {
+			fresh[j].rect = rects[i]
+			inferences[i] = fresh[j]
+		}
+
+		if nets.Cache != nil {
+			nets.Cache.Store(fresh, now)
+		}
+	}
+
+	for i := range crops {
+		s.Faces[i].Yaw = inferences[i].yaw
+		s.Faces[i].Pitch = inferences[i].pitch
+		s.Faces[i].Roll = inferences[i].roll
+		s.Faces[i].PoseQuality = poseQuality(rects[i])
+	}
+
+	// check whether the operators use phones if requested
+	if nets.Phone != nil {
+		phones := detectPhones(nets.Phone, img)
+		for i := range s.Faces {
+			s.Faces[i].UsingPhone = usesPhone(s.Faces[i].Rect, phones)
+			s.UsingPhone = s.UsingPhone || s.Faces[i].UsingPhone
+		}
+	}
+
+	// detect whether the operators wear face masks if requested
+	if nets.Mask != nil {
+		for i, masked := range detectMasks(nets.Mask, crops) {
+			s.Faces[i].Masked = masked
+		}
+	}
+
+	// detect whether the operators have their eyes open if requested
+	if nets.Eyes != nil {
+		eyes := detectEyes(nets.Eyes, img, s.Faces)
+		for i := range eyes {
+			s.Faces[i].Eyes = eyes[i]
+			if eyes[i] != nil {
+				s.EyesClosed = s.EyesClosed || eyes[i].Closed()
+				s.eyesChecked = true
 			}
 		}
+	}
 
-		s.checked = true
+	// identify the operators if requested; the operator at the machine has the largest face
+	if nets.ReID != nil && nets.Gallery != nil {
+		var area int
+		for i, e := range faceEmbeddings(nets.ReID, crops) {
+			s.Faces[i].OperatorID = nets.Gallery.Identify(e)
+			if a := rects[i].Dx() * rects[i].Dy(); a > area {
+				s.OperatorID, area = s.Faces[i].OperatorID, a
+			}
+		}
+	}
 
-		// close Mats
-		poseBlob.Close()
-		for i, _ := range poseRes {
-			poseRes[i].Close()
+	// check whether the operators wear required protective equipment if requested
+	if nets.PPE != nil {
+		dets := detectPPE(nets.PPE, img, ppeLabels)
+		for i := range s.Faces {
+			s.Faces[i].PPE = wearsPPE(s.Faces[i].Rect, dets)
+			for _, m := range missingPPE(ppeRequired, s.Faces[i].PPE) {
+				if len(missingPPE([]string{m}, s.MissingPPE)) > 0 {
+					s.MissingPPE = append(s.MissingPPE, m)
+				}
+			}
+		}
+	}
+
+	// estimate where the operators are looking if requested
+	if nets.Gaze != nil {
+		gaze := detectGaze(nets.Gaze, img, s.Faces)
+		for i := range gaze {
+			s.Faces[i].Gaze = gaze[i]
+		}
+	}
+
+	for i := range crops {
+		// head pose of low quality faces is unreliable so they don't affect watching status
+		if s.Faces[i].PoseQuality > poseConfidence {
+			if s.Faces[i].Gaze != nil {
+				// the operator is watching if their gaze falls within the cone pointing towards the machine
+				s.Faces[i].IsWatching = s.Faces[i].Gaze.Angle(gazeYaw, gazePitch) < gazeCone
+			} else {
+				// the operator is watching if their head is turned towards the machine within the limits
+				s.Faces[i].IsWatching = headWatching(s.Faces[i].Yaw, s.Faces[i].Pitch, s.Faces[i].Roll)
+			}
+			s.IsWatching = s.IsWatching || s.Faces[i].IsWatching
+			s.poseChecked = true
+		}
+
+		s.Faces[i].Sentiment = UNKNOWN
+		if inferences[i].sentChecked {
+			s.Faces[i].SentimentProbs = inferences[i].probs
+			// the most likely mood must be confident enough
+			if float64(inferences[i].confidence) > sentConfidence && !s.Faces[i].Masked {
+				s.Faces[i].Sentiment = sentiment(inferences[i].class)
+			}
+			// sentiment of masked faces is unreliable so it is either unknown or skipped
+			if !s.Faces[i].Masked || maskPolicy != maskSkip {
+				s.sentChecked = true
+			}
+			if s.Faces[i].Sentiment == ANGRY {
+				s.Faces[i].IsAngry = true
+				s.IsAngry = true
+			}
 		}
-		sentBlob.Close()
-		sentRes.Close()
+
+		s.checked = true
 	}
 
 	return s
 }
 
+// headWatching returns true if head with yaw, pitch and roll angles in degrees is turned towards the machine
+func headWatching(yaw, pitch, roll float64) bool {
+	return math.Abs(yaw-poseYaw) < poseYawLimit &&
+		math.Abs(pitch-posePitch) < posePitchLimit &&
+		math.Abs(roll) <= poseRollLimit
+}
+
+// poseQuality returns confidence of head pose estimated from face with bounding box rect.
+// Head pose of faces smaller than the pose model input gets less reliable as they get upscaled.
+func poseQuality(rect image.Rectangle) float64 {
+	size := rect.Dx()
+	if rect.Dy() < size {
+		size = rect.Dy()
+	}
+
+	return math.Min(1, float64(size)/float64(poseMinSize))
+}
+
 // detectFaces detects faces in img and returns them as a slice of rectangles that encapsulates them
-func detectFaces(net *gocv.Net, img *gocv.Mat) []image.Rectangle {
+// along with their detection confidences. The detector input size is scaled by scale unless it is zero.
+func detectFaces(net Model, img *gocv.Mat, scale float64) ([]image.Rectangle, []float64) {
 	// convert img Mat to 672x384 blob that the face detector can analyze
-	blob := gocv.BlobFromImage(*img, 1.0, image.Pt(672, 384), gocv.NewScalar(0, 0, 0, 0), false, false)
+	blob := gocv.BlobFromImage(*img, 1.0, detectorSize(net, image.Pt(672, 384), scale), gocv.NewScalar(0, 0, 0, 0), false, false)
 	defer blob.Close()
 
 	// run a forward pass through the network
@@ -345,6 +1681,7 @@ func detectFaces(net *gocv.Net, img *gocv.Mat) []image.Rectangle {
 
 	// iterate through all detections and append results to faces buffer
 	var faces []image.Rectangle
+	var confidences []float64
 	for i := 0; i < results.Total(); i += 7 {
 		confidence := results.GetFloatAt(0, i+2)
 		if float64(confidence) > faceConfidence {
@@ -353,298 +1690,1941 @@ func detectFaces(net *gocv.Net, img *gocv.Mat) []image.Rectangle {
 			right := int(results.GetFloatAt(0, i+5) * float32(img.Cols()))
 			bottom := int(results.GetFloatAt(0, i+6) * float32(img.Rows()))
 			faces = append(faces, image.Rect(left, top, right, bottom))
+			confidences = append(confidences, float64(confidence))
 		}
 	}
 
-	return faces
+	return faces, confidences
 }
 
-// frameRunner reads image frames from framesChan and performs face and sentiment detections on them
-// doneChan is used to receive a signal from the main goroutine to notify frameRunner to stop and return
-func frameRunner(framesChan <-chan *frame, doneChan <-chan struct{}, resultsChan chan<- *Result,
-	pubChan chan<- *Result, faceNet, sentNet, poseNet *gocv.Net) error {
+// updateWatching updates the watching timer and alert at time ts.
+// The timer and the alert reset only once operator keeps watching the machine for watchResetFrames frames.
+// If attention ratio is measured, the alert is raised instead when operator watches the machine
+// for less than attentionThreshold of attentionWindow.
+func (op *Operator) updateWatching(ts time.Time, result *Result) {
+	if attentionWindow > 0 {
+		op.inattention.Add(ts, !op.now.IsWatching)
+		result.AlertWatching = op.inattention.Full() && 1-op.inattention.Ratio() < op.limits.attention
+		return
+	}
 
-	result := new(Result)
-	// frame is image frame
-	// we want to avoid continuous allocation that lead to GC pauses
-	frame := new(frame)
-	// operator stores operator status
-	op := new(Operator)
-	op.now, op.prev = new(Status), new(Status)
+	if op.now.IsWatching {
+		op.watchingFrames++
+	} else {
+		op.watchingFrames = 0
+	}
 
-	for {
-		select {
-		case <-doneChan:
-			fmt.Printf("Stopping frameRunner: received stop signal\n")
-			close(resultsChan)
-			if pubChan != nil {
-				close(pubChan)
-			}
-			return nil
-		case frame = <-framesChan:
-			if frame == nil {
-				continue
-			}
-			// let's make a copy of the original
-			img := gocv.NewMat()
-			frame.img.CopyTo(&img)
+	if op.watchingFrames >= watchResetFrames {
+		op.timeStoppedWatching = time.Time{}
+		result.AlertWatching = false
+	}
 
-			// detect faces and return them
-			faces := detectFaces(faceNet, &img)
+	// if operator stopped watching record the start time
+	if !op.now.IsWatching && op.timeStoppedWatching.IsZero() {
+		op.timeStoppedWatching = ts
+	}
+
+	// if operator continues not to watch machine and exceeds timeout, set alert
+	if !result.AlertWatching && !op.timeStoppedWatching.IsZero() && ts.Sub(op.timeStoppedWatching) > op.limits.watch {
+		result.AlertWatching = true
+	}
+}
 
-			// detect operator status
-			status := detectStatus(poseNet, sentNet, &img, faces)
+// updateAngry updates the anger timer and alert at time ts.
+// The timer and the alert reset only once operator stays calm for angryResetFrames frames.
+func (op *Operator) updateAngry(ts time.Time, result *Result) {
+	if op.now.IsAngry {
+		op.calmFrames = 0
+	} else {
+		op.calmFrames++
+	}
 
-			// update Result Operator
-			if status.checked {
-				op.now.IsWatching = status.IsWatching
-				op.now.IsAngry = status.IsAngry
+	if op.calmFrames >= angryResetFrames {
+		op.timeStartAngry = time.Time{}
+		result.AlertAngry = false
+	}
 
-				if op.now.IsWatching {
-					result.AlertWatching = false
-				}
+	// if operator starts being angry record the start time
+	if op.now.IsAngry && op.timeStartAngry.IsZero() {
+		op.timeStartAngry = ts
+	}
 
-				if !op.now.IsAngry {
-					result.AlertAngry = false
-				}
+	// if operator remains angry and exceeds timeout, set alert
+	if !result.AlertAngry && !op.timeStartAngry.IsZero() && ts.Sub(op.timeStartAngry) > op.limits.angry {
+		result.AlertAngry = true
+	}
+}
 
-				// If operator stopped watching record the start time
-				// was watching but isnt watching now
-				if op.prev.IsWatching && !op.now.IsWatching {
-					op.timeStoppedWatching = time.Now()
-				}
+// update updates operator status and result alerts using the latest detection d.
+// The raw alerts pass through hysteresis and cool-down gates before they are raised in result.
+func (op *Operator) update(d *detection, result *Result) {
+	if d.reset {
+		op.gates = alertGates{}
+	}
 
-				// if operator starts being angry record the start time
-				// wasnt angry but is angry now
-				if !op.prev.IsAngry && op.now.IsAngry {
-					op.timeStartAngry = time.Now()
-				}
+	op.updateAlerts(d, op.raw)
+	op.gates.apply(d.ts, op.raw, result)
+	result.Perf, result.status, result.Risk = op.raw.Perf, op.raw.status, op.raw.Risk
+}
 
-				// if operator continues not to watch machine and exceeds timeout, set alert
-				if !result.AlertWatching && !op.now.IsWatching {
-					elapsed := time.Since(op.timeStoppedWatching)
-					if elapsed > watchTimeout {
-						result.AlertWatching = true
-					}
-				}
+// updateAlerts updates operator status and raw result alerts using the latest detection d
+func (op *Operator) updateAlerts(d *detection, result *Result) {
+	// input source has changed so start over
+	if d.reset {
+		op.now, op.prev = new(Status), new(Status)
+		op.timeStoppedWatching, op.timeStartAngry, op.timeStartMissingPPE = time.Time{}, time.Time{}, time.Time{}
+		op.timeStartPhone, op.timeStartAbsent, op.timeStartUnattended = time.Time{}, time.Time{}, time.Time{}
+		op.timeStartCrowd = time.Time{}
+		op.timeStartRules = nil
+		op.watchingFrames, op.calmFrames = 0, 0
+		op.perclos.Reset()
+		op.inattention.Reset()
+		op.angry.Reset()
+		op.emotion.Reset()
+		op.risk.Reset()
+		result.clearAlerts()
+	}
 
-				// if operator remains angry and exceeds timeout, set alert
-				if !result.AlertAngry && op.now.IsAngry {
-					elapsed := time.Since(op.timeStartAngry)
-					if elapsed > watchTimeout {
-						result.AlertAngry = true
-					}
-				}
+	status := d.status
+
+	// update Result Operator
+	if status.checked {
+		// identified operator may have their own thresholds
+		op.limits = thresholdsFor(status.OperatorID)
+		// unreliable head pose would flip watching status so keep the previous one
+		if status.poseChecked {
+			op.now.IsWatching = status.IsWatching
+		}
+		// single frame sentiment is noisy so smooth it over several frames
+		if status.sentChecked {
+			op.now.IsAngry = op.angry.Add(status.IsAngry)
+			// if operator sentiment lasts too long or recurs too often, set alert
+			result.AlertEmotion = op.emotion.Update(d.ts, status.Faces)
+		}
+		op.now.Distance = status.Distance
+
+		op.updateWatching(d.ts, result)
+		op.updateAngry(d.ts, result)
+		op.risk.Add(d.ts, op.now.IsWatching, op.now.IsAngry)
+
+		// if operator gets too close to the machine, set alert
+		result.AlertDistance = minDistance > 0 && op.now.Distance > 0 && op.now.Distance < minDistance
+
+		// if operator keeps their eyes closed for too large portion of time, set alert
+		if status.eyesChecked {
+			op.perclos.Add(d.ts, status.EyesClosed)
+			result.AlertDrowsy = op.perclos.Full() && op.perclos.Ratio() > op.limits.perclos
+		}
+
+		// if operator misses protective equipment for longer than timeout, set alert
+		if len(status.MissingPPE) == 0 {
+			op.timeStartMissingPPE = time.Time{}
+			result.AlertPPE = false
+		} else {
+			if op.timeStartMissingPPE.IsZero() {
+				op.timeStartMissingPPE = d.ts
 			}
+			result.AlertPPE = d.ts.Sub(op.timeStartMissingPPE) > ppeTimeout
+		}
 
-			if status.checked {
-				result.Perf = getPerformanceInfo(faceNet, sentNet, poseNet, status.checked)
+		// if operator keeps using phone for longer than timeout, set alert
+		if !status.UsingPhone {
+			op.timeStartPhone = time.Time{}
+			result.AlertPhone = false
+		} else {
+			if op.timeStartPhone.IsZero() {
+				op.timeStartPhone = d.ts
 			}
+			result.AlertPhone = d.ts.Sub(op.timeStartPhone) > op.limits.phone
+		}
+	}
 
-			result.status = status
+	// operator is present if their face was found or a person was detected
+	if status.checked || status.personChecked {
+		if status.checked || status.Present {
+			op.timeStartAbsent = time.Time{}
+			result.AlertAbsent = false
+		} else {
+			if op.timeStartAbsent.IsZero() {
+				op.timeStartAbsent = d.ts
+			}
+			result.AlertAbsent = d.ts.Sub(op.timeStartAbsent) > absentTimeout
+		}
+	}
 
-			// send data down the channels
-			resultsChan <- result
-			if pubChan != nil {
-				pubChan <- result
+	// machine is unattended if no face was found and no person was detected;
+	// the timer is kept as it is if person detection was skipped
+	if unattendedTimeout > 0 && (status.checked || status.personChecked || personModel == "") {
+		if status.checked || status.Present {
+			op.timeStartUnattended = time.Time{}
+			result.AlertUnattended = false
+		} else {
+			if op.timeStartUnattended.IsZero() {
+				op.timeStartUnattended = d.ts
 			}
+			result.AlertUnattended = d.ts.Sub(op.timeStartUnattended) > unattendedTimeout
+		}
+	}
 
-			// latest status is now prev status
-			op.prev.IsWatching = op.now.IsWatching
-			op.prev.IsAngry = op.now.IsAngry
-			// close image matrices
-			img.Close()
+	// if more than maxOperators faces stay in the operator zone for longer than timeout, set alert
+	if maxOperators > 0 {
+		if status.ZoneFaces <= maxOperators {
+			op.timeStartCrowd = time.Time{}
+			result.AlertCrowd = false
+		} else {
+			if op.timeStartCrowd.IsZero() {
+				op.timeStartCrowd = d.ts
+			}
+			result.AlertCrowd = d.ts.Sub(op.timeStartCrowd) > crowdTimeout
 		}
 	}
-}
 
-func parseCliFlags() error {
-	// parse cli flags
-	flag.Parse()
+	// if operator risk score exceeds threshold, set alert; time on station starts over when operator leaves
+	if risk {
+		if result.AlertAbsent || result.AlertUnattended {
+			op.risk.Leave()
+		}
+		result.Risk = op.risk.Score(d.ts, op.perclos.Ratio())
+		result.AlertRisk = riskThreshold > 0 && result.Risk >= riskThreshold
+	}
 
-	// path to face detection model can't be empty
-	if faceModel == "" {
-		return fmt.Errorf("Invalid path to .bin file of face detection model: %s", faceModel)
+	// operator turned away from the camera or absent is not watching the machine
+	if !status.checked && status.personChecked {
+		op.now.IsWatching = false
+		op.updateWatching(d.ts, result)
 	}
-	// path to face detection model config can't be empty
-	if faceConfig == "" {
-		return fmt.Errorf("Invalid path to .xml file of face model configuration: %s", faceConfig)
+
+	// if custom alert rule holds for longer than its duration, set alert
+	if len(alertRules) > 0 {
+		op.updateRules(d.ts, status, result)
 	}
-	// path to sentiment detection model can't be empty
-	if sentModel == "" {
-		return fmt.Errorf("Invalid path to .bin file of sentiment detection model: %s", sentModel)
+
+	if status.checked {
+		result.Perf = d.perf
 	}
-	// path to sentiment detection model config can't be empty
-	if sentConfig == "" {
+
+	result.status = status
+
+	// latest status is now prev status
+	op.prev.IsWatching = op.now.IsWatching
+	op.prev.IsAngry = op.now.IsAngry
+}
+
+// alertColor returns color of alert of type typ according to its severity; acknowledged alerts are displayed in gray
+func alertColor(result *Result, typ string) color.RGBA {
+	if result.Acknowledged[typ] {
+		return color.RGBA{128, 128, 128, 0}
+	}
+
+	if c, ok := severityColors[result.Severities[typ]]; ok {
+		return c
+	}
+
+	return overlayAlertColor
+}
+
+// frameRunner reads image frames from framesChan and performs face and sentiment detections on them
+// Each of the nets runs inference on its own goroutine so there can be as many frames in flight as there are nets.
+// Detections are processed in the order of the frames regardless of which inference finishes first.
+// New nets received from reloadChan replace the nets in use for all the following frames.
+// Alerts are suppressed for the grace period after the first frame and after input source change
+// and while the machine run state received from runChan is stopped.
+// Commands received from cmdChan pause and resume the monitoring, reset the operator timers,
+// acknowledge the alerts and reply with status snapshots.
+// doneChan is used to receive a signal from the main goroutine to notify frameRunner to stop and return
+func frameRunner(framesChan <-chan *frame, doneChan <-chan struct{}, resultsChan chan<- *Result,
+	pubChans []chan<- *Result, nets []*Nets, reloadChan <-chan []*Nets, cmdChan <-chan *command, alertsChans []chan<- Alert,
+	latches []*AlertLatch, runChan <-chan bool, history *History) error {
+
+	result := new(Result)
+	// operator stores operator status
+	op := NewOperator()
+	// ops stores status of every tracked operator if tracking is enabled
+	var ops *Operators
+	if track {
+		ops = NewOperators(NewTracker(trackIoU, trackMaxAge))
+	}
+
+	// alerts tracks lifecycle of the raised alerts
+	alerts := NewAlertManager()
+	// droppedAlerts is number of alerts dropped by the sinks which couldn't keep up
+	var droppedAlerts uint64
+	// publishAlerts records alert state transitions in history, sends them down every channel in alertsChans
+	// and puts them in every interlock latch
+	publishAlerts := func(changed []Alert, img *gocv.Mat) {
+		if snapshotDir != "" && img != nil {
+			snapshotAlerts(snapshotDir, snapshotAttach, changed, *img, result.status, result.Time)
+		}
+		if history != nil {
+			if err := history.Record(changed, img); err != nil {
+				fmt.Printf("Error recording alert history: %v\n", err)
+			}
+		}
+		// interlocks must never miss an alert, so their transitions are coalesced rather than dropped
+		for _, l := range latches {
+			l.Put(changed)
+		}
+		// slow sinks must not hold up the inference, so the alerts which don't fit in their queues are dropped
+		for _, alertsChan := range alertsChans {
+			for _, a := range changed {
+				select {
+				case alertsChan <- a:
+				default:
+					droppedAlerts++
+					fmt.Printf("Dropping %s alert %s: alert sink queue is full; %d alerts dropped so far\n",
+						a.State, a.ID, droppedAlerts)
+				}
+			}
+		}
+	}
+
+	// degrader degrades the inference when it exceeds latency budget
+	degrader := NewDegrader(latencyBudget)
+	// skip alternates frames skipped when the frames are sampled
+	var skip bool
+	// running is machine run state; the machine is assumed to be running until told otherwise
+	running := true
+	// graceUntil is time until which no alerts are raised after startup or input source change
+	var graceUntil time.Time
+	// watchdog detects camera tampering
+	watchdog := NewTamperWatchdog(tamperTimeout)
+	// reset makes the next frame start the operator timers over
+	var reset bool
+	// inputRate and processRate measure rates of accepted and processed frames
+	inputRate, processRate := &rateMeter{window: throughputWindow}, &rateMeter{window: throughputWindow}
+
+	// detsChan collects detections; it never blocks as there are at most len(nets) frames in flight
+	detsChan := make(chan *detection, len(nets))
+	// jobsChan distributes frames to inference goroutines
+	jobsChan := startInferRunners(nets, detsChan)
+	defer func() { close(jobsChan) }()
+
+	// pending stores detections which finished ahead of their preceding frames
+	pending := make(map[uint64]*detection)
+	// seq is sequence number of the next frame; next is sequence number of the next detection to process
+	var seq, next uint64
+	// inflight is number of frames being processed
+	var inflight int
+
+	for {
+		// stop accepting new frames when all inference goroutines are busy
+		in := framesChan
+		if inflight == len(nets) {
+			in = nil
+		}
+
+		select {
+		case <-doneChan:
+			fmt.Printf("Stopping frameRunner: received stop signal\n")
+			close(resultsChan)
+			for _, pubChan := range pubChans {
+				close(pubChan)
+			}
+			return nil
+		case f := <-in:
+			if f == nil || result.Paused || result.Suspended {
+				continue
+			}
+			result.Throughput.Frames++
+			result.Throughput.InputFPS = inputRate.Add(time.Now())
+			// process only every other frame when the inference is degraded the most
+			if degrader.Level() >= degradeSampling {
+				if skip = !skip; skip {
+					result.Throughput.Dropped++
+					continue
+				}
+			}
+			// let's make a copy of the original
+			c := copyFrame(f)
+			c.reset = c.reset || reset
+			reset = false
+			c.seq = seq
+			c.level = degrader.Level()
+			seq++
+			inflight++
+			jobsChan <- c
+		case n := <-reloadChan:
+			// frames in flight finish on the old nets which are closed afterwards
+			close(jobsChan)
+			nets = n
+			jobsChan = startInferRunners(nets, detsChan)
+		case running = <-runChan:
+		case cmd := <-cmdChan:
+			switch cmd.name {
+			case cmdAck:
+				publishAlerts(alerts.Acknowledge(time.Now(), cmd.arg), nil)
+				result.Acknowledged = alerts.acknowledged()
+			case cmdSnooze:
+				publishAlerts(alerts.Snooze(time.Now(), cmd.arg, cmd.duration), nil)
+			case cmdStatus:
+				cmd.reply <- result.ToMQTTMessage()
+			case cmdPause, cmdResume, cmdReset, cmdSuspend, cmdUnsuspend:
+				switch cmd.name {
+				case cmdPause, cmdResume:
+					result.Paused = cmd.name == cmdPause
+				case cmdSuspend, cmdUnsuspend:
+					result.Suspended = cmd.name == cmdSuspend
+				}
+				result.Time = time.Now()
+				reset = true
+				result.clearAlerts()
+				publishAlerts(alerts.Update(time.Now(), result), nil)
+				// the display loop reads one result per frame, so the pause state must not block on it
+				select {
+				case resultsChan <- result:
+				default:
+				}
+			}
+		case d := <-detsChan:
+			inflight--
+			pending[d.seq] = d
+			for p, ok := pending[next]; ok; p, ok = pending[next] {
+				delete(pending, next)
+				next++
+
+				// frames whose inference failed are skipped
+				if p.err != nil {
+					fmt.Printf("Skipping frame: %v\n", p.err)
+				}
+				// frames which were in flight when the monitoring was paused are dropped
+				if result.Paused || result.Suspended || p.err != nil {
+					if p.img != nil {
+						p.img.Close()
+					}
+					result.Throughput.Dropped++
+					continue
+				}
+				result.Throughput.FPS = processRate.Add(time.Now())
+				result.Throughput.Processed++
+
+				if ops != nil {
+					ops.update(p, result)
+				} else {
+					op.update(p, result)
+				}
+				if p.reset {
+					watchdog.Reset()
+				}
+				result.AlertTamper = watchdog.Update(p.ts, p.stats)
+				result.Degradation = degrader.Update(p.latency)
+				result.Time, result.Captured = p.ts, p.captured
+				if graceUntil.IsZero() || p.reset {
+					graceUntil = p.ts.Add(grace)
+				}
+				if !running || p.ts.Before(graceUntil) {
+					result.clearAlerts()
+				}
+				publishAlerts(alerts.Update(p.ts, result), p.img)
+				if p.img != nil {
+					p.img.Close()
+				}
+
+				// send data down the channels; the display only needs the latest result, which is the one
+				// already waiting in resultsChan if the display loop didn't take it yet, so the send never blocks
+				select {
+				case resultsChan <- result:
+				default:
+				}
+				// publishers get a copy as they read it while the result is updated by the following frames
+				snap := result.snapshot()
+				for _, pubChan := range pubChans {
+					// slow consumers miss the results they are not ready for
+					select {
+					case pubChan <- snap:
+					default:
+					}
+				}
+				// replayed frames are not captured at wall clock time, so their pipeline can't be traced
+				if telemetry != nil && !replay {
+					telemetry.Frame(p.ts, p.started, p.started.Add(p.latency), time.Now())
+				}
+			}
+		}
+	}
+}
+
+func parseCliFlags() error {
+	// parse cli flags
+	flag.Parse()
+
+	// creating display window without display crashes the program
+	if !headless && !hasDisplay() {
+		fmt.Printf("No display available; running headless\n")
+		headless = true
+	}
+
+	// path to face detection model can't be empty
+	if faceModel == "" {
+		return fmt.Errorf("Invalid path to .bin file of face detection model: %s", faceModel)
+	}
+	// path to face detection model config can't be empty unless the model doesn't need it
+	if faceConfig == "" && needsConfig(faceModel) {
+		return fmt.Errorf("Invalid path to .xml file of face model configuration: %s", faceConfig)
+	}
+	// path to sentiment detection model can't be empty
+	if sentModel == "" {
+		return fmt.Errorf("Invalid path to .bin file of sentiment detection model: %s", sentModel)
+	}
+	// path to sentiment detection model config can't be empty unless the model doesn't need it
+	if sentConfig == "" && needsConfig(sentModel) {
 		return fmt.Errorf("Invalid path to .xml file of sentiment model configuration: %s", sentConfig)
 	}
 	// path to pose detection model can't be empty
 	if poseModel == "" {
 		return fmt.Errorf("Invalid path to .bin file of pose detection model: %s", poseModel)
 	}
-	// path to pose detection model config can't be empty
-	if poseConfig == "" {
+	// path to pose detection model config can't be empty unless the model doesn't need it
+	if poseConfig == "" && needsConfig(poseModel) {
 		return fmt.Errorf("Invalid path to .xml file of pose model configuration: %s", poseConfig)
 	}
+	// switch the models to the requested precision variants
+	for _, m := range []struct {
+		precision     string
+		model, config *string
+	}{
+		{facePrecision, &faceModel, &faceConfig},
+		{sentPrecision, &sentModel, &sentConfig},
+		{posePrecision, &poseModel, &poseConfig},
+	} {
+		if m.precision == "" {
+			continue
+		}
+		var err error
+		if *m.model, err = withPrecision(*m.model, m.precision); err != nil {
+			return err
+		}
+		if *m.config != "" {
+			if *m.config, err = withPrecision(*m.config, m.precision); err != nil {
+				return err
+			}
+		}
+	}
+	// pose detection model must output yaw, pitch and roll
+	if poseLayers = parseLabels(poseLayersList); len(poseLayers) != 3 {
+		return fmt.Errorf("Invalid pose detection model output layers: %s", poseLayersList)
+	}
+	// facial landmarks model and its config must be provided together
+	if !checkModelFiles(landmarksModel, landmarksConfig) {
+		return fmt.Errorf("Both .bin and .xml files of facial landmarks model must be provided")
+	}
+	// faces can only be aligned using facial landmarks
+	if alignFaces && landmarksModel == "" {
+		return fmt.Errorf("Face alignment requires facial landmarks model")
+	}
+	// gaze estimation model and its config must be provided together
+	if !checkModelFiles(gazeModel, gazeConfig) {
+		return fmt.Errorf("Both .bin and .xml files of gaze estimation model must be provided")
+	}
+	// gaze estimation needs eye positions
+	if gazeModel != "" && landmarksModel == "" {
+		return fmt.Errorf("Gaze estimation requires facial landmarks model")
+	}
+	// eye state detection model and its config must be provided together
+	if !checkModelFiles(eyeModel, eyeConfig) {
+		return fmt.Errorf("Both .bin and .xml files of eye state detection model must be provided")
+	}
+	// eye state detection needs eye positions
+	if eyeModel != "" && landmarksModel == "" {
+		return fmt.Errorf("Eye state detection requires facial landmarks model")
+	}
+	// protective equipment detection model and its config must be provided together
+	if !checkModelFiles(ppeModel, ppeConfig) {
+		return fmt.Errorf("Both .bin and .xml files of protective equipment detection model must be provided")
+	}
+	ppeLabels, ppeRequired = parseLabels(ppeLabelsList), parseLabels(ppeRequiredList)
+	if ppeRequired == nil {
+		ppeRequired = ppeLabels
+	}
+	// required protective equipment must be detectable
+	if missing := missingPPE(ppeRequired, ppeLabels); len(missing) > 0 {
+		return fmt.Errorf("Unknown protective equipment: %s", strings.Join(missing, ", "))
+	}
+	// message catalog of the selected locale must exist
+	if locale != "" {
+		path, err := catalogPath(messagesDir, locale)
+		if err != nil {
+			return fmt.Errorf("Invalid locale: %v", err)
+		}
+		catalog, err := loadMessages(path)
+		if err != nil {
+			return fmt.Errorf("Invalid message catalog %s: %v", path, err)
+		}
+		messages = catalog
+	}
+	// emotion policies must watch known sentiments
+	emotions, err := parseEmotionPolicies(emotionAlerts)
+	if err != nil {
+		return err
+	}
+	emotionPolicies = emotions
+	// sentiment labels default to the emotions-recognition-retail-0003 model classes
+	sentLabels = defaultSentLabels
+	if sentLabelsFile != "" {
+		labels, err := loadSentLabels(sentLabelsFile)
+		if err != nil {
+			return fmt.Errorf("Invalid sentiment labels: %v", err)
+		}
+		sentLabels = labels
+	}
+	// face reidentification model and its config must be provided together
+	if !checkModelFiles(reidModel, reidConfig) {
+		return fmt.Errorf("Both .bin and .xml files of face reidentification model must be provided")
+	}
+	// operators can only be identified using face reidentification model and gallery together
+	if (reidModel == "") != (gallery == "") {
+		return fmt.Errorf("Operator identification requires both face reidentification model and gallery")
+	}
+	// phone detection model and its config must be provided together
+	if !checkModelFiles(phoneModel, phoneConfig) {
+		return fmt.Errorf("Both .bin and .xml files of phone detection model must be provided")
+	}
+	// person detection model and its config must be provided together
+	if !checkModelFiles(personModel, personConfig) {
+		return fmt.Errorf("Both .bin and .xml files of person detection model must be provided")
+	}
+	// face mask detection model and its config must be provided together
+	if !checkModelFiles(maskModel, maskConfig) {
+		return fmt.Errorf("Both .bin and .xml files of face mask detection model must be provided")
+	}
+	// masked face sentiment policy must be supported
+	if !maskPolicies[maskPolicy] {
+		return fmt.Errorf("Invalid mask policy: %s", maskPolicy)
+	}
+	// models without their own backend use the global one
+	for _, v := range []*int{&faceBackend, &sentBackend, &poseBackend} {
+		if *v < 0 {
+			*v = backend
+		}
+	}
+	// GPU must be selectable for every backend running on GPU
+	for _, m := range []struct {
+		backend int
+		targets []int
+	}{
+		{backend, listedTargets(target)},
+		{faceBackend, modelTargets(faceTarget, listedTargets(target))},
+		{sentBackend, modelTargets(sentTarget, listedTargets(target))},
+		{poseBackend, modelTargets(poseTarget, listedTargets(target))},
+	} {
+		if err := checkGPU(gpuIndex, m.backend, m.targets); err != nil {
+			return err
+		}
+	}
+	// model precisions must be supported by any of their inference targets since HETERO and AUTO fall back
+	// to the next target
+	targets := map[string][]int{
+		faceModel: modelTargets(faceTarget, listedTargets(target)), faceConfig: modelTargets(faceTarget, listedTargets(target)),
+		sentModel: modelTargets(sentTarget, listedTargets(target)), sentConfig: modelTargets(sentTarget, listedTargets(target)),
+		poseModel: modelTargets(poseTarget, listedTargets(target)), poseConfig: modelTargets(poseTarget, listedTargets(target)),
+	}
+	for _, m := range modelFiles() {
+		t, ok := targets[m]
+		if !ok {
+			t = listedTargets(target)
+		}
+		if err := checkPrecision(m, t); err != nil {
+			return err
+		}
+	}
+	// video capture API must be supported
+	if _, err := parseCaptureAPI(captureAPI); err != nil {
+		return err
+	}
+	// hardware decoder must be supported
+	if _, err := parseHWAccel(hwDecode); err != nil {
+		return err
+	}
+	// screen can't be captured together with input video file
+	if (screen != "" || screenWindow != "") && input != "" {
+		return fmt.Errorf("Screen capture can't be used together with input video file")
+	}
+	// screen capture frame rate must be positive
+	if streamFPS <= 0 {
+		return fmt.Errorf("Invalid video stream frame rate: %d", streamFPS)
+	}
+	if screenFPS <= 0 {
+		return fmt.Errorf("Invalid screen capture frame rate: %d", screenFPS)
+	}
+	if clips != "" && (clipPre < 0 || clipPost <= 0) {
+		return fmt.Errorf("Invalid alert clip duration: -clip-pre=%s -clip-post=%s", clipPre, clipPost)
+	}
+	// there must be at least one inference request in flight
+	if asyncRequests < 1 {
+		return fmt.Errorf("Invalid number of inference requests: %d", asyncRequests)
+	}
+	// MULTI mode assigns the devices to the inference requests, so every device needs one
+	if targetMode == multiMode && asyncRequests < len(targetDevices) {
+		return fmt.Errorf("Invalid number of inference requests: %d; MULTI mode needs -async of at least %d, one per device",
+			asyncRequests, len(targetDevices))
+	}
+	// face quality filters can't be negative
+	if faceMinSize < 0 || faceMaxAspect < 0 || faceMinSharpness < 0 {
+		return fmt.Errorf("Invalid face quality filter: size %d, aspect %f, sharpness %f", faceMinSize, faceMaxAspect, faceMinSharpness)
+	}
+	// aspect ratio of the longer and shorter side is at least one
+	if faceMaxAspect > 0 && faceMaxAspect < 1 {
+		return fmt.Errorf("Invalid face aspect ratio: %f", faceMaxAspect)
+	}
+	// head pose limits must be positive angles
+	for _, v := range []float64{poseYawLimit, posePitchLimit, poseRollLimit} {
+		if v <= 0 || v > 180 {
+			return fmt.Errorf("Invalid head pose limit: %f", v)
+		}
+	}
+	// head pose quality needs positive face size
+	if poseMinSize < 1 {
+		return fmt.Errorf("Invalid pose minimum face size: %d", poseMinSize)
+	}
+	// risk score weights must be valid and the alert threshold must be a valid score
+	if risk || riskThreshold > 0 {
+		weights, err := parseRiskWeights(riskWeightsList)
+		if err != nil {
+			return err
+		}
+		riskWeights = weights
+		if riskThreshold < 0 || riskThreshold > 1 {
+			return fmt.Errorf("Invalid risk threshold: %f", riskThreshold)
+		}
+		if riskWindow <= 0 {
+			return fmt.Errorf("Invalid risk window: %s", riskWindow)
+		}
+		risk = true
+	}
+	// shift schedule must be valid
+	shifts, err := parseSchedule(schedule)
+	if err != nil {
+		return err
+	}
+	shiftSchedule = shifts
+	// operator zone must be a valid rectangle
+	z, err := parseZone(operatorZone)
+	if err != nil {
+		return err
+	}
+	zone = z
+	// face panel must be placed in a corner
+	switch facePanelCorner {
+	case "", panelTopLeft, panelTopRight, panelBottomLeft, panelBottomRight:
+	default:
+		return fmt.Errorf("Invalid face panel corner: %s", facePanelCorner)
+	}
+	if facePanelSize <= 0 {
+		return fmt.Errorf("Invalid face panel size: %d", facePanelSize)
+	}
+	if fpsLog < 0 {
+		return fmt.Errorf("Invalid frame counter log interval: %s", fpsLog)
+	}
+	// overlay style must be valid
+	if overlayFontScale <= 0 {
+		return fmt.Errorf("Invalid overlay font scale: %f", overlayFontScale)
+	}
+	if overlayThickness <= 0 {
+		return fmt.Errorf("Invalid overlay text thickness: %d", overlayThickness)
+	}
+	if overlayLineHeight < 0 {
+		return fmt.Errorf("Invalid overlay line height: %d", overlayLineHeight)
+	}
+	if err := parseOverlayStyle(overlayColor, overlayOutline, overlayAlert, overlayPos); err != nil {
+		return err
+	}
+	// display window geometry must be valid
+	size, err := parseWindowSize(windowSize)
+	if err != nil {
+		return err
+	}
+	displaySize = size
+	pos, err := parseWindowPosition(windowPos)
+	if err != nil {
+		return err
+	}
+	displayPos = pos
+	if maxOperators < 0 {
+		return fmt.Errorf("Invalid maximum number of operators: %d", maxOperators)
+	}
+	// operator thresholds apply to identified operators and they must be valid
+	if thresholdsSource != "" {
+		if gallery == "" {
+			return fmt.Errorf("Operator thresholds require -gallery")
+		}
+		if thresholdsInterval < 0 {
+			return fmt.Errorf("Invalid operator thresholds interval: %s", thresholdsInterval)
+		}
+		t, err := loadThresholds(thresholdsSource)
+		if err != nil {
+			return fmt.Errorf("Invalid operator thresholds: %v", err)
+		}
+		setThresholds(t)
+	}
+	// camera is identified by its input unless its ID is set
+	if cameraID == "" {
+		cameraID = input
+		if cameraID == "" {
+			cameraID = fmt.Sprintf("%d", deviceID)
+		}
+	}
+	// custom alert rules must compile and their names must not clash with the built-in alert types
+	if rulesPath != "" {
+		ar, err := loadRules(rulesPath)
+		if err != nil {
+			return fmt.Errorf("Invalid alert rules: %v", err)
+		}
+		alertRules = ar
+	}
+	// GPIO pin is asserted by known alert types
+	for _, typ := range parseLabels(gpioAlerts) {
+		if _, ok := alertTypes(new(Result))[typ]; !ok {
+			return fmt.Errorf("Invalid GPIO alert type: %s", typ)
+		}
+	}
+	// Modbus pause needs a coil or register to write into
+	if modbusAddr != "" {
+		if modbusCoil < 0 && modbusRegister < 0 {
+			return fmt.Errorf("Modbus requires coil or register address")
+		}
+		if modbusCoil > 0xFFFF || modbusRegister > 0xFFFF || modbusValue < 0 || modbusValue > 0xFFFF {
+			return fmt.Errorf("Invalid Modbus address or value: must be between 0 and 65535")
+		}
+		if modbusUnit < 0 || modbusUnit > 255 {
+			return fmt.Errorf("Invalid Modbus unit: %d", modbusUnit)
+		}
+		if severityRank(modbusSeverity) < 0 {
+			return fmt.Errorf("Invalid Modbus severity: %s", modbusSeverity)
+		}
+	}
+	// OPC UA client writes into at least one node or reads the run state
+	if opcuaEndpoint != "" {
+		nodes, err := parseOPCUANodes(opcuaNodes)
+		if err != nil {
+			return fmt.Errorf("Invalid OPC UA nodes: %v", err)
+		}
+		if len(nodes) == 0 && opcuaRunState == "" {
+			return fmt.Errorf("OPC UA client requires nodes to write into or run state node")
+		}
+		opcuaNodeIDs = nodes
+	}
+	// snapshots are referenced from alert history
+	if historySnapshots != "" && historyFile == "" {
+		return fmt.Errorf("Alert snapshots require alert history file")
+	}
+	if snapshotDir != "" {
+		if err := checkSnapshotDir(snapshotDir); err != nil {
+			return fmt.Errorf("Invalid snapshot directory: %v", err)
+		}
+	}
+	switch snapshotAttach {
+	case "":
+	case attachPath, attachBase64:
+		if snapshotDir == "" {
+			return fmt.Errorf("Attaching snapshots requires -snapshots flag")
+		}
+	default:
+		return fmt.Errorf("Invalid snapshot attachment: %s", snapshotAttach)
+	}
+	// alert email templates must parse
+	templates, err := loadEmailTemplates(emailTemplateDir)
+	if err != nil {
+		return fmt.Errorf("Invalid alert email templates: %v", err)
+	}
+	emailTemplates = templates
+	if emailInterval < 0 {
+		return fmt.Errorf("Invalid alert email interval: %s", emailInterval)
+	}
+	if emailSnapshots && snapshotDir == "" {
+		return fmt.Errorf("Attaching snapshots to alert emails requires -snapshots flag")
+	}
+	// alert severity rules must be valid
+	rules, err := parseSeverities(alertSeverities)
+	if err != nil {
+		return err
+	}
+	severityRules = rules
+	// alert display modes must be valid
+	banners, err := parseAlertBanners(alertBanner)
+	if err != nil {
+		return err
+	}
+	alertBanners = banners
+	if alertFlash < 0 {
+		return fmt.Errorf("Invalid alert flash period: %s", alertFlash)
+	}
+	// alert escalation steps must be valid and escalate to the available sinks
+	policies, err := parseEscalations(alertEscalations)
+	if err != nil {
+		return err
+	}
+	escalationPolicies = policies
+	if escalationSinks()[sinkMQTT] && !publish {
+		return fmt.Errorf("Escalation to MQTT requires -publish flag")
+	}
+	if detections && !publish {
+		return fmt.Errorf("Detection payload requires -publish flag")
+	}
+	if escalationSinks()[sinkKafka] && !kafka {
+		return fmt.Errorf("Escalation to Kafka requires -kafka flag")
+	}
+	if escalationSinks()[sinkAWSIoT] && !awsIoT {
+		return fmt.Errorf("Escalation to AWS IoT requires -aws-iot flag")
+	}
+	// thing shadow is updated by AWS IoT publisher
+	if awsIoTShadow && !awsIoT {
+		return fmt.Errorf("AWS IoT thing shadow requires -aws-iot flag")
+	}
+	if escalationSinks()[sinkAzureIoT] && !azureIoT {
+		return fmt.Errorf("Escalation to Azure IoT Hub requires -azure-iot flag")
+	}
+	// Home Assistant entities reflect the published operator status
+	if hass {
+		if !publish {
+			return fmt.Errorf("Home Assistant discovery requires -publish flag")
+		}
+		if hassPrefix == "" || strings.ContainsAny(hassPrefix, "+#") {
+			return fmt.Errorf("Invalid Home Assistant discovery prefix: %s", hassPrefix)
+		}
+	}
+	// Sparkplug B IDs are topic levels and the node is identified by the machine ID
+	if sparkplugGroup != "" {
+		if strings.ContainsAny(sparkplugGroup, "/+#") {
+			return fmt.Errorf("Invalid Sparkplug B group ID: %s", sparkplugGroup)
+		}
+		if machineID == "" || strings.ContainsAny(machineID, "/+#") {
+			return fmt.Errorf("Sparkplug B requires valid -machine-id: %s", machineID)
+		}
+	}
+	if escalationSinks()[sinkSyslog] && syslogAddr == "" {
+		return fmt.Errorf("Escalation to syslog requires -syslog flag")
+	}
+	if escalationSinks()[sinkJournald] && !journald {
+		return fmt.Errorf("Escalation to systemd journal requires -journald flag")
+	}
+	if escalationSinks()[sinkSlack] && !slack {
+		return fmt.Errorf("Escalation to Slack requires -slack flag")
+	}
+	if slackSnapshots && snapshotDir == "" {
+		return fmt.Errorf("Posting snapshots to Slack requires -snapshots flag")
+	}
+	// SMS recipients must have valid numbers and shifts
+	recipients, err := parseSMSRecipients(smsTo)
+	if err != nil {
+		return err
+	}
+	smsRecipients = recipients
+	if escalationSinks()[sinkSMS] && len(smsRecipients) == 0 {
+		return fmt.Errorf("Escalation to SMS requires -sms parameter")
+	}
+	if severityRank(smsSeverity) < 0 {
+		return fmt.Errorf("Invalid SMS severity: %s", smsSeverity)
+	}
+	if smsDailyCap < 0 {
+		return fmt.Errorf("Invalid SMS daily cap: %d", smsDailyCap)
+	}
+	switch incidentService {
+	case "", incidentPagerDuty, incidentOpsgenie:
+	default:
+		return fmt.Errorf("Invalid incident management service: %s", incidentService)
+	}
+	if escalationSinks()[sinkIncident] && incidentService == "" {
+		return fmt.Errorf("Escalation to incident requires -incidents parameter")
+	}
+	switch pushService {
+	case "", pushNtfy, pushGotify:
+	default:
+		return fmt.Errorf("Invalid push notification service: %s", pushService)
+	}
+	if escalationSinks()[sinkPush] && pushService == "" {
+		return fmt.Errorf("Escalation to push requires -push parameter")
+	}
+	if severityRank(incidentSeverity) < 0 {
+		return fmt.Errorf("Invalid incident severity: %s", incidentSeverity)
+	}
+	if escalationSinks()[sinkPubSub] && !pubsubPublish {
+		return fmt.Errorf("Escalation to Pub/Sub requires -pubsub flag")
+	}
+	if escalationSinks()[sinkAMQP] && !amqpPublish {
+		return fmt.Errorf("Escalation to AMQP requires -amqp flag")
+	}
+	if escalationSinks()[sinkWebhook] && webhookURLs == "" {
+		return fmt.Errorf("Escalation to webhook requires -webhook parameter")
+	}
+	// MQTT topic prefix can't be empty
+	if strings.Trim(topicPrefix, "/") == "" {
+		return fmt.Errorf("Invalid MQTT topic prefix: %q", topicPrefix)
+	}
+	setTopics(topicPrefix, resultsSubtopic, alertsSubtopic, heartbeatSubtopic, perfSubtopic)
+	// MQTT QoS levels must be valid
+	if qos < 0 || qos > 2 {
+		return fmt.Errorf("Invalid MQTT QoS: %d", qos)
+	}
+	mqttQoS = byte(qos)
+	topics, err := parseTopicOptions(topicOpts)
+	if err != nil {
+		return err
+	}
+	mqttTopics = topics
+	if mqttBuffer < 0 {
+		return fmt.Errorf("Invalid MQTT buffer size: %d", mqttBuffer)
+	}
+	// webhook headers must be valid and failed requests can't be retried negative number of times
+	if _, err := parseHeaders(webhookHeaders); err != nil {
+		return err
+	}
+	if webhookRetries < 0 {
+		return fmt.Errorf("Invalid number of webhook retries: %d", webhookRetries)
+	}
+	// HTTP sink batches must hold at least one status
+	if httpSinkBatch < 1 {
+		return fmt.Errorf("Invalid HTTP sink batch size: %d", httpSinkBatch)
+	}
+	// alert heartbeat interval can't be negative
+	if alertHeartbeat < 0 {
+		return fmt.Errorf("Invalid alert heartbeat interval: %s", alertHeartbeat)
+	}
+	// grace period can't be negative
+	if grace < 0 {
+		return fmt.Errorf("Invalid grace period: %s", grace)
+	}
+	// alert hysteresis and cool-down can't be negative
+	if alertHold < 0 || alertCooldown < 0 {
+		return fmt.Errorf("Invalid alert hold %s or cool-down %s", alertHold, alertCooldown)
+	}
+	// attention ratio must be measured over a valid window
+	if attentionWindow < 0 || attentionThreshold <= 0 || attentionThreshold > 1 {
+		return fmt.Errorf("Invalid attention window %s or threshold %f", attentionWindow, attentionThreshold)
+	}
+	// timers must reset after at least one frame
+	if angryResetFrames < 1 || watchResetFrames < 1 {
+		return fmt.Errorf("Invalid number of timer reset frames: angry %d, watch %d", angryResetFrames, watchResetFrames)
+	}
+	// sentiment must be smoothed over at least one frame
+	if sentWindow < 1 {
+		return fmt.Errorf("Invalid sentiment window: %d", sentWindow)
+	}
+	// number of warm-up passes can't be negative
+	if warmup < 0 {
+		return fmt.Errorf("Invalid number of warm-up passes: %d", warmup)
+	}
+	// event store summary interval can't be negative
+	if storeSummary < 0 {
+		return fmt.Errorf("Invalid event store summary interval: %s", storeSummary)
+	}
+	// latency budget can't be negative
+	if latencyBudget < 0 {
+		return fmt.Errorf("Invalid latency budget: %s", latencyBudget)
+	}
+	// reuse overlap must be a fraction
+	if reuseIoU <= 0 || reuseIoU > 1 {
+		return fmt.Errorf("Invalid reuse IoU: %f", reuseIoU)
+	}
+	// tracking overlap must be a fraction
+	if trackIoU <= 0 || trackIoU > 1 {
+		return fmt.Errorf("Invalid track IoU: %f", trackIoU)
+	}
+	// replay mode requires input video file
+	if replay && input == "" {
+		return fmt.Errorf("Replay mode requires input video file")
+	}
+
+	return nil
+}
+
+// NewInferModel reads DNN model and it configuration, sets its preferable target and backend and returns it.
+// It returns error if either the model files failed to be read or setting the target fails
+func NewInferModel(model, config string, backend, target int) (*gocv.Net, error) {
+	// read in Face model and set the target
+	m := gocv.ReadNet(model, config)
+
+	if err := m.SetPreferableBackend(gocv.NetBackendType(backend)); err != nil {
+		return nil, err
+	}
+
+	if err := m.SetPreferableTarget(gocv.NetTargetType(target)); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// hasDisplay returns true if display window can be opened.
+// X11 and Wayland desktops announce their displays in environment variables; other platforms always have a display.
+func hasDisplay() bool {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		return true
+	}
+
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
+
+// NewCapture creates new video capture from input or camera backend if input is empty and returns it.
+// The capture is opened using api capture API and accel hardware decoder if either of them is requested.
+// If input is not empty, NewCapture adjusts delay parameter so video playback matches FPS in the video file.
+// It fails with error if it either can't open the input video file or the video device
+func NewCapture(input string, deviceID int, api string, accel string, delay *float64) (*gocv.VideoCapture, error) {
+	a, err := parseCaptureAPI(api)
+	if err != nil {
+		return nil, err
+	}
+
+	hw, err := parseHWAccel(accel)
+	if err != nil {
+		return nil, err
+	}
+
+	if input != "" {
+		// open video file
+		var vc *gocv.VideoCapture
+		if a == gocv.VideoCaptureAny && hw == 0 {
+			vc, err = gocv.VideoCaptureFile(input)
+		} else {
+			vc, err = gocv.VideoCaptureFileWithAPIParams(input, a, captureParams(hw))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		fps := vc.Get(gocv.VideoCaptureFPS)
+		*delay = 1000 / fps
+
+		return vc, nil
+	}
+
+	// open camera device
+	var vc *gocv.VideoCapture
+	if a == gocv.VideoCaptureAny && hw == 0 {
+		vc, err = gocv.VideoCaptureDevice(deviceID)
+	} else {
+		vc, err = gocv.VideoCaptureDeviceWithAPIParams(deviceID, a, captureParams(hw))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return vc, nil
+}
+
+// NewMQTTPublisher creates new MQTT client which collects analytics data and publishes them to remote MQTT server.
+// It attempts to make a connection to the remote server and if successful it return the client handler
+// It returns error if either the connection to the remote server failed or if the client config is invalid.
+func NewMQTTPublisher() (*MQTTClient, error) {
+	// create MQTT client and connect to MQTT server
+	opts, err := MQTTClientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	// messages published while the broker is unreachable are buffered unless the buffer is disabled
+	var buffer *OfflineBuffer
+	if mqttBuffer > 0 {
+		buffer, err = NewOfflineBuffer(mqttBuffer, mqttBufferFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading offline buffer: %v", err)
+		}
+	}
+
+	// create MQTT client ad connect to remote server
+	c, err := MQTTConnect(opts, buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// frame ise used to send video frames and program configuration to upstream goroutines
+type frame struct {
+	// img is image frame
+	img *gocv.Mat
+	// depth is depth frame aligned with img; nil if depth is not available
+	depth *gocv.Mat
+	// depthScale is number of meters per depth unit of depth
+	depthScale float64
+	// ts is time when the frame was captured; in replay mode it is time in the input video
+	ts time.Time
+	// captured is wall clock time when the frame was captured
+	captured time.Time
+	// reset signals the frame is the first frame of a new input source
+	reset bool
+	// seq is sequence number of the frame
+	seq uint64
+	// level is inference degradation level the frame is processed at
+	level int
+}
+
+func main() {
+	// run subcommand and exit if requested
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fetch-models":
+			if err := fetchModels(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error fetching models: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "devices":
+			if err := devicesCommand(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "alerts":
+			if err := alertsCommand(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "events":
+			if err := eventsCommand(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "export":
+			if err := exportCommand(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// parse cli flags
+	if err := parseCliFlags(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing command line parameters: %v\n", err)
+		os.Exit(1)
+	}
+
+	// read in the models; every inference request in flight needs its own copy
+	nets, err := LoadNets(asyncRequests, backend, target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	// run a few forward passes so the first frames don't suffer from inference engine initialization
+	if err := warmUpNets(nets, warmup); err != nil {
+		fmt.Fprintf(os.Stderr, "Error warming up models: %v\n", err)
+		os.Exit(1)
+	}
+
+	// create new video capture
+	var vc *gocv.VideoCapture
+	if screen != "" || screenWindow != "" {
+		vc, err = NewScreenCapture(screen, screenWindow, screenFPS, &delay)
+	} else {
+		vc, err = NewCapture(input, deviceID, captureAPI, hwDecode, &delay)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating new video capture: %v\n", err)
+		os.Exit(1)
+	}
+	// vc might be replaced when the input source changes
+	defer func() { vc.Close() }()
+
+	// open RealSense depth stream if requested
+	var dc *DepthCapture
+	if depthDeviceID >= 0 {
+		dc, err = NewDepthCapture(depthDeviceID, depthScale)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating new depth capture: %v\n", err)
+			os.Exit(1)
+		}
+		defer dc.Close()
+	}
+
+	// start recording raw input video if requested
+	var rec *Recorder
+	if record != "" {
+		fps := vc.Get(gocv.VideoCaptureFPS)
+		if fps <= 0 {
+			fps = 1000 / delay
+		}
+		rec, err = NewRecorder(record, recordCodec, fps, recordSegment, recordKeep)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating video recorder: %v\n", err)
+			os.Exit(1)
+		}
+		defer rec.Close()
+	}
+
+	// record video clips of raised alerts if requested
+	var clipRec *ClipRecorder
+	if clips != "" {
+		fps := vc.Get(gocv.VideoCaptureFPS)
+		if fps <= 0 {
+			fps = 1000 / delay
+		}
+		clipRec, err = NewClipRecorder(clips, recordCodec, fps, clipPre, clipPost)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating alert clip recorder: %v\n", err)
+			os.Exit(1)
+		}
+		defer clipRec.Close()
+	}
+
+	// record alert history if requested
+	var history *History
+	if historyFile != "" {
+		history, err = NewHistory(historyFile, historySnapshots)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening alert history: %v\n", err)
+			os.Exit(1)
+		}
+		defer history.Close()
+	}
+
+	// export pipeline telemetry if requested
+	if otel {
+		telemetry, err = newTelemetry()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to set up OpenTelemetry: %v\n", err)
+			os.Exit(1)
+		}
+		defer telemetry.Shutdown()
+	}
+
+	// frames channel provides the source of images to process
+	framesChan := make(chan *frame, 1)
+	// errChan is a channel used to capture program errors
+	errChan := make(chan error, 40)
+	// doneChan is used to signal goroutines they need to stop
+	doneChan := make(chan struct{})
+	// resultsChan is used for detection distribution
+	resultsChan := make(chan *Result, 1)
+	// sigChan is used as a handler to stop all the goroutines
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, os.Kill, syscall.SIGTERM)
+	// pubChans are used for publishing data analytics stats
+	var pubChans []chan<- *Result
+	// runChan is used to receive machine run state
+	var runChan chan bool
+	// sourceChan is used to receive new video input sources
+	sourceChan := make(chan *source, 1)
+	// triggerChan is used to request the models to be reloaded
+	triggerChan := make(chan struct{}, 1)
+	// reloadChan is used to pass reloaded models to frameRunner
+	reloadChan := make(chan []*Nets)
+	// cmdChan is used to send commands to frameRunner
+	cmdChan := make(chan *command, 1)
+	// alertsChans are used for distributing alert state transitions
+	var alertsChans []chan<- Alert
+	// latches are used for distributing alert state transitions to the machine interlocks
+	var latches []*AlertLatch
+	// waitgroup to synchronise all goroutines
+	var wg sync.WaitGroup
+
+	// p publishes to MQTT server; nil if neither publishing nor remote control is enabled
+	var p *MQTTClient
+	if publish || control {
+		p, err = NewMQTTPublisher()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create MQTT publisher: %v\n", err)
+			os.Exit(1)
+		}
+		defer p.Disconnect(100)
+
+		if publish {
+			// status window aggregates every frame, so it gets room for the frames processed while it is sent
+			pubChan := make(chan *Result, windowQueue)
+			pubChans = append(pubChans, pubChan)
+			// start MQTT worker goroutine
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errChan <- messageRunner(doneChan, pubChan, p, resultsTopic, rate)
+			}()
+
+			if detections {
+				detectionsChan := make(chan *Result, 1)
+				pubChans = append(pubChans, detectionsChan)
+				// start detection publishing goroutine
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errChan <- detectionsRunner(doneChan, detectionsChan, p)
+				}()
+			}
+
+			alertsChan := make(chan Alert, alertQueue)
+			alertsChans = append(alertsChans, alertsChan)
+			// start alert publishing goroutine
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errChan <- alertRunner(doneChan, alertsChan, p, sinkMQTT)
+			}()
+
+			// announce Home Assistant entities and re-announce them whenever Home Assistant restarts
+			if hass {
+				publishHassDiscovery(p, hassPrefix)
+				if _, err := p.Subscribe(hassPrefix+"/status", newHassStatusHandler(p, hassPrefix)); err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to subscribe to %s/status: %v\n", hassPrefix, err)
+					os.Exit(1)
+				}
+			}
+		}
+
+		if control {
+			if _, err := p.Subscribe(inputTopic, newSourceHandler(sourceChan)); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to subscribe to %s: %v\n", inputTopic, err)
+				os.Exit(1)
+			}
+			if _, err := p.Subscribe(reloadTopic, newReloadHandler(triggerChan)); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to subscribe to %s: %v\n", reloadTopic, err)
+				os.Exit(1)
+			}
+			if _, err := p.Subscribe(ackTopic, newAckHandler(cmdChan)); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to subscribe to %s: %v\n", ackTopic, err)
+				os.Exit(1)
+			}
+			if _, err := p.Subscribe(cmdTopic, newCommandHandler(cmdChan)); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to subscribe to %s: %v\n", cmdTopic, err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// publish data analytics and alerts to Kafka
+	if kafka {
+		k, err := newKafkaProducer()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create Kafka producer: %v\n", err)
+			os.Exit(1)
+		}
+		defer k.Close()
+
+		kafkaChan := make(chan *Result, 1)
+		pubChans = append(pubChans, kafkaChan)
+		// start Kafka worker goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- messageRunner(doneChan, kafkaChan, k, resultsTopic, rate)
+		}()
+
+		kafkaAlertsChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, kafkaAlertsChan)
+		// start Kafka alert publishing goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- alertRunner(doneChan, kafkaAlertsChan, k, sinkKafka)
+		}()
+	}
+
+	// report data analytics as Sparkplug B edge node
+	if sparkplugGroup != "" {
+		n, err := NewSparkplugNode(sparkplugGroup)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create Sparkplug B node: %v\n", err)
+			os.Exit(1)
+		}
+		defer n.Close()
+
+		sparkplugChan := make(chan *Result, 1)
+		pubChans = append(pubChans, sparkplugChan)
+		// start Sparkplug B worker goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- sparkplugRunner(doneChan, sparkplugChan, n, rate)
+		}()
+	}
+
+	// publish data analytics and alerts to Google Cloud Pub/Sub
+	if pubsubPublish {
+		p, err := newPubSubPublisher()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create Pub/Sub publisher: %v\n", err)
+			os.Exit(1)
+		}
+		defer p.Close()
+
+		pubsubChan := make(chan *Result, 1)
+		pubChans = append(pubChans, pubsubChan)
+		// start Pub/Sub worker goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- messageRunner(doneChan, pubsubChan, p, resultsTopic, rate)
+		}()
+
+		pubsubAlertsChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, pubsubAlertsChan)
+		// start Pub/Sub alert publishing goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- alertRunner(doneChan, pubsubAlertsChan, p, sinkPubSub)
+		}()
+	}
+
+	// publish data analytics and alerts to AWS IoT Core
+	if awsIoT {
+		a, err := NewAWSIoT(awsIoTShadow)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to connect to AWS IoT: %v\n", err)
+			os.Exit(1)
+		}
+		defer a.Close()
+
+		awsChan := make(chan *Result, 1)
+		pubChans = append(pubChans, awsChan)
+		// start AWS IoT worker goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- messageRunner(doneChan, awsChan, a, resultsTopic, rate)
+		}()
+
+		awsAlertsChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, awsAlertsChan)
+		// start AWS IoT alert publishing goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- alertRunner(doneChan, awsAlertsChan, a, sinkAWSIoT)
+		}()
+	}
+
+	// publish data analytics and alerts to Azure IoT Hub
+	if azureIoT {
+		a, err := NewAzureIoT(cmdChan)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to connect to Azure IoT Hub: %v\n", err)
+			os.Exit(1)
+		}
+		defer a.Close()
+
+		azureChan := make(chan *Result, 1)
+		pubChans = append(pubChans, azureChan)
+		// start Azure IoT Hub worker goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- messageRunner(doneChan, azureChan, a, resultsTopic, rate)
+		}()
+
+		azureAlertsChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, azureAlertsChan)
+		// start Azure IoT Hub alert publishing goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- alertRunner(doneChan, azureAlertsChan, a, sinkAzureIoT)
+		}()
+	}
+
+	// publish data analytics and alerts to AMQP broker
+	if amqpPublish {
+		a, err := newAMQPPublisher()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create AMQP publisher: %v\n", err)
+			os.Exit(1)
+		}
+		defer a.Close()
+
+		amqpChan := make(chan *Result, 1)
+		pubChans = append(pubChans, amqpChan)
+		// start AMQP worker goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- messageRunner(doneChan, amqpChan, a, resultsTopic, rate)
+		}()
+
+		amqpAlertsChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, amqpAlertsChan)
+		// start AMQP alert publishing goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- alertRunner(doneChan, amqpAlertsChan, a, sinkAMQP)
+		}()
+	}
 
-	return nil
-}
+	// post batches of operator status to HTTP endpoint
+	if httpSink != "" {
+		headers, _ := parseHeaders(webhookHeaders)
+		w := NewWebhook([]string{httpSink}, headers, webhookRetries)
 
-// NewInferModel reads DNN model and it configuration, sets its preferable target and backend and returns it.
-// It returns error if either the model files failed to be read or setting the target fails
-func NewInferModel(model, config string, backend, target int) (*gocv.Net, error) {
-	// read in Face model and set the target
-	m := gocv.ReadNet(model, config)
+		httpSinkChan := make(chan *Result, 1)
+		pubChans = append(pubChans, httpSinkChan)
+		// start HTTP sink goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- httpSinkRunner(doneChan, httpSinkChan, w, rate, httpSinkBatch)
+		}()
+	}
 
-	if err := m.SetPreferableBackend(gocv.NetBackendType(backend)); err != nil {
-		return nil, err
+	// write operator status and inference performance to InfluxDB
+	if influx {
+		db, err := NewInflux()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create InfluxDB writer: %v\n", err)
+			os.Exit(1)
+		}
+
+		influxChan := make(chan *Result, 1)
+		pubChans = append(pubChans, influxChan)
+		// start InfluxDB goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- influxRunner(doneChan, influxChan, db, rate)
+		}()
 	}
 
-	if err := m.SetPreferableTarget(gocv.NetTargetType(target)); err != nil {
-		return nil, err
+	// store status changes, alerts and summaries locally
+	if storePath != "" {
+		s, err := openEventStore(storePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open event store: %v\n", err)
+			os.Exit(1)
+		}
+		defer s.Close()
+
+		storeChan := make(chan *Result, 1)
+		pubChans = append(pubChans, storeChan)
+		storeAlertsChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, storeAlertsChan)
+		// start event store goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- storeRunner(doneChan, storeChan, storeAlertsChan, s, storeSummary)
+		}()
 	}
 
-	return &m, nil
-}
+	// write operator status of the live session to CSV file
+	if csvPath != "" {
+		f, err := os.Create(csvPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create CSV file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w, err := NewCSVWriter(f, alertTypeNames())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write CSV header: %v\n", err)
+			os.Exit(1)
+		}
 
-// NewCapture creates new video capture from input or camera backend if input is empty and returns it.
-// If input is not empty, NewCapture adjusts delay parameter so video playback matches FPS in the video file.
-// It fails with error if it either can't open the input video file or the video device
-func NewCapture(input string, deviceID int, delay *float64) (*gocv.VideoCapture, error) {
-	if input != "" {
-		// open video file
-		vc, err := gocv.VideoCaptureFile(input)
+		csvChan := make(chan *Result, 1)
+		pubChans = append(pubChans, csvChan)
+		// start CSV goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- csvRunner(doneChan, csvChan, w, rate)
+		}()
+	}
+
+	// email escalated alerts or all the alerts if requested
+	if escalationSinks()[sinkEmail] || emailAll {
+		m, err := NewMailer()
 		if err != nil {
-			return nil, err
+			fmt.Fprintf(os.Stderr, "Failed to create mailer: %v\n", err)
+			os.Exit(1)
 		}
 
-		fps := vc.Get(gocv.VideoCaptureFPS)
-		*delay = 1000 / fps
+		emailChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, emailChan)
+		// start email goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- emailRunner(doneChan, emailChan, m, emailTemplates, emailInterval, emailSnapshots)
+		}()
+	}
 
-		return vc, nil
+	// record video clips of raised alerts
+	if clipRec != nil {
+		clipChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, clipChan)
+		// start alert clip goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- clipRunner(doneChan, clipChan, clipRec)
+		}()
 	}
 
-	// open camera device
-	vc, err := gocv.VideoCaptureDevice(deviceID)
-	if err != nil {
-		return nil, err
+	// log alerts to syslog and systemd journal
+	for _, target := range []struct {
+		enabled bool
+		sink    string
+		open    func() (AlertLogger, error)
+	}{
+		{syslogAddr != "", sinkSyslog, func() (AlertLogger, error) { return newSyslogLogger(syslogAddr) }},
+		{journald, sinkJournald, newJournalLogger},
+	} {
+		if !target.enabled {
+			continue
+		}
+		l, err := target.open()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to connect to %s: %v\n", target.sink, err)
+			os.Exit(1)
+		}
+		defer l.Close()
+
+		logChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, logChan)
+		sink := target.sink
+		// start alert logging goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- logRunner(doneChan, logChan, l, sink)
+		}()
 	}
 
-	return vc, nil
-}
+	// post alerts to Slack
+	if slack {
+		s, err := NewSlack()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create Slack client: %v\n", err)
+			os.Exit(1)
+		}
 
-// NewMQTTPublisher creates new MQTT client which collects analytics data and publishes them to remote MQTT server.
-// It attempts to make a connection to the remote server and if successful it return the client handler
-// It returns error if either the connection to the remote server failed or if the client config is invalid.
-func NewMQTTPublisher() (*MQTTClient, error) {
-	// create MQTT client and connect to MQTT server
-	opts, err := MQTTClientOptions()
-	if err != nil {
-		return nil, err
+		slackChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, slackChan)
+		// start Slack goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- slackRunner(doneChan, slackChan, s, slackSnapshots)
+		}()
 	}
 
-	// create MQTT client ad connect to remote server
-	c, err := MQTTConnect(opts)
-	if err != nil {
-		return nil, err
+	// text alerts
+	if len(smsRecipients) > 0 {
+		t, err := NewTexter(smsRecipients, smsDailyCap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create texter: %v\n", err)
+			os.Exit(1)
+		}
+
+		smsChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, smsChan)
+		// start SMS goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- smsRunner(doneChan, smsChan, t, smsSeverity)
+		}()
 	}
 
-	return c, nil
-}
+	// open and resolve incidents of alerts
+	if incidentService != "" {
+		m, err := newIncidentManager(incidentService)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create incident manager: %v\n", err)
+			os.Exit(1)
+		}
 
-// frame ise used to send video frames and program configuration to upstream goroutines
-type frame struct {
-	// img is image frame
-	img *gocv.Mat
-}
+		incidentChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, incidentChan)
+		// start incident goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- incidentRunner(doneChan, incidentChan, m, incidentSeverity)
+		}()
+	}
 
-func main() {
-	// parse cli flags
-	if err := parseCliFlags(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing command line parameters: %v\n", err)
-		os.Exit(1)
+	// push alert notifications to phones
+	if pushService != "" {
+		pu, err := newPusher(pushService)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create pusher: %v\n", err)
+			os.Exit(1)
+		}
+
+		pushChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, pushChan)
+		// start push notification goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- pushRunner(doneChan, pushChan, pu)
+		}()
 	}
 
-	// read in Face detection model and set its inference backend and target
-	faceNet, err := NewInferModel(faceModel, faceConfig, backend, target)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating Face detection model: %v\n", err)
-		os.Exit(1)
+	// post alerts and operator status to webhook endpoints
+	if webhookURLs != "" {
+		headers, _ := parseHeaders(webhookHeaders)
+		w := NewWebhook(parseLabels(webhookURLs), headers, webhookRetries)
+
+		webhookChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, webhookChan)
+		var statusChan chan *Result
+		if webhookStatus {
+			statusChan = make(chan *Result, 1)
+			pubChans = append(pubChans, statusChan)
+		}
+		// start webhook goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- webhookRunner(doneChan, webhookChan, statusChan, w, rate)
+		}()
 	}
 
-	// read in Sentiment detection model and set its inference backend and target
-	sentNet, err := NewInferModel(sentModel, sentConfig, backend, target)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating Sentiment detection model: %v\n", err)
-		os.Exit(1)
+	// drive machine interlock GPIO pin
+	if gpioPin >= 0 {
+		g, err := NewGPIO(gpioPin, gpioActiveLow)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open GPIO pin %d: %v\n", gpioPin, err)
+			os.Exit(1)
+		}
+
+		gpioLatch := NewAlertLatch()
+		latches = append(latches, gpioLatch)
+		// start GPIO interlock goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- gpioRunner(doneChan, gpioLatch, g, parseLabels(gpioAlerts))
+		}()
 	}
 
-	// read in Pose detection model and set its inference backend and target
-	poseNet, err := NewInferModel(poseModel, poseConfig, backend, target)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating Pose detection model: %v\n", err)
-		os.Exit(1)
+	// pause the machine over Modbus
+	if modbusAddr != "" {
+		modbusLatch := NewAlertLatch()
+		latches = append(latches, modbusLatch)
+		// start Modbus goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- modbusRunner(doneChan, modbusLatch, NewModbusClient(modbusAddr, byte(modbusUnit)), modbusSeverity)
+		}()
 	}
 
-	// create new video capture
-	vc, err := NewCapture(input, deviceID, &delay)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating new video capture: %v\n", err)
-		os.Exit(1)
+	// write alerts and operator status into OPC UA server
+	if opcuaEndpoint != "" {
+		c, err := newOPCUAClient(opcuaEndpoint)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create OPC UA client: %v\n", err)
+			os.Exit(1)
+		}
+
+		opcuaLatch := NewAlertLatch()
+		latches = append(latches, opcuaLatch)
+		opcuaStatusChan := make(chan *Result, 1)
+		pubChans = append(pubChans, opcuaStatusChan)
+		if opcuaRunState != "" {
+			runChan = make(chan bool, 1)
+		}
+		// start OPC UA goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- opcuaRunner(doneChan, opcuaLatch, opcuaStatusChan, runChan, c, opcuaNodeIDs, opcuaRunState)
+		}()
 	}
-	defer vc.Close()
 
-	// frames channel provides the source of images to process
-	framesChan := make(chan *frame, 1)
-	// errChan is a channel used to capture program errors
-	errChan := make(chan error, 2)
-	// doneChan is used to signal goroutines they need to stop
-	doneChan := make(chan struct{})
-	// resultsChan is used for detection distribution
-	resultsChan := make(chan *Result, 1)
-	// sigChan is used as a handler to stop all the goroutines
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, os.Kill, syscall.SIGTERM)
-	// pubChan is used for publishing data analytics stats
-	var pubChan chan *Result
-	// waitgroup to synchronise all goroutines
-	var wg sync.WaitGroup
+	// serve operator status, alerts and snapshots over HTTP
+	var api *APIServer
+	if apiAddr != "" {
+		api = NewAPIServer(apiAddr, streamFPS)
+
+		apiAlertsChan := make(chan Alert, alertQueue)
+		alertsChans = append(alertsChans, apiAlertsChan)
+		apiStatusChan := make(chan *Result, 1)
+		pubChans = append(pubChans, apiStatusChan)
+		// start REST API goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- apiRunner(doneChan, apiStatusChan, apiAlertsChan, api)
+		}()
+	}
 
-	if publish {
-		p, err := NewMQTTPublisher()
+	// stream operator status and inference performance to gRPC clients
+	if grpcAddr != "" {
+		g, err := newGRPCServer(grpcAddr)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create MQTT publisher: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Failed to create gRPC server: %v\n", err)
 			os.Exit(1)
 		}
-		pubChan = make(chan *Result, 1)
-		// start MQTT worker goroutine
+
+		grpcChan := make(chan *Result, 1)
+		pubChans = append(pubChans, grpcChan)
+		// start gRPC goroutine
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			errChan <- messageRunner(doneChan, pubChan, p, topic, rate)
+			errChan <- grpcRunner(doneChan, grpcChan, g)
+		}()
+	}
+
+	// suspend monitoring outside of shifts
+	if len(shiftSchedule) > 0 {
+		// schedule events are published along with the other data
+		var sp *MQTTClient
+		if publish {
+			sp = p
+		}
+		// start shift schedule goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- scheduleRunner(doneChan, cmdChan, sp, shiftSchedule)
+		}()
+	}
+
+	// start operator thresholds reloading goroutine
+	if thresholdsSource != "" && thresholdsInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- thresholdsRunner(doneChan, thresholdsSource, thresholdsInterval)
+		}()
+	}
+
+	// start model reloading goroutine
+	if control || watchModels > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- reloadRunner(doneChan, triggerChan, reloadChan, watchModels)
 		}()
-		defer p.Disconnect(100)
 	}
 
 	// start frameRunner goroutine
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		errChan <- frameRunner(framesChan, doneChan, resultsChan, pubChan, faceNet, sentNet, poseNet)
+		errChan <- frameRunner(framesChan, doneChan, resultsChan, pubChans, nets, reloadChan, cmdChan, alertsChans, latches, runChan, history)
 	}()
 
-	// open display window
-	window := gocv.NewWindow(name)
-	window.SetWindowProperty(gocv.WindowPropertyFullscreen, gocv.WindowAutosize)
-	defer window.Close()
+	// open display window unless running headless
+	var window *gocv.Window
+	if !headless {
+		window = openWindow(name)
+		defer window.Close()
+	}
 
 	// prepare input image matrix
 	img := gocv.NewMat()
 	defer img.Close()
 
+	// prepare depth image matrix
+	depthImg := gocv.NewMat()
+	defer depthImg.Close()
+
 	// initialize the result pointers
 	result := new(Result)
 
+	// replay mode measures time from the start of the input video
+	start := time.Now()
+	fps := vc.Get(gocv.VideoCaptureFPS)
+	// reset is set when the input source changes
+	var reset bool
+	// counter measures the frame rates and latency shown by the frame counter
+	counter := newFrameCounter()
+	var counterLogged time.Time
+
 monitor:
 	for {
 		if ok := vc.Read(&img); !ok {
@@ -655,7 +3635,33 @@ monitor:
 			continue
 		}
 
-		framesChan <- &frame{img: &img}
+		// record the raw frame before anything is drawn into it
+		if rec != nil {
+			if err := rec.Write(img); err != nil {
+				fmt.Printf("Error recording video frame: %v\n", err)
+			}
+		}
+
+		now := time.Now()
+		counter.Captured(now)
+		f := &frame{img: &img, ts: now, captured: now, reset: reset}
+		reset = false
+		if replay {
+			f.ts = frameTimestamp(vc, start, fps)
+		}
+		if dc != nil {
+			if ok := dc.Read(&depthImg); ok && !depthImg.Empty() {
+				f.depth, f.depthScale = &depthImg, dc.scale
+			}
+		}
+		// alert clips get the raw frame too
+		if clipRec != nil {
+			if err := clipRec.Write(img, f.ts); err != nil {
+				fmt.Printf("Error recording alert clip: %v\n", err)
+			}
+		}
+
+		framesChan <- f
 
 		select {
 		case sig := <-sigChan:
@@ -665,31 +3671,141 @@ monitor:
 			fmt.Printf("Shutting down. Encountered error: %s\n", err)
 			break monitor
 		case result = <-resultsChan:
+		case src := <-sourceChan:
+			if replay && src.input == "" {
+				fmt.Printf("Error switching video input to %s: replay mode requires input video file\n", src)
+				break
+			}
+			// open the new source first so we keep running if it fails
+			nvc, err := NewCapture(src.input, src.deviceID, captureAPI, hwDecode, &delay)
+			if err != nil {
+				fmt.Printf("Error switching video input to %s: %v\n", src, err)
+				break
+			}
+			fmt.Printf("Switching video input to %s\n", src)
+			vc.Close()
+			vc = nvc
+			input, deviceID = src.input, src.deviceID
+			start, fps, reset = time.Now(), vc.Get(gocv.VideoCaptureFPS), true
 		default:
 			// do nothing; just display latest results
 		}
+		// crop the primary operator face before anything is drawn over it
+		var panel *facePanel
+		if facePanelCorner != "" {
+			panel = newFacePanel(img, result.status)
+		}
+		// detected faces with their sentiments and head pose
+		drawFaces(&img, result.status)
+		drawSentiments(&img, result.status)
+		if overlayPose {
+			drawPose(&img, result.status)
+		}
+		// flashing border or banner of the most severe alert below the texts
+		drawAlertBanner(&img, result, time.Now(), alertFlash)
 		// inference performance and print it
-		gocv.PutText(&img, fmt.Sprintf("%s", result.Perf), image.Point{0, 15},
-			gocv.FontHersheySimplex, 0.5, color.RGBA{0, 0, 0, 0}, 2)
+		putLine(&img, fmt.Sprintf("%s", result.Perf), 0, overlayTextColor)
 		// inference results label
-		gocv.PutText(&img, fmt.Sprintf("%s", result), image.Point{0, 40},
-			gocv.FontHersheySimplex, 0.5, color.RGBA{0, 0, 0, 0}, 2)
+		putLine(&img, fmt.Sprintf("%s", result), 1, overlayTextColor)
+		// frame rates and latency
+		counter.Displayed(result, time.Now())
+		if fpsCounter {
+			putLine(&img, counter.String(), 2, overlayTextColor)
+		}
+		if fpsLog > 0 && time.Since(counterLogged) >= fpsLog {
+			counterLogged = time.Now()
+			fmt.Printf("%s\n", counter)
+		}
 		// display alert message when operator is not watching machine
 		if result.AlertWatching {
-			gocv.PutText(&img, alertWatching, image.Point{0, 80},
-				gocv.FontHersheySimplex, 0.5, color.RGBA{255, 0, 0, 0}, 2)
+			putLine(&img, msg(alertWatching), 3, alertColor(result, "watching"))
 		}
 		// display alert message when operator is operating machine angrily
 		if result.AlertAngry {
-			gocv.PutText(&img, alertAngry, image.Point{0, 100},
-				gocv.FontHersheySimplex, 0.5, color.RGBA{255, 0, 0, 0}, 2)
+			putLine(&img, msg(alertAngry), 4, alertColor(result, "angry"))
+		}
+		// display alert message when operator is too close to the machine
+		if result.AlertDistance {
+			putLine(&img, msg(alertDistance), 5, alertColor(result, "distance"))
+		}
+		// display alert message when operator is drowsy
+		if result.AlertDrowsy {
+			putLine(&img, msg(alertDrowsy), 6, alertColor(result, "drowsy"))
+		}
+		// display alert message when operator does not wear protective equipment
+		if result.AlertPPE {
+			putLine(&img, msg(alertPPE), 7, alertColor(result, "ppe"))
+		}
+		// display alert message when operator is distracted by phone
+		if result.AlertPhone {
+			putLine(&img, msg(alertPhone), 8, alertColor(result, "phone"))
+		}
+		// display alert message when operator is absent
+		if result.AlertAbsent {
+			putLine(&img, msg(alertAbsent), 9, alertColor(result, "absent"))
+		}
+		// display alert message when nobody is at the machine
+		if result.AlertUnattended {
+			putLine(&img, msg(alertUnattended), 10, alertColor(result, "unattended"))
+		}
+		// display alert message when too many operators are at the machine
+		if result.AlertCrowd {
+			putLine(&img, msg(alertCrowd), 11, alertColor(result, "crowd"))
+		}
+		// display alert message when operator risk score is high
+		if result.AlertRisk {
+			putLine(&img, msg(alertRisk), 12, alertColor(result, "risk"))
+		}
+		// display alert message when camera is obstructed or tampered with
+		if result.AlertTamper {
+			putLine(&img, msg(alertTamper), 13, alertColor(result, "tamper"))
+		}
+		// display alert message when operator sentiment triggers emotion policy
+		if result.AlertEmotion {
+			putLine(&img, msg(alertEmotion), 14, alertColor(result, "emotion"))
+		}
+		// display alert messages of custom alert rules
+		for i, raised := range result.ruleAlerts() {
+			if raised {
+				putLine(&img, alertRules[i].Message, 15+i, alertColor(result, alertRules[i].Name))
+			}
+		}
+		// enlarged primary operator face on top of everything else
+		if panel != nil {
+			panel.Draw(&img, facePanelCorner, facePanelSize)
+			panel.Close()
+		}
+		// serve pending API snapshot request and the video stream with the annotated frame
+		if api != nil {
+			api.Snapshot(img)
+			api.Stream(img)
+		}
+		// pace the video playback as the display window does
+		if headless {
+			time.Sleep(time.Duration(delay * float64(time.Millisecond)))
+			continue
 		}
 		// show the image in the window, and wait 1 millisecond
 		window.IMShow(img)
 
-		// exit when ESC key is pressed
-		if window.WaitKey(int(delay)) == 27 {
+		// exit when ESC key is pressed; acknowledge the alerts when A key is pressed; snooze them when S key is pressed;
+		// toggle fullscreen when F key is pressed
+		switch window.WaitKey(int(delay)) {
+		case 27:
 			break monitor
+		case 'a':
+			select {
+			case cmdChan <- &command{name: cmdAck}:
+			default:
+			}
+		case 's':
+			select {
+			case cmdChan <- &command{name: cmdSnooze, duration: snoozeDuration}:
+			default:
+			}
+		case 'f':
+			fullscreen = !fullscreen
+			setFullscreen(window, fullscreen)
 		}
 	}
 	// signal all goroutines to finish
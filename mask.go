@@ -0,0 +1,72 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// maskUnknown policy marks sentiment of masked faces as unknown
+	maskUnknown = "unknown"
+	// maskSkip policy skips sentiment of masked faces so the operator keeps their previous sentiment
+	maskSkip = "skip"
+)
+
+// maskPolicies are supported masked face sentiment policies
+var maskPolicies = map[string]bool{
+	maskUnknown: true,
+	maskSkip:    true,
+}
+
+// detectMasks detects whether the faces in crops wear face masks and returns the result for every crop.
+// The net is expected to classify 224x224 face crops and output either the mask probability
+// or the no mask and mask class probabilities.
+func detectMasks(net Model, crops []gocv.Mat) []bool {
+	blob := gocv.NewMat()
+	defer blob.Close()
+	gocv.BlobFromImages(crops, &blob, 1.0, image.Pt(224, 224),
+		gocv.NewScalar(0, 0, 0, 0), false, false, gocv.MatTypeCV32F)
+
+	// run a forward pass through mask detection network
+	net.SetInput(blob, "")
+	out := net.Forward("")
+	defer out.Close()
+
+	// flatten the result from [N, C, 1, 1] to [N, C]
+	res := out.Reshape(1, len(crops))
+	defer res.Close()
+
+	// the mask probability is in the last column
+	col := res.Cols() - 1
+
+	masks := make([]bool, len(crops))
+	for i := range crops {
+		masks[i] = float64(res.GetFloatAt(i, col)) > maskConfidence
+	}
+
+	return masks
+}
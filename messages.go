@@ -0,0 +1,135 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultMessages is built-in English message catalog
+var defaultMessages = map[string]string{
+	alertWatching:         "Operator not watching: PAUSE THE MACHINE!",
+	alertAngry:            "Operator angry: PAUSE THE MACHINE!",
+	alertDistance:         "Operator too close: PAUSE THE MACHINE!",
+	alertDrowsy:           "Operator drowsy: PAUSE THE MACHINE!",
+	alertPPE:              "Operator missing protective equipment: PAUSE THE MACHINE!",
+	alertAbsent:           "Operator absent: PAUSE THE MACHINE!",
+	alertPhone:            "Operator distracted by phone: PAUSE THE MACHINE!",
+	alertUnattended:       "Machine unattended: PAUSE THE MACHINE!",
+	alertCrowd:            "Multiple operators at the machine: PAUSE THE MACHINE!",
+	alertRisk:             "Operator fatigue risk high: PAUSE THE MACHINE!",
+	alertTamper:           "Camera obstructed or tampered with: CHECK THE CAMERA!",
+	alertEmotion:          "Operator emotional state: CHECK ON THE OPERATOR!",
+	labelWatching:         "Watching",
+	labelAngry:            "Angry",
+	labelOperator:         "Operator",
+	labelDistance:         "Distance",
+	labelDegradation:      "Degradation",
+	labelPaused:           "Paused",
+	labelRisk:             "Risk",
+	labelSuspended:        "Outside of shift",
+	labelFaceTime:         "Face inference time",
+	labelSentTime:         "Sentiment inference time",
+	labelPoseTime:         "Pose inference time",
+	labelCaptureFPS:       "Capture",
+	labelProcessedFPS:     "Processed",
+	labelLatency:          "Latency",
+	"sentiment.neutral":   "Neutral",
+	"sentiment.happy":     "Happy",
+	"sentiment.sad":       "Sad",
+	"sentiment.surprised": "Surprised",
+	"sentiment.angry":     "Angry",
+}
+
+// messages is message catalog of the selected locale
+var messages = defaultMessages
+
+// msg returns text of message key from the selected catalog.
+// Messages missing in the catalog fall back to the built-in English ones.
+func msg(key string) string {
+	if text, ok := messages[key]; ok {
+		return text
+	}
+
+	return defaultMessages[key]
+}
+
+// catalogPath returns path to message catalog of locale stored in dir.
+// Locales such as de_DE.UTF-8 fall back to de catalog if there is no catalog for the region.
+func catalogPath(dir, locale string) (string, error) {
+	locale = strings.SplitN(locale, ".", 2)[0]
+	candidates := []string{locale}
+	if i := strings.IndexAny(locale, "_-"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+
+	for _, c := range candidates {
+		path := filepath.Join(dir, c+".txt")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("no message catalog for locale %s in %s", locale, dir)
+}
+
+// loadMessages reads message catalog from file at path and returns it.
+// The file lists one key=text message per line; empty lines and lines starting with # are ignored.
+func loadMessages(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	catalog := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid message: %s", line)
+		}
+
+		key := strings.TrimSpace(kv[0])
+		if _, ok := defaultMessages[key]; !ok {
+			return nil, fmt.Errorf("unknown message key: %s", key)
+		}
+		catalog[key] = strings.TrimSpace(kv[1])
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return catalog, nil
+}
@@ -0,0 +1,222 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	// modbusWriteCoil is Modbus write single coil function code
+	modbusWriteCoil = 0x05
+	// modbusWriteRegister is Modbus write single register function code
+	modbusWriteRegister = 0x06
+	// modbusTimeout is Modbus request timeout
+	modbusTimeout = 3 * time.Second
+)
+
+// ModbusClient writes coils and holding registers of Modbus TCP server such as PLC
+type ModbusClient struct {
+	// addr is Modbus TCP server address
+	addr string
+	// unit is Modbus unit identifier
+	unit byte
+	// conn is connection to the server; nil when disconnected
+	conn net.Conn
+	// tid is transaction identifier of the next request
+	tid uint16
+}
+
+// NewModbusClient creates new Modbus TCP client of unit at addr and returns it.
+// The client connects to the server on the first request and reconnects after failures.
+func NewModbusClient(addr string, unit byte) *ModbusClient {
+	return &ModbusClient{
+		addr: addr,
+		unit: unit,
+	}
+}
+
+// WriteCoil sets coil at address addr on or off
+func (c *ModbusClient) WriteCoil(addr uint16, on bool) error {
+	var value uint16
+	if on {
+		value = 0xFF00
+	}
+
+	return c.write(modbusWriteCoil, addr, value)
+}
+
+// WriteRegister writes value into holding register at address addr
+func (c *ModbusClient) WriteRegister(addr, value uint16) error {
+	return c.write(modbusWriteRegister, addr, value)
+}
+
+// write sends single write request with function code fc and waits for the response.
+// It drops the connection if the request fails.
+func (c *ModbusClient) write(fc byte, addr, value uint16) error {
+	if c.conn == nil {
+		conn, err := net.DialTimeout("tcp", c.addr, modbusTimeout)
+		if err != nil {
+			return err
+		}
+		c.conn = conn
+	}
+
+	err := c.request(fc, addr, value)
+	if _, ok := err.(modbusException); !ok && err != nil {
+		c.Close()
+	}
+
+	return err
+}
+
+// request sends single write request with function code fc over the current connection and checks the response
+func (c *ModbusClient) request(fc byte, addr, value uint16) error {
+	c.tid++
+	req := make([]byte, 12)
+	// MBAP header: transaction ID, protocol ID, length of the rest of the message, unit ID
+	binary.BigEndian.PutUint16(req[0:], c.tid)
+	binary.BigEndian.PutUint16(req[4:], 6)
+	req[6] = c.unit
+	// PDU: function code, address, value
+	req[7] = fc
+	binary.BigEndian.PutUint16(req[8:], addr)
+	binary.BigEndian.PutUint16(req[10:], value)
+
+	if err := c.conn.SetDeadline(time.Now().Add(modbusTimeout)); err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(req); err != nil {
+		return err
+	}
+
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint16(header[4:])
+	if n < 2 || n > 254 {
+		return fmt.Errorf("invalid Modbus response length: %d", n)
+	}
+	pdu := make([]byte, n-1)
+	if _, err := io.ReadFull(c.conn, pdu); err != nil {
+		return err
+	}
+
+	if tid := binary.BigEndian.Uint16(header); tid != c.tid {
+		return fmt.Errorf("unexpected Modbus transaction ID: %d", tid)
+	}
+	if pdu[0] == fc|0x80 {
+		return modbusException(pdu[1])
+	}
+	if pdu[0] != fc {
+		return fmt.Errorf("unexpected Modbus function code: %d", pdu[0])
+	}
+
+	return nil
+}
+
+// Close closes connection to the server
+func (c *ModbusClient) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// modbusException is exception code returned by Modbus server
+type modbusException byte
+
+// Error implements error interface for modbusException
+func (e modbusException) Error() string {
+	return fmt.Sprintf("Modbus exception %d", byte(e))
+}
+
+// modbusRunner sets Modbus coil or writes register value while any alert of at least minimum severity
+// received from latch is active and clears them when the alerts clear.
+// Failed writes are retried every second.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func modbusRunner(doneChan <-chan struct{}, latch *AlertLatch, c *ModbusClient, severity string) error {
+	defer c.Close()
+
+	// pause writes the pause state into the coil or the register
+	pause := func(on bool) error {
+		if modbusCoil >= 0 {
+			if err := c.WriteCoil(uint16(modbusCoil), on); err != nil {
+				return err
+			}
+		}
+		if modbusRegister >= 0 {
+			var value uint16
+			if on {
+				value = uint16(modbusValue)
+			}
+			if err := c.WriteRegister(uint16(modbusRegister), value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	active := make(activeAlerts)
+	// paused is the state written into the server; written is false if the last write failed
+	paused, written := false, false
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-latch.C:
+			latch.Apply(active)
+			if on := active.atLeast(severity); on != paused || !written {
+				paused = on
+				written = false
+			}
+		case <-ticker.C:
+		case <-doneChan:
+			fmt.Printf("Stopping modbusRunner: received stop signal\n")
+			if paused {
+				if err := pause(false); err != nil {
+					fmt.Printf("Error clearing machine pause over Modbus: %v\n", err)
+				}
+			}
+			return nil
+		}
+
+		if !written {
+			if err := pause(paused); err != nil {
+				fmt.Printf("Error writing machine pause over Modbus: %v\n", err)
+				continue
+			}
+			written = true
+		}
+	}
+}
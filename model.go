@@ -0,0 +1,122 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// Model is an inference model which the detectors run their forward passes through.
+// It is implemented by gocv.Net so any model format OpenCV can import is supported out of the box.
+type Model interface {
+	// SetInput sets the input blob of the layer with the given name; empty name sets the default input
+	SetInput(blob gocv.Mat, name string)
+	// Forward runs forward pass and returns the output of the layer with the given name; empty name returns the default output
+	Forward(outputName string) gocv.Mat
+	// ForwardLayers runs forward pass and returns the outputs of the layers with the given names
+	ForwardLayers(outBlobNames []string) []gocv.Mat
+	// GetPerfProfile returns time spent in the last forward pass in ticks
+	GetPerfProfile() float64
+	// Empty returns true if the model contains no layers
+	Empty() bool
+	// Close releases the model
+	Close() error
+}
+
+// fixedSizer is implemented by models which may only take inputs of the size they were built for
+type fixedSizer interface {
+	// FixedSize returns true if the model input size is fixed
+	FixedSize() bool
+}
+
+// failer is implemented by models which report failed forward passes; OpenCV networks don't report them
+type failer interface {
+	// Err returns error of the first forward pass which failed since Err was last called and clears it
+	Err() error
+}
+
+// isONNX returns true if model is an ONNX model file
+func isONNX(model string) bool {
+	return strings.ToLower(filepath.Ext(model)) == ".onnx"
+}
+
+// isTFLite returns true if model is a TensorFlow Lite model file
+func isTFLite(model string) bool {
+	return strings.ToLower(filepath.Ext(model)) == ".tflite"
+}
+
+// needsConfig returns true if model file requires separate configuration file
+func needsConfig(model string) bool {
+	return !isONNX(model) && !isTFLite(model)
+}
+
+// NewModel creates new inference model from model file and its configuration and returns it.
+// ONNX models are read using OpenCV ONNX importer and need no configuration file;
+// TensorFlow Lite models run on TensorFlow Lite interpreter and ignore backend and target;
+// any other model is read using OpenCV generic importer which includes OpenVINO IR models.
+// Models on GPU targets run on GPU with index gpu unless it is negative.
+// It returns error if either the model files failed to be read or setting the target or GPU fails
+func NewModel(model, config string, backend, target, gpu int) (Model, error) {
+	if isTFLite(model) {
+		return newTFLiteModel(model)
+	}
+
+	if isGPUTarget(target) && gpu >= 0 {
+		if err := selectGPU(gpu, backend); err != nil {
+			return nil, err
+		}
+	}
+
+	if isONNX(model) {
+		m := gocv.ReadNetFromONNX(model)
+		if err := m.SetPreferableBackend(gocv.NetBackendType(backend)); err != nil {
+			return nil, err
+		}
+		if err := m.SetPreferableTarget(gocv.NetTargetType(target)); err != nil {
+			return nil, err
+		}
+
+		return &m, nil
+	}
+
+	m, err := NewInferModel(model, config, backend, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// checkModelFiles returns true if model and its configuration are either both provided, both omitted
+// or if the model is provided and it doesn't need configuration file
+func checkModelFiles(model, config string) bool {
+	if model == "" {
+		return config == ""
+	}
+
+	return config != "" || !needsConfig(model)
+}
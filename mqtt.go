@@ -26,12 +26,17 @@ package main
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	MQTT "github.com/eclipse/paho.mqtt.golang"
+	"github.com/intel-iot-devkit/machine-operator-monitor-go/payload"
 )
 
 const (
@@ -39,60 +44,184 @@ const (
 	TIMEOUT = 1 * time.Second
 	// QOS is Quality Of Service
 	QOS = 1
+	// reconnectMaxInterval is maximum interval between reconnection attempts; the interval doubles up to it
+	reconnectMaxInterval = time.Minute
 )
 
-// MQTTClient is MQTT client
+// topicOptions are options messages are published to MQTT topic with
+type topicOptions struct {
+	// qos is Quality Of Service
+	qos byte
+	// retained means the broker retains the latest message for subscribers which connect later
+	retained bool
+}
+
+// MQTT topics; they are set by setTopics
+var (
+	// resultsTopic is MQTT topic for operator status
+	resultsTopic string
+	// alertsTopic is MQTT topic for alert state transitions
+	alertsTopic string
+	// heartbeatTopic is MQTT topic for heartbeats of persisting alerts
+	heartbeatTopic string
+	// perfTopic is MQTT topic for inference engine performance
+	perfTopic string
+	// ackTopic is MQTT topic for alert acknowledgments
+	ackTopic string
+	// inputTopic is MQTT topic used to switch the active video input
+	inputTopic string
+	// cmdTopic is MQTT topic used to send commands to the monitor
+	cmdTopic string
+	// statusTopic is MQTT topic for status snapshots requested by status command
+	statusTopic string
+	// reloadTopic is MQTT topic used to reload the models
+	reloadTopic string
+	// ppeTopic is MQTT topic for personal protective equipment alerts
+	ppeTopic string
+	// scheduleTopic is MQTT topic for monitoring suspended and resumed events at shift boundaries
+	scheduleTopic string
+	// connectionTopic is MQTT topic for connection state of the monitor
+	connectionTopic string
+	// detectionsTopic is MQTT topic for detailed detection payloads of processed frames
+	detectionsTopic string
+)
+
+// setTopics sets MQTT topics to sub-topics of prefix.
+// Operator status, alerts, alert heartbeats and performance are published to the given sub-topics;
+// empty sub-topic publishes to prefix itself.
+func setTopics(prefix, results, alerts, heartbeat, perf string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	sub := func(s string) string {
+		if s = strings.Trim(s, "/"); s == "" {
+			return prefix
+		}
+		return prefix + "/" + s
+	}
+
+	resultsTopic, alertsTopic, heartbeatTopic, perfTopic = sub(results), sub(alerts), sub(heartbeat), sub(perf)
+	ackTopic = sub("ack")
+	inputTopic = sub("input")
+	cmdTopic = sub("cmd")
+	statusTopic = sub("status")
+	reloadTopic = sub("reload")
+	ppeTopic = sub("ppe")
+	scheduleTopic = sub("schedule")
+	connectionTopic = sub("connection")
+	detectionsTopic = sub("detections")
+}
+
+var (
+	// mqttQoS is Quality Of Service of topics without their own options
+	mqttQoS byte = QOS
+	// mqttTopics stores publishing options of MQTT topics by topic names
+	mqttTopics = map[string]topicOptions{}
+)
+
+// parseTopicOptions parses comma separated publishing options of MQTT topics in topic=qos[:retain] format,
+// e.g. machine/safety=1:retain,machine/safety/alerts=2 and returns them by topic names
+func parseTopicOptions(s string) (map[string]topicOptions, error) {
+	topics := make(map[string]topicOptions)
+	for _, e := range parseLabels(s) {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("Invalid MQTT topic options: %s", e)
+		}
+
+		parts := strings.Split(kv[1], ":")
+		qos, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || qos < 0 || qos > 2 {
+			return nil, fmt.Errorf("Invalid MQTT QoS: %s", parts[0])
+		}
+
+		opts := topicOptions{qos: byte(qos)}
+		switch {
+		case len(parts) == 2 && strings.TrimSpace(parts[1]) == "retain":
+			opts.retained = true
+		case len(parts) > 1:
+			return nil, fmt.Errorf("Invalid MQTT topic options: %s", e)
+		}
+
+		topics[strings.TrimSpace(kv[0])] = opts
+	}
+
+	return topics, nil
+}
+
+// publishOptions returns options messages are published to topic with
+func publishOptions(topic string) topicOptions {
+	if opts, ok := mqttTopics[topic]; ok {
+		return opts
+	}
+
+	return topicOptions{qos: mqttQoS}
+}
+
+// MQTTClient is MQTT client which reconnects to the broker when the connection is lost
 type MQTTClient struct {
 	// MQTT.Client implements MQTT client
 	client MQTT.Client
+	// mu guards connected and subscriptions
+	mu sync.Mutex
+	// connected means the client is connected to the broker
+	connected bool
+	// subscriptions stores message handlers of subscribed topics which are resubscribed on reconnection
+	subscriptions map[string]MQTT.MessageHandler
+	// buffer stores messages published while the broker is unreachable; nil if they are dropped
+	buffer *OfflineBuffer
 }
 
-// MQTTNewTLSConfig creates MQTT TLS configuration and returns it
+// MQTTNewTLSConfig creates MQTT TLS configuration and returns it.
+// The server certificate is verified against CA certificates in caPath or, if caPath is empty, the system ones.
+// The client certificate and key in crtPath and keyPath are presented to the server unless they are empty.
 // It returns error if it can't read TLS certificate files in provided paths.
-func MQTTNewTLSConfig(crtPath, keyPath string, skipVerify bool) (*tls.Config, error) {
-	// Import trusted certificates from CAfile.pem.
-	// Alternatively, manually add CA certificates to
-	// default openssl CA bundle.
-	certpool := x509.NewCertPool()
-	pemCerts, err := ioutil.ReadFile("samplecerts/CAfile.pem")
-	if err == nil {
-		certpool.AppendCertsFromPEM(pemCerts)
-	}
-
-	// Import client certificate/key pair
-	cert, err := tls.LoadX509KeyPair(crtPath, keyPath)
-	if err != nil {
-		return nil, err
-	}
-
-	// Create tls.Config with desired tls properties
-	return &tls.Config{
-		// RootCAs = certs used to verify server cert.
-		RootCAs: certpool,
-		// ClientAuth = whether to request cert from server.
-		// Since the server is set up for SSL, this happens
-		// anyways.
-		ClientAuth: tls.NoClientCert,
-		// ClientCAs = certs used to validate client cert.
-		ClientCAs: nil,
+func MQTTNewTLSConfig(caPath, crtPath, keyPath string, skipVerify bool) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
 		// InsecureSkipVerify = verify that cert contents
 		// match server. IP matches what is in cert etc.
 		InsecureSkipVerify: skipVerify,
+	}
+
+	// Import trusted certificates from CA file; nil RootCAs use the system ones
+	if caPath != "" {
+		pemCerts, err := ioutil.ReadFile(caPath)
+		if err != nil {
+			return nil, err
+		}
+		certpool := x509.NewCertPool()
+		if !certpool.AppendCertsFromPEM(pemCerts) {
+			return nil, fmt.Errorf("No CA certificates found in %s", caPath)
+		}
+		config.RootCAs = certpool
+	}
+
+	// Import client certificate/key pair
+	if crtPath != "" || keyPath != "" {
+		if crtPath == "" || keyPath == "" {
+			return nil, fmt.Errorf("Client certificate requires both certificate and key")
+		}
+		cert, err := tls.LoadX509KeyPair(crtPath, keyPath)
+		if err != nil {
+			return nil, err
+		}
 		// Certificates = list of certs client sends to server.
-		Certificates: []tls.Certificate{cert},
-	}, nil
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
 }
 
 // MQTTClientOptions creates new MQTT client options and returns it
 // It reads the following environment variables to populate the options:
-// MQTT_SERVER: URI address of MQTT server; required parameter
+// MQTT_SERVER: URI address of MQTT server, e.g. tcp://localhost:1883 or ssl://localhost:8883; required parameter
 // MQTT_CLIENT_ID: MQTT client ID; required parameter
 // MQTT_USERNAME: MQTT username; not required
 // MQTT_PASSWORD: MQTT password for MQTT_USERNAME; not required
-// MQTT_CERT: SSL certificate; not required
-// MQTT_CERT_KEY: SSL certificate private key; not required
-// MQTT_CA_ROOT: SSL CA root certificate; not required
-// MQTT_TLS_SKIP_VERIFY: SSL TLS verification; not required
+// MQTT_CERT: SSL client certificate; not required
+// MQTT_CERT_KEY: SSL client certificate private key; not required
+// MQTT_CA_ROOT: SSL CA root certificate; not required, the system CA certificates are used by default
+// MQTT_TLS_SKIP_VERIFY: skip SSL server certificate verification if true; not required
+// TLS is enabled if the server URI scheme is ssl or tls or if any of the SSL variables is set.
 // It returns error if either MQTT server was not specified or if
 // the MQTT client ID is missing in the client configuration options.
 func MQTTClientOptions() (*MQTT.ClientOptions, error) {
@@ -121,19 +250,26 @@ func MQTTClientOptions() (*MQTT.ClientOptions, error) {
 	opts.CleanSession = true
 	opts.SetPingTimeout(1 * time.Second)
 	opts.SetDefaultPublishHandler(msgHandler)
+	// the broker announces the monitor went offline if it disconnects unexpectedly
+	opts.SetWill(connectionTopic, connectionMessage("offline"), QOS, true)
 
-	if username != "" && password != "" {
+	if username != "" {
 		opts.SetUsername(username)
 		opts.SetPassword(password)
 	}
 
 	var skipVerify bool
 	if tlsSkipVerify != "" {
-		skipVerify = true
+		v, err := strconv.ParseBool(tlsSkipVerify)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid MQTT_TLS_SKIP_VERIFY: %s", tlsSkipVerify)
+		}
+		skipVerify = v
 	}
 
-	if tlsCert != "" && tlsKey != "" && tlsCA != "" {
-		tlsConfig, err := MQTTNewTLSConfig(tlsCert, tlsKey, skipVerify)
+	secure := strings.HasPrefix(server, "ssl://") || strings.HasPrefix(server, "tls://")
+	if secure || tlsCert != "" || tlsKey != "" || tlsCA != "" || skipVerify {
+		tlsConfig, err := MQTTNewTLSConfig(tlsCA, tlsCert, tlsKey, skipVerify)
 		if err != nil {
 			return nil, fmt.Errorf("Invalid TLS configuration: %s", err)
 		}
@@ -144,23 +280,108 @@ func MQTTClientOptions() (*MQTT.ClientOptions, error) {
 }
 
 // MQTTConnect attempts to connect to MQTT server and returns MQTT client
+// The client reconnects with backoff when the connection is lost; messages published meanwhile are stored
+// in buffer and delivered once the connection is restored unless buffer is nil.
 // It returns error if it fails to connect to the MQTT server.
-func MQTTConnect(opts *MQTT.ClientOptions) (*MQTTClient, error) {
-	c := MQTT.NewClient(opts)
+func MQTTConnect(opts *MQTT.ClientOptions, buffer *OfflineBuffer) (*MQTTClient, error) {
+	c := &MQTTClient{
+		subscriptions: make(map[string]MQTT.MessageHandler),
+		buffer:        buffer,
+	}
 
-	if token := c.Connect(); token.Wait() && token.Error() != nil {
+	opts.SetAutoReconnect(true)
+	opts.SetMaxReconnectInterval(reconnectMaxInterval)
+	opts.SetOnConnectHandler(c.onConnect)
+	opts.SetConnectionLostHandler(c.onConnectionLost)
+	c.client = MQTT.NewClient(opts)
+
+	if token := c.client.Connect(); token.Wait() && token.Error() != nil {
 		return nil, token.Error()
 	}
+	c.setConnected(true)
 
-	return &MQTTClient{
-		client: c,
-	}, nil
+	return c, nil
 }
 
-// Publish publishes message to topic
-// It returns MQTT connection Token
-func (c *MQTTClient) Publish(topic, message string) (MQTT.Token, error) {
-	token := c.client.Publish(topic, QOS, false, message)
+// setConnected records whether the client is connected to the broker
+func (c *MQTTClient) setConnected(connected bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.connected = connected
+}
+
+// isConnected returns true if the client is connected to the broker
+func (c *MQTTClient) isConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.connected
+}
+
+// connectionMessage returns MQTT message announcing the monitor connection status
+func connectionMessage(status string) string {
+	msg, err := json.Marshal(payload.Connection{
+		Schema:    payload.SchemaVersion,
+		MachineID: machineID,
+		CameraID:  cameraID,
+		Status:    status,
+	})
+	if err != nil {
+		return "{}"
+	}
+
+	return string(msg)
+}
+
+// publishConnection publishes retained connection status of the monitor
+func (c *MQTTClient) publishConnection(status string) {
+	token := c.client.Publish(connectionTopic, QOS, true, connectionMessage(status))
+	if ok := token.WaitTimeout(TIMEOUT); ok && token.Error() != nil {
+		fmt.Printf("Error publishing message to %s: %v\n", connectionTopic, token.Error())
+	}
+}
+
+// onConnect announces the monitor is online once the client (re)connects, resubscribes to the subscribed topics
+// and delivers the buffered messages
+func (c *MQTTClient) onConnect(client MQTT.Client) {
+	c.publishConnection("online")
+
+	c.mu.Lock()
+	c.connected = true
+	subscriptions := make(map[string]MQTT.MessageHandler, len(c.subscriptions))
+	for topic, handler := range c.subscriptions {
+		subscriptions[topic] = handler
+	}
+	c.mu.Unlock()
+
+	for topic, handler := range subscriptions {
+		if _, err := c.subscribe(topic, handler); err != nil {
+			fmt.Printf("Error resubscribing to %s: %v\n", topic, err)
+		}
+	}
+
+	if c.buffer != nil && c.buffer.Len() > 0 {
+		fmt.Printf("Delivering %d messages buffered while offline\n", c.buffer.Len())
+		if err := c.buffer.Flush(func(topic, message string) error {
+			_, err := c.publish(topic, message)
+			return err
+		}); err != nil {
+			fmt.Printf("Error delivering buffered messages: %v\n", err)
+		}
+	}
+}
+
+// onConnectionLost records the client lost connection to the broker; the client reconnects automatically
+func (c *MQTTClient) onConnectionLost(client MQTT.Client, err error) {
+	c.setConnected(false)
+	fmt.Printf("MQTT connection lost: %v; reconnecting\n", err)
+}
+
+// publish publishes message to topic with the topic publishing options
+func (c *MQTTClient) publish(topic, message string) (MQTT.Token, error) {
+	opts := publishOptions(topic)
+	token := c.client.Publish(topic, opts.qos, opts.retained, message)
 
 	// wait for publish to finish
 	if ok := token.WaitTimeout(TIMEOUT); ok && token.Error() != nil {
@@ -170,15 +391,50 @@ func (c *MQTTClient) Publish(topic, message string) (MQTT.Token, error) {
 	return token, nil
 }
 
+// Publish publishes message to topic with the topic publishing options
+// While the broker is unreachable, the message is buffered and nil Token is returned.
+// It returns MQTT connection Token
+func (c *MQTTClient) Publish(topic, message string) (MQTT.Token, error) {
+	// buffered messages are delivered first so that the messages keep their order
+	if c.buffer != nil && (!c.isConnected() || c.buffer.Len() > 0) {
+		c.buffer.Add(topic, message)
+		return nil, nil
+	}
+
+	token, err := c.publish(topic, message)
+	if err != nil && c.buffer != nil && !c.isConnected() {
+		c.buffer.Add(topic, message)
+		return nil, nil
+	}
+
+	return token, err
+}
+
+// Send publishes message to topic; it implements Sink
+func (c *MQTTClient) Send(topic, message string) error {
+	_, err := c.Publish(topic, message)
+	return err
+}
+
 // msgHandler for MQTT subscription for any desired control channel topic
 func msgHandler(c MQTT.Client, msg MQTT.Message) {
 	fmt.Printf("MQTT message received. Topic: %s Message: %s", msg.Topic(), msg.Payload())
 }
 
-// Subscribe subscribes to specified topic
+// Subscribe subscribes to specified topic and handles received messages using handler
+// The subscription is renewed whenever the client reconnects.
 // It returns MQTT connection Token
-func (c *MQTTClient) Subscribe(topic string) (MQTT.Token, error) {
-	token := c.client.Subscribe(topic, QOS, msgHandler)
+func (c *MQTTClient) Subscribe(topic string, handler MQTT.MessageHandler) (MQTT.Token, error) {
+	c.mu.Lock()
+	c.subscriptions[topic] = handler
+	c.mu.Unlock()
+
+	return c.subscribe(topic, handler)
+}
+
+// subscribe subscribes to topic and handles received messages using handler
+func (c *MQTTClient) subscribe(topic string, handler MQTT.MessageHandler) (MQTT.Token, error) {
+	token := c.client.Subscribe(topic, QOS, handler)
 
 	// wait for the subscription to finish
 	if ok := token.WaitTimeout(TIMEOUT); ok && token.Error() != nil {
@@ -188,7 +444,11 @@ func (c *MQTTClient) Subscribe(topic string) (MQTT.Token, error) {
 	return token, nil
 }
 
-// Disconnect closes the connection to MQTT broker, waiting for pending ms.
+// Disconnect announces the monitor is going offline and closes the connection to MQTT broker, waiting for pending ms.
+// The broker doesn't publish the Last Will and Testament on clean disconnect so the offline state is published here.
 func (c *MQTTClient) Disconnect(pending uint) {
+	if c.isConnected() {
+		c.publishConnection("offline")
+	}
 	c.client.Disconnect(pending)
 }
@@ -0,0 +1,200 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// bufferedMessage is MQTT message buffered while the broker is unreachable
+type bufferedMessage struct {
+	// Topic is MQTT topic the message is published to
+	Topic string `json:"topic"`
+	// Message is message payload
+	Message string `json:"message"`
+}
+
+// OfflineBuffer stores a bounded number of MQTT messages published while the broker is unreachable.
+// The messages are kept in memory and, if a path is set, in a JSON lines file so they survive restarts.
+type OfflineBuffer struct {
+	// mu guards the messages
+	mu sync.Mutex
+	// size is maximum number of buffered messages; the oldest messages are dropped first
+	size int
+	// path is path to the buffer file; empty if the messages are only kept in memory
+	path string
+	// messages are buffered messages in the order they were published
+	messages []bufferedMessage
+}
+
+// NewOfflineBuffer creates new buffer of size messages persisted in file at path unless it is empty and returns it.
+// Messages left in the file by the previous run are loaded into the buffer.
+func NewOfflineBuffer(size int, path string) (*OfflineBuffer, error) {
+	b := &OfflineBuffer{
+		size: size,
+		path: path,
+	}
+
+	if path == "" {
+		return b, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var m bufferedMessage
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			return nil, fmt.Errorf("Invalid buffered message: %v", err)
+		}
+		b.messages = append(b.messages, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	b.trim()
+
+	return b, nil
+}
+
+// trim drops the oldest messages exceeding buffer size and returns true if any were dropped
+func (b *OfflineBuffer) trim() bool {
+	if len(b.messages) <= b.size {
+		return false
+	}
+
+	fmt.Printf("Offline buffer full: dropping %d oldest messages\n", len(b.messages)-b.size)
+	b.messages = b.messages[len(b.messages)-b.size:]
+
+	return true
+}
+
+// save rewrites the buffer file with the buffered messages
+func (b *OfflineBuffer) save() error {
+	f, err := os.Create(b.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, m := range b.messages {
+		line, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		w.Write(append(line, '\n'))
+	}
+
+	return w.Flush()
+}
+
+// appendFile appends message m to the buffer file
+func (b *OfflineBuffer) appendFile(m bufferedMessage) error {
+	line, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(b.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+
+	return err
+}
+
+// Add buffers message published to topic, dropping the oldest message if the buffer is full
+func (b *OfflineBuffer) Add(topic, message string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m := bufferedMessage{Topic: topic, Message: message}
+	b.messages = append(b.messages, m)
+	if b.path == "" {
+		b.trim()
+		return
+	}
+
+	var err error
+	if b.trim() {
+		err = b.save()
+	} else {
+		err = b.appendFile(m)
+	}
+	if err != nil {
+		fmt.Printf("Error writing offline buffer %s: %v\n", b.path, err)
+	}
+}
+
+// Len returns number of buffered messages
+func (b *OfflineBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.messages)
+}
+
+// Flush publishes buffered messages in the order they were buffered using publish until it fails.
+// The published messages are dropped from the buffer; it returns the error publish failed with.
+func (b *OfflineBuffer) Flush(publish func(topic, message string) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var err error
+	var sent int
+	for _, m := range b.messages {
+		if err = publish(m.Topic, m.Message); err != nil {
+			break
+		}
+		sent++
+	}
+	if sent == 0 {
+		return err
+	}
+
+	b.messages = b.messages[sent:]
+	if b.path != "" {
+		if err := b.save(); err != nil {
+			fmt.Printf("Error writing offline buffer %s: %v\n", b.path, err)
+		}
+	}
+
+	return err
+}
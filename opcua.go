@@ -0,0 +1,155 @@
+//go:build opcua
+// +build opcua
+
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+)
+
+// opcuaTimeout is OPC UA request timeout
+const opcuaTimeout = 5 * time.Second
+
+// opcuaClient is OPC UA client which connects to the server without security using anonymous session
+type opcuaClient struct {
+	// endpoint is OPC UA server endpoint
+	endpoint string
+	// c is connected client; nil when disconnected
+	c *opcua.Client
+}
+
+// newOPCUAClient creates new OPC UA client of server at endpoint and returns it.
+// The client connects to the server on the first request and reconnects after failures.
+func newOPCUAClient(endpoint string) (OPCUAClient, error) {
+	return &opcuaClient{endpoint: endpoint}, nil
+}
+
+// connect connects to the server unless the client is connected already
+func (o *opcuaClient) connect() error {
+	if o.c != nil {
+		return nil
+	}
+
+	c := opcua.NewClient(o.endpoint,
+		opcua.SecurityMode(ua.MessageSecurityModeNone),
+		opcua.AuthAnonymous(),
+		opcua.RequestTimeout(opcuaTimeout))
+	ctx, cancel := context.WithTimeout(context.Background(), opcuaTimeout)
+	defer cancel()
+	if err := c.Connect(ctx); err != nil {
+		return err
+	}
+	o.c = c
+
+	return nil
+}
+
+// Write writes values into nodes they are mapped to by node IDs
+func (o *opcuaClient) Write(values map[string]interface{}) error {
+	req := &ua.WriteRequest{}
+	for node, v := range values {
+		id, err := ua.ParseNodeID(node)
+		if err != nil {
+			return err
+		}
+		variant, err := ua.NewVariant(v)
+		if err != nil {
+			return err
+		}
+		req.NodesToWrite = append(req.NodesToWrite, &ua.WriteValue{
+			NodeID:      id,
+			AttributeID: ua.AttributeIDValue,
+			Value: &ua.DataValue{
+				EncodingMask: ua.DataValueValue,
+				Value:        variant,
+			},
+		})
+	}
+
+	if err := o.connect(); err != nil {
+		return err
+	}
+	resp, err := o.c.Write(req)
+	if err != nil {
+		o.Close()
+		return err
+	}
+	for i, status := range resp.Results {
+		if status != ua.StatusOK {
+			return fmt.Errorf("writing node %s failed: %v", req.NodesToWrite[i].NodeID, status)
+		}
+	}
+
+	return nil
+}
+
+// ReadBool reads boolean value of node; numeric values are true unless they are zero
+func (o *opcuaClient) ReadBool(node string) (bool, error) {
+	id, err := ua.ParseNodeID(node)
+	if err != nil {
+		return false, err
+	}
+
+	if err := o.connect(); err != nil {
+		return false, err
+	}
+	resp, err := o.c.Read(&ua.ReadRequest{
+		NodesToRead:        []*ua.ReadValueID{{NodeID: id, AttributeID: ua.AttributeIDValue}},
+		TimestampsToReturn: ua.TimestampsToReturnNeither,
+	})
+	if err != nil {
+		o.Close()
+		return false, err
+	}
+	if len(resp.Results) != 1 || resp.Results[0].Status != ua.StatusOK || resp.Results[0].Value == nil {
+		return false, fmt.Errorf("reading node %s failed", node)
+	}
+
+	switch v := resp.Results[0].Value.Value().(type) {
+	case bool:
+		return v, nil
+	case int16, int32, int64, uint16, uint32, uint64, byte, float32, float64:
+		return fmt.Sprint(v) != "0", nil
+	default:
+		return false, fmt.Errorf("node %s is not boolean: %v", node, v)
+	}
+}
+
+// Close closes connection to the server
+func (o *opcuaClient) Close() error {
+	if o.c == nil {
+		return nil
+	}
+
+	err := o.c.Close()
+	o.c = nil
+	return err
+}
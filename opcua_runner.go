@@ -0,0 +1,191 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// opcuaPollInterval is interval in which machine run state is read from OPC UA server
+const opcuaPollInterval = time.Second
+
+// OPCUAClient writes values into and reads values from OPC UA server nodes
+type OPCUAClient interface {
+	// Write writes values into nodes they are mapped to by node IDs
+	Write(values map[string]interface{}) error
+	// ReadBool reads boolean value of node
+	ReadBool(node string) (bool, error)
+	// Close closes connection to the server
+	Close() error
+}
+
+// opcuaNodeIDs maps alert and status values to IDs of OPC UA nodes they are written into
+var opcuaNodeIDs map[string]string
+
+// opcuaValues returns names of values which can be written into OPC UA nodes
+func opcuaValues() []string {
+	values := []string{"alert", "severity", "status.watching", "status.angry", "status.distance", "status.operator", "status.present"}
+	for typ := range alertTypes(new(Result)) {
+		values = append(values, "alert."+typ)
+	}
+
+	return values
+}
+
+// parseOPCUANodes parses comma separated value=node ID pairs and returns node IDs by value names
+func parseOPCUANodes(s string) (map[string]string, error) {
+	known := make(map[string]bool)
+	for _, v := range opcuaValues() {
+		known[v] = true
+	}
+
+	nodes := make(map[string]string)
+	for _, pair := range parseLabels(s) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[1]) == "" {
+			return nil, fmt.Errorf("invalid value to node mapping: %s", pair)
+		}
+
+		value := strings.TrimSpace(kv[0])
+		if !known[value] {
+			return nil, fmt.Errorf("unknown value: %s", value)
+		}
+		nodes[value] = strings.TrimSpace(kv[1])
+	}
+
+	return nodes, nil
+}
+
+// alertValues returns OPC UA values of active alerts
+func alertValues(active activeAlerts) map[string]interface{} {
+	values := map[string]interface{}{
+		"alert":    len(active) > 0,
+		"severity": "",
+	}
+	for typ := range alertTypes(new(Result)) {
+		values["alert."+typ] = false
+	}
+
+	for _, a := range active {
+		values["alert."+a.Type] = true
+		if severityRank(a.Severity) > severityRank(values["severity"].(string)) {
+			values["severity"] = a.Severity
+		}
+	}
+
+	return values
+}
+
+// statusValues returns OPC UA values of operator status in result
+func statusValues(r *Result) map[string]interface{} {
+	return map[string]interface{}{
+		"status.watching": r.status.IsWatching,
+		"status.angry":    r.status.IsAngry,
+		"status.distance": r.status.Distance,
+		"status.operator": r.status.OperatorID,
+		"status.present":  r.status.Present,
+	}
+}
+
+// opcuaRunner writes alerts received from latch and operator status received from statusChan into the mapped
+// OPC UA nodes. Only the values which changed since the last successful write are written.
+// If runNode is set, machine run state is read from it and sent down runChan whenever it changes.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func opcuaRunner(doneChan <-chan struct{}, latch *AlertLatch, statusChan <-chan *Result, runChan chan<- bool,
+	c OPCUAClient, nodes map[string]string, runNode string) error {
+	defer c.Close()
+
+	// written stores the values written into the nodes
+	written := make(map[string]interface{})
+	// write writes the values which changed into the nodes
+	write := func(values map[string]interface{}) {
+		changed := make(map[string]interface{})
+		for name, v := range values {
+			node, ok := nodes[name]
+			if !ok {
+				continue
+			}
+			if old, ok := written[name]; !ok || old != v {
+				changed[node] = v
+			}
+		}
+		if len(changed) == 0 {
+			return
+		}
+
+		if err := c.Write(changed); err != nil {
+			fmt.Printf("Error writing OPC UA nodes: %v\n", err)
+			return
+		}
+		for name, v := range values {
+			if _, ok := nodes[name]; ok {
+				written[name] = v
+			}
+		}
+	}
+
+	active := make(activeAlerts)
+	write(alertValues(active))
+
+	ticker := time.NewTicker(opcuaPollInterval)
+	defer ticker.Stop()
+	// running is the last machine run state read from the server
+	running := true
+	for {
+		select {
+		case <-latch.C:
+			latch.Apply(active)
+			write(alertValues(active))
+		case r, ok := <-statusChan:
+			if !ok {
+				statusChan = nil
+				continue
+			}
+			write(statusValues(r))
+		case <-ticker.C:
+			// retry failed alert writes
+			write(alertValues(active))
+			if runNode == "" {
+				continue
+			}
+			state, err := c.ReadBool(runNode)
+			if err != nil {
+				fmt.Printf("Error reading machine run state from OPC UA node %s: %v\n", runNode, err)
+				continue
+			}
+			if state != running {
+				select {
+				case runChan <- state:
+					running = state
+				case <-doneChan:
+				}
+			}
+		case <-doneChan:
+			fmt.Printf("Stopping opcuaRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
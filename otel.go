@@ -0,0 +1,152 @@
+//go:build otel
+// +build otel
+
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// otelScope is instrumentation scope of the spans and metrics
+	otelScope = "github.com/intel-iot-devkit/machine-operator-monitor-go"
+	// otelShutdownTimeout is time to wait for the pending spans and metrics to be exported on shutdown
+	otelShutdownTimeout = 5 * time.Second
+)
+
+// otelTelemetry exports pipeline spans and metrics via OTLP
+type otelTelemetry struct {
+	// tracer starts the spans
+	tracer trace.Tracer
+	// frames counts processed frames
+	frames metric.Int64Counter
+	// duration records duration of the pipeline stages in milliseconds
+	duration metric.Float64Histogram
+	// tp batches and exports the spans
+	tp *sdktrace.TracerProvider
+	// mp periodically exports the metrics
+	mp *sdkmetric.MeterProvider
+}
+
+// newTelemetry creates OTLP exporters and returns telemetry which exports to them.
+// The exporters, the sampler and the resource are configured by the standard OTEL_* environment variables,
+// e.g. OTEL_EXPORTER_OTLP_ENDPOINT and OTEL_TRACES_SAMPLER.
+func newTelemetry() (Telemetry, error) {
+	ctx := context.Background()
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", name),
+			attribute.String("machine.id", machineID),
+			attribute.String("camera.id", cameraID),
+		),
+		// OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME override the defaults
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	traceExp, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metricExp, err := otlpmetricgrpc.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &otelTelemetry{
+		tp: sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExp), sdktrace.WithResource(res)),
+		mp: sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExp)), sdkmetric.WithResource(res)),
+	}
+	t.tracer = t.tp.Tracer(otelScope)
+
+	meter := t.mp.Meter(otelScope)
+	if t.frames, err = meter.Int64Counter("monitor.frames",
+		metric.WithDescription("Number of processed frames")); err != nil {
+		return nil, err
+	}
+	if t.duration, err = meter.Float64Histogram("monitor.stage.duration",
+		metric.WithDescription("Duration of frame processing pipeline stages"),
+		metric.WithUnit("ms")); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Frame records frame span with queue, detect and publish stage spans and their durations
+func (t *otelTelemetry) Frame(captured, started, inferred, published time.Time) {
+	ctx, span := t.tracer.Start(context.Background(), "frame", trace.WithTimestamp(captured))
+
+	stages := []struct {
+		name       string
+		start, end time.Time
+	}{
+		// the frame waits for a free inference runner
+		{"queue", captured, started},
+		// the networks infer operator status
+		{"detect", started, inferred},
+		// the detections are put in order, the alerts are updated and the results are handed over
+		{"publish", inferred, published},
+		{"total", captured, published},
+	}
+	for _, s := range stages {
+		if s.name != "total" {
+			_, stage := t.tracer.Start(ctx, s.name, trace.WithTimestamp(s.start))
+			stage.End(trace.WithTimestamp(s.end))
+		}
+		t.duration.Record(ctx, float64(s.end.Sub(s.start))/float64(time.Millisecond),
+			metric.WithAttributes(attribute.String("stage", s.name)))
+	}
+
+	span.End(trace.WithTimestamp(published))
+	t.frames.Add(ctx, 1)
+}
+
+// Shutdown flushes the pending spans and metrics and stops the exporters
+func (t *otelTelemetry) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), otelShutdownTimeout)
+	defer cancel()
+
+	err := t.tp.Shutdown(ctx)
+	if merr := t.mp.Shutdown(ctx); err == nil {
+		err = merr
+	}
+
+	return err
+}
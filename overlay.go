@@ -0,0 +1,238 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// poseFocalLength is focal length in pixels of the virtual camera the head pose axes are projected with
+const poseFocalLength = 950
+
+// Colors of face bounding boxes drawn over the displayed frames
+var (
+	// faceColorWatching is color of faces of people who are watching the machine
+	faceColorWatching = color.RGBA{0, 255, 0, 0}
+	// faceColorNotWatching is color of faces of people who are not watching the machine
+	faceColorNotWatching = color.RGBA{255, 200, 0, 0}
+	// faceColorAngry is color of faces of angry people
+	faceColorAngry = color.RGBA{255, 0, 0, 0}
+)
+
+// Style of texts drawn over the displayed frames
+var (
+	// overlayTextColor is color of the status and performance texts
+	overlayTextColor = color.RGBA{255, 255, 255, 0}
+	// overlayOutlineColor is color of outline drawn around the texts; nil if the texts are not outlined
+	overlayOutlineColor = &color.RGBA{0, 0, 0, 0}
+	// overlayAlertColor is color of alert texts whose severity has no color
+	overlayAlertColor = color.RGBA{255, 0, 0, 0}
+	// overlayOrigin is position of baseline of the first text line
+	overlayOrigin = image.Point{10, 20}
+)
+
+// parseColor parses color from s in "r,g,b" format with components from 0 to 255
+func parseColor(s string) (color.RGBA, error) {
+	var r, g, b int
+	if n, err := fmt.Sscanf(s, "%d,%d,%d", &r, &g, &b); err != nil || n != 3 ||
+		r < 0 || r > 255 || g < 0 || g > 255 || b < 0 || b > 255 {
+		return color.RGBA{}, fmt.Errorf("Invalid color: %s", s)
+	}
+
+	return color.RGBA{uint8(r), uint8(g), uint8(b), 0}, nil
+}
+
+// parseOverlayStyle parses colors and text origin of the overlay from command line parameters;
+// outline "none" disables the text outline
+func parseOverlayStyle(text, outline, alert, origin string) error {
+	var err error
+	if overlayTextColor, err = parseColor(text); err != nil {
+		return err
+	}
+	if overlayAlertColor, err = parseColor(alert); err != nil {
+		return err
+	}
+
+	overlayOutlineColor = nil
+	if outline != "none" {
+		c, err := parseColor(outline)
+		if err != nil {
+			return err
+		}
+		overlayOutlineColor = &c
+	}
+
+	var x, y int
+	if n, err := fmt.Sscanf(origin, "%d,%d", &x, &y); err != nil || n != 2 || x < 0 || y < 0 {
+		return fmt.Errorf("Invalid overlay origin: %s", origin)
+	}
+	overlayOrigin = image.Point{x, y}
+
+	return nil
+}
+
+// putText draws text into img at pt in color c using the overlay font scaled by scale and the overlay thickness.
+// The text is outlined in the outline color so it stays legible on both dark and bright scenes.
+func putText(img *gocv.Mat, text string, pt image.Point, scale float64, c color.RGBA) {
+	fontScale := overlayFontScale * scale
+	if overlayOutlineColor != nil {
+		gocv.PutText(img, text, pt, gocv.FontHersheySimplex, fontScale, *overlayOutlineColor, overlayThickness+2)
+	}
+	gocv.PutText(img, text, pt, gocv.FontHersheySimplex, fontScale, c, overlayThickness)
+}
+
+// putLine draws text into img as line-th line of the overlay text in color c; lines are spaced by the line height
+func putLine(img *gocv.Mat, text string, line int, c color.RGBA) {
+	height := overlayLineHeight
+	if height <= 0 {
+		height = int(40*overlayFontScale) + 2*overlayThickness
+	}
+	putText(img, text, image.Point{overlayOrigin.X, overlayOrigin.Y + line*height}, 1, c)
+}
+
+// faceColor returns color face f is drawn in; anger takes precedence over not watching the machine
+func faceColor(f *Face) color.RGBA {
+	switch {
+	case f.IsAngry:
+		return faceColorAngry
+	case !f.IsWatching:
+		return faceColorNotWatching
+	}
+
+	return faceColorWatching
+}
+
+// drawFaces draws bounding boxes of faces of status s into img color-coded by their watching and angry states
+func drawFaces(img *gocv.Mat, s *Status) {
+	if s == nil {
+		return
+	}
+
+	for _, f := range s.Faces {
+		gocv.Rectangle(img, f.Rect, faceColor(f), 2)
+	}
+}
+
+// sentimentConfidence returns probability of the sentiment of face f; zero if sentiment was not inferred
+func sentimentConfidence(f *Face) float64 {
+	for i, prob := range f.SentimentProbs {
+		if sentiment(i) == f.Sentiment {
+			return float64(prob)
+		}
+	}
+
+	return 0
+}
+
+// drawSentiments draws sentiment labels of faces of status s with their confidences above the face bounding boxes
+// into img in the colors of the boxes; faces with unknown sentiment are not labeled
+func drawSentiments(img *gocv.Mat, s *Status) {
+	if s == nil {
+		return
+	}
+
+	for _, f := range s.Faces {
+		if f.Sentiment == UNKNOWN {
+			continue
+		}
+		label := msg("sentiment." + strings.ToLower(f.Sentiment.String()))
+		if p := sentimentConfidence(f); p > 0 {
+			label = fmt.Sprintf("%s %.0f%%", label, p*100)
+		}
+		// faces at the top edge of the frame are labeled inside the box
+		pt := image.Point{f.Rect.Min.X, f.Rect.Min.Y - 6}
+		if pt.Y < 15 {
+			pt.Y = f.Rect.Min.Y + 18
+		}
+		putText(img, label, pt, 1, faceColor(f))
+	}
+}
+
+// rotate returns vector v rotated by yaw, pitch and roll angles in radians in the head pose model convention
+func rotate(v [3]float64, yaw, pitch, roll float64) [3]float64 {
+	// pitch rotates around x axis
+	x, y, z := v[0], v[1]*math.Cos(pitch)-v[2]*math.Sin(pitch), v[1]*math.Sin(pitch)+v[2]*math.Cos(pitch)
+	// yaw rotates around y axis
+	x, z = x*math.Cos(yaw)-z*math.Sin(yaw), x*math.Sin(yaw)+z*math.Cos(yaw)
+	// roll rotates around z axis
+	x, y = x*math.Cos(roll)-y*math.Sin(roll), x*math.Sin(roll)+y*math.Cos(roll)
+
+	return [3]float64{x, y, z}
+}
+
+// project returns point v relative to head center c projected onto the frame by the virtual camera
+func project(v [3]float64, c image.Point) image.Point {
+	z := v[2] + poseFocalLength
+
+	return image.Point{
+		X: c.X + int(v[0]/z*poseFocalLength),
+		Y: c.Y + int(v[1]/z*poseFocalLength),
+	}
+}
+
+// drawPose draws reliably estimated head pose of faces of status s into img as axes centered in the faces: the red x axis points
+// to the right of the head, the green y axis up and the blue arrow in the direction the head is turned.
+// The gaze is drawn as a yellow arrow if it was estimated, and the yaw and pitch angles are printed below the faces
+// in green if they are within the limits of watching the machine and in red otherwise.
+func drawPose(img *gocv.Mat, s *Status) {
+	if s == nil {
+		return
+	}
+
+	for _, f := range s.Faces {
+		// unreliable head pose is not used to tell whether the person is watching the machine
+		if f.PoseQuality <= poseConfidence {
+			continue
+		}
+		c := image.Point{(f.Rect.Min.X + f.Rect.Max.X) / 2, (f.Rect.Min.Y + f.Rect.Max.Y) / 2}
+		scale := float64(f.Rect.Dx()) / 2
+		yaw, pitch, roll := f.Yaw*math.Pi/180, f.Pitch*math.Pi/180, f.Roll*math.Pi/180
+
+		x := project(rotate([3]float64{scale, 0, 0}, yaw, pitch, roll), c)
+		y := project(rotate([3]float64{0, -scale, 0}, yaw, pitch, roll), c)
+		back := project(rotate([3]float64{0, 0, scale}, yaw, pitch, roll), c)
+		front := project(rotate([3]float64{0, 0, -scale}, yaw, pitch, roll), c)
+		gocv.Line(img, c, x, color.RGBA{255, 0, 0, 0}, 2)
+		gocv.Line(img, c, y, color.RGBA{0, 255, 0, 0}, 2)
+		gocv.ArrowedLine(img, back, front, color.RGBA{0, 0, 255, 0}, 2)
+
+		if f.Gaze != nil {
+			tip := image.Point{c.X + int(f.Gaze.X*2*scale), c.Y - int(f.Gaze.Y*2*scale)}
+			gocv.ArrowedLine(img, c, tip, color.RGBA{255, 255, 0, 0}, 2)
+		}
+
+		angles := color.RGBA{255, 0, 0, 0}
+		if headWatching(f.Yaw, f.Pitch, f.Roll) {
+			angles = color.RGBA{0, 255, 0, 0}
+		}
+		putText(img, fmt.Sprintf("yaw %.0f pitch %.0f roll %.0f", f.Yaw, f.Pitch, f.Roll),
+			image.Point{f.Rect.Min.X, f.Rect.Max.Y + 15}, 0.8, angles)
+	}
+}
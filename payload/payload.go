@@ -0,0 +1,230 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package payload defines JSON payloads the machine operator monitor publishes to MQTT broker and posts to webhooks.
+// Consumers can unmarshal the payloads into the types defined here and check their Schema version.
+package payload
+
+import "time"
+
+// SchemaVersion is version of the payloads defined in this package.
+// Version 1 was the unversioned format which preceded structured payloads.
+const SchemaVersion = 2
+
+// Header identifies the payload schema and the monitor which published it
+type Header struct {
+	// Schema is version of payload schema
+	Schema int `json:"schema"`
+	// Time is time the payload describes
+	Time time.Time `json:"time"`
+	// MachineID identifies the monitored machine; empty if not configured
+	MachineID string `json:"machine_id,omitempty"`
+	// CameraID identifies the camera watching the machine; empty if not configured
+	CameraID string `json:"camera_id,omitempty"`
+}
+
+// Status is machine operator status published periodically
+type Status struct {
+	Header
+	// Operator is ID of the identified operator; empty if the operator is unknown
+	Operator string `json:"operator,omitempty"`
+	// Watching means operator is watching the machine
+	Watching bool `json:"watching"`
+	// Angry means operator is angry
+	Angry bool `json:"angry"`
+	// Sentiment is sentiment detected from the operator face in the latest frame; omitted if it is unknown
+	Sentiment string `json:"sentiment,omitempty"`
+	// Distance is operator distance from the machine in meters; omitted if unknown
+	Distance float64 `json:"distance,omitempty"`
+	// Phone means operator uses phone; omitted if phone detection is disabled
+	Phone *bool `json:"phone,omitempty"`
+	// Degradation is inference degradation level; omitted if latency budget is disabled
+	Degradation *int `json:"degradation,omitempty"`
+	// Risk is operator fatigue risk score; omitted if risk scoring is disabled
+	Risk *float64 `json:"risk,omitempty"`
+	// Paused means monitoring was paused by command
+	Paused bool `json:"paused,omitempty"`
+	// Suspended means monitoring was suspended outside of shifts
+	Suspended bool `json:"suspended,omitempty"`
+	// Alerts maps every alert type to whether the alert is raised
+	Alerts map[string]bool `json:"alerts"`
+	// Severities maps types of raised alerts to their severities
+	Severities map[string]string `json:"severities,omitempty"`
+	// Acknowledged lists types of raised alerts which were acknowledged
+	Acknowledged []string `json:"acknowledged,omitempty"`
+	// Window summarizes all the frames processed since the previous status; the other fields describe the latest frame
+	Window *Window `json:"window,omitempty"`
+}
+
+// Window summarizes frames processed in publishing window which ends at the status time
+type Window struct {
+	// Start is time of the first frame in the window
+	Start time.Time `json:"start"`
+	// Frames is number of frames processed in the window
+	Frames int `json:"frames"`
+	// Missed is number of frames processed in the window which the publisher could not keep up with
+	// and which are not counted in the window
+	Missed int `json:"missed,omitempty"`
+	// Present is number of frames with operator at the machine
+	Present int `json:"present"`
+	// Watching is number of frames operator was watching the machine in
+	Watching int `json:"watching"`
+	// Angry is number of frames operator was angry in
+	Angry int `json:"angry"`
+	// WatchingRatio is ratio of the frames with operator at the machine the operator was watching the machine in
+	WatchingRatio float64 `json:"watching_ratio"`
+	// AngryRatio is ratio of the frames with operator at the machine the operator was angry in
+	AngryRatio float64 `json:"angry_ratio"`
+	// AlertFrames maps alert types to number of frames the alert was raised in; omitted types were not raised
+	AlertFrames map[string]int `json:"alert_frames,omitempty"`
+	// Raised maps alert types to number of times the alert was raised in the window; omitted types were not raised
+	Raised map[string]int `json:"raised,omitempty"`
+}
+
+// Alert is alert state transition published as it happens
+type Alert struct {
+	Header
+	// ID uniquely identifies the alert
+	ID string `json:"id"`
+	// Type is alert type
+	Type string `json:"type"`
+	// State is alert state: raised, acknowledged, cleared or snoozed
+	State string `json:"state"`
+	// Severity is alert severity: info, warning or critical
+	Severity string `json:"severity"`
+	// Escalation is number of escalation steps the alert went through
+	Escalation int `json:"escalation,omitempty"`
+	// Sinks are sinks the alert is published to; omitted if the alert goes to the default sinks
+	Sinks []string `json:"sinks,omitempty"`
+	// Raised is time when the alert was raised
+	Raised time.Time `json:"raised"`
+	// Acknowledged is time when the alert was acknowledged; omitted if it was not acknowledged
+	Acknowledged *time.Time `json:"acknowledged,omitempty"`
+	// Cleared is time when the alert was cleared; omitted if it was not cleared
+	Cleared *time.Time `json:"cleared,omitempty"`
+	// Snoozed is time when the alert type was snoozed; omitted if it was not snoozed
+	Snoozed *time.Time `json:"snoozed,omitempty"`
+	// SnoozedUntil is time until which the alert type is snoozed; omitted if it was not snoozed
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+	// Heartbeat means the alert state did not change; the alert is republished as it persists
+	Heartbeat bool `json:"heartbeat,omitempty"`
+	// Snapshot is path to annotated snapshot of the frame the alert was raised in; omitted if not attached
+	Snapshot string `json:"snapshot,omitempty"`
+	// Image is base64 encoded JPEG of the snapshot; omitted if not attached
+	Image string `json:"image,omitempty"`
+}
+
+// Perf is inference engine performance published with operator status
+type Perf struct {
+	Header
+	// FaceNet is face detection inference time in milliseconds
+	FaceNet float64 `json:"face_net"`
+	// SentNet is sentiment detection inference time in milliseconds
+	SentNet float64 `json:"sent_net"`
+	// PoseNet is head pose estimation inference time in milliseconds
+	PoseNet float64 `json:"pose_net"`
+	// Nets maps optional networks which ran, e.g. landmarks or phone, to their inference times in milliseconds
+	Nets map[string]float64 `json:"nets,omitempty"`
+	// FPS is rate of processed frames in frames per second
+	FPS float64 `json:"fps"`
+	// InputFPS is rate of frames accepted for processing in frames per second
+	InputFPS float64 `json:"input_fps"`
+	// Frames is number of frames accepted for processing since the start
+	Frames uint64 `json:"frames"`
+	// Dropped is number of accepted frames which were not processed since the start
+	Dropped uint64 `json:"dropped"`
+}
+
+// Connection is connection state of the monitor retained by MQTT broker.
+// The broker publishes the offline state as the monitor's Last Will and Testament when the monitor
+// crashes or loses connectivity.
+type Connection struct {
+	// Schema is version of payload schema
+	Schema int `json:"schema"`
+	// MachineID identifies the monitored machine; empty if not configured
+	MachineID string `json:"machine_id,omitempty"`
+	// CameraID identifies the camera watching the machine; empty if not configured
+	CameraID string `json:"camera_id,omitempty"`
+	// Status is connection state: online or offline
+	Status string `json:"status"`
+}
+
+// PPE is personal protective equipment alert published with operator status
+type PPE struct {
+	Header
+	// Alert means the protective equipment alert is raised
+	Alert bool `json:"alert"`
+	// Missing are labels of required protective equipment operator does not wear
+	Missing []string `json:"missing"`
+}
+
+// Detection is detailed detection result of a single processed frame published if requested
+type Detection struct {
+	Header
+	// Faces are faces detected in the frame
+	Faces []Face `json:"faces"`
+}
+
+// Face is face detected in the frame
+type Face struct {
+	// Box is face bounding box in pixels
+	Box Box `json:"box"`
+	// Confidence is face detection confidence
+	Confidence float64 `json:"confidence"`
+	// Yaw is head yaw angle in degrees
+	Yaw float64 `json:"yaw"`
+	// Pitch is head pitch angle in degrees
+	Pitch float64 `json:"pitch"`
+	// Roll is head roll angle in degrees
+	Roll float64 `json:"roll"`
+	// PoseConfidence is confidence of the head pose estimate
+	PoseConfidence float64 `json:"pose_confidence"`
+	// Sentiment is the most likely sentiment; omitted if it is unknown
+	Sentiment string `json:"sentiment,omitempty"`
+	// Sentiments maps sentiments to their probabilities; omitted if sentiment was not inferred
+	Sentiments map[string]float64 `json:"sentiments,omitempty"`
+	// Operator is ID of the identified operator; empty if the operator is unknown
+	Operator string `json:"operator,omitempty"`
+	// Track is ID of the face track; omitted if tracking is disabled
+	Track int `json:"track,omitempty"`
+	// Watching means the person is watching the machine
+	Watching bool `json:"watching"`
+	// Angry means the person is angry
+	Angry bool `json:"angry"`
+	// Masked means the person wears face mask
+	Masked bool `json:"masked,omitempty"`
+	// Phone means the person uses phone
+	Phone bool `json:"phone,omitempty"`
+}
+
+// Box is bounding box in pixels
+type Box struct {
+	// X is left edge
+	X int `json:"x"`
+	// Y is top edge
+	Y int `json:"y"`
+	// Width is box width
+	Width int `json:"width"`
+	// Height is box height
+	Height int `json:"height"`
+}
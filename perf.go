@@ -0,0 +1,153 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// throughputWindow is time over which frame rates are measured
+const throughputWindow = 5 * time.Second
+
+// Throughput stores frame rates and counts of the monitoring pipeline
+type Throughput struct {
+	// InputFPS is rate of frames accepted for processing in frames per second
+	InputFPS float64
+	// FPS is rate of processed frames in frames per second
+	FPS float64
+	// Frames is number of frames accepted for processing
+	Frames uint64
+	// Dropped is number of accepted frames which were not processed, e.g. when the frames are sampled
+	Dropped uint64
+	// Processed is number of frames processed
+	Processed uint64
+}
+
+// rateMeter measures rate of events over consecutive windows
+type rateMeter struct {
+	// window is time over which the rate is measured
+	window time.Duration
+	// start is time of the first event of the current window
+	start time.Time
+	// count is number of events in the current window following the first one
+	count int
+	// rate is rate measured over the latest full window in events per second
+	rate float64
+}
+
+// Add records event at time ts and returns the rate measured over the latest full window
+func (m *rateMeter) Add(ts time.Time) float64 {
+	if m.start.IsZero() {
+		m.start = ts
+		return m.rate
+	}
+
+	m.count++
+	if elapsed := ts.Sub(m.start); elapsed >= m.window {
+		m.rate = float64(m.count) / elapsed.Seconds()
+		m.start, m.count = ts, 0
+	}
+
+	return m.rate
+}
+
+// latencyMeter measures mean latency over consecutive windows
+type latencyMeter struct {
+	// window is time over which the latency is measured
+	window time.Duration
+	// start is time of the first latency of the current window
+	start time.Time
+	// sum is sum of latencies in the current window
+	sum time.Duration
+	// count is number of latencies in the current window
+	count int
+	// mean is mean latency over the latest full window
+	mean time.Duration
+}
+
+// Add records latency d at time ts and returns the mean latency over the latest full window
+func (m *latencyMeter) Add(ts time.Time, d time.Duration) time.Duration {
+	if m.start.IsZero() {
+		m.start, m.mean = ts, d
+	}
+
+	m.sum += d
+	m.count++
+	if ts.Sub(m.start) >= m.window {
+		m.mean = m.sum / time.Duration(m.count)
+		m.start, m.sum, m.count = ts, 0, 0
+	}
+
+	return m.mean
+}
+
+// frameCounter measures capture and processing frame rates and capture-to-display latency in the display loop
+type frameCounter struct {
+	// capture measures rate of captured frames
+	capture rateMeter
+	// latency measures time from frame capture to display of its result
+	latency latencyMeter
+	// captureFPS is rate of captured frames in frames per second
+	captureFPS float64
+	// fps is rate of processed frames in frames per second
+	fps float64
+	// delay is mean capture-to-display latency
+	delay time.Duration
+	// displayed is capture time of the frame of the latest displayed result
+	displayed time.Time
+}
+
+// newFrameCounter creates new frame counter and returns it
+func newFrameCounter() *frameCounter {
+	return &frameCounter{
+		capture: rateMeter{window: throughputWindow},
+		latency: latencyMeter{window: throughputWindow},
+	}
+}
+
+// Captured records frame captured at time ts
+func (c *frameCounter) Captured(ts time.Time) {
+	c.captureFPS = c.capture.Add(ts)
+}
+
+// Displayed records result r displayed at time ts; results which were already displayed are not counted again
+func (c *frameCounter) Displayed(r *Result, ts time.Time) {
+	c.fps = r.Throughput.FPS
+	if r.Captured.After(c.displayed) {
+		c.displayed = r.Captured
+		c.delay = c.latency.Add(ts, ts.Sub(r.Captured))
+	}
+}
+
+// String implements fmt.Stringer interface for frameCounter
+func (c *frameCounter) String() string {
+	return fmt.Sprintf("%s: %.1f fps, %s: %.1f fps, %s: %d ms", msg(labelCaptureFPS), c.captureFPS,
+		msg(labelProcessedFPS), c.fps, msg(labelLatency), c.delay/time.Millisecond)
+}
+
+// hasPerf returns true if result carries inference performance or frame throughput
+func (r *Result) hasPerf() bool {
+	return r.Perf != nil || r.Throughput.Frames > 0
+}
@@ -0,0 +1,60 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// detectPersons detects persons in img and returns their bounding boxes.
+// The net is expected to be compatible with person-detection-retail-0013 model.
+// The detector input size is scaled by scale unless it is zero.
+func detectPersons(net Model, img *gocv.Mat, scale float64) []image.Rectangle {
+	// convert img Mat to 544x320 blob that the person detector can analyze
+	blob := gocv.BlobFromImage(*img, 1.0, detectorSize(net, image.Pt(544, 320), scale), gocv.NewScalar(0, 0, 0, 0), false, false)
+	defer blob.Close()
+
+	// run a forward pass through the network
+	net.SetInput(blob, "")
+	results := net.Forward("")
+	defer results.Close()
+
+	var persons []image.Rectangle
+	for i := 0; i < results.Total(); i += 7 {
+		confidence := results.GetFloatAt(0, i+2)
+		if float64(confidence) <= personConfidence {
+			continue
+		}
+
+		left := int(results.GetFloatAt(0, i+3) * float32(img.Cols()))
+		top := int(results.GetFloatAt(0, i+4) * float32(img.Rows()))
+		right := int(results.GetFloatAt(0, i+5) * float32(img.Cols()))
+		bottom := int(results.GetFloatAt(0, i+6) * float32(img.Rows()))
+		persons = append(persons, image.Rect(left, top, right, bottom))
+	}
+
+	return persons
+}
@@ -0,0 +1,76 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// detectPhones detects handheld phones in img and returns their bounding boxes.
+// The net is expected to be SSD object detector; detections of classes other than phoneClass are ignored.
+func detectPhones(net Model, img *gocv.Mat) []image.Rectangle {
+	// convert img Mat to 300x300 blob that the phone detector can analyze
+	blob := gocv.BlobFromImage(*img, 1.0, image.Pt(300, 300), gocv.NewScalar(0, 0, 0, 0), false, false)
+	defer blob.Close()
+
+	// run a forward pass through the network
+	net.SetInput(blob, "")
+	results := net.Forward("")
+	defer results.Close()
+
+	var phones []image.Rectangle
+	for i := 0; i < results.Total(); i += 7 {
+		class := int(results.GetFloatAt(0, i+1))
+		confidence := results.GetFloatAt(0, i+2)
+		if class != phoneClass || float64(confidence) <= phoneConfidence {
+			continue
+		}
+
+		left := int(results.GetFloatAt(0, i+3) * float32(img.Cols()))
+		top := int(results.GetFloatAt(0, i+4) * float32(img.Rows()))
+		right := int(results.GetFloatAt(0, i+5) * float32(img.Cols()))
+		bottom := int(results.GetFloatAt(0, i+6) * float32(img.Rows()))
+		phones = append(phones, image.Rect(left, top, right, bottom))
+	}
+
+	return phones
+}
+
+// usesPhone returns true if the person whose face is bounded by face rectangle uses any of phones.
+// A phone is used if its center lies within the face region extended sideways and downwards,
+// which covers both phone held at the ear and phone held in hands in front of the chest.
+func usesPhone(face image.Rectangle, phones []image.Rectangle) bool {
+	region := image.Rect(face.Min.X-face.Dx(), face.Min.Y, face.Max.X+face.Dx(), face.Max.Y+2*face.Dy())
+
+	for _, p := range phones {
+		center := image.Pt((p.Min.X+p.Max.X)/2, (p.Min.Y+p.Max.Y)/2)
+		if center.In(region) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,141 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"strings"
+
+	"github.com/intel-iot-devkit/machine-operator-monitor-go/payload"
+	"gocv.io/x/gocv"
+)
+
+// ppeDetection is a single piece of personal protective equipment detected in a frame
+type ppeDetection struct {
+	// label is equipment label
+	label string
+	// rect is equipment bounding box
+	rect image.Rectangle
+}
+
+// parseLabels parses comma separated list of labels
+func parseLabels(s string) []string {
+	var labels []string
+	for _, l := range strings.Split(s, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			labels = append(labels, l)
+		}
+	}
+
+	return labels
+}
+
+// detectPPE detects personal protective equipment in img and returns it.
+// The net is expected to be SSD detector whose class IDs start at 1 and map to labels in order.
+func detectPPE(net Model, img *gocv.Mat, labels []string) []ppeDetection {
+	// convert img Mat to 300x300 blob that the PPE detector can analyze
+	blob := gocv.BlobFromImage(*img, 1.0, image.Pt(300, 300), gocv.NewScalar(0, 0, 0, 0), false, false)
+	defer blob.Close()
+
+	// run a forward pass through the network
+	net.SetInput(blob, "")
+	results := net.Forward("")
+	defer results.Close()
+
+	var dets []ppeDetection
+	for i := 0; i < results.Total(); i += 7 {
+		class := int(results.GetFloatAt(0, i+1))
+		confidence := results.GetFloatAt(0, i+2)
+		if class < 1 || class > len(labels) || float64(confidence) <= ppeConfidence {
+			continue
+		}
+
+		left := int(results.GetFloatAt(0, i+3) * float32(img.Cols()))
+		top := int(results.GetFloatAt(0, i+4) * float32(img.Rows()))
+		right := int(results.GetFloatAt(0, i+5) * float32(img.Cols()))
+		bottom := int(results.GetFloatAt(0, i+6) * float32(img.Rows()))
+		dets = append(dets, ppeDetection{
+			label: labels[class-1],
+			rect:  image.Rect(left, top, right, bottom),
+		})
+	}
+
+	return dets
+}
+
+// wearsPPE returns labels of equipment in dets worn by the person whose face is bounded by face rectangle.
+// Equipment is worn if its center lies within the face region extended upwards and sideways,
+// which covers both head-worn equipment like hardhats and face-worn equipment like safety glasses.
+func wearsPPE(face image.Rectangle, dets []ppeDetection) []string {
+	region := image.Rect(face.Min.X-face.Dx()/4, face.Min.Y-face.Dy(), face.Max.X+face.Dx()/4, face.Max.Y)
+
+	var worn []string
+	for _, d := range dets {
+		center := image.Pt((d.rect.Min.X+d.rect.Max.X)/2, (d.rect.Min.Y+d.rect.Max.Y)/2)
+		if center.In(region) {
+			worn = append(worn, d.label)
+		}
+	}
+
+	return worn
+}
+
+// missingPPE returns required equipment which is not in worn
+func missingPPE(required, worn []string) []string {
+	var missing []string
+	for _, r := range required {
+		var found bool
+		for _, w := range worn {
+			if w == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, r)
+		}
+	}
+
+	return missing
+}
+
+// ToPPEMessage turns result into MQTT message with personal protective equipment alert
+func (r *Result) ToPPEMessage() string {
+	p := payload.PPE{
+		Header:  payloadHeader(r.Time),
+		Alert:   r.AlertPPE,
+		Missing: r.status.MissingPPE,
+	}
+	if p.Missing == nil {
+		p.Missing = []string{}
+	}
+
+	msg, err := json.Marshal(p)
+	if err != nil {
+		return "{}"
+	}
+
+	return string(msg)
+}
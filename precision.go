@@ -0,0 +1,122 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// precisions lists model precisions in the order they are looked up in model paths
+var precisions = []string{"FP16-INT8", "FP32-INT8", "INT8", "FP16", "FP32"}
+
+// targetPrecisions maps inference targets to model precisions they support
+var targetPrecisions = map[int][]string{
+	// CPU
+	0: {"FP32", "FP16", "INT8", "FP16-INT8", "FP32-INT8"},
+	// OpenCL
+	1: {"FP32", "FP16"},
+	// OpenCL half precision
+	2: {"FP16"},
+	// VPU
+	3: {"FP16"},
+}
+
+// isPrecision returns true if s is a known model precision
+func isPrecision(s string) bool {
+	for _, p := range precisions {
+		if strings.EqualFold(s, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// modelPrecision returns precision of model stored in Open Model Zoo directory layout,
+// i.e. the name of the closest parent directory which is a known precision.
+// It returns empty string if the precision can't be determined from the path.
+func modelPrecision(path string) string {
+	for dir := filepath.Dir(path); dir != "." && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if base := filepath.Base(dir); isPrecision(base) {
+			return strings.ToUpper(base)
+		}
+	}
+
+	return ""
+}
+
+// withPrecision returns path to precision variant of model stored in Open Model Zoo directory layout.
+// It returns error if precision is unknown or if the model path contains no precision directory.
+func withPrecision(path, precision string) (string, error) {
+	if !isPrecision(precision) {
+		return "", fmt.Errorf("Unknown model precision: %s", precision)
+	}
+
+	current := modelPrecision(path)
+	if current == "" {
+		return "", fmt.Errorf("Can't select %s precision: no precision directory in model path %s", precision, path)
+	}
+
+	elems := strings.Split(filepath.ToSlash(path), "/")
+	for i := len(elems) - 2; i >= 0; i-- {
+		if strings.EqualFold(elems[i], current) {
+			elems[i] = strings.ToUpper(precision)
+			break
+		}
+	}
+
+	return filepath.FromSlash(strings.Join(elems, "/")), nil
+}
+
+// checkPrecision returns error if model precision is not supported by any of the inference targets.
+// Models whose precision can't be determined, and targets with unknown precisions, are assumed to be supported.
+func checkPrecision(model string, targets []int) error {
+	precision := modelPrecision(model)
+	if precision == "" {
+		return nil
+	}
+
+	var supported []string
+	seen := make(map[string]bool)
+	for _, t := range targets {
+		ps, ok := targetPrecisions[t]
+		if !ok {
+			return nil
+		}
+		for _, p := range ps {
+			if p == precision {
+				return nil
+			}
+			if !seen[p] {
+				seen[p] = true
+				supported = append(supported, p)
+			}
+		}
+	}
+
+	return fmt.Errorf("%s precision of model %s is not supported by targets %v; supported precisions: %s",
+		precision, model, targets, strings.Join(supported, ", "))
+}
@@ -0,0 +1,150 @@
+//go:build pubsub
+// +build pubsub
+
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/api/option"
+)
+
+const (
+	// pubsubTopicAttr is message attribute carrying the MQTT topic the message corresponds to
+	pubsubTopicAttr = "topic"
+	// pubsubMachineAttr is message attribute carrying the machine ID
+	pubsubMachineAttr = "machine_id"
+	// pubsubCameraAttr is message attribute carrying the camera ID
+	pubsubCameraAttr = "camera_id"
+	// pubsubAlertTypeAttr is message attribute carrying the alert type of alert messages
+	pubsubAlertTypeAttr = "alert_type"
+	// pubsubAlertStateAttr is message attribute carrying the alert state of alert messages
+	pubsubAlertStateAttr = "alert_state"
+)
+
+// pubsubPublisher publishes messages to Google Cloud Pub/Sub topic with attributes subscriptions can filter on
+type pubsubPublisher struct {
+	// client is Pub/Sub client
+	client *pubsub.Client
+	// topic is Pub/Sub topic
+	topic *pubsub.Topic
+}
+
+// newPubSubPublisher creates new Pub/Sub publisher and returns it
+// It reads the following environment variables to configure the publisher:
+// PUBSUB_PROJECT: Google Cloud project ID; required parameter
+// PUBSUB_TOPIC: Pub/Sub topic ID; defaults to machine-safety
+// PUBSUB_CREDENTIALS: service account key file; not required, the application default credentials are used by default
+// It returns error if no project is configured, if the credentials are invalid or if the topic doesn't exist.
+func newPubSubPublisher() (SinkCloser, error) {
+	project := os.Getenv("PUBSUB_PROJECT")
+	topicID := os.Getenv("PUBSUB_TOPIC")
+	credentials := os.Getenv("PUBSUB_CREDENTIALS")
+
+	if project == "" {
+		return nil, fmt.Errorf("Pub/Sub project is empty")
+	}
+	if topicID == "" {
+		topicID = "machine-safety"
+	}
+
+	var opts []option.ClientOption
+	if credentials != "" {
+		opts = append(opts, option.WithCredentialsFile(credentials))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := pubsub.NewClient(context.Background(), project, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	topic := client.Topic(topicID)
+	ok, err := topic.Exists(ctx)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	if !ok {
+		client.Close()
+		return nil, fmt.Errorf("Pub/Sub topic %s doesn't exist", topic)
+	}
+	// messages are sent as soon as they are published rather than batched
+	topic.PublishSettings.CountThreshold = 1
+
+	return &pubsubPublisher{
+		client: client,
+		topic:  topic,
+	}, nil
+}
+
+// Send publishes message corresponding to MQTT topic to the Pub/Sub topic.
+// The MQTT topic, the machine and camera IDs and the type and state of alerts are sent in message attributes.
+func (p *pubsubPublisher) Send(topic, message string) error {
+	var fields struct {
+		MachineID string `json:"machine_id"`
+		CameraID  string `json:"camera_id"`
+		Type      string `json:"type"`
+		State     string `json:"state"`
+	}
+	// all payloads are JSON objects; attributes of ones which fail to decode are left out
+	json.Unmarshal([]byte(message), &fields)
+
+	attrs := map[string]string{pubsubTopicAttr: topic}
+	for k, v := range map[string]string{
+		pubsubMachineAttr:    fields.MachineID,
+		pubsubCameraAttr:     fields.CameraID,
+		pubsubAlertTypeAttr:  fields.Type,
+		pubsubAlertStateAttr: fields.State,
+	} {
+		if v != "" {
+			attrs[k] = v
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := p.topic.Publish(ctx, &pubsub.Message{
+		Data:       []byte(message),
+		Attributes: attrs,
+	}).Get(ctx)
+
+	return err
+}
+
+// Close flushes the pending messages and closes the client
+func (p *pubsubPublisher) Close() error {
+	p.topic.Stop()
+	return p.client.Close()
+}
@@ -0,0 +1,208 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Push notification services
+const (
+	// pushNtfy is ntfy.sh or self-hosted ntfy server
+	pushNtfy = "ntfy"
+	// pushGotify is self-hosted Gotify server
+	pushGotify = "gotify"
+)
+
+// ntfyPriorities maps alert severities to ntfy message priorities; other state transitions get low priority
+var ntfyPriorities = map[string]string{
+	severityInfo:     "3",
+	severityWarning:  "4",
+	severityCritical: "5",
+}
+
+// gotifyPriorities maps alert severities to Gotify message priorities; other state transitions get low priority
+var gotifyPriorities = map[string]int{
+	severityInfo:     3,
+	severityWarning:  6,
+	severityCritical: 9,
+}
+
+// Pusher pushes notifications of alerts to phones
+type Pusher interface {
+	// Push pushes notification of state transition of alert a
+	Push(a Alert) error
+}
+
+// pushTitle returns title of notification of state transition of alert a
+func pushTitle(a Alert) string {
+	title := fmt.Sprintf("%s alert %s", a.Type, a.State)
+	if machineID != "" {
+		title = fmt.Sprintf("%s: %s", machineID, title)
+	}
+
+	return title
+}
+
+// pushMessage returns message of notification of state transition of alert a
+func pushMessage(a Alert) string {
+	return fmt.Sprintf("%s\n%s severity, raised at %s", msg("alert."+a.Type), a.Severity, a.Raised.Format("15:04:05"))
+}
+
+// Ntfy pushes notifications to ntfy topic
+type Ntfy struct {
+	// url is URL of the topic
+	url string
+	// token is access token; empty if the topic is public
+	token string
+	// client sends the requests
+	client *http.Client
+}
+
+// NewNtfy creates new ntfy pusher and returns it
+// It reads the following environment variables to configure the pusher:
+// NTFY_URL: URL of the topic, e.g. https://ntfy.sh/press-7-alerts; required parameter
+// NTFY_TOKEN: access token of protected topic; not required
+// It returns error if the topic URL is missing.
+func NewNtfy() (*Ntfy, error) {
+	url := os.Getenv("NTFY_URL")
+	if url == "" {
+		return nil, fmt.Errorf("ntfy topic URL is empty")
+	}
+
+	return &Ntfy{
+		url:    url,
+		token:  os.Getenv("NTFY_TOKEN"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Push publishes notification of state transition of alert a to the topic
+func (n *Ntfy) Push(a Alert) error {
+	req, err := http.NewRequest(http.MethodPost, n.url, strings.NewReader(pushMessage(a)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", pushTitle(a))
+	req.Header.Set("Tags", "warning,"+a.Type)
+	priority := "2"
+	if a.State == alertRaised {
+		priority = ntfyPriorities[a.Severity]
+	}
+	req.Header.Set("Priority", priority)
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Gotify pushes notifications to Gotify server
+type Gotify struct {
+	// url is URL of the message endpoint
+	url string
+	// headers authenticate the requests
+	headers http.Header
+	// client sends the requests
+	client *http.Client
+}
+
+// NewGotify creates new Gotify pusher and returns it
+// It reads the following environment variables to configure the pusher:
+// GOTIFY_URL: URL of the server, e.g. https://gotify.example.com; required parameter
+// GOTIFY_TOKEN: application token; required parameter
+// It returns error if any of the required parameters is missing.
+func NewGotify() (*Gotify, error) {
+	url := os.Getenv("GOTIFY_URL")
+	token := os.Getenv("GOTIFY_TOKEN")
+
+	if url == "" || token == "" {
+		return nil, fmt.Errorf("Gotify server URL or application token is empty")
+	}
+
+	return &Gotify{
+		url:     strings.TrimSuffix(url, "/") + "/message",
+		headers: http.Header{"X-Gotify-Key": {token}},
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Push sends notification of state transition of alert a to the server
+func (g *Gotify) Push(a Alert) error {
+	priority := 1
+	if a.State == alertRaised {
+		priority = gotifyPriorities[a.Severity]
+	}
+
+	return postJSON(g.client, g.url, g.headers, map[string]interface{}{
+		"title":    pushTitle(a),
+		"message":  pushMessage(a),
+		"priority": priority,
+	})
+}
+
+// newPusher creates pusher of service and returns it
+func newPusher(service string) (Pusher, error) {
+	switch service {
+	case pushNtfy:
+		return NewNtfy()
+	case pushGotify:
+		return NewGotify()
+	}
+
+	return nil, fmt.Errorf("Unsupported push notification service: %s", service)
+}
+
+// pushRunner pushes notifications of alert state transitions received from alertsChan and routed to push as they happen
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func pushRunner(doneChan <-chan struct{}, alertsChan <-chan Alert, p Pusher) error {
+	for {
+		select {
+		case a := <-alertsChan:
+			if !a.routedTo(sinkPush) || a.Heartbeat {
+				continue
+			}
+			if err := p.Push(a); err != nil {
+				fmt.Printf("Error pushing alert %s notification: %v\n", a.ID, err)
+			}
+		case <-doneChan:
+			fmt.Printf("Stopping pushRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,76 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// faceQuality returns true if face crop bounded by rect is good enough to detect operator status from.
+// Faces which are too small, have unusual aspect ratio or are blurred are typically faces
+// of people in the background or faces in motion and their status is unreliable.
+func faceQuality(crop gocv.Mat, rect image.Rectangle) bool {
+	w, h := rect.Dx(), rect.Dy()
+	if w < faceMinSize || h < faceMinSize {
+		return false
+	}
+
+	if faceMaxAspect > 0 {
+		aspect := float64(w) / float64(h)
+		if aspect < 1 {
+			aspect = 1 / aspect
+		}
+		if aspect > faceMaxAspect {
+			return false
+		}
+	}
+
+	if faceMinSharpness > 0 && sharpness(crop) < faceMinSharpness {
+		return false
+	}
+
+	return true
+}
+
+// sharpness returns sharpness of img measured as variance of its Laplacian; blurred images have low variance
+func sharpness(img gocv.Mat) float64 {
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(img, &gray, gocv.ColorBGRToGray)
+
+	lap := gocv.NewMat()
+	defer lap.Close()
+	gocv.Laplacian(gray, &lap, gocv.MatTypeCV64F, 1, 1, 0, gocv.BorderDefault)
+
+	mean, stdDev := gocv.NewMat(), gocv.NewMat()
+	defer mean.Close()
+	defer stdDev.Close()
+	gocv.MeanStdDev(lap, &mean, &stdDev)
+
+	sd := stdDev.GetDoubleAt(0, 0)
+
+	return sd * sd
+}
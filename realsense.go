@@ -0,0 +1,166 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"gocv.io/x/gocv"
+)
+
+// DepthCapture captures depth frames from the depth stream of Intel RealSense camera
+type DepthCapture struct {
+	// vc is depth stream video capture
+	vc *gocv.VideoCapture
+	// scale is number of meters per depth unit
+	scale float64
+}
+
+// NewDepthCapture opens depth stream of Intel RealSense camera exposed as deviceID video device and returns it.
+// RealSense cameras expose their depth stream as a separate video device with 16-bit depth pixel format.
+// It fails with error if the depth video device can't be opened.
+func NewDepthCapture(deviceID int, scale float64) (*DepthCapture, error) {
+	vc, err := gocv.VideoCaptureDevice(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// we want raw 16-bit depth values rather than RGB converted frames
+	vc.Set(gocv.VideoCaptureConvertRGB, 0)
+
+	return &DepthCapture{
+		vc:    vc,
+		scale: scale,
+	}, nil
+}
+
+// Read reads the next depth frame into img
+func (d *DepthCapture) Read(img *gocv.Mat) bool {
+	return d.vc.Read(img)
+}
+
+// Close closes the depth stream
+func (d *DepthCapture) Close() error {
+	return d.vc.Close()
+}
+
+// FaceDepth stores depth information about detected face
+type FaceDepth struct {
+	// Distance is median distance of the face from the camera in meters
+	Distance float64
+	// Relief is standard deviation of the face depth in meters
+	Relief float64
+}
+
+// faceDepth measures the depth of the face bounded by rect in depth frame.
+// rect is expected in the coordinates of the color frame of size frameSize.
+// It returns false if depth frame contains no valid measurements for the face.
+func faceDepth(depth *gocv.Mat, rect image.Rectangle, frameSize image.Point, scale float64) (*FaceDepth, bool) {
+	if depth == nil || depth.Empty() || frameSize.X == 0 || frameSize.Y == 0 {
+		return nil, false
+	}
+
+	// depth stream might be of different resolution than the color stream
+	sx := float64(depth.Cols()) / float64(frameSize.X)
+	sy := float64(depth.Rows()) / float64(frameSize.Y)
+
+	// only sample the center of the face so we don't measure the background
+	c := image.Rect(
+		int(float64(rect.Min.X+rect.Dx()/4)*sx),
+		int(float64(rect.Min.Y+rect.Dy()/4)*sy),
+		int(float64(rect.Max.X-rect.Dx()/4)*sx),
+		int(float64(rect.Max.Y-rect.Dy()/4)*sy),
+	).Intersect(image.Rect(0, 0, depth.Cols(), depth.Rows()))
+
+	if c.Empty() {
+		return nil, false
+	}
+
+	var values []float64
+	for y := c.Min.Y; y < c.Max.Y; y++ {
+		for x := c.Min.X; x < c.Max.X; x++ {
+			// depth is stored as unsigned 16-bit values; zero means no measurement
+			if v := uint16(depth.GetShortAt(y, x)); v != 0 {
+				values = append(values, float64(v)*scale)
+			}
+		}
+	}
+
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	sort.Float64s(values)
+
+	var mean, variance float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(values))
+
+	return &FaceDepth{
+		Distance: values[len(values)/2],
+		Relief:   math.Sqrt(variance),
+	}, true
+}
+
+// filterFacesByDepth drops the faces which are either too far from the camera or which are flat.
+// Flat faces are typically printed on posters or displayed on screens behind the machine.
+// depth values are converted to meters by scale.
+// It returns the remaining faces and the distance of the closest face or zero if it can't be measured.
+func filterFacesByDepth(depth *gocv.Mat, scale float64, faces []image.Rectangle, frameSize image.Point) ([]image.Rectangle, float64) {
+	var kept []image.Rectangle
+	var closest float64
+
+	for i := range faces {
+		fd, ok := faceDepth(depth, faces[i], frameSize, scale)
+		if !ok {
+			// we can't tell whether the face is real so we keep it
+			kept = append(kept, faces[i])
+			continue
+		}
+
+		if depthMaxDistance > 0 && fd.Distance > depthMaxDistance {
+			continue
+		}
+
+		if fd.Relief < depthMinRelief {
+			continue
+		}
+
+		if closest == 0 || fd.Distance < closest {
+			closest = fd.Distance
+		}
+
+		kept = append(kept, faces[i])
+	}
+
+	return kept, closest
+}
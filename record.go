@@ -0,0 +1,140 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// Recorder records raw video frames into segmented video files
+type Recorder struct {
+	// dir is directory where the segments are stored
+	dir string
+	// codec is FOURCC video codec code
+	codec string
+	// fps is recorded video frame rate
+	fps float64
+	// segment is maximum duration of a single video file
+	segment time.Duration
+	// keep is maximum number of segments kept on disk; zero means keep all
+	keep int
+	// vw writes the currently recorded segment
+	vw *gocv.VideoWriter
+	// started records time when the current segment was started
+	started time.Time
+}
+
+// NewRecorder creates new video recorder which stores video segments in dir and returns it.
+// It fails with error if dir can't be created or if the codec is not a valid FOURCC code.
+func NewRecorder(dir, codec string, fps float64, segment time.Duration, keep int) (*Recorder, error) {
+	if len(codec) != 4 {
+		return nil, fmt.Errorf("Invalid video codec: %s", codec)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Recorder{
+		dir:     dir,
+		codec:   codec,
+		fps:     fps,
+		segment: segment,
+		keep:    keep,
+	}, nil
+}
+
+// Write writes img into the current segment.
+// It starts a new segment when the current one exceeds the maximum segment duration.
+// It returns error if new segment file can't be created or if the frame can't be written.
+func (r *Recorder) Write(img gocv.Mat) error {
+	if r.vw == nil || (r.segment > 0 && time.Since(r.started) > r.segment) {
+		if err := r.rotate(img.Cols(), img.Rows()); err != nil {
+			return err
+		}
+	}
+
+	return r.vw.Write(img)
+}
+
+// rotate closes the current segment, removes old segments and starts a new one
+func (r *Recorder) rotate(width, height int) error {
+	if r.vw != nil {
+		r.vw.Close()
+		r.vw = nil
+	}
+
+	if err := r.prune(); err != nil {
+		return err
+	}
+
+	r.started = time.Now()
+	path := filepath.Join(r.dir, fmt.Sprintf("%s-%s.avi", name, r.started.Format("20060102-150405")))
+
+	vw, err := gocv.VideoWriterFile(path, r.codec, r.fps, width, height, true)
+	if err != nil {
+		return err
+	}
+	r.vw = vw
+
+	return nil
+}
+
+// prune removes the oldest segments so there is room for a new one
+func (r *Recorder) prune() error {
+	if r.keep <= 0 {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(r.dir, name+"-*.avi"))
+	if err != nil {
+		return err
+	}
+	// segment names contain their start time so they sort chronologically
+	sort.Strings(files)
+
+	for len(files) >= r.keep {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+
+	return nil
+}
+
+// Close closes the current segment
+func (r *Recorder) Close() error {
+	if r.vw == nil {
+		return nil
+	}
+
+	return r.vw.Close()
+}
@@ -0,0 +1,194 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// galleryEntry is a face embedding of a known operator
+type galleryEntry struct {
+	// id is operator ID
+	id string
+	// embedding is face embedding vector
+	embedding []float32
+}
+
+// Gallery stores face embeddings of known operators
+type Gallery struct {
+	// entries are known operator face embeddings
+	entries []galleryEntry
+	// threshold is minimum cosine similarity of matching faces
+	threshold float64
+}
+
+// isImage returns true if path has an image file extension
+func isImage(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".bmp":
+		return true
+	default:
+		return false
+	}
+}
+
+// NewGallery reads images of known operators from dir and computes their face embeddings using nets.
+// Operator ID is either the image file name without extension or the name of the subdirectory
+// the image is stored in, which allows for multiple images per operator.
+// It returns error if the directory can't be read or if it contains no usable images.
+func NewGallery(dir string, nets *Nets, threshold float64) (*Gallery, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// images maps image paths to operator IDs
+	images := make(map[string]string)
+	for _, f := range files {
+		path := filepath.Join(dir, f.Name())
+		if !f.IsDir() {
+			if isImage(path) {
+				images[path] = strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))
+			}
+			continue
+		}
+
+		sub, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range sub {
+			if p := filepath.Join(path, s.Name()); !s.IsDir() && isImage(p) {
+				images[p] = f.Name()
+			}
+		}
+	}
+
+	g := &Gallery{
+		threshold: threshold,
+	}
+
+	for path, id := range images {
+		img := gocv.IMRead(path, gocv.IMReadColor)
+		if img.Empty() {
+			fmt.Printf("Skipping gallery image %s: can't read image\n", path)
+			continue
+		}
+
+		// use the largest face in the image or the whole image if no face is found
+		rect := image.Rect(0, 0, img.Cols(), img.Rows())
+		var area int
+		faces, _ := detectFaces(nets.Face, &img, 0)
+		for _, f := range faces {
+			if f.In(rect) && f.Dx()*f.Dy() > area {
+				rect, area = f, f.Dx()*f.Dy()
+			}
+		}
+
+		region := img.Region(rect)
+		crop := gocv.NewMat()
+		region.CopyTo(&crop)
+		region.Close()
+
+		embeddings := faceEmbeddings(nets.ReID, []gocv.Mat{crop})
+		g.entries = append(g.entries, galleryEntry{id: id, embedding: embeddings[0]})
+
+		crop.Close()
+		img.Close()
+	}
+
+	if len(g.entries) == 0 {
+		return nil, fmt.Errorf("No operator images found in gallery: %s", dir)
+	}
+
+	return g, nil
+}
+
+// faceEmbeddings computes embedding vectors of face crops and returns them.
+// The net is expected to be compatible with face-reidentification-retail-0095 model.
+func faceEmbeddings(net Model, crops []gocv.Mat) [][]float32 {
+	blob := gocv.NewMat()
+	defer blob.Close()
+	gocv.BlobFromImages(crops, &blob, 1.0, image.Pt(128, 128),
+		gocv.NewScalar(0, 0, 0, 0), false, false, gocv.MatTypeCV32F)
+
+	// run a forward pass through reidentification network
+	net.SetInput(blob, "")
+	out := net.Forward("")
+	defer out.Close()
+
+	// flatten the result from [N, D, 1, 1] to [N, D]
+	res := out.Reshape(1, len(crops))
+	defer res.Close()
+
+	embeddings := make([][]float32, len(crops))
+	for i := range crops {
+		embeddings[i] = make([]float32, res.Cols())
+		for j := range embeddings[i] {
+			embeddings[i][j] = res.GetFloatAt(i, j)
+		}
+	}
+
+	return embeddings
+}
+
+// cosineSimilarity returns cosine similarity of vectors a and b
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+
+	if na == 0 || nb == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// Identify returns ID of the known operator whose face is the most similar to the embedding.
+// It returns empty string if no known operator is similar enough.
+func (g *Gallery) Identify(embedding []float32) string {
+	var id string
+	best := g.threshold
+	for _, e := range g.entries {
+		if sim := cosineSimilarity(embedding, e.embedding); sim > best {
+			id, best = e.id, sim
+		}
+	}
+
+	return id
+}
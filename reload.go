@@ -0,0 +1,285 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"time"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	"gocv.io/x/gocv"
+)
+
+// modelFiles returns paths to all model files in use
+func modelFiles() []string {
+	var files []string
+	for _, f := range []string{
+		faceModel, faceConfig, sentModel, sentConfig, poseModel, poseConfig,
+		landmarksModel, landmarksConfig, gazeModel, gazeConfig, eyeModel, eyeConfig,
+		ppeModel, ppeConfig, reidModel, reidConfig, maskModel, maskConfig,
+		phoneModel, phoneConfig, personModel, personConfig,
+	} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+
+	return files
+}
+
+// modTimes returns modification times of files; files which can't be accessed are skipped
+func modTimes(files []string) map[string]time.Time {
+	times := make(map[string]time.Time)
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			times[f] = fi.ModTime()
+		}
+	}
+
+	return times
+}
+
+// netProbe is test forward pass of a network with the outputs it must produce
+type netProbe struct {
+	// name is network name used in error messages
+	name string
+	// net is the tested network
+	net Model
+	// size is size of the blank input image
+	size image.Point
+	// layers are names of the checked output layers; nil checks the default output
+	layers []string
+	// check returns true if the outputs have the expected shape; nil only checks they are not empty
+	check func(outs []gocv.Mat) bool
+}
+
+// Names of the networks
+const (
+	netFace      = "Face detection"
+	netSent      = "Sentiment detection"
+	netPose      = "Pose detection"
+	netLandmarks = "Facial landmarks detection"
+	netGaze      = "Gaze estimation"
+	netEyes      = "Eye state detection"
+	netPPE       = "Protective equipment detection"
+	netReID      = "Face reidentification"
+	netMask      = "Face mask detection"
+	netPhone     = "Phone detection"
+	netPerson    = "Person detection"
+)
+
+// probe returns test forward pass of network net called name.
+// The gaze estimation network takes several inputs, so it is only checked for being empty.
+func probe(name string, net Model) netProbe {
+	p := netProbe{name: name, net: net}
+	switch name {
+	case netFace:
+		p.size = image.Pt(672, 384)
+		p.check = func(outs []gocv.Mat) bool {
+			// detections are stored in rows of 7 values
+			return outs[0].Total()%7 == 0
+		}
+	case netSent:
+		p.size = image.Pt(64, 64)
+		p.check = func(outs []gocv.Mat) bool {
+			return outs[0].Total() == len(sentLabels)
+		}
+	case netPose:
+		p.size, p.layers = image.Pt(60, 60), poseLayers
+		p.check = func(outs []gocv.Mat) bool {
+			return len(outs) == 3 && outs[0].Total() == 1 && outs[1].Total() == 1 && outs[2].Total() == 1
+		}
+	case netLandmarks:
+		p.size = image.Pt(48, 48)
+	case netEyes:
+		p.size = image.Pt(32, 32)
+	case netPPE, netPhone:
+		p.size = image.Pt(300, 300)
+	case netReID:
+		p.size = image.Pt(128, 128)
+	case netMask:
+		p.size = image.Pt(224, 224)
+	case netPerson:
+		p.size = image.Pt(544, 320)
+	}
+
+	return p
+}
+
+// netProbes returns test forward passes of all networks of n
+func netProbes(n *Nets) []netProbe {
+	return []netProbe{
+		probe(netFace, n.Face), probe(netSent, n.Sent), probe(netPose, n.Pose),
+		probe(netLandmarks, n.Landmarks), probe(netGaze, n.Gaze), probe(netEyes, n.Eyes), probe(netPPE, n.PPE),
+		probe(netReID, n.ReID), probe(netMask, n.Mask), probe(netPhone, n.Phone), probe(netPerson, n.Person),
+	}
+}
+
+// run runs the test forward pass and returns error if the network is empty or produces unexpected outputs.
+// OpenCV doesn't report failed forward passes other than by returning empty outputs, so the outputs are checked.
+func (p netProbe) run() error {
+	if p.net.Empty() {
+		return fmt.Errorf("%s network is empty", p.name)
+	}
+	if p.size == (image.Point{}) {
+		return nil
+	}
+
+	img := gocv.NewMatWithSize(p.size.Y, p.size.X, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	blob := gocv.BlobFromImage(img, 1.0, p.size, gocv.NewScalar(0, 0, 0, 0), false, false)
+	defer blob.Close()
+
+	p.net.SetInput(blob, "")
+	var outs []gocv.Mat
+	if p.layers == nil {
+		outs = []gocv.Mat{p.net.Forward("")}
+	} else {
+		outs = p.net.ForwardLayers(p.layers)
+	}
+	defer func() {
+		for i := range outs {
+			outs[i].Close()
+		}
+	}()
+
+	if f, ok := p.net.(failer); ok {
+		if err := f.Err(); err != nil {
+			return fmt.Errorf("%s network failed: %v", p.name, err)
+		}
+	}
+	for i := range outs {
+		if outs[i].Empty() {
+			return fmt.Errorf("%s network produced no output", p.name)
+		}
+	}
+	if len(outs) == 0 || (p.check != nil && !p.check(outs)) {
+		return fmt.Errorf("%s network produced output of unexpected shape", p.name)
+	}
+
+	return nil
+}
+
+// validateNets checks that all nets are loaded and runs a test forward pass of a blank image through every network,
+// checking the shapes of the outputs. It returns error if any of the networks fails the test.
+func validateNets(nets []*Nets) error {
+	for i := range nets {
+		for _, p := range netProbes(nets[i]) {
+			if p.net == nil {
+				continue
+			}
+			if err := p.run(); err != nil {
+				return fmt.Errorf("Test forward pass failed: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// newReloadHandler returns MQTT message handler which requests the models to be reloaded
+func newReloadHandler(triggerChan chan<- struct{}) MQTT.MessageHandler {
+	return func(c MQTT.Client, msg MQTT.Message) {
+		select {
+		case triggerChan <- struct{}{}:
+		default:
+			fmt.Printf("Ignoring model reload request: another reload is in progress\n")
+		}
+	}
+}
+
+// reloadRunner reloads the models whenever a request is received on triggerChan or, if interval is positive,
+// whenever any of the model files changes. New models are validated with a test forward pass
+// before they are sent down the reloadChan to replace the models in use.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func reloadRunner(doneChan <-chan struct{}, triggerChan <-chan struct{}, reloadChan chan<- []*Nets, interval time.Duration) error {
+	// tick stays nil, i.e. never fires, if model files are not watched
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	times := modTimes(modelFiles())
+
+	for {
+		select {
+		case <-doneChan:
+			fmt.Printf("Stopping reloadRunner: received stop signal\n")
+			return nil
+		case <-tick:
+			current := modTimes(modelFiles())
+			var changed bool
+			for f, t := range current {
+				if !t.Equal(times[f]) {
+					changed = true
+				}
+			}
+			if !changed {
+				continue
+			}
+			// wait for the next tick in case the files are still being written
+			times = current
+			select {
+			case <-doneChan:
+				fmt.Printf("Stopping reloadRunner: received stop signal\n")
+				return nil
+			case <-time.After(interval):
+			}
+			times = modTimes(modelFiles())
+		case <-triggerChan:
+			times = modTimes(modelFiles())
+		}
+
+		fmt.Printf("Reloading models\n")
+		nets, err := LoadNets(asyncRequests, backend, target)
+		if err != nil {
+			fmt.Printf("Error reloading models: %v\n", err)
+			continue
+		}
+
+		// the new models are validated by the first warm-up pass
+		if err := warmUpNets(nets, warmup+1); err != nil {
+			fmt.Printf("Error reloading models: %v\n", err)
+			for i := range nets {
+				nets[i].Close()
+			}
+			continue
+		}
+
+		select {
+		case reloadChan <- nets:
+			fmt.Printf("Models reloaded\n")
+		case <-doneChan:
+			for i := range nets {
+				nets[i].Close()
+			}
+			fmt.Printf("Stopping reloadRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
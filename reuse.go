@@ -0,0 +1,120 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"image"
+	"sync"
+	"time"
+)
+
+// faceInference is head pose and sentiment inferred from a face
+type faceInference struct {
+	// rect is the face bounding box
+	rect image.Rectangle
+	// ts is time when the inference ran
+	ts time.Time
+	// yaw, pitch and roll are head pose angles in degrees
+	yaw, pitch, roll float64
+	// class is the most likely sentiment model output class
+	class int
+	// confidence is confidence of the most likely class
+	confidence float32
+	// probs are probabilities of all sentiment model output classes
+	probs []float32
+	// sentChecked means sentiment was inferred
+	sentChecked bool
+}
+
+// InferenceCache stores recent face inferences so that faces which haven't moved can reuse them.
+// It is safe for concurrent use by multiple inference goroutines.
+type InferenceCache struct {
+	// mu protects entries
+	mu sync.Mutex
+	// entries are the cached inferences
+	entries []*faceInference
+	// minIoU is minimum overlap of face bounding boxes for the face to be considered not moving
+	minIoU float64
+	// maxAge is maximum age of reused inference
+	maxAge time.Duration
+}
+
+// NewInferenceCache creates new inference cache and returns it
+func NewInferenceCache(minIoU float64, maxAge time.Duration) *InferenceCache {
+	return &InferenceCache{
+		minIoU: minIoU,
+		maxAge: maxAge,
+	}
+}
+
+// Lookup returns cached inference of the face bounded by rect at time ts which overlaps it the most.
+// If needSent is true, only inferences including sentiment are returned.
+// It returns nil if no inference younger than maxAge overlaps rect enough.
+func (c *InferenceCache) Lookup(rect image.Rectangle, ts time.Time, needSent bool) *faceInference {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var best *faceInference
+	var bestIoU float64
+	for _, e := range c.entries {
+		if ts.Sub(e.ts) > c.maxAge || (needSent && !e.sentChecked) {
+			continue
+		}
+		if v := iou(rect, e.rect); v >= c.minIoU && v > bestIoU {
+			best, bestIoU = e, v
+		}
+	}
+
+	return best
+}
+
+// Store caches inferences which ran at time ts and drops the expired ones and the ones they replace
+func (c *InferenceCache) Store(inferences []*faceInference, ts time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// replaced returns true if e is inference of a face which is inferred again
+	replaced := func(e *faceInference) bool {
+		for _, inf := range inferences {
+			if iou(e.rect, inf.rect) >= c.minIoU {
+				return true
+			}
+		}
+		return false
+	}
+
+	entries := c.entries[:0]
+	for _, e := range c.entries {
+		if ts.Sub(e.ts) <= c.maxAge && !replaced(e) {
+			entries = append(entries, e)
+		}
+	}
+
+	for _, inf := range inferences {
+		inf.ts = ts
+		entries = append(entries, inf)
+	}
+
+	c.entries = entries
+}
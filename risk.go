@@ -0,0 +1,159 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// riskMaxEpisodes is number of anger episodes within the risk window which maximizes anger risk
+const riskMaxEpisodes = 3
+
+// riskWeights are weights of inattention, anger, drowsiness and time on station in the risk score
+var riskWeights [4]float64
+
+// parseRiskWeights parses comma separated weights of inattention, anger, drowsiness and time on station
+func parseRiskWeights(s string) ([4]float64, error) {
+	var weights [4]float64
+	labels := parseLabels(s)
+	if len(labels) != len(weights) {
+		return weights, fmt.Errorf("Invalid risk weights: %s: expected %d weights", s, len(weights))
+	}
+
+	var sum float64
+	for i, l := range labels {
+		w, err := strconv.ParseFloat(l, 64)
+		if err != nil || w < 0 {
+			return weights, fmt.Errorf("Invalid risk weight: %s", l)
+		}
+		weights[i] = w
+		sum += w
+	}
+	if sum == 0 {
+		return weights, fmt.Errorf("Invalid risk weights: %s: at least one weight must be positive", s)
+	}
+
+	return weights, nil
+}
+
+// attentionSample records whether operator was watching the machine at a given time
+type attentionSample struct {
+	ts       time.Time
+	watching bool
+}
+
+// RiskScore combines inattention, anger episodes, drowsiness and time on station into a single score
+// measured over a rolling time window
+type RiskScore struct {
+	// window is duration of the rolling time window
+	window time.Duration
+	// samples are attention samples within the window
+	samples []attentionSample
+	// episodes are start times of anger episodes within the window
+	episodes []time.Time
+	// angry means operator was angry in the latest sample
+	angry bool
+	// onStation is time when operator arrived at the station
+	onStation time.Time
+}
+
+// NewRiskScore creates new risk score measured over window and returns it
+func NewRiskScore(window time.Duration) *RiskScore {
+	return &RiskScore{
+		window: window,
+	}
+}
+
+// Add records operator attention and anger at time ts and drops the samples which fell out of the window
+func (r *RiskScore) Add(ts time.Time, watching, angry bool) {
+	if r.onStation.IsZero() {
+		r.onStation = ts
+	}
+	r.samples = append(r.samples, attentionSample{ts: ts, watching: watching})
+	if angry && !r.angry {
+		r.episodes = append(r.episodes, ts)
+	}
+	r.angry = angry
+
+	var i int
+	for i < len(r.samples) && ts.Sub(r.samples[i].ts) > r.window {
+		i++
+	}
+	r.samples = r.samples[i:]
+
+	var j int
+	for j < len(r.episodes) && ts.Sub(r.episodes[j]) > r.window {
+		j++
+	}
+	r.episodes = r.episodes[j:]
+}
+
+// Leave records operator left the station so their time on station starts over when they return
+func (r *RiskScore) Leave() {
+	r.onStation = time.Time{}
+}
+
+// Score returns risk score between 0 and 1 at time ts given drowsiness, i.e. ratio of time operator eyes are closed
+func (r *RiskScore) Score(ts time.Time, drowsiness float64) float64 {
+	var inattention float64
+	if len(r.samples) > 0 {
+		var away int
+		for _, s := range r.samples {
+			if !s.watching {
+				away++
+			}
+		}
+		inattention = float64(away) / float64(len(r.samples))
+	}
+
+	anger := float64(len(r.episodes)) / riskMaxEpisodes
+	if anger > 1 {
+		anger = 1
+	}
+
+	var station float64
+	if !r.onStation.IsZero() && riskStationTime > 0 {
+		station = float64(ts.Sub(r.onStation)) / float64(riskStationTime)
+		if station > 1 {
+			station = 1
+		}
+	}
+
+	var score, sum float64
+	for i, v := range []float64{inattention, anger, drowsiness, station} {
+		score += riskWeights[i] * v
+		sum += riskWeights[i]
+	}
+
+	return score / sum
+}
+
+// Reset drops all the samples and starts the measurement over
+func (r *RiskScore) Reset() {
+	r.samples, r.episodes = nil, nil
+	r.angry = false
+	r.onStation = time.Time{}
+}
@@ -0,0 +1,165 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/antonmedv/expr"
+	"github.com/antonmedv/expr/vm"
+)
+
+// Rule is custom alert rule defined by an expression over the detected operator status
+type Rule struct {
+	// Name is rule name; it is the type of the alerts the rule raises
+	Name string `json:"name"`
+	// Expr is boolean expression which holds while the alert condition lasts
+	Expr string `json:"expr"`
+	// For is time the expression must hold for before the alert is raised, e.g. 10s
+	For string `json:"for"`
+	// Message is text displayed when the alert is raised; defaults to the rule name
+	Message string `json:"message"`
+	// hold is parsed For
+	hold time.Duration
+	// program is compiled Expr
+	program *vm.Program
+}
+
+// alertRules are custom alert rules loaded from rules file
+var alertRules []*Rule
+
+// ruleEnv returns variables the rule expressions are evaluated over.
+// Operator status s is detected from the latest frame, while watching and angry are smoothed over several frames.
+func ruleEnv(s *Status, watching, angry bool) map[string]interface{} {
+	env := map[string]interface{}{
+		"watching":    watching,
+		"angry":       angry,
+		"sentiment":   UNKNOWN.String(),
+		"yaw":         0.0,
+		"pitch":       0.0,
+		"roll":        0.0,
+		"masked":      false,
+		"distance":    s.Distance,
+		"operator":    s.OperatorID,
+		"faces":       len(s.Faces),
+		"zone_faces":  s.ZoneFaces,
+		"eyes_closed": s.EyesClosed,
+		"phone":       s.UsingPhone,
+		"missing_ppe": s.MissingPPE,
+		"present":     s.checked || s.Present,
+	}
+
+	// face attributes are taken from the first face
+	if len(s.Faces) > 0 {
+		f := s.Faces[0]
+		env["sentiment"] = f.Sentiment.String()
+		env["yaw"], env["pitch"], env["roll"] = f.Yaw, f.Pitch, f.Roll
+		env["masked"] = f.Masked
+	}
+
+	return env
+}
+
+// loadRules reads alert rules from JSON file at path, compiles them and returns them.
+// It returns error if any rule is invalid or if its name clashes with another alert type.
+func loadRules(path string) ([]*Rule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+
+	types := alertTypes(new(Result))
+	env := ruleEnv(new(Status), false, false)
+	for _, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule name is empty")
+		}
+		if _, ok := types[r.Name]; ok {
+			return nil, fmt.Errorf("rule %s: name clashes with another alert type", r.Name)
+		}
+		types[r.Name] = false
+
+		if r.For != "" {
+			if r.hold, err = time.ParseDuration(r.For); err != nil || r.hold < 0 {
+				return nil, fmt.Errorf("rule %s: invalid duration: %s", r.Name, r.For)
+			}
+		}
+
+		if r.program, err = expr.Compile(r.Expr, expr.Env(env), expr.AsBool()); err != nil {
+			return nil, fmt.Errorf("rule %s: %v", r.Name, err)
+		}
+
+		if r.Message == "" {
+			r.Message = r.Name
+		}
+	}
+
+	return rules, nil
+}
+
+// Eval evaluates the rule expression over env and returns its result
+func (r *Rule) Eval(env map[string]interface{}) (bool, error) {
+	out, err := expr.Run(r.program, env)
+	if err != nil {
+		return false, err
+	}
+
+	return out.(bool), nil
+}
+
+// updateRules evaluates custom alert rules over status at time ts and raises the alerts in result
+// of the rules which held for longer than their durations
+func (op *Operator) updateRules(ts time.Time, status *Status, result *Result) {
+	if len(op.timeStartRules) != len(alertRules) {
+		op.timeStartRules = make([]time.Time, len(alertRules))
+	}
+
+	env := ruleEnv(status, op.now.IsWatching, op.now.IsAngry)
+	alerts := result.ruleAlerts()
+	for i, r := range alertRules {
+		holds, err := r.Eval(env)
+		if err != nil {
+			fmt.Printf("Error evaluating alert rule %s: %v\n", r.Name, err)
+		}
+
+		if !holds {
+			op.timeStartRules[i] = time.Time{}
+			alerts[i] = false
+			continue
+		}
+
+		if op.timeStartRules[i].IsZero() {
+			op.timeStartRules[i] = ts
+		}
+		alerts[i] = ts.Sub(op.timeStartRules[i]) >= r.hold
+	}
+}
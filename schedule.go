@@ -0,0 +1,216 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// scheduleInterval is interval in which the shift schedule is checked
+	scheduleInterval = 10 * time.Second
+)
+
+// weekdays maps weekday abbreviations to weekdays
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// shift is shift time window which repeats on selected weekdays
+type shift struct {
+	// days marks weekdays the shift starts on
+	days [7]bool
+	// start is time since midnight when the shift starts
+	start time.Duration
+	// end is time since midnight when the shift ends; shifts which end before they start span midnight
+	end time.Duration
+}
+
+// Schedule is shift schedule; monitoring is active only during the shifts
+type Schedule []shift
+
+// shiftSchedule is shift schedule parsed from command line; empty schedule keeps monitoring always active
+var shiftSchedule Schedule
+
+// parseSchedule parses comma separated shifts in "days hh:mm-hh:mm" format, e.g. mon-fri 06:00-22:00,sat 08:00-12:00.
+// Days are either a single weekday, a range of weekdays or * for every day.
+func parseSchedule(s string) (Schedule, error) {
+	var schedule Schedule
+	for _, w := range parseLabels(s) {
+		fields := strings.Fields(w)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Invalid shift: %s", w)
+		}
+
+		var sh shift
+		if err := sh.parseDays(strings.ToLower(fields[0])); err != nil {
+			return nil, err
+		}
+
+		times := strings.SplitN(fields[1], "-", 2)
+		if len(times) != 2 {
+			return nil, fmt.Errorf("Invalid shift time: %s", fields[1])
+		}
+		var err error
+		if sh.start, err = parseClock(times[0]); err != nil {
+			return nil, err
+		}
+		if sh.end, err = parseClock(times[1]); err != nil {
+			return nil, err
+		}
+		if sh.start == sh.end {
+			return nil, fmt.Errorf("Invalid shift time: %s: shift is empty", fields[1])
+		}
+
+		schedule = append(schedule, sh)
+	}
+
+	return schedule, nil
+}
+
+// parseDays parses days the shift starts on
+func (sh *shift) parseDays(s string) error {
+	if s == "*" {
+		for i := range sh.days {
+			sh.days[i] = true
+		}
+		return nil
+	}
+
+	bounds := strings.SplitN(s, "-", 2)
+	first, ok := weekdays[bounds[0]]
+	if !ok {
+		return fmt.Errorf("Invalid weekday: %s", bounds[0])
+	}
+	last := first
+	if len(bounds) == 2 {
+		if last, ok = weekdays[bounds[1]]; !ok {
+			return fmt.Errorf("Invalid weekday: %s", bounds[1])
+		}
+	}
+
+	// ranges may wrap around the end of the week, e.g. sat-sun
+	for d := first; ; d = (d + 1) % 7 {
+		sh.days[d] = true
+		if d == last {
+			break
+		}
+	}
+
+	return nil
+}
+
+// parseClock parses time of day in hh:mm format and returns time since midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid time of day: %s", s)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Active returns true if t falls into any of the shifts
+func (s Schedule) Active(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	since := t.Sub(midnight)
+	today, yesterday := t.Weekday(), (t.Weekday()+6)%7
+
+	for _, sh := range s {
+		if sh.start < sh.end {
+			if sh.days[today] && since >= sh.start && since < sh.end {
+				return true
+			}
+			continue
+		}
+		// shift spans midnight
+		if (sh.days[today] && since >= sh.start) || (sh.days[yesterday] && since < sh.end) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scheduleEvent is monitoring suspended or resumed event
+type scheduleEvent struct {
+	// Event is either suspended or resumed
+	Event string `json:"event"`
+	// Time is time of the event
+	Time time.Time `json:"time"`
+}
+
+// scheduleRunner suspends the monitoring outside of the shifts in schedule and resumes it when a shift starts
+// by sending commands down the cmdChan. The events are published to scheduleTopic if c is not nil.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func scheduleRunner(doneChan <-chan struct{}, cmdChan chan<- *command, c *MQTTClient, schedule Schedule) error {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	// monitoring is active when the program starts
+	active := true
+	for now := time.Now(); ; {
+		if a := schedule.Active(now); a != active {
+			cmd, event := &command{name: cmdUnsuspend}, "resumed"
+			if !a {
+				cmd, event = &command{name: cmdSuspend}, "suspended"
+			}
+
+			select {
+			case cmdChan <- cmd:
+			case <-doneChan:
+				fmt.Printf("Stopping scheduleRunner: received stop signal\n")
+				return nil
+			}
+			active = a
+
+			fmt.Printf("Monitoring %s by shift schedule\n", event)
+			if c != nil {
+				msg, err := json.Marshal(scheduleEvent{Event: event, Time: now})
+				if err != nil {
+					return err
+				}
+				if _, err := c.Publish(scheduleTopic, string(msg)); err != nil {
+					fmt.Printf("Error publishing message to %s: %v\n", scheduleTopic, err)
+				}
+			}
+		}
+
+		select {
+		case now = <-ticker.C:
+		case <-doneChan:
+			fmt.Printf("Stopping scheduleRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"os"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// defaultSentLabels maps output classes of emotions-recognition-retail-0003 model to sentiments
+var defaultSentLabels = []Sentiment{NEUTRAL, HAPPY, SAD, SURPRISED, ANGRY}
+
+// parseSentiment parses sentiment name s and returns it
+func parseSentiment(s string) (Sentiment, error) {
+	for _, v := range []Sentiment{NEUTRAL, HAPPY, SAD, SURPRISED, ANGRY, UNKNOWN} {
+		if strings.EqualFold(s, v.String()) {
+			return v, nil
+		}
+	}
+
+	return UNKNOWN, fmt.Errorf("Invalid sentiment: %s", s)
+}
+
+// loadSentLabels reads sentiment labels from file at path and returns them.
+// The file lists one sentiment per line in the order of sentiment model output classes;
+// empty lines and lines starting with # are ignored.
+func loadSentLabels(path string) ([]Sentiment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var labels []Sentiment
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		s, err := parseSentiment(line)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, s)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(labels) == 0 {
+		return nil, fmt.Errorf("No sentiment labels found in %s", path)
+	}
+
+	return labels, nil
+}
+
+// sentiment returns sentiment of model output class i
+func sentiment(i int) Sentiment {
+	if i < 0 || i >= len(sentLabels) {
+		return UNKNOWN
+	}
+
+	return sentLabels[i]
+}
+
+// classifySentiments propagates face crops forward through sentiment network
+// and returns the most likely output class, its confidence and the probabilities of all classes for every crop
+func classifySentiments(net Model, crops []gocv.Mat) ([]int, []float32, [][]float32) {
+	blob := gocv.NewMat()
+	defer blob.Close()
+	gocv.BlobFromImages(crops, &blob, 1.0, image.Pt(64, 64),
+		gocv.NewScalar(0, 0, 0, 0), false, false, gocv.MatTypeCV32F)
+
+	// run a forward pass through sentiment network
+	net.SetInput(blob, "")
+	out := net.Forward("")
+	defer out.Close()
+
+	// flatten the result from [N, 5, 1, 1] to [N, 5]
+	res := out.Reshape(1, len(crops))
+	defer res.Close()
+
+	classes := make([]int, len(crops))
+	confidences := make([]float32, len(crops))
+	probs := make([][]float32, len(crops))
+	for i := range crops {
+		// find the most likely mood in returned list of sentiments
+		row := res.Region(image.Rect(0, i, res.Cols(), i+1))
+		_, confidence, _, maxLoc := gocv.MinMaxLoc(row)
+		row.Close()
+		classes[i], confidences[i] = maxLoc.X, confidence
+
+		probs[i] = make([]float32, res.Cols())
+		for j := range probs[i] {
+			probs[i][j] = res.GetFloatAt(i, j)
+		}
+	}
+
+	return classes, confidences, probs
+}
@@ -0,0 +1,126 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// severityInfo is severity of informational alerts
+	severityInfo = "info"
+	// severityWarning is severity of warning alerts
+	severityWarning = "warning"
+	// severityCritical is severity of critical alerts
+	severityCritical = "critical"
+)
+
+// severityColors maps alert severities to colors they are displayed in
+var severityColors = map[string]color.RGBA{
+	severityInfo:     {255, 255, 0, 0},
+	severityWarning:  {255, 128, 0, 0},
+	severityCritical: {255, 0, 0, 0},
+}
+
+// severityLevels lists alert severities from the lowest
+var severityLevels = []string{severityInfo, severityWarning, severityCritical}
+
+// severityRank returns rank of severity s; higher severities have higher ranks and unknown ones rank -1
+func severityRank(s string) int {
+	for i, level := range severityLevels {
+		if s == level {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// severityRule assigns severity to alert which has been raised for at least after
+type severityRule struct {
+	// after is time since the alert was raised
+	after time.Duration
+	// severity is the assigned severity
+	severity string
+}
+
+// severityRules stores severity rules of alert types sorted by time since the alert was raised
+var severityRules = map[string][]severityRule{}
+
+// parseSeverities parses comma separated severity rules in type=severity[:after] format, e.g.
+// watching=warning,watching=critical:30s and returns them by alert types
+func parseSeverities(s string) (map[string][]severityRule, error) {
+	rules := make(map[string][]severityRule)
+	for _, r := range parseLabels(s) {
+		kv := strings.SplitN(r, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid severity rule: %s", r)
+		}
+
+		typ := strings.TrimSpace(kv[0])
+		if _, ok := alertTypes(new(Result))[typ]; !ok {
+			return nil, fmt.Errorf("Invalid alert type: %s", typ)
+		}
+
+		parts := strings.SplitN(kv[1], ":", 2)
+		rule := severityRule{severity: strings.ToLower(strings.TrimSpace(parts[0]))}
+		if _, ok := severityColors[rule.severity]; !ok {
+			return nil, fmt.Errorf("Invalid severity: %s", parts[0])
+		}
+
+		if len(parts) == 2 {
+			after, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+			if err != nil || after < 0 {
+				return nil, fmt.Errorf("Invalid severity threshold: %s", parts[1])
+			}
+			rule.after = after
+		}
+
+		rules[typ] = append(rules[typ], rule)
+	}
+
+	for typ := range rules {
+		r := rules[typ]
+		sort.Slice(r, func(i, j int) bool { return r[i].after < r[j].after })
+	}
+
+	return rules, nil
+}
+
+// severity returns severity of alert of type typ which has been raised for elapsed time.
+// Alerts without matching rule are warnings.
+func severity(typ string, elapsed time.Duration) string {
+	s := severityWarning
+	for _, r := range severityRules[typ] {
+		if elapsed >= r.after {
+			s = r.severity
+		}
+	}
+
+	return s
+}
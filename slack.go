@@ -0,0 +1,264 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// slackAPI is URL of Slack Web API
+const slackAPI = "https://slack.com/api/"
+
+// slackEmoji are emoji prepended to Slack messages of alert state transitions
+var slackEmoji = map[string]string{
+	alertRaised:       ":rotating_light:",
+	alertAcknowledged: ":eyes:",
+	alertCleared:      ":white_check_mark:",
+	alertSnoozed:      ":zzz:",
+}
+
+// slackResponse is response of Slack Web API method
+type slackResponse struct {
+	// OK means the method succeeded
+	OK bool `json:"ok"`
+	// Error is error code if the method failed
+	Error string `json:"error"`
+	// TS is timestamp identifying posted message
+	TS string `json:"ts"`
+	// UploadURL is URL the uploaded file is posted to
+	UploadURL string `json:"upload_url"`
+	// FileID is ID of the uploaded file
+	FileID string `json:"file_id"`
+}
+
+// Slack posts alerts to Slack channel either through incoming webhook or as bot user.
+// Bot posts state transitions of an alert to a thread started by the raised alert and uploads its snapshot.
+type Slack struct {
+	// webhook is incoming webhook URL; empty if the bot token is used
+	webhook string
+	// token is bot user OAuth token; empty if the incoming webhook is used
+	token string
+	// channel is ID of the channel the bot posts to
+	channel string
+	// client sends the requests
+	client *http.Client
+	// threads are timestamps of the messages which started threads of alerts by alert IDs
+	threads map[string]string
+}
+
+// NewSlack creates new Slack client and returns it
+// It reads the following environment variables to configure the client:
+// SLACK_WEBHOOK_URL: incoming webhook URL; required unless SLACK_BOT_TOKEN is set
+// SLACK_BOT_TOKEN: bot user OAuth token with chat:write and files:write scopes; not required
+// SLACK_CHANNEL: ID of the channel the bot posts to; required if SLACK_BOT_TOKEN is set
+// It returns error if neither the webhook URL nor the bot token and channel are set.
+func NewSlack() (*Slack, error) {
+	webhook := os.Getenv("SLACK_WEBHOOK_URL")
+	token := os.Getenv("SLACK_BOT_TOKEN")
+	channel := os.Getenv("SLACK_CHANNEL")
+
+	if token != "" && channel == "" {
+		return nil, fmt.Errorf("Slack channel is empty")
+	}
+
+	if token == "" && webhook == "" {
+		return nil, fmt.Errorf("Slack webhook URL and bot token are empty")
+	}
+
+	if token == "" {
+		if _, err := url.ParseRequestURI(webhook); err != nil {
+			return nil, fmt.Errorf("Invalid Slack webhook URL: %v", err)
+		}
+	}
+
+	return &Slack{
+		webhook: webhook,
+		token:   token,
+		channel: channel,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		threads: make(map[string]string),
+	}, nil
+}
+
+// slackText returns text of Slack message about state transition of alert a
+func slackText(a Alert) string {
+	machine := ""
+	if machineID != "" {
+		machine = fmt.Sprintf(" on *%s*", machineID)
+		if cameraID != "" {
+			machine = fmt.Sprintf(" on *%s* (camera %s)", machineID, cameraID)
+		}
+	}
+
+	return fmt.Sprintf("%s *%s* %s alert %s%s at %s: %s\nID: `%s`",
+		slackEmoji[a.State], a.Severity, a.Type, a.State, machine,
+		eventTime(a).Format("15:04:05 MST"), msg("alert."+a.Type), a.ID)
+}
+
+// call calls Slack Web API method with params and returns its response
+func (s *Slack) call(method string, params url.Values) (*slackResponse, error) {
+	req, err := http.NewRequest(http.MethodPost, slackAPI+method, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s: unexpected status: %s", method, resp.Status)
+	}
+
+	r := &slackResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(r); err != nil {
+		return nil, fmt.Errorf("%s: %v", method, err)
+	}
+	if !r.OK {
+		return nil, fmt.Errorf("%s: %s", method, r.Error)
+	}
+
+	return r, nil
+}
+
+// post posts body to url
+func (s *Slack) post(url, contentType string, body []byte) error {
+	resp, err := s.client.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// upload uploads JPEG image as file name with title to thread ts of the channel
+func (s *Slack) upload(name, title string, image []byte, ts string) error {
+	r, err := s.call("files.getUploadURLExternal", url.Values{
+		"filename": {name},
+		"length":   {strconv.Itoa(len(image))},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.post(r.UploadURL, "image/jpeg", image); err != nil {
+		return fmt.Errorf("uploading %s: %v", name, err)
+	}
+
+	files, err := json.Marshal([]map[string]string{{"id": r.FileID, "title": title}})
+	if err != nil {
+		return err
+	}
+	params := url.Values{
+		"files":      {string(files)},
+		"channel_id": {s.channel},
+	}
+	if ts != "" {
+		params.Set("thread_ts", ts)
+	}
+	_, err = s.call("files.completeUploadExternal", params)
+
+	return err
+}
+
+// Post posts state transition of alert a to the channel along with JPEG snapshot image unless it is empty.
+// The snapshot is only posted by the bot as incoming webhooks can't upload files.
+func (s *Slack) Post(a Alert, image []byte) error {
+	text := slackText(a)
+
+	if s.token == "" {
+		body, err := json.Marshal(map[string]string{"text": text})
+		if err != nil {
+			return err
+		}
+		return s.post(s.webhook, "application/json", body)
+	}
+
+	params := url.Values{
+		"channel": {s.channel},
+		"text":    {text},
+	}
+	thread, ok := s.threads[a.ID]
+	if ok {
+		params.Set("thread_ts", thread)
+	}
+	r, err := s.call("chat.postMessage", params)
+	if err != nil {
+		return err
+	}
+
+	if a.State == alertCleared {
+		delete(s.threads, a.ID)
+	} else if !ok {
+		thread = r.TS
+		s.threads[a.ID] = thread
+	}
+
+	if len(image) == 0 {
+		return nil
+	}
+
+	return s.upload(a.ID+".jpg", msg("alert."+a.Type), image, thread)
+}
+
+// slackRunner posts alert state transitions received from alertsChan and routed to Slack as they happen.
+// Snapshots of raised alerts are posted along with them if attach is true.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func slackRunner(doneChan <-chan struct{}, alertsChan <-chan Alert, s *Slack, attach bool) error {
+	for {
+		select {
+		case a := <-alertsChan:
+			if !a.routedTo(sinkSlack) || a.Heartbeat {
+				continue
+			}
+			var image []byte
+			if attach {
+				image = readSnapshot(a)
+			}
+			if err := s.Post(a, image); err != nil {
+				fmt.Printf("Error posting alert %s to Slack: %v\n", a.ID, err)
+			}
+		case <-doneChan:
+			fmt.Printf("Stopping slackRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,62 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+// MajorityVote smooths a noisy binary classification by majority vote over a sliding window of frames
+type MajorityVote struct {
+	// size is number of frames in the window
+	size int
+	// samples are classification results within the window
+	samples []bool
+}
+
+// NewMajorityVote creates new majority vote over window of size frames and returns it
+func NewMajorityVote(size int) *MajorityVote {
+	return &MajorityVote{
+		size: size,
+	}
+}
+
+// Add records classification result v, drops the results which fell out of the window
+// and returns true if more than half of the results within the window are true
+func (m *MajorityVote) Add(v bool) bool {
+	m.samples = append(m.samples, v)
+	if len(m.samples) > m.size {
+		m.samples = m.samples[len(m.samples)-m.size:]
+	}
+
+	var count int
+	for _, s := range m.samples {
+		if s {
+			count++
+		}
+	}
+
+	return count*2 > len(m.samples)
+}
+
+// Reset drops all the results
+func (m *MajorityVote) Reset() {
+	m.samples = nil
+}
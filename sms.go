@@ -0,0 +1,214 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// twilioAPI is URL of Twilio REST API
+const twilioAPI = "https://api.twilio.com/2010-04-01/Accounts/"
+
+// smsRecipient is phone number alerts are texted to during its shifts
+type smsRecipient struct {
+	// number is phone number in E.164 format
+	number string
+	// shifts are shifts of the recipient; empty shifts mean the recipient is texted any time
+	shifts Schedule
+}
+
+// parseSMSRecipients parses comma separated phone numbers in number[@days hh:mm-hh:mm] format,
+// e.g. +15551230001@mon-fri 06:00-14:00,+15551230002@mon-fri 14:00-22:00,+15551230003.
+// Numbers without shift are texted any time.
+func parseSMSRecipients(s string) ([]smsRecipient, error) {
+	var recipients []smsRecipient
+	for _, r := range parseLabels(s) {
+		parts := strings.SplitN(r, "@", 2)
+		number := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(number, "+") || len(number) < 8 || strings.Trim(number[1:], "0123456789") != "" {
+			return nil, fmt.Errorf("Invalid SMS phone number: %s", number)
+		}
+
+		recipient := smsRecipient{number: number}
+		if len(parts) == 2 {
+			shifts, err := parseSchedule(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			recipient.shifts = shifts
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, nil
+}
+
+// Texter texts alerts through Twilio
+type Texter struct {
+	// sid is Twilio account SID
+	sid string
+	// token is Twilio auth token
+	token string
+	// from is sender phone number
+	from string
+	// recipients are recipients of the texts
+	recipients []smsRecipient
+	// dailyCap is maximum number of texts sent per day; zero means unlimited
+	dailyCap int
+	// day is the day the texts are counted for
+	day time.Time
+	// sent is number of texts sent on the day
+	sent int
+	// client sends the requests
+	client *http.Client
+}
+
+// NewTexter creates new texter sending at most dailyCap texts per day to recipients and returns it
+// It reads the following environment variables to configure the texter:
+// TWILIO_ACCOUNT_SID: Twilio account SID; required parameter
+// TWILIO_AUTH_TOKEN: Twilio auth token; required parameter
+// TWILIO_FROM: sender phone number in E.164 format; required parameter
+// It returns error if any of the required parameters is missing.
+func NewTexter(recipients []smsRecipient, dailyCap int) (*Texter, error) {
+	sid := os.Getenv("TWILIO_ACCOUNT_SID")
+	token := os.Getenv("TWILIO_AUTH_TOKEN")
+	from := os.Getenv("TWILIO_FROM")
+
+	if sid == "" || token == "" {
+		return nil, fmt.Errorf("Twilio account SID or auth token is empty")
+	}
+
+	if from == "" {
+		return nil, fmt.Errorf("Twilio sender number is empty")
+	}
+
+	return &Texter{
+		sid:        sid,
+		token:      token,
+		from:       from,
+		recipients: recipients,
+		dailyCap:   dailyCap,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// send texts body to phone number once
+func (t *Texter) send(number, body string) error {
+	form := url.Values{
+		"To":   {number},
+		"From": {t.from},
+		"Body": {body},
+	}
+	req, err := http.NewRequest(http.MethodPost, twilioAPI+t.sid+"/Messages.json", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.sid, t.token)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Message != "" {
+			return fmt.Errorf("unexpected status: %s: %s", resp.Status, e.Message)
+		}
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Send texts body to the recipients whose shifts are active at time now.
+// It returns error if the daily cap is reached; the texts over the cap are not sent.
+func (t *Texter) Send(body string, now time.Time) error {
+	if day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()); !day.Equal(t.day) {
+		t.day, t.sent = day, 0
+	}
+
+	var lastErr error
+	for _, r := range t.recipients {
+		if len(r.shifts) > 0 && !r.shifts.Active(now) {
+			continue
+		}
+		if t.dailyCap > 0 && t.sent >= t.dailyCap {
+			return fmt.Errorf("daily cap of %d texts reached", t.dailyCap)
+		}
+		// failed texts count towards the cap too as they may have been billed
+		t.sent++
+		if err := t.send(r.number, body); err != nil {
+			lastErr = fmt.Errorf("%s: %v", r.number, err)
+		}
+	}
+
+	return lastErr
+}
+
+// smsText returns text message about raised alert a
+func smsText(a Alert) string {
+	text := fmt.Sprintf("%s %s alert: %s (%s)", strings.ToUpper(a.Severity), a.Type, msg("alert."+a.Type), a.ID)
+	if machineID != "" {
+		text = fmt.Sprintf("[%s] %s", machineID, text)
+	}
+	if a.Escalation > 0 {
+		text += fmt.Sprintf(", unacknowledged for %s", time.Since(a.Raised).Truncate(time.Second))
+	}
+
+	return text
+}
+
+// smsRunner texts raised alerts received from alertsChan and routed to SMS as they happen.
+// Alerts published to the default sinks are only texted if they have at least severity.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func smsRunner(doneChan <-chan struct{}, alertsChan <-chan Alert, t *Texter, severity string) error {
+	for {
+		select {
+		case a := <-alertsChan:
+			if !a.routedTo(sinkSMS) || a.Heartbeat || a.State != alertRaised {
+				continue
+			}
+			if a.Sinks == nil && severityRank(a.Severity) < severityRank(severity) {
+				continue
+			}
+			if err := t.Send(smsText(a), time.Now()); err != nil {
+				fmt.Printf("Error texting alert %s: %v\n", a.ID, err)
+			}
+		case <-doneChan:
+			fmt.Printf("Stopping smsRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// Ways snapshots of raised alerts are attached to the published alerts
+const (
+	// attachPath attaches path to the snapshot file
+	attachPath = "path"
+	// attachBase64 attaches path to the snapshot file and base64 encoded JPEG of the snapshot
+	attachBase64 = "base64"
+)
+
+// annotateSnapshot returns copy of frame img with face bounding boxes of status s and texts of raised alerts drawn
+// into it; the faces are color-coded as in the program window
+func annotateSnapshot(img gocv.Mat, s *Status, raised []Alert, ts time.Time) gocv.Mat {
+	snap := img.Clone()
+
+	drawFaces(&snap, s)
+
+	header := ts.Format(time.RFC3339)
+	if machineID != "" {
+		header = machineID + " " + header
+	}
+	gocv.PutText(&snap, header, image.Point{10, 25}, gocv.FontHersheySimplex, 0.6, color.RGBA{255, 255, 255, 0}, 2)
+	for i, a := range raised {
+		c, ok := severityColors[a.Severity]
+		if !ok {
+			c = overlayAlertColor
+		}
+		text := fmt.Sprintf("%s: %s", a.Severity, msg("alert."+a.Type))
+		gocv.PutText(&snap, text, image.Point{10, 55 + 25*i}, gocv.FontHersheySimplex, 0.6, c, 2)
+	}
+
+	return snap
+}
+
+// snapshotAlerts saves annotated snapshot of frame img with operator status s to dir for the raised alerts
+// in alerts and attaches it to them according to attach.
+// Every alert gets its own copy of the snapshot named by its ID so that the snapshots outlive each other.
+func snapshotAlerts(dir, attach string, alerts []Alert, img gocv.Mat, s *Status, ts time.Time) {
+	var raised []int
+	var raisedAlerts []Alert
+	for i, a := range alerts {
+		if a.State == alertRaised && !a.Heartbeat {
+			raised = append(raised, i)
+			raisedAlerts = append(raisedAlerts, a)
+		}
+	}
+	if len(raised) == 0 {
+		return
+	}
+
+	snap := annotateSnapshot(img, s, raisedAlerts, ts)
+	defer snap.Close()
+
+	var encoded string
+	if attach == attachBase64 {
+		buf, err := gocv.IMEncode(gocv.JPEGFileExt, snap)
+		if err != nil {
+			fmt.Printf("Error encoding alert snapshot: %v\n", err)
+		} else {
+			encoded = base64.StdEncoding.EncodeToString(buf.GetBytes())
+			buf.Close()
+		}
+	}
+
+	for _, i := range raised {
+		path := filepath.Join(dir, alerts[i].ID+".jpg")
+		if !gocv.IMWrite(path, snap) {
+			fmt.Printf("Error saving alert snapshot %s\n", path)
+			continue
+		}
+		if attach != "" {
+			alerts[i].snapshot = path
+			alerts[i].image = encoded
+		}
+	}
+}
+
+// readSnapshot returns annotated snapshot of the frame alert a was raised in; nil if the snapshot was not saved
+func readSnapshot(a Alert) []byte {
+	if snapshotDir == "" || a.State != alertRaised {
+		return nil
+	}
+
+	image, err := ioutil.ReadFile(filepath.Join(snapshotDir, a.ID+".jpg"))
+	if err != nil {
+		return nil
+	}
+
+	return image
+}
+
+// checkSnapshotDir creates snapshot directory dir unless it exists
+func checkSnapshotDir(dir string) error {
+	return os.MkdirAll(dir, 0755)
+}
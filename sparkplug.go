@@ -0,0 +1,444 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	"github.com/intel-iot-devkit/machine-operator-monitor-go/payload"
+)
+
+const (
+	// sparkplugNamespace is Sparkplug B topic namespace
+	sparkplugNamespace = "spBv1.0"
+	// sparkplugRebirth is metric host applications write to request the node to publish its birth certificate again
+	sparkplugRebirth = "Node Control/Rebirth"
+	// sparkplugBdSeq is metric which pairs node birth and death certificates
+	sparkplugBdSeq = "bdSeq"
+)
+
+// Sparkplug B metric data types
+const (
+	spInt32   = 3
+	spUInt64  = 8
+	spDouble  = 10
+	spBoolean = 11
+	spString  = 12
+)
+
+// protoBuf encodes protobuf messages; Sparkplug B payloads are small enough not to need generated code
+type protoBuf []byte
+
+// varint appends varint field
+func (b *protoBuf) varint(field int, v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(field)<<3)
+	*b = append(*b, buf[:n]...)
+	n = binary.PutUvarint(buf[:], v)
+	*b = append(*b, buf[:n]...)
+}
+
+// bytes appends length-delimited field
+func (b *protoBuf) bytes(field int, data []byte) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(field)<<3|2)
+	*b = append(*b, buf[:n]...)
+	n = binary.PutUvarint(buf[:], uint64(len(data)))
+	*b = append(*b, buf[:n]...)
+	*b = append(*b, data...)
+}
+
+// double appends 64-bit field
+func (b *protoBuf) double(field int, v float64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(field)<<3|1)
+	*b = append(*b, buf[:n]...)
+	binary.LittleEndian.PutUint64(buf[:8], math.Float64bits(v))
+	*b = append(*b, buf[:8]...)
+}
+
+// protoFields decodes protobuf message b and calls fn with every field; v is value of varint and fixed size fields,
+// data is content of length-delimited ones. It returns error if the message is malformed.
+func protoFields(b []byte, fn func(field int, v uint64, data []byte)) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return fmt.Errorf("invalid field key")
+		}
+		b = b[n:]
+
+		var v uint64
+		var data []byte
+		switch key & 7 {
+		case 0:
+			if v, n = binary.Uvarint(b); n <= 0 {
+				return fmt.Errorf("invalid varint")
+			}
+			b = b[n:]
+		case 1:
+			if len(b) < 8 {
+				return fmt.Errorf("truncated 64-bit field")
+			}
+			v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return fmt.Errorf("truncated length-delimited field")
+			}
+			data, b = b[n:n+int(l)], b[n+int(l):]
+		case 5:
+			if len(b) < 4 {
+				return fmt.Errorf("truncated 32-bit field")
+			}
+			v, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d", key&7)
+		}
+
+		fn(int(key>>3), v, data)
+	}
+
+	return nil
+}
+
+// sparkplugMetric is Sparkplug B metric the node reports
+type sparkplugMetric struct {
+	// name is metric name
+	name string
+	// datatype is Sparkplug B data type
+	datatype uint32
+	// value is current metric value: bool, string, float64, int or uint64 by datatype; nil if unknown
+	value interface{}
+}
+
+// encode encodes the metric with alias; the name is only included in birth certificates
+func (m *sparkplugMetric) encode(alias int, birth bool, ts time.Time) []byte {
+	var b protoBuf
+	if birth {
+		b.bytes(1, []byte(m.name))
+	}
+	if alias >= 0 {
+		b.varint(2, uint64(alias))
+	}
+	b.varint(3, uint64(ts.UnixNano()/int64(time.Millisecond)))
+	b.varint(4, uint64(m.datatype))
+
+	switch v := m.value.(type) {
+	case nil:
+		b.varint(7, 1)
+	case bool:
+		var i uint64
+		if v {
+			i = 1
+		}
+		b.varint(14, i)
+	case string:
+		b.bytes(15, []byte(v))
+	case float64:
+		b.double(13, v)
+	case int:
+		b.varint(10, uint64(uint32(int32(v))))
+	case uint64:
+		b.varint(11, v)
+	}
+
+	return b
+}
+
+// sparkplugPayload encodes Sparkplug B payload with timestamp ts, encoded metrics and sequence number seq;
+// negative seq is left out
+func sparkplugPayload(ts time.Time, metrics [][]byte, seq int) []byte {
+	var b protoBuf
+	b.varint(1, uint64(ts.UnixNano()/int64(time.Millisecond)))
+	for _, m := range metrics {
+		b.bytes(2, m)
+	}
+	if seq >= 0 {
+		b.varint(3, uint64(seq))
+	}
+
+	return b
+}
+
+// SparkplugNode is Sparkplug B edge node which reports operator status, alerts and inference performance
+// as node metrics. The metrics are declared with their aliases in the birth certificate (NBIRTH)
+// and only the changed ones are reported in the data messages (NDATA).
+type SparkplugNode struct {
+	// client is MQTT client of the node
+	client MQTT.Client
+	// group is Sparkplug group ID
+	group string
+	// node is edge node ID
+	node string
+	// bdSeq pairs the birth certificates with the death certificate registered as MQTT will
+	bdSeq uint64
+	// mu guards seq and metrics which are published from the runner and MQTT handlers
+	mu sync.Mutex
+	// seq is sequence number of the latest message
+	seq int
+	// metrics are node metrics; their aliases are their indexes
+	metrics []*sparkplugMetric
+	// aliases stores metric aliases by metric names
+	aliases map[string]int
+}
+
+// NewSparkplugNode connects to MQTT broker configured by MQTT_* environment variables as Sparkplug B edge node
+// of group with machine ID as the node ID and returns it.
+// The node connects with its own client ID, MQTT_CLIENT_ID with -sparkplug suffix, and its death certificate as will.
+// It returns error if the MQTT configuration is invalid or if it fails to connect.
+func NewSparkplugNode(group string) (*SparkplugNode, error) {
+	opts, err := MQTTClientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &SparkplugNode{
+		group: group,
+		node:  machineID,
+		// every process starts a new session
+		bdSeq:   uint64(time.Now().Unix() % 256),
+		aliases: make(map[string]int),
+	}
+
+	n.define(sparkplugRebirth, spBoolean).value = false
+	for _, name := range []string{"Status/Operator", "Status/Sentiment"} {
+		n.define(name, spString)
+	}
+	for _, name := range []string{"Status/Watching", "Status/Angry", "Status/Phone", "Status/Paused", "Status/Suspended"} {
+		n.define(name, spBoolean)
+	}
+	for _, name := range []string{"Status/Distance", "Status/Risk"} {
+		n.define(name, spDouble)
+	}
+	n.define("Status/Degradation", spInt32)
+	types := alertTypeNames()
+	sort.Strings(types)
+	for _, typ := range types {
+		n.define("Alerts/"+typ, spBoolean)
+	}
+	for _, name := range []string{"Perf/FaceNet", "Perf/SentNet", "Perf/PoseNet", "Perf/FPS", "Perf/InputFPS"} {
+		n.define(name, spDouble)
+	}
+	n.define("Perf/Dropped", spUInt64)
+
+	death := &sparkplugMetric{name: sparkplugBdSeq, datatype: spUInt64, value: n.bdSeq}
+	now := time.Now()
+	opts.SetClientID(opts.ClientID + "-sparkplug")
+	opts.SetBinaryWill(n.topic("NDEATH"), sparkplugPayload(now, [][]byte{death.encode(-1, true, now)}, -1), 1, false)
+	opts.SetAutoReconnect(true)
+	opts.SetMaxReconnectInterval(reconnectMaxInterval)
+	opts.SetOnConnectHandler(n.onConnect)
+	opts.SetConnectionLostHandler(func(client MQTT.Client, err error) {
+		fmt.Printf("Sparkplug connection lost: %v; reconnecting\n", err)
+	})
+
+	n.client = MQTT.NewClient(opts)
+	if token := n.client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, token.Error()
+	}
+
+	return n, nil
+}
+
+// define adds metric name of datatype with unknown value and returns it
+func (n *SparkplugNode) define(name string, datatype uint32) *sparkplugMetric {
+	m := &sparkplugMetric{name: name, datatype: datatype}
+	n.aliases[name] = len(n.metrics)
+	n.metrics = append(n.metrics, m)
+
+	return m
+}
+
+// topic returns Sparkplug B topic of the node for message type typ
+func (n *SparkplugNode) topic(typ string) string {
+	return sparkplugNamespace + "/" + n.group + "/" + typ + "/" + n.node
+}
+
+// onConnect subscribes to node commands and publishes the birth certificate once the client (re)connects
+func (n *SparkplugNode) onConnect(client MQTT.Client) {
+	topic := n.topic("NCMD")
+	if token := client.Subscribe(topic, QOS, n.handleCommand); token.WaitTimeout(TIMEOUT) && token.Error() != nil {
+		fmt.Printf("Error subscribing to %s: %v\n", topic, token.Error())
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.birth()
+}
+
+// birth publishes the birth certificate with all the metrics and resets the sequence number; n.mu must be held
+func (n *SparkplugNode) birth() {
+	now := time.Now()
+	metrics := [][]byte{(&sparkplugMetric{name: sparkplugBdSeq, datatype: spUInt64, value: n.bdSeq}).encode(-1, true, now)}
+	for alias, m := range n.metrics {
+		metrics = append(metrics, m.encode(alias, true, now))
+	}
+
+	n.seq = 0
+	n.send("NBIRTH", sparkplugPayload(now, metrics, n.seq))
+}
+
+// handleCommand republishes the birth certificate when host application requests the node rebirth
+func (n *SparkplugNode) handleCommand(c MQTT.Client, msg MQTT.Message) {
+	var rebirth bool
+	err := protoFields(msg.Payload(), func(field int, v uint64, data []byte) {
+		if field != 2 {
+			return
+		}
+		var name string
+		var value bool
+		alias := -1
+		// metrics which fail to decode are ignored along with the rest of the payload below
+		protoFields(data, func(field int, v uint64, data []byte) {
+			switch field {
+			case 1:
+				name = string(data)
+			case 2:
+				alias = int(v)
+			case 14:
+				value = v != 0
+			}
+		})
+		if (name == sparkplugRebirth || alias == n.aliases[sparkplugRebirth]) && value {
+			rebirth = true
+		}
+	})
+	if err != nil {
+		fmt.Printf("Ignoring invalid Sparkplug command: %v\n", err)
+		return
+	}
+
+	if rebirth {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		n.birth()
+	}
+}
+
+// send publishes Sparkplug B message of type typ; Sparkplug requires node messages to be published with QoS 0
+func (n *SparkplugNode) send(typ string, data []byte) {
+	topic := n.topic(typ)
+	token := n.client.Publish(topic, 0, false, data)
+	if ok := token.WaitTimeout(TIMEOUT); ok && token.Error() != nil {
+		fmt.Printf("Error publishing message to %s: %v\n", topic, token.Error())
+	}
+}
+
+// Update sets the metrics to operator status s and inference performance perf and publishes the changed ones;
+// nil perf leaves the performance metrics unchanged
+func (n *SparkplugNode) Update(s payload.Status, perf *payload.Perf) {
+	values := map[string]interface{}{
+		"Status/Operator":    s.Operator,
+		"Status/Sentiment":   s.Sentiment,
+		"Status/Watching":    s.Watching,
+		"Status/Angry":       s.Angry,
+		"Status/Paused":      s.Paused,
+		"Status/Suspended":   s.Suspended,
+		"Status/Distance":    s.Distance,
+		"Status/Phone":       nil,
+		"Status/Risk":        nil,
+		"Status/Degradation": nil,
+	}
+	if s.Phone != nil {
+		values["Status/Phone"] = *s.Phone
+	}
+	if s.Risk != nil {
+		values["Status/Risk"] = *s.Risk
+	}
+	if s.Degradation != nil {
+		values["Status/Degradation"] = *s.Degradation
+	}
+	for typ, raised := range s.Alerts {
+		values["Alerts/"+typ] = raised
+	}
+	if perf != nil {
+		values["Perf/FaceNet"] = perf.FaceNet
+		values["Perf/SentNet"] = perf.SentNet
+		values["Perf/PoseNet"] = perf.PoseNet
+		values["Perf/FPS"] = perf.FPS
+		values["Perf/InputFPS"] = perf.InputFPS
+		values["Perf/Dropped"] = perf.Dropped
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now()
+	var metrics [][]byte
+	for alias, m := range n.metrics {
+		v, ok := values[m.name]
+		if !ok || v == m.value {
+			continue
+		}
+		m.value = v
+		metrics = append(metrics, m.encode(alias, false, now))
+	}
+	if len(metrics) == 0 {
+		return
+	}
+
+	n.seq = (n.seq + 1) % 256
+	n.send("NDATA", sparkplugPayload(now, metrics, n.seq))
+}
+
+// Close publishes the death certificate and disconnects from the broker
+func (n *SparkplugNode) Close() error {
+	now := time.Now()
+	death := &sparkplugMetric{name: sparkplugBdSeq, datatype: spUInt64, value: n.bdSeq}
+	n.send("NDEATH", sparkplugPayload(now, [][]byte{death.encode(-1, true, now)}, -1))
+	n.client.Disconnect(100)
+
+	return nil
+}
+
+// sparkplugRunner reads data published to pubChan with rate frequency and reports them as Sparkplug B metrics of n
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func sparkplugRunner(doneChan <-chan struct{}, pubChan <-chan *Result, n *SparkplugNode, rate int) error {
+	ticker := time.NewTicker(time.Duration(rate) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			result := <-pubChan
+			var perf *payload.Perf
+			if result.hasPerf() {
+				p := result.ToPerfPayload()
+				perf = &p
+			}
+			n.Update(result.ToPayload(), perf)
+		case <-pubChan:
+			// we discard messages in between ticker times
+		case <-doneChan:
+			fmt.Printf("Stopping sparkplugRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
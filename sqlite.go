@@ -0,0 +1,113 @@
+//go:build sqlite
+// +build sqlite
+
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	// registers sqlite3 database driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteSchema creates the events table; the time is stored as milliseconds since the Unix epoch
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	time INTEGER NOT NULL,
+	kind TEXT NOT NULL,
+	type TEXT NOT NULL DEFAULT '',
+	state TEXT NOT NULL DEFAULT '',
+	data TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_time ON events (time);
+`
+
+// sqliteStore is event store kept in SQLite database
+type sqliteStore struct {
+	// db is the database
+	db *sql.DB
+}
+
+// toMillis returns t as milliseconds since the Unix epoch
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// openEventStore opens SQLite event store at path, creating it if it doesn't exist, and returns it
+func openEventStore(path string) (EventStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	// the store is written by a single goroutine
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+// Add stores event e
+func (s *sqliteStore) Add(e storedEvent) error {
+	_, err := s.db.Exec("INSERT INTO events (time, kind, type, state, data) VALUES (?, ?, ?, ?, ?)",
+		toMillis(e.Time), e.Kind, e.Type, e.State, e.Data)
+
+	return err
+}
+
+// Query returns events since from ordered by time; empty kind and typ match any kind and alert type
+func (s *sqliteStore) Query(from time.Time, kind, typ string) ([]storedEvent, error) {
+	rows, err := s.db.Query(`SELECT time, kind, type, state, data FROM events
+		WHERE time >= ? AND (? = '' OR kind = ?) AND (? = '' OR type = ?)
+		ORDER BY time, id`, toMillis(from), kind, kind, typ, typ)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []storedEvent
+	for rows.Next() {
+		var e storedEvent
+		var ms int64
+		if err := rows.Scan(&ms, &e.Kind, &e.Type, &e.State, &e.Data); err != nil {
+			return nil, err
+		}
+		e.Time = time.Unix(0, ms*int64(time.Millisecond))
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// Close closes the database
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,224 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/intel-iot-devkit/machine-operator-monitor-go/payload"
+)
+
+const (
+	// eventStatus is kind of stored operator status change
+	eventStatus = "status"
+	// eventAlert is kind of stored alert state transition
+	eventAlert = "alert"
+	// eventSummary is kind of stored periodic summary
+	eventSummary = "summary"
+)
+
+// storedEvent is event kept in event store
+type storedEvent struct {
+	// Time is time of the event
+	Time time.Time
+	// Kind is kind of the event: status, alert or summary
+	Kind string
+	// Type is alert type; empty unless the event is alert
+	Type string
+	// State is alert state; empty unless the event is alert
+	State string
+	// Data is JSON payload of the event
+	Data string
+}
+
+// EventStore persists events so that the history survives without network connectivity
+type EventStore interface {
+	// Add stores event e
+	Add(e storedEvent) error
+	// Query returns events since from ordered by time; empty kind and typ match any kind and alert type
+	Query(from time.Time, kind, typ string) ([]storedEvent, error)
+	// Close closes the store
+	Close() error
+}
+
+// statusRecord is operator status stored with the inference performance of the frame
+type statusRecord struct {
+	payload.Status
+	// Perf is inference performance; omitted if it was not reported
+	Perf *payload.Perf `json:"perf,omitempty"`
+}
+
+// summaryRecord summarizes operator status sampled over summary interval
+type summaryRecord struct {
+	// Start is start of the summary interval
+	Start time.Time `json:"start"`
+	// End is end of the summary interval
+	End time.Time `json:"end"`
+	// Samples is number of sampled operator statuses
+	Samples int `json:"samples"`
+	// Watching is fraction of the samples in which operator was watching the machine
+	Watching float64 `json:"watching"`
+	// Angry is fraction of the samples in which operator was angry
+	Angry float64 `json:"angry"`
+	// Raised maps alert types to number of times the alerts were raised
+	Raised map[string]int `json:"raised"`
+}
+
+// changed returns true if operator status a differs from b in anything but time, distance and risk score
+// which change with nearly every frame
+func changed(a, b *payload.Status) bool {
+	if a == nil || b == nil {
+		return a != b
+	}
+
+	x, y := *a, *b
+	x.Header.Time, y.Header.Time = time.Time{}, time.Time{}
+	x.Distance, y.Distance = 0, 0
+	x.Risk, y.Risk = nil, nil
+
+	return !reflect.DeepEqual(x, y)
+}
+
+// storeRunner stores operator status received from statusChan whenever it changes, alert state transitions
+// received from alertsChan and, unless summary is zero, summary of the operator status every summary interval in s.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func storeRunner(doneChan <-chan struct{}, statusChan <-chan *Result, alertsChan <-chan Alert, s EventStore, summary time.Duration) error {
+	var tick <-chan time.Time
+	if summary > 0 {
+		ticker := time.NewTicker(summary)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var last *payload.Status
+	sum := summaryRecord{Start: time.Now(), Raised: make(map[string]int)}
+	for {
+		select {
+		case result, ok := <-statusChan:
+			if !ok {
+				// frameRunner stopped; wait until we are told to stop
+				statusChan = nil
+				continue
+			}
+			status := result.ToPayload()
+			sum.Samples++
+			if status.Watching {
+				sum.Watching++
+			}
+			if status.Angry {
+				sum.Angry++
+			}
+			if !changed(&status, last) {
+				continue
+			}
+			last = &status
+
+			rec := statusRecord{Status: status}
+			if result.hasPerf() {
+				perf := result.ToPerfPayload()
+				rec.Perf = &perf
+			}
+			data, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			if err := s.Add(storedEvent{Time: status.Time, Kind: eventStatus, Data: string(data)}); err != nil {
+				fmt.Printf("Error storing status: %v\n", err)
+			}
+		case a := <-alertsChan:
+			if a.Heartbeat {
+				continue
+			}
+			if a.State == alertRaised {
+				sum.Raised[a.Type]++
+			}
+			e := storedEvent{Time: eventTime(a), Kind: eventAlert, Type: a.Type, State: a.State, Data: a.ToMQTTMessage()}
+			if err := s.Add(e); err != nil {
+				fmt.Printf("Error storing alert %s: %v\n", a.ID, err)
+			}
+		case now := <-tick:
+			sum.End = now
+			if sum.Samples > 0 {
+				sum.Watching /= float64(sum.Samples)
+				sum.Angry /= float64(sum.Samples)
+			}
+			data, err := json.Marshal(sum)
+			if err != nil {
+				return err
+			}
+			if err := s.Add(storedEvent{Time: now, Kind: eventSummary, Data: string(data)}); err != nil {
+				fmt.Printf("Error storing summary: %v\n", err)
+			}
+			sum = summaryRecord{Start: now, Raised: make(map[string]int)}
+		case <-doneChan:
+			fmt.Printf("Stopping storeRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
+
+// eventsCommand implements events subcommand which queries event store
+func eventsCommand(args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("Usage: %s events list [options]", os.Args[0])
+	}
+
+	fs := flag.NewFlagSet("events list", flag.ExitOnError)
+	path := fs.String("store", "events.db", "Path to SQLite event store")
+	since := fs.String("since", "24h", "List events since the given time in RFC3339 format or the given duration ago")
+	kind := fs.String("kind", "", "List events of the given kind only: status, alert or summary")
+	typ := fs.String("type", "", "List alerts of the given type only")
+	fs.Parse(args[1:])
+
+	from, err := parseSince(*since)
+	if err != nil {
+		return fmt.Errorf("Invalid time: %s", *since)
+	}
+	switch *kind {
+	case "", eventStatus, eventAlert, eventSummary:
+	default:
+		return fmt.Errorf("Invalid event kind: %s", *kind)
+	}
+
+	s, err := openEventStore(*path)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	events, err := s.Query(from, *kind, *typ)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		fmt.Printf("%s  %-7s %-9s %-13s %s\n", e.Time.Format(time.RFC3339), e.Kind, e.Type, e.State, e.Data)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"image"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// tamperFrozenDiff is mean absolute difference of thumbnails of consecutive frames below which the video is frozen
+const tamperFrozenDiff = 0.5
+
+// frameStats are frame image statistics which reveal camera tampering
+type frameStats struct {
+	// brightness is mean intensity of the frame between 0 and 255
+	brightness float64
+	// sharpness is sharpness of the frame; see sharpness
+	sharpness float64
+	// thumb is grayscale thumbnail of the frame
+	thumb []byte
+}
+
+// measureFrame returns image statistics of img.
+// They are measured on downscaled image as they don't need to be precise.
+func measureFrame(img gocv.Mat) *frameStats {
+	small := gocv.NewMat()
+	defer small.Close()
+	gocv.Resize(img, &small, image.Pt(320, 180), 0, 0, gocv.InterpolationLinear)
+
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(small, &gray, gocv.ColorBGRToGray)
+
+	thumb := gocv.NewMat()
+	defer thumb.Close()
+	gocv.Resize(gray, &thumb, image.Pt(32, 18), 0, 0, gocv.InterpolationArea)
+
+	return &frameStats{
+		brightness: gray.Mean().Val1,
+		sharpness:  sharpness(small),
+		thumb:      thumb.ToBytes(),
+	}
+}
+
+// frozen returns true if thumbnails a and b of consecutive frames are virtually identical
+func frozen(a, b []byte) bool {
+	if len(a) == 0 || len(a) != len(b) {
+		return false
+	}
+
+	var diff int
+	for i := range a {
+		if a[i] > b[i] {
+			diff += int(a[i] - b[i])
+		} else {
+			diff += int(b[i] - a[i])
+		}
+	}
+
+	return float64(diff)/float64(len(a)) < tamperFrozenDiff
+}
+
+// TamperWatchdog raises alert when the camera is obstructed or tampered with, i.e. when the frames
+// stay dark, heavily blurred or frozen for longer than timeout
+type TamperWatchdog struct {
+	// timeout is time the frames are allowed to look tampered for
+	timeout time.Duration
+	// prev is thumbnail of the previous frame
+	prev []byte
+	// since is time when the frames started to look tampered
+	since time.Time
+}
+
+// NewTamperWatchdog creates new tamper watchdog and returns it
+func NewTamperWatchdog(timeout time.Duration) *TamperWatchdog {
+	return &TamperWatchdog{
+		timeout: timeout,
+	}
+}
+
+// Update checks frame statistics s measured at time ts and returns true if the camera looks tampered with
+func (w *TamperWatchdog) Update(ts time.Time, s *frameStats) bool {
+	if s == nil {
+		return false
+	}
+
+	tampered := s.brightness < tamperBrightness || s.sharpness < tamperSharpness || frozen(w.prev, s.thumb)
+	w.prev = s.thumb
+
+	if !tampered {
+		w.since = time.Time{}
+		return false
+	}
+	if w.since.IsZero() {
+		w.since = ts
+	}
+
+	return ts.Sub(w.since) > w.timeout
+}
+
+// Reset starts the watchdog over
+func (w *TamperWatchdog) Reset() {
+	w.prev = nil
+	w.since = time.Time{}
+}
@@ -0,0 +1,268 @@
+//go:build tflite
+// +build tflite
+
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"runtime"
+	"time"
+
+	"github.com/mattn/go-tflite"
+	"gocv.io/x/gocv"
+)
+
+// tfliteModel runs inference using TensorFlow Lite interpreter.
+// It accepts the same NCHW input blobs as OpenCV networks and runs one invocation per blob sample,
+// so the detectors can batch their inputs regardless of the batch size the model was exported with.
+type tfliteModel struct {
+	// model is TensorFlow Lite model
+	model *tflite.Model
+	// options are interpreter options
+	options *tflite.InterpreterOptions
+	// interpreter runs the model
+	interpreter *tflite.Interpreter
+	// inputs stores input blobs by input tensor index
+	inputs map[int]gocv.Mat
+	// perf is duration of the last forward pass
+	perf time.Duration
+	// err is error of the first failed forward pass since Err was last called
+	err error
+}
+
+// newTFLiteModel reads TensorFlow Lite model from file and returns it.
+// It returns error if the model can't be read or if its tensors can't be allocated.
+func newTFLiteModel(path string) (Model, error) {
+	model := tflite.NewModelFromFile(path)
+	if model == nil {
+		return nil, fmt.Errorf("Can't read TensorFlow Lite model: %s", path)
+	}
+
+	options := tflite.NewInterpreterOptions()
+	options.SetNumThread(runtime.NumCPU())
+
+	interpreter := tflite.NewInterpreter(model, options)
+	if interpreter == nil {
+		options.Delete()
+		model.Delete()
+		return nil, fmt.Errorf("Can't create TensorFlow Lite interpreter: %s", path)
+	}
+
+	if status := interpreter.AllocateTensors(); status != tflite.OK {
+		interpreter.Delete()
+		options.Delete()
+		model.Delete()
+		return nil, fmt.Errorf("Can't allocate TensorFlow Lite tensors: %s", path)
+	}
+
+	return &tfliteModel{
+		model:       model,
+		options:     options,
+		interpreter: interpreter,
+		inputs:      make(map[int]gocv.Mat),
+	}, nil
+}
+
+// SetInput sets the input blob of the input tensor with the given name; empty name sets the first input
+func (m *tfliteModel) SetInput(blob gocv.Mat, name string) {
+	for i := 0; i < m.interpreter.GetInputTensorCount(); i++ {
+		if (name == "" && i == 0) || m.interpreter.GetInputTensor(i).Name() == name {
+			m.inputs[i] = blob
+			return
+		}
+	}
+}
+
+// Forward runs forward pass and returns the output tensor with the given name; empty name returns the first output
+func (m *tfliteModel) Forward(outputName string) gocv.Mat {
+	return m.ForwardLayers([]string{outputName})[0]
+}
+
+// ForwardLayers runs forward pass and returns the output tensors with the given names.
+// Every output is returned as [N, K] matrix where N is the batch size of the inputs.
+func (m *tfliteModel) ForwardLayers(outBlobNames []string) []gocv.Mat {
+	start := time.Now()
+	defer func() { m.perf = time.Since(start) }()
+
+	// outputs maps requested output tensor index to requested position
+	outputs := make(map[int]int)
+	for j, name := range outBlobNames {
+		for i := 0; i < m.interpreter.GetOutputTensorCount(); i++ {
+			if (name == "" && i == 0) || m.interpreter.GetOutputTensor(i).Name() == name {
+				outputs[i] = j
+			}
+		}
+	}
+
+	// batch is number of samples in the input blobs
+	batch := 1
+	for _, blob := range m.inputs {
+		if n := blob.Size()[0]; n > batch {
+			batch = n
+		}
+	}
+
+	data := make([][]float32, len(outBlobNames))
+	for n := 0; n < batch; n++ {
+		var err error
+		for i, blob := range m.inputs {
+			if err = setTensor(m.interpreter.GetInputTensor(i), blob, n); err != nil {
+				break
+			}
+		}
+
+		// failed invocation yields zero outputs of the expected shape, so the detectors don't read past them,
+		// and the error is reported so that the frame is skipped
+		if err == nil {
+			if status := m.interpreter.Invoke(); status != tflite.OK {
+				err = fmt.Errorf("TensorFlow Lite invocation failed with status %v", status)
+			}
+		}
+		if err != nil && m.err == nil {
+			m.err = err
+		}
+
+		for i, j := range outputs {
+			values := tensorFloats(m.interpreter.GetOutputTensor(i))
+			if err != nil {
+				values = make([]float32, len(values))
+			}
+			data[j] = append(data[j], values...)
+		}
+	}
+
+	res := make([]gocv.Mat, len(outBlobNames))
+	for j := range res {
+		cols := len(data[j]) / batch
+		buf := make([]byte, 4*len(data[j]))
+		for k, v := range data[j] {
+			binary.LittleEndian.PutUint32(buf[4*k:], math.Float32bits(v))
+		}
+		mat, err := gocv.NewMatFromBytes(batch, cols, gocv.MatTypeCV32F, buf)
+		if err != nil {
+			mat = gocv.NewMat()
+		}
+		res[j] = mat
+	}
+
+	return res
+}
+
+// FixedSize returns true as TensorFlow Lite input tensors have fixed size
+func (m *tfliteModel) FixedSize() bool {
+	return true
+}
+
+// Err returns error of the first forward pass which failed since Err was last called and clears it
+func (m *tfliteModel) Err() error {
+	err := m.err
+	m.err = nil
+
+	return err
+}
+
+// setTensor copies n-th sample of NCHW blob into NHWC tensor converting it to the tensor type
+// It returns error if the blob has no such sample or its size doesn't match the tensor.
+func setTensor(t *tflite.Tensor, blob gocv.Mat, n int) error {
+	size := blob.Size()
+	if len(size) != 4 || n >= size[0] {
+		return fmt.Errorf("input blob of size %v has no sample %d", size, n)
+	}
+	values, err := blob.DataPtrFloat32()
+	if err != nil {
+		return err
+	}
+
+	c, h, w := size[1], size[2], size[3]
+	// the tensor must take a single NHWC sample of the blob's size
+	if t.NumDims() != 4 || t.Dim(1) != h || t.Dim(2) != w || t.Dim(3) != c {
+		return fmt.Errorf("input blob of size %dx%dx%d doesn't match input tensor %s", w, h, c, t.Name())
+	}
+	sample := values[n*c*h*w : (n+1)*c*h*w]
+
+	switch t.Type() {
+	case tflite.Float32:
+		dst := t.Float32s()
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				for ch := 0; ch < c; ch++ {
+					dst[(y*w+x)*c+ch] = sample[(ch*h+y)*w+x]
+				}
+			}
+		}
+	case tflite.UInt8:
+		dst := t.UInt8s()
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				for ch := 0; ch < c; ch++ {
+					v := math.Max(0, math.Min(255, float64(sample[(ch*h+y)*w+x])))
+					dst[(y*w+x)*c+ch] = uint8(v)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// tensorFloats returns tensor values as float32, dequantizing them if the tensor is quantized
+func tensorFloats(t *tflite.Tensor) []float32 {
+	switch t.Type() {
+	case tflite.Float32:
+		return append([]float32(nil), t.Float32s()...)
+	case tflite.UInt8:
+		q := t.QuantizationParams()
+		values := t.UInt8s()
+		res := make([]float32, len(values))
+		for i, v := range values {
+			res[i] = float32(q.Scale * float64(int(v)-q.ZeroPoint))
+		}
+		return res
+	default:
+		return nil
+	}
+}
+
+// GetPerfProfile returns time spent in the last forward pass in ticks
+func (m *tfliteModel) GetPerfProfile() float64 {
+	return m.perf.Seconds() * gocv.GetTickFrequency()
+}
+
+// Empty returns true if the model has no inputs
+func (m *tfliteModel) Empty() bool {
+	return m.interpreter.GetInputTensorCount() == 0
+}
+
+// Close releases the interpreter and the model
+func (m *tfliteModel) Close() error {
+	m.interpreter.Delete()
+	m.options.Delete()
+	m.model.Delete()
+
+	return nil
+}
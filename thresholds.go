@@ -0,0 +1,193 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Thresholds are alert timeouts and thresholds which can be overridden for individual operators
+type Thresholds struct {
+	// watch is maximum time operator is allowed not to be watching machine for
+	watch time.Duration
+	// angry is maximum time operator is allowed to be angry operating machine for
+	angry time.Duration
+	// phone is maximum time operator is allowed to use phone for
+	phone time.Duration
+	// attention is minimum ratio of time operator must be watching machine for within attention window
+	attention float64
+	// perclos is maximum ratio of time operator is allowed to have their eyes closed
+	perclos float64
+}
+
+// thresholdOverrides are thresholds of an operator as they are stored in thresholds config; empty ones are not overridden
+type thresholdOverrides struct {
+	WatchTimeout       string   `json:"watch_timeout"`
+	AngryTimeout       string   `json:"angry_timeout"`
+	PhoneTimeout       string   `json:"phone_timeout"`
+	AttentionThreshold *float64 `json:"attention_threshold"`
+	PerclosThreshold   *float64 `json:"perclos_threshold"`
+}
+
+var (
+	// thresholdsMu guards operatorThresholds which are replaced as they are refreshed
+	thresholdsMu sync.RWMutex
+	// operatorThresholds stores thresholds of operators by their IDs
+	operatorThresholds map[string]Thresholds
+)
+
+// defaultThresholds returns thresholds set by cli flags
+func defaultThresholds() Thresholds {
+	return Thresholds{
+		watch:     watchTimeout,
+		angry:     angryTimeout,
+		phone:     phoneTimeout,
+		attention: attentionThreshold,
+		perclos:   perclosThreshold,
+	}
+}
+
+// thresholdsFor returns thresholds of operator with ID id; unknown operators use the default thresholds
+func thresholdsFor(id string) Thresholds {
+	thresholdsMu.RLock()
+	defer thresholdsMu.RUnlock()
+
+	if t, ok := operatorThresholds[id]; ok && id != "" {
+		return t
+	}
+
+	return defaultThresholds()
+}
+
+// parseThresholds parses JSON thresholds config mapping operator IDs to their overrides and returns the thresholds
+func parseThresholds(data []byte) (map[string]Thresholds, error) {
+	var config map[string]thresholdOverrides
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	thresholds := make(map[string]Thresholds)
+	for id, o := range config {
+		t := defaultThresholds()
+		for _, d := range []struct {
+			value string
+			dst   *time.Duration
+		}{{o.WatchTimeout, &t.watch}, {o.AngryTimeout, &t.angry}, {o.PhoneTimeout, &t.phone}} {
+			if d.value == "" {
+				continue
+			}
+			v, err := time.ParseDuration(d.value)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("operator %s: invalid timeout: %s", id, d.value)
+			}
+			*d.dst = v
+		}
+		if o.AttentionThreshold != nil {
+			if *o.AttentionThreshold <= 0 || *o.AttentionThreshold > 1 {
+				return nil, fmt.Errorf("operator %s: invalid attention threshold: %f", id, *o.AttentionThreshold)
+			}
+			t.attention = *o.AttentionThreshold
+		}
+		if o.PerclosThreshold != nil {
+			if *o.PerclosThreshold < 0 || *o.PerclosThreshold > 1 {
+				return nil, fmt.Errorf("operator %s: invalid PERCLOS threshold: %f", id, *o.PerclosThreshold)
+			}
+			t.perclos = *o.PerclosThreshold
+		}
+		thresholds[id] = t
+	}
+
+	return thresholds, nil
+}
+
+// isURL returns true if src is HTTP or HTTPS URL rather than a file path
+func isURL(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+// loadThresholds reads thresholds config from file or HTTP endpoint src and returns the thresholds
+func loadThresholds(src string) (map[string]Thresholds, error) {
+	if !isURL(src) {
+		data, err := ioutil.ReadFile(src)
+		if err != nil {
+			return nil, err
+		}
+		return parseThresholds(data)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(src)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseThresholds(data)
+}
+
+// setThresholds replaces operator thresholds with t
+func setThresholds(t map[string]Thresholds) {
+	thresholdsMu.Lock()
+	defer thresholdsMu.Unlock()
+
+	operatorThresholds = t
+}
+
+// thresholdsRunner reloads operator thresholds from src every interval.
+// Thresholds which fail to load are reported and the ones in use are kept.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func thresholdsRunner(doneChan <-chan struct{}, src string, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t, err := loadThresholds(src)
+			if err != nil {
+				fmt.Printf("Error reloading operator thresholds from %s: %v\n", src, err)
+				continue
+			}
+			setThresholds(t)
+		case <-doneChan:
+			fmt.Printf("Stopping thresholdsRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,200 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"image"
+	"sort"
+	"time"
+)
+
+// faceTrack is a face tracked across frames
+type faceTrack struct {
+	// rect is the last known face bounding box
+	rect image.Rectangle
+	// lastSeen records time when the face was last detected
+	lastSeen time.Time
+}
+
+// Tracker assigns persistent IDs to faces detected across frames by matching their bounding boxes
+type Tracker struct {
+	// nextID is ID assigned to the next new track
+	nextID int
+	// tracks stores active tracks by their IDs
+	tracks map[int]*faceTrack
+	// minIoU is minimum intersection over union of bounding boxes of the same face in consecutive detections
+	minIoU float64
+	// maxAge is maximum time a track is kept without its face being detected
+	maxAge time.Duration
+}
+
+// NewTracker creates new face tracker and returns it
+func NewTracker(minIoU float64, maxAge time.Duration) *Tracker {
+	return &Tracker{
+		nextID: 1,
+		tracks: make(map[int]*faceTrack),
+		minIoU: minIoU,
+		maxAge: maxAge,
+	}
+}
+
+// iou returns intersection over union of rectangles a and b
+func iou(a, b image.Rectangle) float64 {
+	in := a.Intersect(b)
+	if in.Empty() {
+		return 0
+	}
+
+	i := float64(in.Dx() * in.Dy())
+	u := float64(a.Dx()*a.Dy()+b.Dx()*b.Dy()) - i
+
+	return i / u
+}
+
+// Update assigns track IDs to faces detected at time ts.
+// Faces are greedily matched with the tracks whose bounding boxes overlap them the most;
+// unmatched faces start new tracks. It returns IDs of the tracks which expired.
+func (t *Tracker) Update(faces []*Face, ts time.Time) []int {
+	// pair is a candidate match of a face and a track
+	type pair struct {
+		face  int
+		track int
+		iou   float64
+	}
+
+	var pairs []pair
+	for i := range faces {
+		for id, tr := range t.tracks {
+			if v := iou(faces[i].Rect, tr.rect); v >= t.minIoU {
+				pairs = append(pairs, pair{face: i, track: id, iou: v})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].iou > pairs[j].iou })
+
+	matchedFaces := make(map[int]bool)
+	matchedTracks := make(map[int]bool)
+	for _, p := range pairs {
+		if matchedFaces[p.face] || matchedTracks[p.track] {
+			continue
+		}
+		matchedFaces[p.face], matchedTracks[p.track] = true, true
+		faces[p.face].TrackID = p.track
+		t.tracks[p.track].rect = faces[p.face].Rect
+		t.tracks[p.track].lastSeen = ts
+	}
+
+	for i := range faces {
+		if matchedFaces[i] {
+			continue
+		}
+		faces[i].TrackID = t.nextID
+		t.tracks[t.nextID] = &faceTrack{rect: faces[i].Rect, lastSeen: ts}
+		t.nextID++
+	}
+
+	var expired []int
+	for id, tr := range t.tracks {
+		if ts.Sub(tr.lastSeen) > t.maxAge {
+			expired = append(expired, id)
+			delete(t.tracks, id)
+		}
+	}
+
+	return expired
+}
+
+// Reset drops all the tracks
+func (t *Tracker) Reset() {
+	t.tracks = make(map[int]*faceTrack)
+}
+
+// trackedOperator is an operator whose face is tracked across frames
+type trackedOperator struct {
+	// op is operator status
+	op *Operator
+	// alerts stores alerts raised for the operator
+	alerts *Result
+}
+
+// Operators maintains status and alerts of every tracked operator individually
+type Operators struct {
+	// tracker assigns track IDs to detected faces
+	tracker *Tracker
+	// ops stores tracked operators by their track IDs
+	ops map[int]*trackedOperator
+	// scene monitors presence of the operators when no face is found
+	scene *trackedOperator
+}
+
+// NewOperators creates new tracked operators and returns them
+func NewOperators(tracker *Tracker) *Operators {
+	return &Operators{
+		tracker: tracker,
+		ops:     make(map[int]*trackedOperator),
+		scene:   &trackedOperator{op: NewOperator(), alerts: new(Result)},
+	}
+}
+
+// update updates status of every tracked operator using the latest detection d.
+// The result alerts are raised if they are raised for any of the tracked operators.
+func (o *Operators) update(d *detection, result *Result) {
+	// input source has changed so start over
+	if d.reset {
+		o.tracker.Reset()
+		o.ops = make(map[int]*trackedOperator)
+	}
+
+	for _, id := range o.tracker.Update(d.status.Faces, d.ts) {
+		delete(o.ops, id)
+	}
+
+	for _, f := range d.status.Faces {
+		t, ok := o.ops[f.TrackID]
+		if !ok {
+			t = &trackedOperator{op: NewOperator(), alerts: new(Result)}
+			o.ops[f.TrackID] = t
+		}
+		t.op.update(&detection{ts: d.ts, status: f.status(d.status)}, t.alerts)
+	}
+
+	// no face was found so the operators are monitored as a whole; start over once faces are found
+	if d.status.checked {
+		o.scene = &trackedOperator{op: NewOperator(), alerts: new(Result)}
+	} else {
+		o.scene.op.update(&detection{ts: d.ts, reset: d.reset, status: d.status}, o.scene.alerts)
+	}
+
+	result.clearAlerts()
+	result.mergeAlerts(o.scene.alerts)
+	for _, t := range o.ops {
+		result.mergeAlerts(t.alerts)
+	}
+
+	if d.status.checked {
+		result.Perf = d.perf
+	}
+
+	result.status = d.status
+}
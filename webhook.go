@@ -0,0 +1,250 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// webhookEventHeader is HTTP header carrying type of the posted event: alert or status
+	webhookEventHeader = "X-Monitor-Event"
+	// webhookSignatureHeader is HTTP header carrying HMAC-SHA256 signature of the request body
+	webhookSignatureHeader = "X-Monitor-Signature"
+	// webhookBackoff is delay before the first retry; it doubles with every retry
+	webhookBackoff = 500 * time.Millisecond
+	// webhookDeadline is time within which an event must be delivered; it is not retried afterwards
+	webhookDeadline = 30 * time.Second
+	// webhookQueue is number of events queued for every endpoint
+	webhookQueue = 64
+)
+
+// Webhook posts JSON events to HTTP endpoints
+type Webhook struct {
+	// urls are URLs of the endpoints
+	urls []string
+	// headers are custom HTTP headers sent with every request
+	headers http.Header
+	// secret is key of HMAC signature of request bodies; empty if the requests are not signed
+	secret []byte
+	// retries is number of times a failed request is retried
+	retries int
+	// client sends the requests
+	client *http.Client
+}
+
+// parseHeaders parses comma separated HTTP headers in Name:value format and returns them
+func parseHeaders(s string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, h := range parseLabels(s) {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("Invalid webhook header: %s", h)
+		}
+		headers.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+
+	return headers, nil
+}
+
+// NewWebhook creates new webhook posting to urls with headers and returns it.
+// It reads the following environment variables to configure the webhook:
+// WEBHOOK_SECRET: key of HMAC-SHA256 signature of request bodies; not required
+func NewWebhook(urls []string, headers http.Header, retries int) *Webhook {
+	return &Webhook{
+		urls:    urls,
+		headers: headers,
+		secret:  []byte(os.Getenv("WEBHOOK_SECRET")),
+		retries: retries,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// sign returns hex encoded HMAC-SHA256 signature of body
+func (w *Webhook) sign(body []byte) string {
+	mac := hmac.New(sha256.New, w.secret)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// post posts body to url once
+func (w *Webhook) post(url, event string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for name, values := range w.headers {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, event)
+	if len(w.secret) > 0 {
+		req.Header.Set(webhookSignatureHeader, w.sign(body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// deliver posts body of event to url, retrying failed request with exponential backoff until deadline.
+// It gives up retrying when doneChan is closed.
+func (w *Webhook) deliver(doneChan <-chan struct{}, url, event string, body []byte, deadline time.Time) error {
+	backoff := webhookBackoff
+	err := w.post(url, event, body)
+	for i := 0; err != nil && i < w.retries; i++ {
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("%v; giving up after %d retries", err, i)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-doneChan:
+			return err
+		}
+		backoff *= 2
+		err = w.post(url, event, body)
+	}
+
+	return err
+}
+
+// Post posts JSON body of event to all the endpoints, retrying failed requests with exponential backoff
+// for at most webhookDeadline per endpoint. It returns the last error if any of the endpoints fails to accept the event.
+func (w *Webhook) Post(event, body string) error {
+	var lastErr error
+	for _, url := range w.urls {
+		if err := w.deliver(nil, url, event, []byte(body), time.Now().Add(webhookDeadline)); err != nil {
+			lastErr = fmt.Errorf("%s: %v", url, err)
+		}
+	}
+
+	return lastErr
+}
+
+// webhookEvent is event queued for delivery to webhook endpoint
+type webhookEvent struct {
+	// name is event type
+	name string
+	// desc describes the event in error messages
+	desc string
+	// body is JSON body of the event
+	body []byte
+	// deadline is time until which the delivery is retried
+	deadline time.Time
+}
+
+// startDelivery starts goroutine per endpoint which posts events in the order they are queued, so that
+// an unreachable endpoint retrying its requests doesn't hold up the others nor the caller.
+// It returns the queues of the endpoints; the goroutines stop when doneChan is closed.
+func (w *Webhook) startDelivery(doneChan <-chan struct{}) []chan webhookEvent {
+	queues := make([]chan webhookEvent, len(w.urls))
+	for i, url := range w.urls {
+		queue := make(chan webhookEvent, webhookQueue)
+		queues[i] = queue
+		go func(url string) {
+			for {
+				select {
+				case e := <-queue:
+					if err := w.deliver(doneChan, url, e.name, e.body, e.deadline); err != nil {
+						fmt.Printf("Error posting %s to webhook %s: %v\n", e.desc, url, err)
+					}
+				case <-doneChan:
+					return
+				}
+			}
+		}(url)
+	}
+
+	return queues
+}
+
+// enqueueWebhook queues event of type name described by desc with body to all the endpoint queues.
+// Events which don't fit in the queue of a slow endpoint are dropped.
+func enqueueWebhook(queues []chan webhookEvent, name, desc, body string) {
+	e := webhookEvent{name: name, desc: desc, body: []byte(body), deadline: time.Now().Add(webhookDeadline)}
+	for _, queue := range queues {
+		select {
+		case queue <- e:
+		default:
+			fmt.Printf("Dropping %s: webhook queue is full\n", desc)
+		}
+	}
+}
+
+// webhookRunner posts alert state transitions received from alertsChan and routed to webhook as they happen
+// and, unless statusChan is nil, operator status received from statusChan every rate seconds.
+// The events are posted in the background, so failing endpoints don't hold up the alerts.
+// doneChan is used to receive a signal from the main goroutine to notify the routine to stop and return
+func webhookRunner(doneChan <-chan struct{}, alertsChan <-chan Alert, statusChan <-chan *Result, w *Webhook, rate int) error {
+	ticker := time.NewTicker(time.Duration(rate) * time.Second)
+	defer ticker.Stop()
+
+	queues := w.startDelivery(doneChan)
+
+	for {
+		select {
+		case a := <-alertsChan:
+			if !a.routedTo(sinkWebhook) {
+				continue
+			}
+			enqueueWebhook(queues, "alert", "alert "+a.ID, a.ToMQTTMessage())
+		case <-ticker.C:
+			if statusChan == nil {
+				continue
+			}
+			select {
+			case result := <-statusChan:
+				enqueueWebhook(queues, "status", "status", result.ToMQTTMessage())
+			case <-doneChan:
+				fmt.Printf("Stopping webhookRunner: received stop signal\n")
+				return nil
+			}
+		case <-statusChan:
+			// we discard status in between ticker times
+		case <-doneChan:
+			fmt.Printf("Stopping webhookRunner: received stop signal\n")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,153 @@
+/*
+* Copyright (c) 2018 Intel Corporation.
+*
+* Permission is hereby granted, free of charge, to any person obtaining
+* a copy of this software and associated documentation files (the
+* "Software"), to deal in the Software without restriction, including
+* without limitation the rights to use, copy, modify, merge, publish,
+* distribute, sublicense, and/or sell copies of the Software, and to
+* permit persons to whom the Software is furnished to do so, subject to
+* the following conditions:
+*
+* The above copyright notice and this permission notice shall be
+* included in all copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+* EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+* NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+* LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+* OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+* WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/intel-iot-devkit/machine-operator-monitor-go/payload"
+)
+
+// windowQueue is number of results queued for the publishing window while its status is being sent
+const windowQueue = 256
+
+// Window accumulates results processed in publishing window, so that the published status summarizes all
+// the frames rather than samples one of them
+type Window struct {
+	// start is time of the first result in the window
+	start time.Time
+	// frames is number of results in the window
+	frames int
+	// missed is number of processed frames which did not make it to the window
+	missed int
+	// processed is number of frames processed by the pipeline as of the latest result
+	processed uint64
+	// present is number of results with operator at the machine
+	present int
+	// watching is number of results operator was watching the machine in
+	watching int
+	// angry is number of results operator was angry in
+	angry int
+	// alertFrames is number of results alert was raised in by alert types
+	alertFrames map[string]int
+	// raised is number of times alert was raised by alert types
+	raised map[string]int
+	// prev are alert types raised in the previous result; they carry over to the next window
+	prev map[string]bool
+	// last is copy of the latest result in the window
+	last *Result
+}
+
+// NewWindow creates new empty publishing window and returns it
+func NewWindow() *Window {
+	w := &Window{prev: make(map[string]bool)}
+	w.Reset()
+
+	return w
+}
+
+// Reset empties the window
+func (w *Window) Reset() {
+	w.start, w.last = time.Time{}, nil
+	w.frames, w.missed, w.present, w.watching, w.angry = 0, 0, 0, 0, 0
+	w.alertFrames = make(map[string]int)
+	w.raised = make(map[string]int)
+}
+
+// Len returns number of results in the window
+func (w *Window) Len() int {
+	return w.frames
+}
+
+// Add adds result r to the window; r must be a copy which is not updated any more.
+// Frames processed since the previous result which were not added are counted as missed.
+func (w *Window) Add(r *Result) {
+	if w.frames == 0 {
+		w.start = r.Time
+	}
+	w.frames++
+	w.last = r
+
+	processed := r.Throughput.Processed
+	if w.processed > 0 && processed > w.processed+1 {
+		w.missed += int(processed - w.processed - 1)
+	}
+	w.processed = processed
+
+	if r.status != nil && len(r.status.Faces) > 0 {
+		w.present++
+		if r.status.IsWatching {
+			w.watching++
+		}
+		if r.status.IsAngry {
+			w.angry++
+		}
+	}
+
+	for typ, raised := range alertTypes(r) {
+		if raised {
+			w.alertFrames[typ]++
+			if !w.prev[typ] {
+				w.raised[typ]++
+			}
+		}
+		w.prev[typ] = raised
+	}
+}
+
+// ToPayload turns the latest result in the window into status payload summarizing the window
+func (w *Window) ToPayload() payload.Status {
+	p := w.last.ToPayload()
+	p.Window = &payload.Window{
+		Start:    w.start,
+		Frames:   w.frames,
+		Missed:   w.missed,
+		Present:  w.present,
+		Watching: w.watching,
+		Angry:    w.angry,
+	}
+	if w.present > 0 {
+		p.Window.WatchingRatio = float64(w.watching) / float64(w.present)
+		p.Window.AngryRatio = float64(w.angry) / float64(w.present)
+	}
+	if len(w.alertFrames) > 0 {
+		p.Window.AlertFrames = w.alertFrames
+	}
+	if len(w.raised) > 0 {
+		p.Window.Raised = w.raised
+	}
+
+	return p
+}
+
+// ToMQTTMessage turns the window into MQTT message
+func (w *Window) ToMQTTMessage() string {
+	msg, err := json.Marshal(w.ToPayload())
+	if err != nil {
+		return "{}"
+	}
+
+	return string(msg)
+}